@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// 家长控制/访问控制规则所在的模块与表
+const (
+	accessModule = "access_control"
+	accessTable  = "rule"
+)
+
+// 规则模式
+const (
+	accessBlock    = "block"    // 始终禁止上网
+	accessSchedule = "schedule" // 仅在指定时段允许上网
+)
+
+// 规范化MAC地址为路由器使用的 AA-BB-CC-DD-EE-FF 格式
+func normalizeMAC(s string) (string, bool) {
+	hw, err := net.ParseMAC(strings.TrimSpace(s))
+	if err != nil || len(hw) != 6 {
+		return "", false
+	}
+	return strings.ToUpper(strings.ReplaceAll(hw.String(), ":", "-")), true
+}
+
+// 把 mon,tue / weekdays 等写法转换为路由器使用的七位掩码（周日起）
+func daysMask(input string) (string, error) {
+	set, err := expandDays(strings.Split(input, ","))
+	if err != nil {
+		return "", err
+	}
+	if len(set) == 0 {
+		return "1111111", nil
+	}
+	mask := []byte("0000000")
+	for wd := range set {
+		mask[wd] = '1'
+	}
+	return string(mask), nil
+}
+
+// /access 提交的表单
+type accessForm struct {
+	Op    string `form:"op"`
+	Name  string `form:"name"` // 启停、删除的规则
+	MAC   string `form:"mac"`
+	Alias string `form:"alias"`
+	Mode  string `form:"mode"`
+	Start string `form:"start"`
+	End   string `form:"end"`
+	Days  string `form:"days"`
+}
+
+// 由表单构造一条规则，返回错误说明
+func accessRuleFromForm(form accessForm) (map[string]interface{}, string) {
+	mac, ok := normalizeMAC(form.MAC)
+	if !ok {
+		return nil, tr("access.bad_mac", form.MAC)
+	}
+	rule := map[string]interface{}{
+		"mac":    mac,
+		"alias":  form.Alias,
+		"mode":   form.Mode,
+		"enable": "on",
+	}
+	switch form.Mode {
+	case accessBlock:
+	case accessSchedule:
+		for _, clock := range []string{form.Start, form.End} {
+			if _, err := parseClock(clock); err != nil {
+				return nil, err.Error()
+			}
+		}
+		rule["start"], rule["end"] = form.Start, form.End
+		mask, err := daysMask(form.Days)
+		if err != nil {
+			return nil, err.Error()
+		}
+		rule["days"] = mask
+	default:
+		return nil, tr("error.bad_parameter")
+	}
+	return rule, ""
+}
+
+// /access：访问控制规则的增删改查
+func accessHandler(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{"CanEdit": canEdit(r)}
+
+	if r.Method == http.MethodPost {
+		var form accessForm
+		if err := bindForm(formOf(r), &form); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var err error
+		var msg string
+		name := form.Name
+		switch form.Op {
+		case "add":
+			rule, problem := accessRuleFromForm(form)
+			if problem != "" {
+				data["Error"] = problem
+				break
+			}
+			err = addTableEntry(accessModule, accessTable, rule)
+			msg = tr("access.added", rule["mac"])
+		case "delete":
+			err = deleteTableEntry(accessModule, accessTable, name)
+			msg = tr("access.deleted", name)
+		case "enable", "disable":
+			value := "on"
+			if form.Op == "disable" {
+				value = "off"
+			}
+			err = updateTableEntry(accessModule, accessTable, name, map[string]interface{}{"enable": value})
+			msg = tr("access.toggled", name, value)
+		default:
+			data["Error"] = tr("error.bad_parameter")
+		}
+		if err != nil {
+			data["Error"] = userMessage(err) + ": " + err.Error()
+		} else if msg != "" {
+			logf("%s\n", msg)
+			recordEvent("access_control", msg, map[string]interface{}{"op": form.Op, "name": name})
+			data["Result"] = msg
+		}
+	}
+
+	rules, err := queryTable(accessModule, accessTable)
+	if err != nil && data["Error"] == nil {
+		data["Error"] = userMessage(err)
+	}
+	data["Rules"] = rules
+	renderTemplate(w, http.StatusOK, "access.html", data)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 需要对外开放的一个端口
+type portSpec struct {
+	Proto string // tcp / udp
+	Port  int
+}
+
+func (p portSpec) String() string {
+	return fmt.Sprintf("%s/%d", p.Proto, p.Port)
+}
+
+// 解析 "tcp:443, udp:51820, 8080" 形式的端口列表，未写协议时默认tcp
+func parsePorts(s string) ([]portSpec, error) {
+	var ports []portSpec
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '，' }) {
+		proto, portStr := "tcp", item
+		if i := strings.IndexAny(item, ":/"); i >= 0 {
+			proto, portStr = strings.ToLower(item[:i]), item[i+1:]
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port < 1 || port > 65535 || (proto != "tcp" && proto != "udp") {
+			return nil, routerErr(ErrBadParameter, 0, tr("advisor.bad_port", item))
+		}
+		ports = append(ports, portSpec{proto, port})
+	}
+	return ports, nil
+}
+
+// 一条开放方案建议
+type recommendation struct {
+	ID          string // ipv6_rule / upnp_pinhole / full_open
+	Title       string
+	Detail      string
+	Exposure    int  // 暴露程度 1=仅指定端口 3=主机全部端口
+	Recommended bool // 满足需求的最小暴露方案
+	Available   bool
+	Reason      string // 不可用的原因
+}
+
+// 根据目的给出开放方案，按暴露程度从小到大排列
+func advise(ports []portSpec, fullCone bool) []recommendation {
+	cfg := globalConfig{}.Get()
+	hasTarget := cfg.DmzDestIP6 != ""
+	portList := make([]string, len(ports))
+	for i, p := range ports {
+		portList[i] = p.String()
+	}
+
+	rule := recommendation{
+		ID:       "ipv6_rule",
+		Title:    tr("advisor.ipv6_rule"),
+		Detail:   tr("advisor.ipv6_rule.detail", strings.Join(portList, ", "), cfg.DmzDestIP6),
+		Exposure: 1,
+	}
+	pinhole := recommendation{
+		ID:       "upnp_pinhole",
+		Title:    tr("advisor.upnp_pinhole"),
+		Detail:   tr("advisor.upnp_pinhole.detail"),
+		Exposure: 1,
+	}
+	full := recommendation{
+		ID:        "full_open",
+		Title:     tr("advisor.full_open"),
+		Detail:    tr("advisor.full_open.detail"),
+		Exposure:  3,
+		Available: true,
+	}
+
+	switch {
+	case !hasTarget:
+		rule.Reason = tr("advisor.reason.no_target")
+		pinhole.Reason = rule.Reason
+	case len(ports) == 0:
+		rule.Reason = tr("advisor.reason.no_ports")
+		pinhole.Reason = rule.Reason
+	default:
+		pinhole.Available = true
+		rule.Available = true
+		// 固件不支持IPv6规则表时不推荐
+		if _, err := queryTable("firewall", "ipv6_rule"); err != nil {
+			rule.Available = false
+			rule.Reason = userMessage(err)
+		}
+	}
+
+	switch {
+	case fullCone || len(ports) == 0:
+		full.Recommended = true
+	case rule.Available:
+		rule.Recommended = true
+	case pinhole.Available:
+		pinhole.Recommended = true
+	default:
+		full.Recommended = true
+	}
+	return []recommendation{rule, pinhole, full}
+}
+
+// 执行选中的方案，返回每一步的结果说明
+func applyRecommendation(id string, ports []portSpec, actor string) ([]string, error) {
+	cfg := globalConfig{}.Get()
+	var results []string
+	switch id {
+	case "ipv6_rule":
+		for _, p := range ports {
+			err := addTableEntry("firewall", "ipv6_rule", map[string]interface{}{
+				"enable":    "on",
+				"proto":     p.Proto,
+				"dest_ip6":  cfg.DmzDestIP6,
+				"dest_port": strconv.Itoa(p.Port),
+			})
+			if err != nil {
+				return results, err
+			}
+			results = append(results, tr("advisor.result.rule", p, cfg.DmzDestIP6))
+		}
+	case "upnp_pinhole":
+		for _, p := range ports {
+			id, err := addUPnPPinhole(cfg.DmzDestIP6, p.Port, p.Proto, 24*time.Hour)
+			if err != nil {
+				return results, routerErr(ErrUnsupportedFirmware, 0, err.Error())
+			}
+			results = append(results, tr("advisor.result.pinhole", p, id))
+		}
+	case "full_open":
+		full := func(c *Config) { c.IPv6FirewallEnable, c.DmzEnable = "off", "1" }
+		if _, err := applyUpdate(currentBackend(), globalConfig{}, full, nil, sourceUser, actor, allSections); err != nil {
+			return results, err
+		}
+		results = append(results, tr("advisor.result.full_open"))
+		results = append(results, exposureWarnings()...)
+	default:
+		return nil, routerErr(ErrBadParameter, 0, tr("advisor.unknown_plan", id))
+	}
+	return results, nil
+}
+
+// /advisor 的查询参数与提交的表单
+type advisorForm struct {
+	Ports     string `form:"ports"`
+	FullCone  bool   `form:"full_cone"`
+	Apply     string `form:"apply"` // 要执行的方案
+	Overwrite bool   `form:"overwrite"`
+}
+
+// /advisor：根据用户的目的推荐最小暴露的开放方式
+func advisorHandler(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{"Config": config}
+
+	var form advisorForm
+	if err := bindForm(formOf(r), &form); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	portsInput, fullCone := form.Ports, form.FullCone
+	data["Ports"] = portsInput
+	data["FullCone"] = fullCone
+
+	ports, err := parsePorts(portsInput)
+	if err != nil {
+		data["Error"] = err.Error()
+		renderTemplate(w, http.StatusBadRequest, "advisor.html", data)
+		return
+	}
+
+	if r.Method == http.MethodPost && form.Apply != "" {
+		if !form.Overwrite {
+			desired := firewallState{}
+			if form.Apply == "full_open" {
+				desired = desiredFromConfig()
+				desired.DmzEnable = "1"
+			}
+			if conflicts := detectConflicts(desired, ports); len(conflicts) > 0 {
+				renderConflicts(w, r, conflicts)
+				return
+			}
+		}
+		results, err := applyRecommendation(form.Apply, ports, actorOf(r))
+		data["Results"] = results
+		if err != nil {
+			data["Error"] = userMessage(err) + ": " + err.Error()
+			renderTemplate(w, httpStatusFor(err), "advisor.html", data)
+			return
+		}
+	}
+
+	if portsInput != "" || fullCone {
+		data["Recommendations"] = advise(ports, fullCone)
+	}
+	renderTemplate(w, http.StatusOK, "advisor.html", data)
+}
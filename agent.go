@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 中心控制端配置：允许连接的代理及其令牌
+type ControllerConfig struct {
+	Agents   map[string]string `json:"agents"`    // 代理名称 -> 令牌
+	PollWait string            `json:"poll_wait"` // 长轮询最长等待时间，默认 25s
+}
+
+// 代理端配置：主动连接到中心控制端，无需在代理所在网络做端口转发
+type AgentConfig struct {
+	ControllerURL string `json:"controller_url"` // 如 https://home.example.com:8080
+	Name          string `json:"name"`
+	Token         string `json:"token"`
+	RetryInterval string `json:"retry_interval"` // 连接失败后的重试间隔，默认 10s
+}
+
+// 代理每次轮询上报的信息
+type agentReport struct {
+	RouterIP string        `json:"router_ip"`
+	Sync     string        `json:"sync"`
+	Tracked  trackedState  `json:"tracked"`
+	Results  []agentResult `json:"results,omitempty"`
+}
+
+// 中心下发给代理的设置
+type agentCommand struct {
+	ID      string        `json:"id"`
+	State   firewallState `json:"state"`
+	By      string        `json:"by,omitempty"`
+	Created time.Time     `json:"created"`
+}
+
+type agentResult struct {
+	ID    string    `json:"id"`
+	OK    bool      `json:"ok"`
+	Error string    `json:"error,omitempty"`
+	At    time.Time `json:"at"`
+}
+
+// 中心端记录的代理状态
+type remoteAgent struct {
+	Name       string
+	Remote     string
+	LastSeen   time.Time
+	Report     agentReport
+	Pending    []agentCommand
+	LastResult *agentResult
+	wake       chan struct{}
+}
+
+var (
+	agentsMu     sync.Mutex
+	remoteAgents = map[string]*remoteAgent{}
+	agentClient  = &http.Client{Timeout: time.Minute}
+)
+
+func controllerEnabled() bool { return len(globalConfig{}.Get().Controller.Agents) > 0 }
+
+func pollWait() time.Duration {
+	return parseDurationOr(globalConfig{}.Get().Controller.PollWait, 25*time.Second)
+}
+
+// 启动时校验控制端与代理配置
+func validateAgents() error {
+	for name, token := range config.Controller.Agents {
+		if name == "" || len(token) < 16 {
+			return fmt.Errorf("%s", tr("agent.weak_token", name))
+		}
+		registerSecret(token)
+	}
+	if config.Agent.ControllerURL != "" {
+		if config.Agent.Name == "" || config.Agent.Token == "" {
+			return fmt.Errorf("%s", tr("agent.incomplete"))
+		}
+		registerSecret(config.Agent.Token)
+	}
+	return nil
+}
+
+func agentFor(name string) *remoteAgent {
+	a, ok := remoteAgents[name]
+	if !ok {
+		a = &remoteAgent{Name: name, wake: make(chan struct{}, 1)}
+		remoteAgents[name] = a
+	}
+	return a
+}
+
+// POST /api/agent/poll：代理上报状态并长轮询等待下发的设置
+func agentPollHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.Header.Get("X-Agent-Name")
+	expected, ok := globalConfig{}.Get().Controller.Agents[name]
+	if !ok || subtle.ConstantTimeCompare([]byte(expected), []byte(r.Header.Get("X-Agent-Token"))) != 1 {
+		http.Error(w, tr("auth.required"), http.StatusUnauthorized)
+		return
+	}
+	var report agentReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	agentsMu.Lock()
+	a := agentFor(name)
+	a.Remote = r.RemoteAddr
+	a.LastSeen = time.Now()
+	a.Report = report
+	for i := range report.Results {
+		res := report.Results[i]
+		a.LastResult = &res
+		msg := tr("agent.result_ok", name)
+		if !res.OK {
+			msg = tr("agent.result_failed", name, res.Error)
+		}
+		logf("%s\n", msg)
+		recordEvent("agent_result", msg, map[string]interface{}{"agent": name, "command": res.ID, "success": res.OK})
+	}
+	wake := a.wake
+	agentsMu.Unlock()
+
+	timer := time.NewTimer(pollWait())
+	defer timer.Stop()
+	for {
+		agentsMu.Lock()
+		if len(a.Pending) > 0 {
+			cmd := a.Pending[0]
+			a.Pending = a.Pending[1:]
+			agentsMu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(cmd)
+			return
+		}
+		agentsMu.Unlock()
+
+		select {
+		case <-wake:
+		case <-timer.C:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// 代理列表中的一行
+type agentRow struct {
+	*remoteAgent
+	Online bool
+}
+
+// /agents 下发设置的表单
+type agentCommandForm struct {
+	Agent              string `form:"agent"`
+	IPv6FirewallEnable string `form:"ipv6_firewall_enable,lower"`
+	DmzEnable          string `form:"dmz_enable"`
+	DmzDestIP          string `form:"dmz_dest_ip"`
+	DmzDestIP6         string `form:"dmz_dest_ip6"`
+}
+
+// /agents：查看各代理状态并下发设置
+func agentsHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := globalConfig{}.Get()
+	if r.Method == http.MethodPost {
+		var form agentCommandForm
+		if err := bindForm(formOf(r), &form); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		name := form.Agent
+		if _, ok := cfg.Controller.Agents[name]; !ok {
+			http.Error(w, tr("agent.unknown", name), http.StatusBadRequest)
+			return
+		}
+		state := firewallState{
+			IPv6FirewallEnable: form.IPv6FirewallEnable,
+			DmzEnable:          form.DmzEnable,
+			DmzDestIP:          form.DmzDestIP,
+			DmzDestIP6:         form.DmzDestIP6,
+		}
+		if (state.IPv6FirewallEnable != "on" && state.IPv6FirewallEnable != "off") || (state.DmzEnable != "0" && state.DmzEnable != "1") {
+			http.Error(w, tr("error.bad_parameter"), http.StatusBadRequest)
+			return
+		}
+		cmd := agentCommand{ID: randomToken()[:12], State: state, Created: time.Now()}
+		if u := currentUser(r); u != nil {
+			cmd.By = u.Name
+		}
+
+		agentsMu.Lock()
+		a := agentFor(name)
+		a.Pending = append(a.Pending, cmd)
+		select {
+		case a.wake <- struct{}{}:
+		default:
+		}
+		agentsMu.Unlock()
+
+		recordEvent("agent_command", tr("agent.queued", name), map[string]interface{}{"agent": name, "command": cmd.ID, "by": cmd.By})
+		http.Redirect(w, r, "/agents", http.StatusSeeOther)
+		return
+	}
+
+	agentsMu.Lock()
+	var rows []agentRow
+	for name := range cfg.Controller.Agents {
+		a := agentFor(name)
+		rows = append(rows, agentRow{a, time.Since(a.LastSeen) < pollWait()+30*time.Second})
+	}
+	agentsMu.Unlock()
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+
+	renderTemplate(w, http.StatusOK, "agents.html", map[string]interface{}{
+		"Agents":  rows,
+		"CanEdit": canEdit(r),
+	})
+}
+
+// 代理端：主动连接中心并执行下发的设置，stop 关闭时退出
+func runAgent(stop <-chan struct{}) {
+	cfg := globalConfig{}.Get()
+	retry := parseDurationOr(cfg.Agent.RetryInterval, 10*time.Second)
+	url := strings.TrimSuffix(cfg.Agent.ControllerURL, "/") + "/api/agent/poll"
+	var results []agentResult
+	connected := false
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		cfg := globalConfig{}.Get()
+		snapshot, syncState := trackedSnapshot()
+		body, _ := json.Marshal(agentReport{RouterIP: cfg.RouterIP, Sync: syncState, Tracked: snapshot, Results: results})
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			warn("agent.connect_failed", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Agent-Name", cfg.Agent.Name)
+		req.Header.Set("X-Agent-Token", cfg.Agent.Token)
+
+		resp, err := agentClient.Do(req)
+		if err == nil && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+			err = fmt.Errorf("%s", resp.Status)
+			resp.Body.Close()
+		}
+		if err != nil {
+			if connected {
+				warn("agent.connect_failed", err)
+			}
+			sayDebug("debug.agent_connect_failed", err)
+			connected = false
+			select {
+			case <-stop:
+				return
+			case <-time.After(retry):
+			}
+			continue
+		}
+		if !connected {
+			say("agent.connected", cfg.Agent.ControllerURL)
+			connected = true
+		}
+		results = nil
+
+		var cmd agentCommand
+		data, readErr := readLimited(resp.Body, maxRouterResponseBytes)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNoContent || readErr != nil || json.Unmarshal(data, &cmd) != nil {
+			continue
+		}
+
+		_, applyErr := applyDesired(cmd.State, sourceRemote, "", allSections)
+		res := agentResult{ID: cmd.ID, OK: applyErr == nil, At: time.Now()}
+		if applyErr != nil {
+			res.Error = userMessage(applyErr)
+		}
+		say("agent.applied", cmd.ID, userMessage(applyErr))
+		results = append(results, res)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// /api/v1 的统一响应：成功时 data 为结果，失败时 error 给出机器可读的代码
+type apiV1Response struct {
+	OK    bool        `json:"ok"`
+	Data  interface{} `json:"data,omitempty"`
+	Error *apiV1Error `json:"error,omitempty"`
+}
+
+type apiV1Error struct {
+	Code            string `json:"code"`                        // bad_parameter / auth_expired / unreachable ...
+	Message         string `json:"message"`                     // 面向用户的说明
+	Detail          string `json:"detail,omitempty"`            // 已脱敏的细节
+	RouterErrorCode int    `json:"router_error_code,omitempty"` // 路由器返回的 error_code
+}
+
+func writeV1(w http.ResponseWriter, data interface{}, err error) {
+	resp := apiV1Response{OK: err == nil, Data: data}
+	if err != nil {
+		resp.Data = nil
+		resp.Error = &apiV1Error{Code: errorCode(err), Message: userMessage(err), Detail: redact(err.Error())}
+		var re *RouterError
+		if errors.As(err, &re) {
+			resp.Error.RouterErrorCode = re.Code
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatusFor(err))
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(resp)
+}
+
+// 严格解析JSON请求体，不认识的字段视为参数错误
+func decodeV1(r *http.Request, dst interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return routerErr(ErrBadParameter, 0, err.Error())
+	}
+	return nil
+}
+
+// 检查一组设置值的格式
+func validateFirewallState(fs firewallState) error {
+	if fs.IPv6FirewallEnable != "on" && fs.IPv6FirewallEnable != "off" {
+		return routerErr(ErrBadParameter, 0, "ipv6_firewall_enable="+fs.IPv6FirewallEnable)
+	}
+	if fs.DmzEnable != "0" && fs.DmzEnable != "1" {
+		return routerErr(ErrBadParameter, 0, "dmz_enable="+fs.DmzEnable)
+	}
+	if ip := net.ParseIP(fs.DmzDestIP); fs.DmzDestIP != "" && (ip == nil || ip.To4() == nil) {
+		return routerErr(ErrBadParameter, 0, "dmz_dest_ip="+fs.DmzDestIP)
+	}
+	if ip := net.ParseIP(fs.DmzDestIP6); fs.DmzDestIP6 != "" && (ip == nil || ip.To4() != nil) {
+		return routerErr(ErrBadParameter, 0, "dmz_dest_ip6="+fs.DmzDestIP6)
+	}
+	return nil
+}
+
+// GET /api/v1/status 的结果
+type apiV1Status struct {
+	Sync              string         `json:"sync"` // in_sync / drifted / unknown
+	Desired           *firewallState `json:"desired,omitempty"`
+	Confirmed         *firewallState `json:"confirmed,omitempty"`
+	ConfirmedAt       *time.Time     `json:"confirmed_at,omitempty"`
+	LastApplyAt       *time.Time     `json:"last_apply_at,omitempty"`
+	LastApplyError    string         `json:"last_apply_error,omitempty"`
+	WANIPv6           string         `json:"wan_ipv6,omitempty"`
+	RefreshError      *apiV1Error    `json:"refresh_error,omitempty"` // 本次读取路由器失败的原因
+	MaintenanceWindow string         `json:"maintenance_window,omitempty"`
+	Location          string         `json:"location,omitempty"`
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// /api/v1 的处理器；与首页一样由调用方传入路由器后端与配置，测试时可替换
+type apiV1Handlers struct {
+	client RouterClient
+	store  ConfigStore
+}
+
+// GET /api/v1/status：读取路由器当前设置并与期望状态比较
+func (h apiV1Handlers) status(w http.ResponseWriter, r *http.Request) {
+	var refreshErr error
+	if h.store.Get().routerConfigured() {
+		_, refreshErr = refreshConfirmedState(h.client)
+	}
+	s, sync := trackedSnapshot()
+	status := apiV1Status{
+		Sync:           sync,
+		Desired:        s.Desired,
+		Confirmed:      s.Confirmed,
+		ConfirmedAt:    timePtr(s.ConfirmedAt),
+		LastApplyAt:    timePtr(s.LastApplyAt),
+		LastApplyError: s.LastApplyError,
+		WANIPv6:        s.WANIPv6,
+		Location:       activeLocation(),
+	}
+	if refreshErr != nil {
+		status.RefreshError = &apiV1Error{Code: errorCode(refreshErr), Message: userMessage(refreshErr), Detail: redact(refreshErr.Error())}
+	}
+	if mw := activeMaintenance(time.Now()); mw != nil {
+		status.MaintenanceWindow = mw.String()
+	}
+	writeV1(w, status, nil)
+}
+
+// POST /api/v1/apply 的请求体，也是 /api/apply 的表单；未给出的字段沿用预设或当前配置
+type apiV1ApplyRequest struct {
+	Profile            string  `json:"profile" form:"profile"`
+	Action             string  `json:"action" form:"action"` // open / close，默认 open
+	IPv6FirewallEnable *string `json:"ipv6_firewall_enable" form:"ipv6_firewall_enable,lower"`
+	DmzEnable          *string `json:"dmz_enable" form:"dmz_enable"`
+	DmzDestIP          *string `json:"dmz_dest_ip" form:"dmz_dest_ip"`
+	DmzDestIP6         *string `json:"dmz_dest_ip6" form:"dmz_dest_ip6"`
+	OnlyFirewall       bool    `json:"only_firewall" form:"only_firewall"` // 只写入IPv6防火墙，DMZ保持不变
+	OnlyDMZ            bool    `json:"only_dmz" form:"only_dmz"`           // 只写入DMZ
+}
+
+// POST /api/v1/apply：与 /api/apply 相同，请求与响应均为JSON
+func (h apiV1Handlers) apply(w http.ResponseWriter, r *http.Request) {
+	var req apiV1ApplyRequest
+	if err := decodeV1(r, &req); err != nil {
+		writeV1(w, nil, err)
+		return
+	}
+	// 与表单绑定一样去除空白，开关值转为小写
+	for _, v := range []*string{req.IPv6FirewallEnable, req.DmzEnable, req.DmzDestIP, req.DmzDestIP6} {
+		if v != nil {
+			*v = strings.TrimSpace(*v)
+		}
+	}
+	if v := req.IPv6FirewallEnable; v != nil {
+		*v = strings.ToLower(*v)
+	}
+	msg, err := applyRequestWith(h.client, h.store, req, actorOf(r))
+	if err != nil {
+		writeV1(w, nil, err)
+		return
+	}
+	s, sync := trackedSnapshot()
+	writeV1(w, map[string]interface{}{"message": msg, "sync": sync, "confirmed": s.Confirmed}, nil)
+}
+
+// /api/v1/config 读写的配置项；stok 与密码只写不读
+type apiV1Config struct {
+	RouterIP           string `json:"router_ip"`
+	StokSet            bool   `json:"stok_set"`
+	RouterPasswordSet  bool   `json:"router_password_set"`
+	IPv6FirewallEnable string `json:"ipv6_firewall_enable"`
+	DmzEnable          string `json:"dmz_enable"`
+	DmzDestIP          string `json:"dmz_dest_ip"`
+	DmzDestIP6         string `json:"dmz_dest_ip6"`
+	WANPort            string `json:"wan_port"`
+	ServerPort         string `json:"server_port"`
+}
+
+// PUT /api/v1/config 的请求体，只修改给出的字段
+type apiV1ConfigUpdate struct {
+	RouterIP           *string `json:"router_ip"`
+	Stok               *string `json:"stok"`
+	RouterPassword     *string `json:"router_password"`
+	IPv6FirewallEnable *string `json:"ipv6_firewall_enable"`
+	DmzEnable          *string `json:"dmz_enable"`
+	DmzDestIP          *string `json:"dmz_dest_ip"`
+	DmzDestIP6         *string `json:"dmz_dest_ip6"`
+	WANPort            *string `json:"wan_port"`
+}
+
+func v1ConfigOf(cfg Config) apiV1Config {
+	return apiV1Config{
+		RouterIP:           cfg.RouterIP,
+		StokSet:            cfg.Stok != "",
+		RouterPasswordSet:  cfg.RouterPassword != "",
+		IPv6FirewallEnable: cfg.IPv6FirewallEnable,
+		DmzEnable:          cfg.DmzEnable,
+		DmzDestIP:          cfg.DmzDestIP,
+		DmzDestIP6:         cfg.DmzDestIP6,
+		WANPort:            cfg.WANPort,
+		ServerPort:         cfg.ServerPort,
+	}
+}
+
+// GET /api/v1/config 返回当前配置；PUT 修改配置但不立即应用到路由器
+func (h apiV1Handlers) config(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeV1(w, v1ConfigOf(h.store.Get()), nil)
+		return
+	}
+	var req apiV1ConfigUpdate
+	if err := decodeV1(r, &req); err != nil {
+		writeV1(w, nil, err)
+		return
+	}
+	desired := h.store.Get().desired()
+	for _, f := range []struct {
+		v   *string
+		dst *string
+	}{
+		{req.IPv6FirewallEnable, &desired.IPv6FirewallEnable},
+		{req.DmzEnable, &desired.DmzEnable},
+		{req.DmzDestIP, &desired.DmzDestIP},
+		{req.DmzDestIP6, &desired.DmzDestIP6},
+	} {
+		if f.v != nil {
+			*f.dst = *f.v
+		}
+	}
+	if err := validateFirewallState(desired); err != nil {
+		writeV1(w, nil, err)
+		return
+	}
+	if req.WANPort != nil && !validWANPort(*req.WANPort) {
+		writeV1(w, nil, routerErr(ErrBadParameter, 0, "wan_port="+*req.WANPort))
+		return
+	}
+	if req.RouterIP != nil && *req.RouterIP == "" {
+		writeV1(w, nil, routerErr(ErrBadParameter, 0, fmt.Sprintf("router_ip=%q", *req.RouterIP)))
+		return
+	}
+	// 与进行中的修改互斥，不会在应用设置的中途改掉路由器地址或目标状态
+	applyMu.Lock()
+	defer applyMu.Unlock()
+	h.store.Update(func(c *Config) {
+		if req.RouterIP != nil {
+			c.RouterIP = *req.RouterIP
+		}
+		if req.Stok != nil {
+			c.Stok = *req.Stok
+			registerSecret(c.Stok)
+		}
+		if req.RouterPassword != nil {
+			c.RouterPassword = *req.RouterPassword
+			registerSecret(c.RouterPassword)
+		}
+		c.IPv6FirewallEnable = desired.IPv6FirewallEnable
+		c.DmzEnable = desired.DmzEnable
+		c.DmzDestIP = desired.DmzDestIP
+		c.DmzDestIP6 = desired.DmzDestIP6
+		if req.WANPort != nil {
+			c.WANPort = *req.WANPort
+		}
+	})
+	writeV1(w, v1ConfigOf(h.store.Get()), nil)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 只保存在内存中的配置，用于确认处理器读写的是注入的配置
+type memoryConfig struct {
+	mu sync.Mutex
+	c  Config
+}
+
+func (m *memoryConfig) Get() Config {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.c
+}
+
+func (m *memoryConfig) Update(f func(*Config)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f(&m.c)
+}
+
+func serveV1(h http.HandlerFunc, method, body string) (int, apiV1Response) {
+	req := httptest.NewRequest(method, "/api/v1/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	var resp apiV1Response
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp
+}
+
+func TestAPIV1StatusUsesInjectedClient(t *testing.T) {
+	setupTest(t)
+	fake := &fakeRouter{state: firewallState{IPv6FirewallEnable: "off", DmzEnable: "1", DmzDestIP: "192.168.0.66"}}
+	h := apiV1Handlers{client: fake, store: globalConfig{}}
+
+	code, resp := serveV1(h.status, http.MethodGet, "")
+	data, _ := json.Marshal(resp.Data)
+	if code != http.StatusOK || !strings.Contains(string(data), "192.168.0.66") {
+		t.Errorf("status = %d %s", code, data)
+	}
+}
+
+func TestAPIV1ApplyUsesInjectedClient(t *testing.T) {
+	setupTest(t)
+	fake := &fakeRouter{state: firewallState{IPv6FirewallEnable: "on", DmzEnable: "0"}}
+	useFakeBackend(t, &fakeRouter{})
+	h := apiV1Handlers{client: fake, store: globalConfig{}}
+
+	code, resp := serveV1(h.apply, http.MethodPost, `{"ipv6_firewall_enable":"off","dmz_enable":"0"}`)
+	if code != http.StatusOK || !resp.OK {
+		t.Fatalf("apply = %d %+v", code, resp.Error)
+	}
+	if fake.sets == 0 || fake.state.IPv6FirewallEnable != "off" {
+		t.Errorf("设置应写入注入的后端: %+v（%d 次写入）", fake.state, fake.sets)
+	}
+}
+
+func TestAPIV1ConfigUsesInjectedStore(t *testing.T) {
+	setupTest(t)
+	store := &memoryConfig{c: Config{RouterIP: "192.168.5.1", IPv6FirewallEnable: "on", DmzEnable: "0"}}
+	h := apiV1Handlers{client: &fakeRouter{}, store: store}
+	before := globalConfig{}.Get()
+
+	code, resp := serveV1(h.config, http.MethodPut, `{"dmz_enable":"1","dmz_dest_ip":"192.168.5.20"}`)
+	if code != http.StatusOK || !resp.OK {
+		t.Fatalf("config = %d %+v", code, resp.Error)
+	}
+	if c := store.Get(); c.DmzEnable != "1" || c.DmzDestIP != "192.168.5.20" || c.RouterIP != "192.168.5.1" {
+		t.Errorf("注入的配置 = %+v", c)
+	}
+	if after := (globalConfig{}).Get(); after.DmzDestIP != before.DmzDestIP {
+		t.Errorf("不应修改全局配置: dmz_dest_ip=%q", after.DmzDestIP)
+	}
+}
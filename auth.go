@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"tplinkfirewalloff/internal/server"
+)
+
+// 界面角色
+const (
+	roleAdmin  = "admin"  // 可修改设置
+	roleViewer = "viewer" // 只读
+)
+
+// 界面用户，未配置任何用户时不启用登录
+type UserAccount struct {
+	Name     string `json:"name"`
+	Password string `json:"password"` // 明文，或 hash-password 子命令生成的 sha256:盐:摘要
+	Role     string `json:"role"`     // admin/viewer
+	SSO      bool   `json:"-"`        // 通过OIDC登录
+}
+
+type userKey struct{}
+
+// 校验密码，支持加盐sha256与明文
+func (u UserAccount) checkPassword(password string) bool {
+	if strings.HasPrefix(u.Password, "sha256:") {
+		parts := strings.SplitN(u.Password, ":", 3)
+		if len(parts) != 3 {
+			return false
+		}
+		return subtle.ConstantTimeCompare([]byte(hashPassword(parts[1], password)), []byte(u.Password)) == 1
+	}
+	return subtle.ConstantTimeCompare([]byte(u.Password), []byte(password)) == 1
+}
+
+func hashPassword(salt, password string) string {
+	sum := sha256.Sum256([]byte(salt + password))
+	return "sha256:" + salt + ":" + hex.EncodeToString(sum[:])
+}
+
+// 生成带随机盐的密码摘要
+func newPasswordHash(password string) string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hashPassword(hex.EncodeToString(buf), password)
+}
+
+// hash-password 子命令：生成可写入配置文件的密码摘要
+func runHashPassword(args []string) error {
+	if len(args) != 1 || args[0] == "" {
+		return fmt.Errorf("%s", tr("console.hash_password_usage"))
+	}
+	fmt.Println(newPasswordHash(args[0]))
+	return nil
+}
+
+// 启动时校验用户配置
+func validateUsers() error {
+	seen := map[string]bool{}
+	for _, u := range config.Users {
+		if u.Name == "" || u.Password == "" {
+			return fmt.Errorf("%s", tr("auth.user_incomplete"))
+		}
+		if u.Role != roleAdmin && u.Role != roleViewer {
+			return fmt.Errorf("%s", tr("auth.bad_role", u.Name, u.Role))
+		}
+		if seen[u.Name] {
+			return fmt.Errorf("%s", tr("auth.duplicate_user", u.Name))
+		}
+		seen[u.Name] = true
+		if !strings.HasPrefix(u.Password, "sha256:") {
+			registerSecret(u.Password)
+		}
+	}
+	return nil
+}
+
+// 按用户名与密码查找用户
+func authenticate(name, password string) *UserAccount {
+	cfg := globalConfig{}.Get()
+	for i := range cfg.Users {
+		u := &config.Users[i]
+		if u.Name == name && u.checkPassword(password) {
+			return u
+		}
+	}
+	return nil
+}
+
+// 当前请求的登录用户，未启用登录时返回nil
+func currentUser(r *http.Request) *UserAccount {
+	u, _ := r.Context().Value(userKey{}).(*UserAccount)
+	return u
+}
+
+// 发起请求的用户名，未登录时为客户端地址，用于记录修改历史
+func actorOf(r *http.Request) string {
+	if u := currentUser(r); u != nil {
+		return u.Name
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// 是否启用了任意一种登录方式
+func authEnabled() bool {
+	cfg := globalConfig{}.Get()
+	return len(cfg.Users) > 0 || cfg.OIDC.enabled()
+}
+
+// 当前请求是否允许修改设置
+func canEdit(r *http.Request) bool {
+	if !authEnabled() {
+		return true
+	}
+	u := currentUser(r)
+	return u != nil && u.Role == roleAdmin
+}
+
+// 只有管理员才能访问的只读页面（可能包含敏感信息）
+var adminOnlyPaths = map[string]bool{
+	"/api/debug/self":   true,
+	"/api/debug/bundle": true,
+}
+
+// 其他网站发起的修改请求（POST、PUT等），无论是否需要登录都拒绝
+func crossSiteChange(r *http.Request) bool {
+	return r.Method != http.MethodGet && r.Method != http.MethodHead && server.CrossSite(r)
+}
+
+// 登录与角色检查：查看者只能发起只读请求。
+// 支持本地用户的基本认证，以及OIDC会话或Bearer令牌；
+// 修改类请求一律拒绝跨站提交，未启用登录时同样检查
+func requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := globalConfig{}.Get()
+		readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead
+		if crossSiteChange(r) {
+			http.Error(w, tr("auth.cross_site"), http.StatusForbidden)
+			return
+		}
+		// 代理接口与携带令牌的 /apply 链接自行认证，健康检查供容器与监控使用，无需登录
+		if !authEnabled() || strings.HasPrefix(r.URL.Path, "/auth/") || r.URL.Path == "/api/agent/poll" ||
+			r.URL.Path == "/healthz" || r.URL.Path == "/readyz" ||
+			(r.URL.Path == "/apply" && r.URL.Query().Get("token") != "") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var user *UserAccount
+		if cfg.OIDC.enabled() {
+			user = oidcRequestUser(r)
+		}
+		if name, password, ok := r.BasicAuth(); ok && user == nil {
+			user = authenticate(name, password)
+		}
+		if user == nil {
+			// 浏览器访问页面时跳转到OIDC登录
+			if cfg.OIDC.enabled() && r.Method == http.MethodGet && !strings.HasPrefix(r.URL.Path, "/api/") {
+				http.Redirect(w, r, "/auth/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+				return
+			}
+			if len(cfg.Users) > 0 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="TP-LINK IPv6 Firewall", charset="UTF-8"`)
+			}
+			http.Error(w, tr("auth.required"), http.StatusUnauthorized)
+			return
+		}
+		if user.Role != roleAdmin && (!readOnly || adminOnlyPaths[r.URL.Path]) {
+			http.Error(w, tr("auth.forbidden"), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
+	})
+}
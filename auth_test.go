@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCrossSiteChangesRejected(t *testing.T) {
+	setupTest(t)
+	config.Users = []UserAccount{{Name: "admin", Password: "secret", Role: roleAdmin}}
+	t.Cleanup(func() { config.Users = nil })
+	h := requireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, c := range []struct {
+		method  string
+		headers map[string]string
+		want    int
+	}{
+		{http.MethodPost, nil, http.StatusOK},
+		{http.MethodPost, map[string]string{"Origin": "http://example.com"}, http.StatusOK},
+		{http.MethodPost, map[string]string{"Referer": "http://example.com/quick"}, http.StatusOK},
+		{http.MethodPost, map[string]string{"Sec-Fetch-Site": "same-origin"}, http.StatusOK},
+		{http.MethodPost, map[string]string{"Origin": "http://evil.example"}, http.StatusForbidden},
+		{http.MethodPost, map[string]string{"Origin": "null"}, http.StatusForbidden},
+		{http.MethodPost, map[string]string{"Referer": "http://evil.example/"}, http.StatusForbidden},
+		{http.MethodPost, map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "http://example.com"}, http.StatusForbidden},
+		{http.MethodGet, map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusOK},
+	} {
+		req := httptest.NewRequest(c.method, "http://example.com/rollback", nil)
+		req.SetBasicAuth("admin", "secret")
+		for k, v := range c.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.want {
+			t.Errorf("%s %v = %d, want %d", c.method, c.headers, rec.Code, c.want)
+		}
+	}
+}
+
+func TestDeepLinkFromOtherSiteNeedsConfirmation(t *testing.T) {
+	setupTest(t)
+	config.Users = []UserAccount{{Name: "admin", Password: "secret", Role: roleAdmin}}
+	t.Cleanup(func() { config.Users = nil })
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/apply?profile=xbox&action=open", nil)
+	req.SetBasicAuth("admin", "secret")
+	req = req.WithContext(context.WithValue(req.Context(), userKey{}, &config.Users[0]))
+	if !deepLinkAuthorized(req) {
+		t.Error("同站带认证头的链接应可直接执行")
+	}
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	if deepLinkAuthorized(req) {
+		t.Error("其他网站点开的链接不应直接执行")
+	}
+}
+
+func TestTrustedListenerRejectsCrossSite(t *testing.T) {
+	setupTest(t)
+	var user *UserAccount
+	h := trustListener(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { user = currentUser(r) }))
+
+	for _, c := range []struct {
+		method string
+		origin string
+		want   int
+	}{
+		{http.MethodPost, "", http.StatusOK},
+		{http.MethodPost, "http://example.com", http.StatusOK},
+		{http.MethodPost, "http://evil.example", http.StatusForbidden},
+		{http.MethodPost, "null", http.StatusForbidden},
+		{http.MethodGet, "http://evil.example", http.StatusOK},
+	} {
+		user = nil
+		req := httptest.NewRequest(c.method, "http://example.com/rollback", nil)
+		if c.origin != "" {
+			req.Header.Set("Origin", c.origin)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.want {
+			t.Errorf("%s %q = %d, want %d", c.method, c.origin, rec.Code, c.want)
+		}
+		if c.want == http.StatusOK && (user == nil || user.Role != roleAdmin) {
+			t.Errorf("%s %q: 免登录监听应视为管理员，得到 %v", c.method, c.origin, user)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"tplinkfirewalloff/internal/router"
+	"tplinkfirewalloff/pkg/tplink"
+)
+
+// 路由器后端：一类固件的登录与读写方式，接口定义在 router 包中。新增路由器系列时实现该接口并登记到
+// routerBackends，网页、命令行等上层只通过它操作路由器
+type (
+	RouterClient   = router.Client
+	Capabilities   = router.Capabilities
+	dsRequester    = router.DSRequester
+	sectionsSetter = router.SectionsSetter
+)
+
+var fullCapabilities = router.FullCapabilities
+
+// firmware_type 的取值
+const (
+	firmwareStok   = ""       // 默认：stok + /ds JSON接口
+	firmwareLegacy = "legacy" // 早期 TL-WDR/Archer 固件：Cookie认证 + userRpm 页面
+	firmwareSMB    = "smb"    // 商用 ER/TL-R 系列：同样的 /ds 接口，但按 table/para 读写
+)
+
+// 按 firmware_type 登记的后端
+var routerBackends = map[string]RouterClient{
+	firmwareStok:   dsClient{post: postDS, caps: fullCapabilities},
+	firmwareLegacy: dsClient{login: legacyLogin, post: legacyDS, caps: Capabilities{DMZ: true}},
+	firmwareSMB:    dsClient{post: smbDS, caps: fullCapabilities},
+	"mercury":      dialectClient("mercury"),
+	"fast":         dialectClient("fast"),
+}
+
+// 当前 firmware_type 对应的后端
+func currentBackend() RouterClient {
+	if c, ok := routerBackends[globalConfig{}.Get().FirmwareType]; ok {
+		return c
+	}
+	return routerBackends[firmwareStok]
+}
+
+func validateFirmwareType() error {
+	if _, ok := routerBackends[config.FirmwareType]; ok {
+		return nil
+	}
+	return fmt.Errorf("firmware_type %q", config.FirmwareType)
+}
+
+// 写入选中的部分，后端能合并写入时只发送一次请求
+func setSections(client RouterClient, fs firewallState, sections applySections, wanPort string) ([]byte, error) {
+	if s, ok := client.(sectionsSetter); ok {
+		return s.SetSections(fs, sections, wanPort)
+	}
+	var responseBody []byte
+	var err error
+	if sections.Firewall {
+		if responseBody, err = client.SetFirewall(fs); err != nil {
+			return responseBody, err
+		}
+	}
+	if sections.DMZ {
+		responseBody, err = client.SetDMZ(fs, wanPort)
+	}
+	return responseBody, err
+}
+
+// 总是转给当前 firmware_type 的后端。注入给网页处理器后，
+// 切换路由器或重新读取配置改变了固件类型时随之改变
+type activeBackend struct{}
+
+func (activeBackend) Login(host, password string) (string, error) {
+	return currentBackend().Login(host, password)
+}
+
+func (activeBackend) GetFirewall() (firewallState, error) {
+	return currentBackend().GetFirewall()
+}
+
+func (activeBackend) SetFirewall(fs firewallState) ([]byte, error) {
+	return currentBackend().SetFirewall(fs)
+}
+
+func (activeBackend) SetDMZ(fs firewallState, wanPort string) ([]byte, error) {
+	return currentBackend().SetDMZ(fs, wanPort)
+}
+
+func (activeBackend) SetSections(fs firewallState, sections applySections, wanPort string) ([]byte, error) {
+	return setSections(currentBackend(), fs, sections, wanPort)
+}
+
+func (activeBackend) Capabilities() Capabilities {
+	return currentBackend().Capabilities()
+}
+
+// 发送 /ds 形式的请求
+type dsPost func(host, stok string, requestBody map[string]interface{}) ([]byte, error)
+
+// 基于 /ds 接口（或转换为 /ds 形式）的后端，各固件只需提供发送方式，
+// 登录默认为JSON登录
+type dsClient struct {
+	loginPath string                                      // JSON登录的路径，默认为 /
+	login     func(host, password string) (string, error) // 非JSON登录的固件
+	post      dsPost
+	caps      Capabilities
+	session   *routerSession // 绑定的路由器，为 nil 时发往当前选中的路由器
+}
+
+// 绑定到 routers 中一台路由器的会话，stok 只保存在会话中，不影响当前选中的路由器
+type routerSession struct {
+	host     string
+	password string
+	mu       sync.Mutex
+	stok     string
+}
+
+// 按路由器自己的 firmware_type 创建后端，请求发往该路由器
+func backendFor(p RouterProfile) (RouterClient, error) {
+	c, ok := routerBackends[p.FirmwareType].(dsClient)
+	if !ok {
+		return nil, routerErr(ErrUnsupportedFirmware, codeUnsupported, "firmware_type "+p.FirmwareType)
+	}
+	c.session = &routerSession{host: p.RouterIP, password: p.RouterPassword, stok: p.Stok}
+	return c, nil
+}
+
+// 发送 /ds 请求：绑定了路由器时发往该路由器，否则经限流与熔断器发往当前选中的路由器
+func (c dsClient) call(op string, requestBody map[string]interface{}) ([]byte, error) {
+	if c.session == nil {
+		return callRouterVia(c.post, op, requestBody)
+	}
+	s := c.session
+	if err := limiterFor(s.host).Wait(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stok == "" && s.password != "" {
+		stok, err := c.Login(s.host, s.password)
+		if err != nil {
+			return nil, err
+		}
+		s.stok = stok
+	}
+	registerSecret(s.stok)
+	responseBody, err := c.post(s.host, s.stok, requestBody)
+	// stok失效时用密码重新登录一次
+	if s.password != "" && authExpired(responseBody, err) {
+		stok, loginErr := c.Login(s.host, s.password)
+		if loginErr != nil {
+			return nil, loginErr
+		}
+		s.stok = stok
+		registerSecret(s.stok)
+		responseBody, err = c.post(s.host, s.stok, requestBody)
+	}
+	return responseBody, err
+}
+
+// 请求发往的路由器地址
+func (c dsClient) host() string {
+	if c.session != nil {
+		return c.session.host
+	}
+	return globalConfig{}.Get().RouterIP
+}
+
+// 查询路由器某模块下的若干节，同 queryRouter
+func (c dsClient) query(module string, sections ...string) (sectionState, error) {
+	return queryRouterVia(c.host(), c.call, module, sections...)
+}
+
+func (c dsClient) Login(host, password string) (string, error) {
+	if c.login != nil {
+		return c.login(host, password)
+	}
+	path := c.loginPath
+	if path == "" {
+		path = "/"
+	}
+	return jsonLogin(host, password, path)
+}
+
+func (c dsClient) DS(host, stok string, requestBody map[string]interface{}) ([]byte, error) {
+	return c.post(host, stok, requestBody)
+}
+
+// 经 post 发送的 tplink 客户端，防火墙与DMZ的请求格式由 pkg/tplink 生成；
+// raw 不为 nil 时记下路由器的原始响应
+func (c dsClient) api(op string, raw *[]byte) *tplink.Client {
+	return &tplink.Client{Send: func(ctx context.Context, request interface{}) ([]byte, error) {
+		// 各固件的 post 按 map 形式转换请求
+		var requestBody map[string]interface{}
+		if err := json.Unmarshal(mustJSON(request), &requestBody); err != nil {
+			return nil, err
+		}
+		responseBody, err := c.call(op, requestBody)
+		if raw != nil {
+			*raw = responseBody
+		}
+		return responseBody, err
+	}}
+}
+
+func (c dsClient) GetFirewall() (firewallState, error) {
+	value, err := queryCache.get(c.host()+"|tplink.Firewall", func() (interface{}, error) {
+		fw, err := c.api("get", nil).GetFirewall(routerCtx)
+		if err != nil {
+			return nil, tplinkErr(err)
+		}
+		return fw, nil
+	})
+	if err != nil {
+		return firewallState{}, err
+	}
+	return stateFromFirewall(value.(tplink.Firewall)), nil
+}
+
+func (c dsClient) SetFirewall(fs firewallState) ([]byte, error) {
+	return c.SetSections(fs, applySections{Firewall: true}, "")
+}
+
+func (c dsClient) SetDMZ(fs firewallState, wanPort string) ([]byte, error) {
+	return c.SetSections(fs, applySections{DMZ: true}, wanPort)
+}
+
+// 一次 set 请求写入选中的部分；路由器以HTTP 200返回非0的 error_code 时同样视为失败
+func (c dsClient) SetSections(fs firewallState, sections applySections, wanPort string) ([]byte, error) {
+	var responseBody []byte
+	err := c.api("set", &responseBody).SetFirewall(routerCtx, firewallFor(fs, sections, wanPort))
+	return responseBody, tplinkErr(err)
+}
+
+func (c dsClient) Capabilities() Capabilities {
+	return c.caps
+}
+
+// 写入设置前检查后端是否支持；不支持IPv6防火墙的固件只接受关闭
+func checkCapabilities(caps Capabilities, fs firewallState, sections applySections) error {
+	if sections.Firewall && !caps.IPv6Firewall && fs.IPv6FirewallEnable != "off" {
+		return routerErr(ErrUnsupportedFirmware, codeUnsupported, "ipv6_firewall")
+	}
+	if sections.DMZ && !caps.DMZ {
+		return routerErr(ErrUnsupportedFirmware, codeUnsupported, "dmz")
+	}
+	return nil
+}
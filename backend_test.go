@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckCapabilities(t *testing.T) {
+	legacy := Capabilities{DMZ: true}
+	on := firewallState{IPv6FirewallEnable: "on"}
+	off := firewallState{IPv6FirewallEnable: "off"}
+	cases := []struct {
+		caps     Capabilities
+		fs       firewallState
+		sections applySections
+		ok       bool
+	}{
+		{fullCapabilities, on, allSections, true},
+		{legacy, off, allSections, true},
+		{legacy, on, allSections, false},
+		{legacy, on, applySections{DMZ: true}, true},
+		{Capabilities{IPv6Firewall: true}, off, applySections{DMZ: true}, false},
+	}
+	for i, c := range cases {
+		err := checkCapabilities(c.caps, c.fs, c.sections)
+		if (err == nil) != c.ok {
+			t.Errorf("#%d: checkCapabilities = %v", i, err)
+		}
+		if err != nil && !errors.Is(err, ErrUnsupportedFirmware) {
+			t.Errorf("#%d: 错误类别 %v", i, err)
+		}
+	}
+}
+
+func TestRegisteredBackends(t *testing.T) {
+	for _, name := range []string{firmwareStok, firmwareLegacy, firmwareSMB, "mercury", "fast"} {
+		c, ok := routerBackends[name]
+		if !ok {
+			t.Errorf("firmware_type %q 没有登记后端", name)
+			continue
+		}
+		if _, ok := c.(dsRequester); !ok {
+			t.Errorf("%q 后端不支持 /ds 请求", name)
+		}
+	}
+	config.FirmwareType = "no-such-firmware"
+	defer func() { config.FirmwareType = "" }()
+	if validateFirmwareType() == nil {
+		t.Error("未知的 firmware_type 应校验失败")
+	}
+}
+
+func TestDSClientUsesOwnPost(t *testing.T) {
+	setupTest(t)
+	var bodies []map[string]interface{}
+	c := dsClient{
+		post: func(host, stok string, requestBody map[string]interface{}) ([]byte, error) {
+			bodies = append(bodies, requestBody)
+			return []byte(`{"error_code":0,"firewall":{"ipv6_firewall":{"enable":"off"},"dmz":{"enable":"0"}}}`), nil
+		},
+		caps: fullCapabilities,
+	}
+	// 当前选中的是其他固件的后端，dsClient 仍应经自己的 post 发送
+	config.FirmwareType = firmwareLegacy
+	defer func() { config.FirmwareType = "" }()
+
+	fs, err := c.GetFirewall()
+	if err != nil || fs.IPv6FirewallEnable != "off" {
+		t.Fatalf("GetFirewall = %+v, %v", fs, err)
+	}
+	if _, err := c.SetSections(firewallState{IPv6FirewallEnable: "on"}, applySections{Firewall: true}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 || bodies[0]["method"] != "get" || bodies[1]["method"] != "set" {
+		t.Errorf("post 收到的请求: %v", bodies)
+	}
+}
+
+func TestDialectRename(t *testing.T) {
+	d := dialects["mercury"]
+	req := map[string]interface{}{
+		"method": "set",
+		"firewall": map[string]interface{}{
+			"ipv6_firewall": map[string]interface{}{"enable": "off"},
+			"dmz":           map[string]interface{}{"enable": "1", "dest_ip": "192.168.1.9"},
+		},
+	}
+	vendor := d.rename(req, true)
+	fw := vendor["firewall"].(map[string]interface{})
+	if _, ok := fw["ipv6_fw"]; !ok {
+		t.Fatalf("节名未转换: %v", fw)
+	}
+	if ip := fw["dmz"].(map[string]interface{})["ipaddr"]; ip != "192.168.1.9" {
+		t.Fatalf("字段名未转换: %v", fw["dmz"])
+	}
+	back := d.rename(vendor, false)["firewall"].(map[string]interface{})
+	if back["dmz"].(map[string]interface{})["dest_ip"] != "192.168.1.9" || back["ipv6_firewall"] == nil {
+		t.Errorf("转换回TP-LINK名称失败: %v", back)
+	}
+}
+
+func TestPluginTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("插件为 shell 脚本")
+	}
+	path := filepath.Join(t.TempDir(), "slow")
+	// sleep 是脚本的子进程，结束脚本后仍占用输出管道
+	if err := os.WriteFile(path, []byte("#!/bin/sh\nsleep 10\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	prev := pluginTimeout
+	pluginTimeout = 100 * time.Millisecond
+	defer func() { pluginTimeout = prev }()
+
+	start := time.Now()
+	if _, err := runPlugin(path, pluginHook, nil); err == nil {
+		t.Fatal("超时的插件应返回错误")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("超时后 %v 才返回", elapsed)
+	}
+}
+
+// 后端插件只在 firmware_type 选中时使用，请求中没有stok，也拿不到本程序的环境变量
+func TestPluginBackendIsOptIn(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("插件为 shell 脚本")
+	}
+	dir := t.TempDir()
+	script := `#!/bin/sh
+if [ "$1" = describe ]; then echo '{"name":"relay","kinds":["backend"]}'; exit 0; fi
+cat > request.json
+env > env.txt
+echo '{"error_code":0}'
+`
+	if err := os.WriteFile(filepath.Join(dir, "relay"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	setupTest(t)
+	t.Setenv("TPLINK_ROUTER_PASSWORD", "secret-password")
+	config.PluginDir = dir
+	defer func() {
+		config.PluginDir, config.FirmwareType = "", ""
+		plugins = nil
+		delete(routerBackends, "plugin:relay")
+	}()
+	if err := loadPlugins(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := routerBackends["plugin:relay"]; !ok {
+		t.Fatal("后端插件应登记为 plugin:relay")
+	}
+	request := map[string]interface{}{"method": "get"}
+	if _, err := postRouterTo(config.RouterIP, request); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "request.json")); err == nil {
+		t.Fatal("未选中时不应使用后端插件")
+	}
+
+	config.FirmwareType = "plugin:relay"
+	if err := validateFirmwareType(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := postRouterTo(config.RouterIP, request); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "request.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req map[string]interface{}
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := req["stok"]; ok || strings.Contains(string(data), config.Stok) {
+		t.Errorf("插件请求中不应有stok: %s", data)
+	}
+	env, err := os.ReadFile(filepath.Join(dir, "env.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(env), "secret-password") {
+		t.Error("插件不应拿到 TPLINK_* 环境变量")
+	}
+}
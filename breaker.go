@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// 熔断器状态
+const (
+	breakerClosed   = "closed"    // 正常
+	breakerOpen     = "open"      // 熔断中，直接拒绝请求
+	breakerHalfOpen = "half-open" // 冷却结束，放行一次试探请求
+)
+
+// 路由器熔断器：连续失败达到阈值后暂停请求一段时间，
+// 避免在路由器离线或重启期间持续发送请求
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	state     string
+	openedAt  time.Time
+	probing   bool // 半开状态下是否已有试探请求在进行
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, state: breakerClosed}
+}
+
+// 请求前调用，熔断中返回 ErrCircuitOpen
+func (b *circuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 {
+		return nil
+	}
+	switch b.state {
+	case breakerOpen:
+		remaining := b.cooldown - time.Since(b.openedAt)
+		if remaining > 0 {
+			return &RouterError{Kind: ErrCircuitOpen, Detail: tr("breaker.retry_in", remaining.Round(time.Second))}
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return nil
+	case breakerHalfOpen:
+		if b.probing {
+			return &RouterError{Kind: ErrCircuitOpen, Detail: tr("breaker.probing")}
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// 记录请求结果；参数错误、认证失败属于调用方问题，不计入失败次数
+func (b *circuitBreaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if err == nil || errors.Is(err, ErrBadParameter) || errors.Is(err, ErrAuthExpired) || errors.Is(err, ErrCircuitOpen) {
+		if err == nil || b.state == breakerHalfOpen {
+			if b.state != breakerClosed {
+				say("console.breaker_recovered")
+			}
+			b.state = breakerClosed
+			b.failures = 0
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+		if b.state != breakerOpen {
+			warn("console.breaker_open", b.failures, b.cooldown)
+		}
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// 当前状态，以及熔断中剩余的冷却时间
+func (b *circuitBreaker) State() (string, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerOpen {
+		if remaining := b.cooldown - time.Since(b.openedAt); remaining > 0 {
+			return b.state, remaining
+		}
+		return breakerHalfOpen, 0
+	}
+	return b.state, 0
+}
+
+// 当前连续失败次数
+func (b *circuitBreaker) Failures() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures
+}
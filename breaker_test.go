@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := newCircuitBreaker(2, 50*time.Millisecond)
+	down := routerErr(ErrUnreachable, 0, "down")
+
+	// 参数错误和认证失败不计入失败次数
+	b.Record(routerErr(ErrBadParameter, 0, "bad"))
+	b.Record(routerErr(ErrAuthExpired, 0, "auth"))
+	b.Record(down)
+	if err := b.Allow(); err != nil || b.Failures() != 1 {
+		t.Fatalf("一次失败后 Allow = %v, failures = %d", err, b.Failures())
+	}
+	b.Record(down)
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("连续失败后 Allow = %v, want ErrCircuitOpen", err)
+	}
+	if state, remaining := b.State(); state != breakerOpen || remaining <= 0 {
+		t.Errorf("State = %s %v", state, remaining)
+	}
+
+	// 冷却结束后只放行一个试探请求，试探失败重新熔断
+	time.Sleep(60 * time.Millisecond)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("冷却后 Allow = %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("试探进行中 Allow = %v", err)
+	}
+	b.Record(down)
+	if state, _ := b.State(); state != breakerOpen {
+		t.Fatalf("试探失败后 State = %s", state)
+	}
+
+	// 试探成功后恢复
+	time.Sleep(60 * time.Millisecond)
+	if err := b.Allow(); err != nil {
+		t.Fatal(err)
+	}
+	b.Record(nil)
+	if state, _ := b.State(); state != breakerClosed || b.Failures() != 0 {
+		t.Errorf("恢复后 State = %s, failures = %d", state, b.Failures())
+	}
+	if err := b.Allow(); err != nil {
+		t.Errorf("恢复后 Allow = %v", err)
+	}
+}
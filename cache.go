@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 路由器 get 查询的结果：节名 -> 字段
+type sectionState map[string]map[string]interface{}
+
+// 短期缓存路由器状态查询，并合并同时发起的相同查询，
+// 避免页面、监控等在同一时刻各自请求一次路由器
+type stateCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	entries  map[string]cacheEntry
+	inflight map[string]*inflightQuery
+}
+
+type cacheEntry struct {
+	at    time.Time
+	value interface{}
+}
+
+type inflightQuery struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+var queryCache = &stateCache{
+	entries:  map[string]cacheEntry{},
+	inflight: map[string]*inflightQuery{},
+}
+
+func (c *stateCache) get(key string, fetch func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && time.Since(e.at) < c.ttl {
+		c.mu.Unlock()
+		return e.value, nil
+	}
+	if q, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		q.wg.Wait()
+		return q.value, q.err
+	}
+	q := &inflightQuery{}
+	q.wg.Add(1)
+	c.inflight[key] = q
+	c.mu.Unlock()
+
+	q.value, q.err = fetch()
+	q.wg.Done()
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if q.err == nil && c.ttl > 0 {
+		c.entries[key] = cacheEntry{at: time.Now(), value: q.value}
+	}
+	c.mu.Unlock()
+	return q.value, q.err
+}
+
+// 设置成功后清空缓存，下次查询读取路由器的最新状态
+func (c *stateCache) invalidate() {
+	c.mu.Lock()
+	c.entries = map[string]cacheEntry{}
+	c.mu.Unlock()
+}
+
+// 查询路由器某模块下的若干节，如 queryRouter("firewall", "dmz", "ipv6_firewall")
+func queryRouter(module string, sections ...string) (sectionState, error) {
+	return queryRouterVia(globalConfig{}.Get().RouterIP, callRouter, module, sections...)
+}
+
+// 同 queryRouter，经 call 向 host 上的路由器发送
+func queryRouterVia(host string, call func(op string, requestBody map[string]interface{}) ([]byte, error), module string, sections ...string) (sectionState, error) {
+	names := append([]string(nil), sections...)
+	sort.Strings(names)
+	key := host + "|" + module + "|" + strings.Join(names, ",")
+
+	value, err := queryCache.get(key, func() (interface{}, error) {
+		responseBody, err := call("get", map[string]interface{}{
+			"method": "get",
+			module:   map[string]interface{}{"name": names},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := decodeRouterResponse(responseBody)
+		if err != nil {
+			return nil, err
+		}
+		var state sectionState
+		if err := json.Unmarshal(resp[module], &state); err != nil {
+			return nil, routerErr(ErrUnsupportedFirmware, 0, tr("response.missing", module))
+		}
+		return state, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(sectionState), nil
+}
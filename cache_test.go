@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStateCache(t *testing.T) {
+	c := &stateCache{ttl: 50 * time.Millisecond, entries: map[string]cacheEntry{}, inflight: map[string]*inflightQuery{}}
+	var fetches int32
+	fetch := func() (interface{}, error) {
+		return atomic.AddInt32(&fetches, 1), nil
+	}
+
+	// TTL 内重复查询不再请求路由器
+	first, _ := c.get("firewall", fetch)
+	second, _ := c.get("firewall", fetch)
+	if first != second || fetches != 1 {
+		t.Fatalf("TTL内查询了 %d 次", fetches)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if v, _ := c.get("firewall", fetch); v != int32(2) {
+		t.Errorf("过期后 get = %v", v)
+	}
+	c.invalidate()
+	if v, _ := c.get("firewall", fetch); v != int32(3) {
+		t.Errorf("清空后 get = %v", v)
+	}
+
+	// 失败的结果不缓存
+	failed := errors.New("down")
+	if _, err := c.get("wan", func() (interface{}, error) { return nil, failed }); err != failed {
+		t.Fatalf("get = %v", err)
+	}
+	if v, err := c.get("wan", fetch); err != nil || v != int32(4) {
+		t.Errorf("失败后 get = %v, %v", v, err)
+	}
+}
+
+// 同时发起的相同查询合并为一次
+func TestStateCacheCoalesces(t *testing.T) {
+	c := &stateCache{entries: map[string]cacheEntry{}, inflight: map[string]*inflightQuery{}}
+	var fetches int32
+	release := make(chan struct{})
+	fetch := func() (interface{}, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return "state", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]interface{}, 5)
+	go c.get("firewall", fetch)
+	for atomic.LoadInt32(&fetches) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = c.get("firewall", fetch)
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("查询了 %d 次", n)
+	}
+	for _, r := range results {
+		if r != "state" {
+			t.Errorf("result = %v", r)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// 一次路由器交互记录，URL与请求体均已脱敏，便于附在问题报告中分享
+type Interaction struct {
+	Method       string `json:"method"`
+	URL          string `json:"url"`
+	RequestBody  string `json:"request_body"`
+	Status       int    `json:"status"`
+	ResponseBody string `json:"response_body"`
+}
+
+// 磁带文件：录制真实路由器的请求/响应，或在没有设备时回放
+type Cassette struct {
+	path         string
+	replay       bool
+	next         http.RoundTripper
+	mu           sync.Mutex
+	Interactions []Interaction `json:"interactions"`
+	used         []bool
+}
+
+// 打开磁带，mode 为 record 或 replay
+func openCassette(path, mode string, next http.RoundTripper) (*Cassette, error) {
+	c := &Cassette{path: path, next: next}
+	switch mode {
+	case "record":
+	case "replay":
+		c.replay = true
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("%s", tr("cassette.bad_file", err))
+		}
+		c.used = make([]bool, len(c.Interactions))
+	default:
+		return nil, fmt.Errorf("%s", tr("cassette.bad_mode", mode))
+	}
+	return c, nil
+}
+
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	key := Interaction{
+		Method:      req.Method,
+		URL:         redact(req.URL.String()),
+		RequestBody: redact(normalizeJSON(reqBody)),
+	}
+
+	if c.replay {
+		return c.play(req, key)
+	}
+	return c.record(req, key)
+}
+
+// 按顺序查找第一条未使用且匹配的记录
+func (c *Cassette) play(req *http.Request, key Interaction) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, it := range c.Interactions {
+		if c.used[i] || it.Method != key.Method || it.URL != key.URL || it.RequestBody != key.RequestBody {
+			continue
+		}
+		c.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", it.Status, http.StatusText(it.Status)),
+			StatusCode:    it.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": []string{"application/json"}},
+			Body:          io.NopCloser(strings.NewReader(it.ResponseBody)),
+			ContentLength: int64(len(it.ResponseBody)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%s", tr("cassette.no_match", key.Method, key.URL, key.RequestBody))
+}
+
+func (c *Cassette) record(req *http.Request, key Interaction) (*http.Response, error) {
+	resp, err := c.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readLimited(resp.Body, maxRouterResponseBytes)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	key.Status = resp.StatusCode
+	key.ResponseBody = redact(string(respBody))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Interactions = append(c.Interactions, key)
+	if err := c.save(); err != nil {
+		warn("console.cassette_write_failed", err)
+	}
+	return resp, nil
+}
+
+// 每次录制后整体写回，程序异常退出也不会丢失已录制的内容
+func (c *Cassette) save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0600)
+}
+
+// 规范化JSON（键排序），避免map遍历顺序导致回放匹配失败
+func normalizeJSON(body []byte) string {
+	var v interface{}
+	if len(body) == 0 || json.Unmarshal(body, &v) != nil {
+		return string(body)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return string(body)
+	}
+	return string(out)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// 子命令共用的参数解析
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return routerErr(ErrBadParameter, 0, err.Error())
+	}
+	return nil
+}
+
+// apply：按配置和参数设置一次后退出
+func runApply(args []string) error {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	configPath := fs.String("config", "config.json", tr("flag.config"))
+	registerLogFlags(fs)
+	registerRouterFlag(fs)
+	f := registerHeadlessFlags(fs)
+	registerConfigFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	defer cleanup()
+	if err := setup(*configPath); err != nil {
+		return err
+	}
+	return applyOnce(f)
+}
+
+// status：读取路由器当前设置并与配置比较
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	configPath := fs.String("config", "config.json", tr("flag.config"))
+	registerLogFlags(fs)
+	registerRouterFlag(fs)
+	asJSON := fs.Bool("json", false, tr("flag.json"))
+	registerConfigFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := setup(*configPath); err != nil {
+		return err
+	}
+	if !routerConfigured() {
+		return routerErr(ErrBadParameter, 0, tr("headless.no_router"))
+	}
+
+	current, err := refreshConfirmedState(currentBackend())
+	if err != nil {
+		return fmt.Errorf("%s: %w", userMessage(err), err)
+	}
+	desired := desiredFromConfig()
+	sync := syncDrifted
+	if current.Matches(desired) {
+		sync = syncInSync
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]interface{}{
+			"router_ip": config.RouterIP,
+			"sync":      sync,
+			"current":   current,
+			"desired":   desired,
+		})
+	}
+	say("status.router", config.RouterIP)
+	say("status.current", current.IPv6FirewallEnable, current.DmzEnable, current.DmzDestIP, current.DmzDestIP6)
+	say("status.desired", desired.IPv6FirewallEnable, desired.DmzEnable, desired.DmzDestIP, desired.DmzDestIP6)
+	say("status.sync", tr("sync."+sync))
+	return nil
+}
+
+// login：用管理员密码登录路由器并输出stok。
+// 密码依次取 -router-password、TPLINK_ROUTER_PASSWORD、配置中的 router_password，都没有时从标准输入读取
+func runLogin(args []string) error {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	configPath := fs.String("config", "config.json", tr("flag.config"))
+	registerLogFlags(fs)
+	registerRouterFlag(fs)
+	registerConfigFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := setup(*configPath); err != nil {
+		return err
+	}
+	if config.RouterIP == "" {
+		return routerErr(ErrBadParameter, 0, tr("headless.no_router"))
+	}
+
+	password := config.RouterPassword
+	if password == "" {
+		fmt.Fprint(os.Stderr, tr("login.prompt"))
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	stok, err := routerLogin(config.RouterIP, password)
+	if err != nil {
+		return fmt.Errorf("%s: %w", userMessage(err), err)
+	}
+	// stok需要原样输出供脚本使用，不经过脱敏
+	fmt.Println(stok)
+	return nil
+}
+
+// discover：在默认网关和常见地址中查找路由器
+func runDiscover(args []string) error {
+	fs := flag.NewFlagSet("discover", flag.ContinueOnError)
+	configPath := fs.String("config", "config.json", tr("flag.config"))
+	registerLogFlags(fs)
+	registerConfigFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := setup(*configPath); err != nil {
+		return err
+	}
+
+	found := 0
+	for _, ip := range routerCandidates("") {
+		if !probeHost(ip, 2*time.Second) {
+			continue
+		}
+		found++
+		// 有stok时顺带读取型号和MAC
+		detail := tr("discover.unknown")
+		if config.Stok != "" {
+			if id, err := queryIdentityAt(ip); err == nil {
+				detail = id.String()
+			}
+		}
+		say("discover.found", ip, detail)
+	}
+	if found == 0 {
+		return routerErr(ErrUnreachable, 0, tr("discover.none"))
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// 管理页面地址中的stok，如 http://192.168.1.1/stok=xxxx/ds 或 http://tplogin.cn/;stok=xxxx/...
+var stokURLPattern = regexp.MustCompile(`(?:https?://([^/\s;]+))?/?;?stok=([0-9A-Za-z]{8,128})`)
+
+// 单独复制的stok：同时包含字母和数字的长串
+var bareStokPattern = regexp.MustCompile(`^[0-9A-Za-z]{16,128}$`)
+
+// 从剪贴板文本中识别stok及管理页面地址
+func extractStok(text string) (routerIP, stok string, ok bool) {
+	text = strings.TrimSpace(text)
+	if m := stokURLPattern.FindStringSubmatch(text); m != nil {
+		return m[1], m[2], true
+	}
+	if bareStokPattern.MatchString(text) && strings.ContainsAny(text, "0123456789") && strings.IndexFunc(text, func(r rune) bool {
+		return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+	}) >= 0 {
+		return "", text, true
+	}
+	return "", "", false
+}
+
+// /api/clipboard：GET 读取本机剪贴板（仅允许本机浏览器访问），
+// POST 识别浏览器读取到的剪贴板文本（text 字段）
+func clipboardHandler(w http.ResponseWriter, r *http.Request) {
+	var text string
+	var err error
+	if r.Method == http.MethodPost {
+		var form struct {
+			Text string `form:"text"`
+		}
+		if err := bindForm(formOf(r), &form); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		text = form.Text
+	} else {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			http.Error(w, tr("clipboard.local_only"), http.StatusForbidden)
+			return
+		}
+		text, err = readClipboard()
+	}
+
+	resp := map[string]interface{}{"found": false}
+	if err != nil {
+		resp["error"] = err.Error()
+	} else if routerIP, stok, ok := extractStok(text); ok {
+		registerSecret(stok)
+		resp["found"] = true
+		resp["stok"] = stok
+		resp["router_ip"] = routerIP
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
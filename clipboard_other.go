@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+// 其他系统上由浏览器读取剪贴板
+func readClipboard() (string, error) {
+	return "", errors.New(tr("clipboard.unsupported"))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	user32                         = syscall.NewLazyDLL("user32.dll")
+	kernel32                       = syscall.NewLazyDLL("kernel32.dll")
+	procIsClipboardFormatAvailable = user32.NewProc("IsClipboardFormatAvailable")
+	procOpenClipboard              = user32.NewProc("OpenClipboard")
+	procCloseClipboard             = user32.NewProc("CloseClipboard")
+	procGetClipboardData           = user32.NewProc("GetClipboardData")
+	procGlobalLock                 = kernel32.NewProc("GlobalLock")
+	procGlobalUnlock               = kernel32.NewProc("GlobalUnlock")
+)
+
+const cfUnicodeText = 13
+
+// 读取Windows剪贴板中的文本
+func readClipboard() (string, error) {
+	// 剪贴板的打开与关闭必须在同一线程
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if ok, _, _ := procIsClipboardFormatAvailable.Call(cfUnicodeText); ok == 0 {
+		return "", nil
+	}
+	if ok, _, err := procOpenClipboard.Call(0); ok == 0 {
+		return "", err
+	}
+	defer procCloseClipboard.Call()
+
+	handle, _, err := procGetClipboardData.Call(cfUnicodeText)
+	if handle == 0 {
+		return "", err
+	}
+	ptr, _, err := procGlobalLock.Call(handle)
+	if ptr == 0 {
+		return "", err
+	}
+	defer procGlobalUnlock.Call(handle)
+
+	// 逐个读取UTF-16字符直到结尾的0，最多读取64K字符
+	p := *(*unsafe.Pointer)(unsafe.Pointer(&ptr))
+	var buf []uint16
+	for i := uintptr(0); i < 1<<16; i++ {
+		c := *(*uint16)(unsafe.Add(p, i*2))
+		if c == 0 {
+			break
+		}
+		buf = append(buf, c)
+	}
+	return syscall.UTF16ToString(buf), nil
+}
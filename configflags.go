@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+)
+
+// 命令行给出的配置项，在配置文件与环境变量之后应用，重新读取配置文件时仍然有效
+var configFlags []configFlag
+
+type configFlag struct {
+	key   string // 配置文件中的字段，如 watch.interval
+	value string
+}
+
+// 常用字段的简短写法，与完整写法（如 --dmz-enable）含义相同
+var configFlagAliases = []struct{ name, key string }{
+	{"port", "server_port"},
+	{"ipv6-firewall", "ipv6_firewall_enable"},
+	{"dmz", "dmz_enable"},
+	{"dmz-ip", "dmz_dest_ip"},
+	{"dmz-ip6", "dmz_dest_ip6"},
+}
+
+// 一个配置字段对应的参数，值在解析参数时按字段类型检查
+type configFlagValue struct {
+	key    string
+	isBool bool
+}
+
+func (v *configFlagValue) String() string { return "" }
+
+func (v *configFlagValue) Set(s string) error {
+	if f, ok := configField(&Config{}, v.key); ok {
+		if err := setEnvValue(f.value, s); err != nil {
+			return err
+		}
+	}
+	configFlags = append(configFlags, configFlag{key: v.key, value: s})
+	return nil
+}
+
+// 布尔字段可以只写参数名，如 --debug
+func (v *configFlagValue) IsBoolFlag() bool { return v.isBool }
+
+// 配置字段对应的参数名，如 router_ip 为 router-ip，watch.interval 为 watch-interval
+func flagName(key string) string {
+	return strings.ToLower(envNameChars.ReplaceAllString(key, "-"))
+}
+
+// 按配置文件中的字段名找 c 的字段
+func configField(c *Config, key string) (envField, bool) {
+	for _, f := range envFields(c) {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return envField{}, false
+}
+
+// 为子命令注册与配置字段一一对应的参数，如 --router-ip、--dmz-dest-ip6、--watch-interval；
+// 数组与映射用JSON书写。子命令已有的同名参数（如 -log-level）优先，需在其后调用
+func registerConfigFlags(fs *flag.FlagSet) {
+	define := func(name string, f envField) {
+		if fs.Lookup(name) == nil {
+			fs.Var(&configFlagValue{key: f.key, isBool: f.value.Kind() == reflect.Bool}, name, tr("flag.config_field", f.key))
+		}
+	}
+	for _, f := range envFields(&Config{}) {
+		define(flagName(f.key), f)
+	}
+	for _, a := range configFlagAliases {
+		if f, ok := configField(&Config{}, a.key); ok {
+			define(a.name, f)
+		}
+	}
+}
+
+// 把命令行给出的配置项写入 c，同一字段给出多次时以最后一次为准
+func applyFlagConfig(c *Config) {
+	for _, o := range configFlags {
+		if f, ok := configField(c, o.key); ok {
+			// 解析参数时已检查过
+			setEnvValue(f.value, o.value)
+		}
+	}
+}
+
+// 配置文件字段 key 是否由命令行参数给出
+func flagOverridden(key string) bool {
+	for _, o := range configFlags {
+		if o.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// 环境变量与命令行参数覆盖配置文件中的值，命令行参数优先
+func applyOverrides(c *Config) error {
+	if err := applyEnvConfig(c); err != nil {
+		return err
+	}
+	applyFlagConfig(c)
+	return nil
+}
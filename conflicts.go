@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 与路由器现有配置的一处冲突
+type conflict struct {
+	Kind    string // dmz_target / port_forward / ipv6_rule / upnp
+	Message string
+}
+
+// 应用前检查与路由器现有配置的冲突；ports 为本次要开放的端口，可为空
+func detectConflicts(desired firewallState, ports []portSpec) []conflict {
+	var conflicts []conflict
+
+	// DMZ已指向其他主机，开启后会把原主机挤掉
+	if desired.DmzEnable == "1" {
+		if st, err := queryRouter("firewall", "dmz"); err == nil {
+			current := stateFromRouter(st)
+			if current.DmzEnable == "1" && (differentTarget(current.DmzDestIP, desired.DmzDestIP) || differentTarget(current.DmzDestIP6, desired.DmzDestIP6)) {
+				conflicts = append(conflicts, conflict{"dmz_target", tr("conflict.dmz_target", strings.TrimSpace(current.DmzDestIP+" "+current.DmzDestIP6))})
+			}
+		}
+	}
+	if len(ports) == 0 {
+		return conflicts
+	}
+
+	// 端口转发（虚拟服务器）占用了相同的外部端口
+	if entries, err := queryTable("firewall", "redirect"); err == nil {
+		for _, e := range entries {
+			start, end := entryPortRange(e, "src_dport_start", "src_dport_end", "external_port")
+			for _, p := range ports {
+				if start > 0 && p.Port >= start && p.Port <= end && protoMatches(entryString(e, "proto"), p.Proto) {
+					conflicts = append(conflicts, conflict{"port_forward", tr("conflict.port_forward", p, entryString(e, "name"), entryString(e, "dest_ip"))})
+				}
+			}
+		}
+	}
+
+	// 已存在相同端口的IPv6放行规则
+	if entries, err := queryTable("firewall", "ipv6_rule"); err == nil {
+		for _, e := range entries {
+			start, end := entryPortRange(e, "dest_port", "dest_port_end", "")
+			for _, p := range ports {
+				if start > 0 && p.Port >= start && p.Port <= end && protoMatches(entryString(e, "proto"), p.Proto) {
+					conflicts = append(conflicts, conflict{"ipv6_rule", tr("conflict.ipv6_rule", p, entryString(e, "name"), entryString(e, "dest_ip6"))})
+				}
+			}
+		}
+	}
+
+	// UPnP映射由局域网内的程序自动创建，容易被忽略
+	if mappings, err := listUPnPMappings(); err == nil {
+		for _, m := range mappings {
+			for _, p := range ports {
+				if m.ExternalPort == p.Port && protoMatches(m.Protocol, p.Proto) {
+					conflicts = append(conflicts, conflict{"upnp", tr("conflict.upnp", p, m.InternalClient, m.Description)})
+				}
+			}
+		}
+	} else {
+		sayDebug("debug.upnp_read_failed", err)
+	}
+	return conflicts
+}
+
+// 显示冲突并提供覆盖确认，确认表单原样带回本次提交的字段
+func renderConflicts(w http.ResponseWriter, r *http.Request, conflicts []conflict) {
+	renderTemplate(w, http.StatusConflict, "conflict.html", map[string]interface{}{
+		"Conflicts": conflicts,
+		"Form":      r.PostForm,
+		"Action":    r.URL.Path,
+	})
+}
+
+func differentTarget(current, desired string) bool {
+	return current != "" && desired != "" && current != desired
+}
+
+func protoMatches(entryProto, proto string) bool {
+	entryProto = strings.ToLower(entryProto)
+	return entryProto == "" || entryProto == "all" || entryProto == proto
+}
+
+func entryString(e tableEntry, field string) string {
+	if v, ok := e[field]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// 读取条目的端口范围，只有单个端口时起止相同；"8000-8010" 形式也能识别
+func entryPortRange(e tableEntry, startField, endField, altField string) (int, int) {
+	raw := entryString(e, startField)
+	if raw == "" && altField != "" {
+		raw = entryString(e, altField)
+	}
+	if i := strings.IndexAny(raw, "-:"); i > 0 {
+		start, _ := strconv.Atoi(raw[:i])
+		end, _ := strconv.Atoi(raw[i+1:])
+		return start, end
+	}
+	start, _ := strconv.Atoi(raw)
+	end := start
+	if endField != "" {
+		if v, err := strconv.Atoi(entryString(e, endField)); err == nil && v >= start {
+			end = v
+		}
+	}
+	return start, end
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 环境变量前缀：TPLINK_ 加大写的字段名覆盖配置文件中的值，如 TPLINK_ROUTER_IP、TPLINK_DMZ_DEST_IP6；
+// 嵌套的字段以 _ 连接，如 TPLINK_WATCH_INTERVAL；数组与映射用JSON书写，
+// 如 TPLINK_HEADERS='{"Referer":"http://192.168.0.1/"}'
+const envPrefix = "TPLINK_"
+
+// 配置字段对应的环境变量
+type envField struct {
+	name  string // 如 TPLINK_WATCH_INTERVAL
+	key   string // 配置文件中的字段，如 watch.interval
+	value reflect.Value
+}
+
+var (
+	envNameChars    = regexp.MustCompile(`[^A-Za-z0-9]+`)
+	jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// 按 json 标签列出 c 的全部字段及其环境变量，嵌套的结构体逐层展开
+func envFields(c *Config) []envField {
+	var fields []envField
+	var walk func(v reflect.Value, name, key string)
+	walk = func(v reflect.Value, name, key string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := strings.Split(f.Tag.Get("json"), ",")[0]
+			if tag == "-" || f.PkgPath != "" {
+				continue
+			}
+			if tag == "" {
+				tag = f.Name
+			}
+			n := name + strings.ToUpper(envNameChars.ReplaceAllString(tag, "_"))
+			if f.Type.Kind() == reflect.Struct && !reflect.PtrTo(f.Type).Implements(jsonUnmarshaler) {
+				walk(v.Field(i), n+"_", key+tag+".")
+				continue
+			}
+			fields = append(fields, envField{name: n, key: key + tag, value: v.Field(i)})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), envPrefix, "")
+	return fields
+}
+
+// 按字段类型解析环境变量的值，数组、映射等按JSON解析
+func setEnvValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		x, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(x)
+	default:
+		p := reflect.New(v.Type())
+		if err := json.Unmarshal([]byte(s), p.Interface()); err != nil {
+			return err
+		}
+		v.Set(p.Elem())
+	}
+	return nil
+}
+
+// 早期版本使用的不带前缀的变量，容器（如 NAS 上的 Docker）中常用，保留兼容。
+// 只保留这几个，避免误用容器中其他程序的 DEBUG、LOG_LEVEL 等变量
+var plainEnvNames = map[string]string{
+	"router_ip":   "ROUTER_IP",
+	"stok":        "STOK",
+	"server_port": "SERVER_PORT",
+}
+
+func plainEnvName(name string) bool {
+	for _, plain := range plainEnvNames {
+		if plain == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 字段对应的环境变量及其值，同时设置时 TPLINK_ 前缀的优先
+func (f envField) lookup() (name, value string, ok bool) {
+	if v, ok := os.LookupEnv(f.name); ok {
+		return f.name, v, true
+	}
+	if plain, found := plainEnvNames[f.key]; found {
+		if v, ok := os.LookupEnv(plain); ok {
+			return plain, v, true
+		}
+	}
+	return "", "", false
+}
+
+// 用环境变量覆盖配置，值无法按字段类型解析时返回错误
+func applyEnvConfig(c *Config) error {
+	for _, f := range envFields(c) {
+		if name, v, ok := f.lookup(); ok {
+			if err := setEnvValue(f.value, v); err != nil {
+				return fmt.Errorf("%s", tr("env.bad_value", name, err))
+			}
+		}
+	}
+	return nil
+}
+
+// 配置文件字段 key（如 router_ip、watch.interval）是否被环境变量覆盖
+func envOverridden(key string) bool {
+	for _, f := range envFields(&Config{}) {
+		if f.key == key {
+			_, _, ok := f.lookup()
+			return ok
+		}
+	}
+	return false
+}
+
+// 是否已通过环境变量给出路由器地址，此时可以不提供配置文件
+func envConfigured() bool {
+	return os.Getenv(envPrefix+"ROUTER_IP") != "" || os.Getenv(plainEnvNames["router_ip"]) != ""
+}
+
+// config env：列出可用的环境变量及对应的配置字段
+func runConfigEnv() error {
+	for _, f := range envFields(&Config{}) {
+		fmt.Printf("%-40s %s\n", f.name, f.key)
+		if plain, ok := plainEnvNames[f.key]; ok {
+			fmt.Printf("%-40s %s\n", plain, f.key)
+		}
+	}
+	return nil
+}
+
+// 标准输入是否为终端；容器、服务或被重定向时不是。
+// /dev/null 也是字符设备（docker run 不带 -i 时即是），需要单独排除
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(fi, null)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tplinkfirewalloff/internal/configstore"
+)
+
+func TestEnvOverridesConfig(t *testing.T) {
+	setupTest(t)
+	// 除 ROUTER_IP、STOK、SERVER_PORT 外，不带前缀的变量属于其他程序，不应生效
+	t.Setenv("DMZ_DEST_IP", "192.168.0.40")
+	t.Setenv("SERVER_PORT", "9999")
+	t.Setenv("DEBUG", "1")
+	t.Setenv("TPLINK_DMZ_DEST_IP", "192.168.0.41")
+	t.Setenv("TPLINK_WATCH_INTERVAL", "30s")
+	t.Setenv("TPLINK_RATE_LIMIT", "2.5")
+	t.Setenv("TPLINK_HEADERS", `{"Referer": "http://192.168.0.1/"}`)
+
+	if _, err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if config.DmzDestIP != "192.168.0.41" || config.ServerPort != "9999" || config.Debug {
+		t.Errorf("不带前缀的变量处理不正确: dmz_dest_ip=%q server_port=%q debug=%v", config.DmzDestIP, config.ServerPort, config.Debug)
+	}
+	if config.Watch.Interval != "30s" || config.RateLimit != 2.5 || config.Headers["Referer"] != "http://192.168.0.1/" {
+		t.Errorf("环境变量未生效: watch=%+v rate_limit=%v headers=%v", config.Watch, config.RateLimit, config.Headers)
+	}
+	if !envOverridden("watch.interval") || !envOverridden("dmz_dest_ip") || envOverridden("dmz_enable") || !envOverridden("server_port") {
+		t.Error("envOverridden 结果不正确")
+	}
+
+	// 同时设置时 TPLINK_ 前缀的优先
+	t.Setenv("TPLINK_SERVER_PORT", "9001")
+	if _, err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if config.ServerPort != "9001" {
+		t.Errorf("server_port = %q, want 9001", config.ServerPort)
+	}
+
+	t.Setenv("TPLINK_RATE_LIMIT", "fast")
+	if _, err := reloadConfig(); err == nil {
+		t.Fatal("无效的环境变量没有报错")
+	}
+}
+
+func TestFlagsOverrideEnvAndSurviveReload(t *testing.T) {
+	setupTest(t)
+	t.Cleanup(func() { configFlags = nil })
+	t.Setenv("TPLINK_SERVER_PORT", "9000")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	registerConfigFlags(fs)
+	if err := fs.Parse([]string{"--port", "9100", "--ipv6-firewall", "off", "--watch-enabled", "--dmz-dest-ip6", "::1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"--rate-limit", "fast"}); err == nil {
+		t.Error("无效的参数值没有报错")
+	}
+
+	if _, err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if config.ServerPort != "9100" || config.IPv6FirewallEnable != "off" || !config.Watch.Enabled || config.DmzDestIP6 != "::1" {
+		t.Errorf("命令行参数未生效: port=%q firewall=%q watch=%+v dmz_dest_ip6=%q",
+			config.ServerPort, config.IPv6FirewallEnable, config.Watch, config.DmzDestIP6)
+	}
+	if !flagOverridden("server_port") || flagOverridden("dmz_enable") {
+		t.Error("flagOverridden 结果不正确")
+	}
+}
+
+func TestHeadlessDMZFlagsAreConfigAliases(t *testing.T) {
+	setupTest(t)
+	t.Cleanup(func() { configFlags = nil })
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	registerHeadlessFlags(fs)
+	registerConfigFlags(fs)
+	// 简写与完整写法写入同一字段，以最后一次为准
+	if err := fs.Parse([]string{"--dmz-enable", "1", "--dmz", "0", "--dmz-ip", "192.168.0.5", "--dmz-dest-ip6", "::5"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if config.DmzEnable != "0" || config.DmzDestIP != "192.168.0.5" || config.DmzDestIP6 != "::5" {
+		t.Errorf("dmz=%q dmz_dest_ip=%q dmz_dest_ip6=%q", config.DmzEnable, config.DmzDestIP, config.DmzDestIP6)
+	}
+	if !flagOverridden("dmz_enable") || !flagOverridden("dmz_dest_ip") {
+		t.Error("简写参数未记为命令行覆盖")
+	}
+}
+
+func TestFlagsApplyWithoutConfigFile(t *testing.T) {
+	setupTest(t)
+	t.Cleanup(func() { configFlags = nil })
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	registerConfigFlags(fs)
+	if err := fs.Parse([]string{"--port", "9200"}); err != nil {
+		t.Fatal(err)
+	}
+	// 未给出路由器地址，仍提示配置文件不存在，但其他参数照常生效
+	err := loadConfig(configstore.NewFile(filepath.Join(t.TempDir(), "config.json")))
+	if !os.IsNotExist(err) {
+		t.Errorf("err = %v, want not exist", err)
+	}
+	if config.ServerPort != "9200" {
+		t.Errorf("server_port = %q, want 9200", config.ServerPort)
+	}
+}
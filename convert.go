@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"tplinkfirewalloff/internal/configstore"
+)
+
+// 未指定 -config 时依次查找的配置文件，第一个存在的生效
+var defaultConfigFiles = []string{"config.json", "config.yaml", "config.yml", "config.toml"}
+
+// 使用默认的 config.json 而它不存在时，改用同目录下的 config.yaml、config.yml 或 config.toml
+func findConfig(path string) string {
+	if path != defaultConfigFiles[0] {
+		return path
+	}
+	for _, name := range defaultConfigFiles {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return path
+}
+
+// config convert：在 JSON、YAML、TOML 之间转换配置文件，格式按扩展名或 -from、-to 区分；
+// 输入文件为 - 时从标准输入读取，不指定输出文件时输出到标准输出
+func runConfig(args []string) error {
+	if len(args) == 1 && args[0] == "env" {
+		return runConfigEnv()
+	}
+	if len(args) == 0 || args[0] != "convert" {
+		return routerErr(ErrBadParameter, 0, tr("config.usage"))
+	}
+	fs := flag.NewFlagSet("config convert", flag.ContinueOnError)
+	from := fs.String("from", "", tr("flag.convert_from"))
+	to := fs.String("to", "", tr("flag.convert_to"))
+	force := fs.Bool("force", false, tr("flag.convert_force"))
+	// 参数可以写在文件名前后
+	var files []string
+	for rest := args[1:]; ; {
+		if err := parseFlags(fs, rest); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		files, rest = append(files, fs.Arg(0)), fs.Args()[1:]
+	}
+	if len(files) < 1 || len(files) > 2 {
+		return routerErr(ErrBadParameter, 0, tr("convert.usage"))
+	}
+	in, out := files[0], ""
+	if len(files) == 2 {
+		out = files[1]
+	}
+	target := out
+	switch {
+	case out == "" && *to == "":
+		return routerErr(ErrBadParameter, 0, tr("convert.usage"))
+	case out == "":
+		target = "config." + *to
+	}
+	for _, ext := range []string{filepath.Ext(target), "." + *from} {
+		switch strings.ToLower(ext) {
+		case ".", ".json", ".yaml", ".yml", ".toml":
+		default:
+			return routerErr(ErrBadParameter, 0, tr("convert.bad_format", ext))
+		}
+	}
+
+	// 保持原有的字段顺序，数字与布尔值按配置字段的类型转换，如 server_port: 8080 转为 "8080"
+	var v json.RawMessage
+	src := &configstore.File{Path: in, Format: *from}
+	if err := src.LoadAs(&v, reflect.TypeOf(Config{})); err != nil {
+		return routerErr(ErrBadParameter, 0, tr("console.config_read_failed", err))
+	}
+	data, err := configstore.Encode(target, v)
+	if err != nil {
+		return routerErr(ErrBadParameter, 0, err.Error())
+	}
+	if ext := strings.ToLower(filepath.Ext(target)); ext != ".json" {
+		data = append([]byte("# "+tr("convert.header", filepath.Base(in))+"\n"), data...)
+	}
+	if out == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if _, err := os.Stat(out); err == nil && !*force {
+		return routerErr(ErrBadParameter, 0, tr("convert.exists", out))
+	}
+	if err := os.WriteFile(out, data, 0600); err != nil {
+		return err
+	}
+	say("convert.done", in, out)
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	bits := func(vs ...int) uint64 {
+		var b uint64
+		for _, v := range vs {
+			b |= 1 << uint(v)
+		}
+		return b
+	}
+	cases := []struct {
+		expr                          string
+		minute, hour, dom, month, dow uint64
+	}{
+		{"0 8 * * *", bits(0), bits(8), bits(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31), bits(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12), bits(0, 1, 2, 3, 4, 5, 6, 7)},
+		{"*/20 9-17/4 1,15 jan,Jul mon-fri", bits(0, 20, 40), bits(9, 13, 17), bits(1, 15), bits(1, 7), bits(1, 2, 3, 4, 5)},
+		{"5/20 23 31 dec sun", bits(5, 25, 45), bits(23), bits(31), bits(12), bits(0)},
+		// 周日可写作7
+		{"59 0 1 1 7", bits(59), bits(0), bits(1), bits(1), bits(0, 7)},
+	}
+	for _, c := range cases {
+		got, err := parseCron(c.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q): %v", c.expr, err)
+			continue
+		}
+		if got.minute != c.minute || got.hour != c.hour || got.dom != c.dom || got.month != c.month || got.dow != c.dow {
+			t.Errorf("parseCron(%q) = %+v", c.expr, got)
+		}
+	}
+
+	for _, bad := range []string{
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"0 0 0 * *",
+		"0 0 32 * *",
+		"0 0 * 0 *",
+		"0 0 * 13 *",
+		"0 0 * * 8",
+		"0 0 * * funday",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		if _, err := parseCron(bad); err == nil {
+			t.Errorf("parseCron(%q) 应报错", bad)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, ny)
+	}
+	// 2026-11-01 回拨，01:00-01:59 出现两次
+	edt130 := at(time.November, 1, 1, 30)
+	est110 := edt130.Add(40 * time.Minute)
+
+	cases := []struct {
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		{"*/15 * * * *", at(time.January, 5, 10, 7), at(time.January, 5, 10, 15)},
+		{"*/15 * * * *", at(time.January, 5, 10, 15), at(time.January, 5, 10, 30)},
+		{"5/20 * * * *", at(time.January, 5, 10, 30), at(time.January, 5, 10, 45)},
+		{"0 9-17/4 * * *", at(time.January, 5, 10, 0), at(time.January, 5, 13, 0)},
+		{"0 9-17/4 * * *", at(time.January, 5, 17, 0), at(time.January, 6, 9, 0)},
+		{"0 0 1,15 * *", at(time.January, 2, 0, 0), at(time.January, 15, 0, 0)},
+		{"0 0 31 * *", at(time.February, 1, 0, 0), at(time.March, 31, 0, 0)},
+		{"0 0 1 jan,jul *", at(time.February, 1, 0, 0), at(time.July, 1, 0, 0)},
+		// 2026-01-03 是周六
+		{"0 8 * * mon-fri", at(time.January, 3, 0, 0), at(time.January, 5, 8, 0)},
+		{"0 0 * * 7", at(time.January, 1, 0, 0), at(time.January, 4, 0, 0)},
+		// 日和周都有限定时满足其一即可：2月10日是周二，2月13日是周五
+		{"0 0 10 * fri", at(time.February, 7, 0, 0), at(time.February, 10, 0, 0)},
+		{"0 0 10 * fri", at(time.February, 10, 0, 0), at(time.February, 13, 0, 0)},
+		// 2026-03-08 02:00 跳到 03:00：跳过的时刻按跳过的时长顺延，之后恢复正常
+		{"30 2 * * *", at(time.March, 8, 0, 0), at(time.March, 8, 3, 30)},
+		{"30 2 * * *", at(time.March, 8, 3, 30), at(time.March, 9, 2, 30)},
+		{"*/30 * * * *", at(time.March, 8, 1, 45), at(time.March, 8, 3, 0)},
+		{"*/30 * * * *", at(time.March, 8, 3, 0), at(time.March, 8, 3, 30)},
+		// 回拨时重复的时刻只在第一次出现时执行
+		{"30 1 * * *", at(time.November, 1, 0, 0), edt130},
+		{"30 1 * * *", edt130, at(time.November, 2, 1, 30)},
+		{"30 1 * * *", est110, at(time.November, 2, 1, 30)},
+		{"0 * * * *", edt130, at(time.November, 1, 2, 0)},
+	}
+	for _, c := range cases {
+		expr, err := parseCron(c.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", c.expr, err)
+		}
+		got, err := expr.next(c.after, ny)
+		if err != nil || !got.Equal(c.want) {
+			t.Errorf("%q.next(%v) = %v, %v; want %v", c.expr, c.after, got, err, c.want)
+		}
+	}
+
+	never, err := parseCron("0 0 31 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := never.next(at(time.January, 1, 0, 0), ny); err == nil {
+		t.Error("2月31日不应有执行时刻")
+	}
+}
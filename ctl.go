@@ -0,0 +1,374 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"tplinkfirewalloff/internal/eventstore"
+)
+
+// 最长等待新日志的时间，ctl logs -f 依靠它长轮询
+const maxLogsWait = 60 * time.Second
+
+// POST /api/apply：按预设或字段修改设置，与界面一样经过 applySettings 排队执行
+func apiApplyHandler(w http.ResponseWriter, r *http.Request) {
+	var req apiV1ApplyRequest
+	if err := bindForm(formOf(r), &req); err != nil {
+		writeAPIResult(w, err, "")
+		return
+	}
+	msg, err := applyRequest(req, actorOf(r))
+	writeAPIResult(w, err, msg)
+}
+
+// 按请求中的预设与字段修改设置，返回成功时的提示
+func applyRequest(req apiV1ApplyRequest, actor string) (string, error) {
+	return applyRequestWith(activeBackend{}, globalConfig{}, req, actor)
+}
+
+// 同 applyRequest，单独指定的字段经 client 应用并写入 store；预设仍按当前选中的路由器执行
+func applyRequestWith(client RouterClient, store ConfigStore, req apiV1ApplyRequest, actor string) (string, error) {
+	desired := store.Get().desired()
+	if name := req.Profile; name != "" {
+		profile := findProfile(name)
+		if profile == nil {
+			return "", routerErr(ErrBadParameter, 0, tr("deeplink.unknown_profile", name))
+		}
+		action := req.Action
+		if action == "" {
+			action = actionOpen
+		}
+		var ok bool
+		if desired, ok = profile.state(action); !ok {
+			return "", routerErr(ErrBadParameter, 0, tr("deeplink.unknown_action", action))
+		}
+		// 没有单独指定字段时按预设执行，没有公网IPv6时可回退到IPv4
+		if req.IPv6FirewallEnable == nil && req.DmzEnable == nil && req.DmzDestIP == nil && req.DmzDestIP6 == nil {
+			path, err := applyProfileBy(*profile, action, actor)
+			return tr("deeplink.done_path", profile.Name, action, pathLabel(path)), err
+		}
+	}
+	// 单独指定的字段覆盖预设
+	if v := req.IPv6FirewallEnable; v != nil && *v != "" {
+		desired.IPv6FirewallEnable = *v
+	}
+	if v := req.DmzEnable; v != nil && *v != "" {
+		desired.DmzEnable = *v
+	}
+	if req.DmzDestIP != nil {
+		desired.DmzDestIP = *req.DmzDestIP
+	}
+	if req.DmzDestIP6 != nil {
+		desired.DmzDestIP6 = *req.DmzDestIP6
+	}
+	if err := validateFirewallState(desired); err != nil {
+		return "", err
+	}
+
+	sections := sectionsFor(req.OnlyFirewall, req.OnlyDMZ)
+	changed, err := applyUpdate(client, store, func(c *Config) { assignSections(c, desired, sections) }, nil, sourceCtl, actor, sections)
+	message := tr("ctl.applied")
+	if !changed {
+		message = tr("apply.unchanged")
+	}
+	if err != nil {
+		logf("%s\n", tr("ctl.apply_failed", userMessage(err)))
+	} else {
+		logf("%s\n", message)
+	}
+	return message, err
+}
+
+func writeAPIResult(w http.ResponseWriter, err error, okMessage string) {
+	resp := map[string]interface{}{"ok": err == nil, "message": okMessage}
+	if err != nil {
+		resp["message"] = userMessage(err)
+		resp["error"] = redact(err.Error())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatusFor(err))
+	json.NewEncoder(w).Encode(resp)
+}
+
+// /api/logs 的查询参数
+type logsQuery struct {
+	After string `form:"after"` // RFC3339
+	Limit int    `form:"limit"`
+	Wait  string `form:"wait"` // 等待新事件的时长
+}
+
+// GET /api/logs?after=<RFC3339>&limit=20&wait=30s：读取历史事件，
+// 指定 wait 且暂无新事件时等待，直到有新事件或超时
+func logsHandler(w http.ResponseWriter, r *http.Request) {
+	var query logsQuery
+	if err := bindForm(formOf(r), &query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var after time.Time
+	if v := query.After; v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			http.Error(w, "after: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		after = t
+	}
+	limit := query.Limit
+	wait, _ := time.ParseDuration(query.Wait)
+	if wait > maxLogsWait {
+		wait = maxLogsWait
+	}
+
+	deadline := time.Now().Add(wait)
+	var events []historyEvent
+	for {
+		var err error
+		events, err = queryHistory(eventstore.Query{After: after})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(events) > 0 || time.Now().After(deadline) {
+			break
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	if events == nil {
+		events = []historyEvent{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// ctl 客户端：通过本地API控制正在运行的实例
+type ctlClient struct {
+	server   string
+	user     string
+	password string
+	http     *http.Client
+}
+
+// 子命令 ctl status / ctl apply / ctl logs [-f]
+func runCtl(args []string) error {
+	fs := flag.NewFlagSet("ctl", flag.ContinueOnError)
+	configPath := fs.String("config", "config.json", tr("flag.config"))
+	server := fs.String("server", "", tr("flag.ctl_server"))
+	user := fs.String("user", "", tr("flag.ctl_user"))
+	if err := fs.Parse(args); err != nil {
+		return routerErr(ErrBadParameter, 0, err.Error())
+	}
+	if fs.NArg() == 0 {
+		return routerErr(ErrBadParameter, 0, tr("ctl.usage"))
+	}
+
+	c := &ctlClient{server: *server, user: *user, password: os.Getenv("TPLINK_CTL_PASSWORD"), http: &http.Client{Timeout: maxLogsWait + 30*time.Second}}
+	if c.server == "" {
+		// 未指定时连接配置中的本机监听地址，优先免登录的监听
+		if err := readConfig(*configPath); err != nil {
+			warn("console.config_read_failed", err)
+		}
+		l := localListener()
+		c.server = l.url()
+		if l.TLSSelfSigned && l.TLSCert == "" {
+			c.http.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+		}
+	}
+	c.server = strings.TrimRight(c.server, "/")
+
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "status":
+		return c.status()
+	case "apply":
+		return c.apply(rest)
+	case "logs":
+		return c.logs(rest)
+	}
+	return routerErr(ErrBadParameter, 0, tr("ctl.usage"))
+}
+
+func localListener() ListenerConfig {
+	listeners := listenerConfigs()
+	for _, l := range listeners {
+		if l.Auth == "none" {
+			return l
+		}
+	}
+	return listeners[0]
+}
+
+func (c *ctlClient) do(method, path string, form url.Values) ([]byte, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequest(method, c.server+path, body)
+	if err != nil {
+		return nil, routerErr(ErrBadParameter, 0, err.Error())
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, routerErr(ErrUnreachable, 0, tr("ctl.unreachable", c.server, err))
+	}
+	defer resp.Body.Close()
+	data, err := readLimited(resp.Body, maxRouterResponseBytes)
+	if err != nil {
+		return nil, routerErr(ErrUnreachable, 0, err.Error())
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg := strings.TrimSpace(string(data))
+		var result struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &result) == nil && result.Error != "" {
+			msg = result.Error
+		}
+		kind := errorForStatus(resp.StatusCode)
+		// 服务端的错误信息已带有类别前缀
+		return nil, &RouterError{Kind: kind, Detail: strings.TrimPrefix(msg, kind.Error()+": ")}
+	}
+	return data, nil
+}
+
+// HTTP状态码还原为错误类别，使 ctl 的退出码与服务端一致
+func errorForStatus(status int) error {
+	switch status {
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusUnauthorized, http.StatusForbidden:
+		return ErrBadParameter
+	case http.StatusNotImplemented:
+		return ErrUnsupportedFirmware
+	case http.StatusGatewayTimeout:
+		return ErrUnreachable
+	case http.StatusServiceUnavailable:
+		return ErrCircuitOpen
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusLocked:
+		return ErrMaintenance
+	case http.StatusConflict:
+		return ErrIdentityMismatch
+	}
+	return ErrRouter
+}
+
+func (c *ctlClient) status() error {
+	data, err := c.do(http.MethodGet, "/api/status", nil)
+	if err != nil {
+		return err
+	}
+	os.Stdout.Write(data)
+	return nil
+}
+
+func (c *ctlClient) apply(args []string) error {
+	fs := flag.NewFlagSet("ctl apply", flag.ContinueOnError)
+	profile := fs.String("preset", "", tr("flag.preset"))
+	action := fs.String("action", actionOpen, tr("flag.ctl_action"))
+	firewall := fs.String("ipv6-firewall", "", "on/off")
+	dmz := fs.String("dmz", "", "0/1")
+	dmzIP := fs.String("dmz-ip", "", "dmz_dest_ip")
+	dmzIP6 := fs.String("dmz-ip6", "", "dmz_dest_ip6")
+	onlyFirewall := fs.Bool("only-firewall", false, tr("flag.only_firewall"))
+	onlyDMZ := fs.Bool("only-dmz", false, tr("flag.only_dmz"))
+	if err := fs.Parse(args); err != nil {
+		return routerErr(ErrBadParameter, 0, err.Error())
+	}
+
+	form := url.Values{}
+	if *profile != "" {
+		form.Set("profile", *profile)
+		form.Set("action", *action)
+	}
+	if *firewall != "" {
+		form.Set("ipv6_firewall_enable", *firewall)
+	}
+	if *dmz != "" {
+		form.Set("dmz_enable", *dmz)
+	}
+	// 只提交命令行中出现的地址参数，允许显式清空
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "dmz-ip":
+			form.Set("dmz_dest_ip", *dmzIP)
+		case "dmz-ip6":
+			form.Set("dmz_dest_ip6", *dmzIP6)
+		}
+	})
+	if len(form) == 0 {
+		return routerErr(ErrBadParameter, 0, tr("ctl.usage"))
+	}
+	if *onlyFirewall {
+		form.Set("only_firewall", "1")
+	}
+	if *onlyDMZ {
+		form.Set("only_dmz", "1")
+	}
+
+	data, err := c.do(http.MethodPost, "/api/apply", form)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Message string `json:"message"`
+	}
+	json.Unmarshal(data, &result)
+	fmt.Println(result.Message)
+	return nil
+}
+
+func (c *ctlClient) logs(args []string) error {
+	fs := flag.NewFlagSet("ctl logs", flag.ContinueOnError)
+	follow := fs.Bool("f", false, tr("flag.ctl_follow"))
+	limit := fs.Int("n", 20, tr("flag.ctl_lines"))
+	if err := fs.Parse(args); err != nil {
+		return routerErr(ErrBadParameter, 0, err.Error())
+	}
+
+	query := url.Values{"limit": {strconv.Itoa(*limit)}}
+	for {
+		data, err := c.do(http.MethodGet, "/api/logs?"+query.Encode(), nil)
+		if err != nil {
+			return err
+		}
+		var events []historyEvent
+		if err := json.Unmarshal(data, &events); err != nil {
+			return routerErr(ErrRouter, 0, err.Error())
+		}
+		for _, e := range events {
+			fmt.Printf("%s  %-16s %s\n", e.Time.Local().Format("2006-01-02 15:04:05"), e.Kind, e.Message)
+			query.Set("after", e.Time.Format(time.RFC3339Nano))
+		}
+		if !*follow {
+			return nil
+		}
+		if query.Get("after") == "" {
+			// 还没有任何事件时从现在开始跟踪
+			query.Set("after", time.Now().Format(time.RFC3339Nano))
+		}
+		query.Del("limit")
+		query.Set("wait", maxLogsWait.String())
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+var startedAt = time.Now()
+
+// /api/debug/self：报告运行时状态，用于排查长时间运行时的异常
+func debugSelfHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(selfReport())
+}
+
+// 运行时状态，同时用于诊断包
+func selfReport() map[string]interface{} {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	state, remaining := breaker.State()
+
+	limitersMu.Lock()
+	queues := map[string]int{}
+	for ip, l := range limiters {
+		queues[ip] = l.Waiting()
+	}
+	limitersMu.Unlock()
+
+	queryCache.mu.Lock()
+	cached, inflight := len(queryCache.entries), len(queryCache.inflight)
+	queryCache.mu.Unlock()
+
+	up, known, downSince := availability.Status()
+
+	resp := map[string]interface{}{
+		"uptime_seconds": int(time.Since(startedAt).Seconds()),
+		"started_at":     startedAt.Format(time.RFC3339),
+		"go_version":     runtime.Version(),
+		"goroutines":     runtime.NumGoroutine(),
+		"memory": map[string]interface{}{
+			"alloc_bytes":       mem.Alloc,
+			"sys_bytes":         mem.Sys,
+			"heap_objects":      mem.HeapObjects,
+			"gc_cycles":         mem.NumGC,
+			"total_alloc_bytes": mem.TotalAlloc,
+		},
+		"circuit_breaker": map[string]interface{}{
+			"state":             state,
+			"failures":          breaker.Failures(),
+			"remaining_seconds": int(remaining.Seconds()),
+		},
+		"router_monitor": map[string]interface{}{
+			"enabled":    globalConfig{}.Get().RouterMonitor.Enabled,
+			"up":         up,
+			"known":      known,
+			"down_since": downSince,
+		},
+		"rate_limit_queue": queues,
+		"router_timings":   timingSnapshot(),
+		"state_cache": map[string]interface{}{
+			"entries":  cached,
+			"inflight": inflight,
+		},
+		"plugins": plugins,
+	}
+	return resp
+}
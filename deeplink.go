@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"tplinkfirewalloff/internal/server"
+)
+
+// 预设的暴露目标，供 /apply 链接一键开放或关闭
+type Profile struct {
+	Name               string `json:"name"`
+	DmzDestIP          string `json:"dmz_dest_ip"`
+	DmzDestIP6         string `json:"dmz_dest_ip6"`
+	IPv6FirewallOnOpen string `json:"ipv6_firewall_on_open"` // 开放时IPv6防火墙的状态，默认 off
+	Ports              string `json:"ports"`                 // 需要开放的端口，如 "tcp:25565, udp:19132"，IPv4回退时使用
+	IPv4Fallback       string `json:"ipv4_fallback"`         // 没有公网IPv6时的回退方式：port_forward / upnp，留空不回退
+}
+
+// 链接支持的动作
+const (
+	actionOpen  = "open"  // 开启DMZ指向该主机
+	actionClose = "close" // 关闭DMZ并打开IPv6防火墙
+)
+
+// 启动时校验预设
+func validateProfiles() error {
+	seen := map[string]bool{}
+	for _, p := range config.Profiles {
+		if p.Name == "" || seen[p.Name] {
+			return fmt.Errorf("%s", tr("deeplink.bad_profile", p.Name))
+		}
+		seen[p.Name] = true
+		if p.DmzDestIP != "" && net.ParseIP(p.DmzDestIP).To4() == nil {
+			return fmt.Errorf("%s: dmz_dest_ip %q", p.Name, p.DmzDestIP)
+		}
+		if p.DmzDestIP6 != "" && net.ParseIP(p.DmzDestIP6) == nil {
+			return fmt.Errorf("%s: dmz_dest_ip6 %q", p.Name, p.DmzDestIP6)
+		}
+		if _, err := parsePorts(p.Ports); err != nil {
+			return fmt.Errorf("%s: %v", p.Name, err)
+		}
+		switch p.IPv4Fallback {
+		case "":
+		case pathPortForward, pathUPnP:
+			if p.Ports == "" || p.DmzDestIP == "" {
+				return fmt.Errorf("%s", tr("fallback.incomplete", p.Name))
+			}
+		default:
+			return fmt.Errorf("%s: ipv4_fallback %q", p.Name, p.IPv4Fallback)
+		}
+	}
+	registerSecret(config.ApplyToken)
+	return nil
+}
+
+func findProfile(name string) *Profile {
+	cfg := globalConfig{}.Get()
+	for i := range cfg.Profiles {
+		if cfg.Profiles[i].Name == name {
+			return &config.Profiles[i]
+		}
+	}
+	return nil
+}
+
+// 按预设和动作得到要写入路由器的设置
+func (p Profile) state(action string) (firewallState, bool) {
+	switch action {
+	case actionOpen:
+		fw := p.IPv6FirewallOnOpen
+		if fw == "" {
+			fw = "off"
+		}
+		return firewallState{IPv6FirewallEnable: fw, DmzEnable: "1", DmzDestIP: p.DmzDestIP, DmzDestIP6: p.DmzDestIP6}, true
+	case actionClose:
+		return firewallState{IPv6FirewallEnable: "on", DmzEnable: "0", DmzDestIP: p.DmzDestIP, DmzDestIP6: p.DmzDestIP6}, true
+	}
+	return firewallState{}, false
+}
+
+// 链接是否可以直接执行：携带正确的 apply_token，或以管理员身份通过
+// Authorization 头认证且不是跨站请求。浏览器会自动重发基本认证，
+// 从其他网站点开的链接因此需要在页面上再确认一次
+func deepLinkAuthorized(r *http.Request) bool {
+	cfg := globalConfig{}.Get()
+	// 与 requireAuth 的放行条件一致，令牌只从URL读取
+	if token := r.URL.Query().Get("token"); token != "" && cfg.ApplyToken != "" {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(cfg.ApplyToken)) == 1
+	}
+	u := currentUser(r)
+	return u != nil && u.Role == roleAdmin && r.Header.Get("Authorization") != "" && !server.CrossSite(r)
+}
+
+// /apply?profile=xbox&action=open：执行预设动作，便于书签或Stream Deck一键触发
+func applyLinkHandler(w http.ResponseWriter, r *http.Request) {
+	var form struct {
+		Profile string `form:"profile"`
+		Action  string `form:"action"`
+		Format  string `form:"format"` // json 时返回JSON
+	}
+	if err := bindForm(formOf(r), &form); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name, action := form.Profile, form.Action
+	profile := findProfile(name)
+	if profile == nil {
+		http.Error(w, tr("deeplink.unknown_profile", name), http.StatusNotFound)
+		return
+	}
+	desired, ok := profile.state(action)
+	if !ok {
+		http.Error(w, tr("deeplink.unknown_action", action), http.StatusBadRequest)
+		return
+	}
+
+	direct := deepLinkAuthorized(r)
+	// 已登录的管理员通过页面确认（POST）；跨站提交的表单已被 requireAuth 拒绝
+	confirmed := r.Method == http.MethodPost && canEdit(r) && authEnabled()
+	if !direct && !confirmed {
+		if r.Method == http.MethodGet && canEdit(r) && authEnabled() {
+			renderTemplate(w, http.StatusOK, "apply.html", map[string]interface{}{
+				"Profile": profile,
+				"Action":  action,
+				"State":   desired,
+			})
+			return
+		}
+		http.Error(w, tr("auth.required"), http.StatusUnauthorized)
+		return
+	}
+
+	path, err := applyProfileBy(*profile, action, actorOf(r))
+	msg := tr("deeplink.done", profile.Name, action)
+	if action == actionOpen {
+		msg = tr("deeplink.done_path", profile.Name, action, pathLabel(path))
+	}
+	if err != nil {
+		msg = tr("deeplink.failed", profile.Name, action, userMessage(err))
+	}
+	logf("%s\n", msg)
+
+	if form.Format == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		resp := map[string]interface{}{"ok": err == nil, "message": msg, "path": path}
+		if err != nil {
+			resp["error"] = err.Error()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(httpStatusFor(err))
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	if err != nil {
+		renderTemplate(w, httpStatusFor(err), "error.html", map[string]interface{}{
+			"Message": userMessage(err),
+			"Detail":  err.Error(),
+		})
+		return
+	}
+	warnings := []string{msg}
+	if path == pathIPv6 {
+		warnings = append(warnings, exposureWarnings()...)
+	}
+	renderTemplate(w, http.StatusOK, "success.html", map[string]interface{}{"Warnings": warnings})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"tplinkfirewalloff/internal/server"
+)
+
+// DHCP服务器设置所在的模块、节与地址保留表
+const (
+	dhcpModule  = "dhcpd"
+	dhcpSection = "udhcpd"
+	dhcpStatic  = "dhcp_static"
+)
+
+// DHCP服务器设置，只修改提交了的字段
+type dhcpSettings struct {
+	Enable    *string `form:"enable,lower"` // on/off
+	PoolStart *string `form:"pool_start"`   // 地址池起始
+	PoolEnd   *string `form:"pool_end"`     // 地址池结束
+	LeaseTime *string `form:"lease_time"`   // 租期（分钟）
+	Gateway   *string `form:"gateway"`      // 下发的网关，留空为路由器自身
+	PriDNS    *string `form:"pri_dns"`      // 首选DNS
+	SndDNS    *string `form:"snd_dns"`      // 备用DNS
+}
+
+var dhcpFields = server.Names(dhcpSettings{})
+
+// /dhcp 提交的表单
+type dhcpForm struct {
+	Op   string `form:"op"`
+	MAC  string `form:"mac"`
+	IP   string `form:"ip"`
+	Note string `form:"note"`
+	Name string `form:"name"` // 取消保留的条目
+	dhcpSettings
+}
+
+// 校验并整理表单中的DHCP设置，返回无效字段
+func dhcpSettingsFromForm(settings dhcpSettings) (map[string]interface{}, []string) {
+	submitted := server.Submitted(settings)
+	fields := map[string]interface{}{}
+	var invalid []string
+	for _, f := range dhcpFields {
+		v, ok := submitted[f]
+		if !ok {
+			continue
+		}
+		switch f {
+		case "enable":
+			ok = v == "on" || v == "off"
+		case "pool_start", "pool_end":
+			ok = net.ParseIP(v).To4() != nil
+		case "lease_time":
+			n, err := strconv.Atoi(v)
+			ok = err == nil && n >= 1 && n <= 2880
+		default:
+			// 网关与DNS可以留空
+			ok = v == "" || net.ParseIP(v).To4() != nil
+		}
+		if !ok {
+			invalid = append(invalid, f)
+			continue
+		}
+		fields[f] = v
+	}
+	start, _ := fields["pool_start"].(string)
+	end, _ := fields["pool_end"].(string)
+	if start != "" && end != "" && bytes.Compare(net.ParseIP(start).To4(), net.ParseIP(end).To4()) > 0 {
+		invalid = append(invalid, "pool_start > pool_end")
+	}
+	return fields, invalid
+}
+
+// /dhcp：DHCP服务器设置与地址保留
+func dhcpHandler(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{"CanEdit": canEdit(r), "Fields": dhcpFields}
+
+	if r.Method == http.MethodPost {
+		var form dhcpForm
+		if err := bindForm(formOf(r), &form); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var err error
+		var msg string
+		switch form.Op {
+		case "settings":
+			fields, invalid := dhcpSettingsFromForm(form.dhcpSettings)
+			if len(invalid) > 0 || len(fields) == 0 {
+				data["Error"] = tr("iptv.invalid", strings.Join(invalid, ", "))
+				break
+			}
+			err = setSection(dhcpModule, dhcpSection, fields)
+			msg = tr("dhcp.updated")
+		case "reserve":
+			mac, ok := normalizeMAC(form.MAC)
+			ip := net.ParseIP(form.IP).To4()
+			if !ok || ip == nil {
+				data["Error"] = tr("dhcp.bad_reservation")
+				break
+			}
+			err = addTableEntry(dhcpModule, dhcpStatic, map[string]interface{}{
+				"mac":  mac,
+				"ip":   ip.String(),
+				"note": form.Note,
+			})
+			msg = tr("dhcp.reserved", mac, ip)
+		case "delete":
+			err = deleteTableEntry(dhcpModule, dhcpStatic, form.Name)
+			msg = tr("dhcp.unreserved", form.Name)
+		default:
+			data["Error"] = tr("error.bad_parameter")
+		}
+		if err != nil {
+			data["Error"] = userMessage(err) + ": " + err.Error()
+		} else if msg != "" {
+			logf("%s\n", msg)
+			recordEvent("dhcp", msg, map[string]interface{}{"op": form.Op})
+			data["Result"] = msg
+		}
+	}
+
+	if st, err := queryRouter(dhcpModule, dhcpSection); err == nil {
+		data["Settings"] = st[dhcpSection]
+	} else if data["Error"] == nil {
+		data["Error"] = userMessage(err)
+	}
+	if reservations, err := queryTable(dhcpModule, dhcpStatic); err == nil {
+		data["Reservations"] = reservations
+	}
+	renderTemplate(w, http.StatusOK, "dhcp.html", data)
+}
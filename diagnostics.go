@@ -0,0 +1,246 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"tplinkfirewalloff/internal/eventstore"
+)
+
+// 版本号，发布时通过 -ldflags "-X main.version=v1.2.3" 设置
+var version = "dev"
+
+// 最近的路由器请求/响应，供诊断包使用，内容均已脱敏
+type routerExchange struct {
+	Time     time.Time `json:"time"`
+	URL      string    `json:"url"`
+	Request  string    `json:"request"`
+	Status   int       `json:"status,omitempty"`
+	Response string    `json:"response,omitempty"`
+	Code     *int      `json:"error_code,omitempty"` // 响应中的 error_code，往往是设置失败的唯一线索
+	Error    string    `json:"error,omitempty"`
+}
+
+const (
+	maxExchanges     = 20
+	maxExchangeBytes = 4096
+	diagHistoryLines = 500
+)
+
+var (
+	exchangesMu sync.Mutex
+	exchanges   []routerExchange
+)
+
+// 记录一次路由器交互，只保留最近 maxExchanges 条
+func recordExchange(url string, request []byte, status int, response []byte, err error) {
+	ex := routerExchange{
+		Time:     time.Now(),
+		URL:      redact(url),
+		Request:  truncate(redact(string(request)), maxExchangeBytes),
+		Status:   status,
+		Response: truncate(redact(string(response)), maxExchangeBytes),
+		Error:    redactErr(err),
+	}
+	var parsed struct {
+		ErrorCode *int `json:"error_code"`
+	}
+	if json.Unmarshal(response, &parsed) == nil {
+		ex.Code = parsed.ErrorCode
+	}
+	exchangesMu.Lock()
+	defer exchangesMu.Unlock()
+	exchanges = append(exchanges, ex)
+	if len(exchanges) > maxExchanges {
+		exchanges = exchanges[len(exchanges)-maxExchanges:]
+	}
+	appendRouterLog(ex)
+}
+
+// 配置了 router_log 时把每次交互追加到该文件，每行一个JSON，便于提交问题时附上
+func appendRouterLog(ex routerExchange) {
+	cfg := globalConfig{}.Get()
+	if cfg.RouterLog == "" {
+		return
+	}
+	line, err := json.Marshal(ex)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(cfg.RouterLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		warn("console.router_log_failed", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		warn("console.router_log_failed", err)
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "...(truncated)"
+}
+
+// 版本与运行环境
+func versionInfo() map[string]interface{} {
+	info := map[string]interface{}{
+		"version":    version,
+		"go_version": runtime.Version(),
+		"os":         runtime.GOOS,
+		"arch":       runtime.GOARCH,
+		"started_at": startedAt.Format(time.RFC3339),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			if s.Key == "vcs.revision" || s.Key == "vcs.time" || s.Key == "vcs.modified" {
+				info[s.Key] = s.Value
+			}
+		}
+	}
+	return info
+}
+
+// 去掉凭据后的配置，再整体脱敏一次兜底
+func redactedConfig() []byte {
+	c := config
+	c.Stok = maskSecret(c.Stok)
+	c.RouterPassword = maskSecret(c.RouterPassword)
+	c.Locations = append([]Location(nil), c.Locations...)
+	for i := range c.Locations {
+		c.Locations[i].Stok = maskSecret(c.Locations[i].Stok)
+	}
+	c.ApplyToken = maskSecret(c.ApplyToken)
+	c.OIDC.ClientSecret = maskSecret(c.OIDC.ClientSecret)
+	c.Agent.Token = maskSecret(c.Agent.Token)
+	c.Notify.TelegramBotToken = maskSecret(c.Notify.TelegramBotToken)
+	c.Notify.WebhookURL = maskSecret(c.Notify.WebhookURL)
+	c.Users = append([]UserAccount(nil), c.Users...)
+	for i := range c.Users {
+		c.Users[i].Password = maskSecret(c.Users[i].Password)
+	}
+	if len(c.Controller.Agents) > 0 {
+		agents := map[string]string{}
+		for name := range c.Controller.Agents {
+			agents[name] = redactedMark
+		}
+		c.Controller.Agents = agents
+	}
+	data, _ := json.MarshalIndent(c, "", "  ")
+	return []byte(redact(string(data)))
+}
+
+func maskSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	return redactedMark
+}
+
+// 诊断时探测的功能，与各页面使用的查询一致
+var capabilityProbes = []struct {
+	Name    string
+	Module  string
+	Section string
+	Table   bool
+}{
+	{"device_info", "device_info", "info", false},
+	{"dmz", "firewall", "dmz", false},
+	{"ipv6_firewall", "firewall", "ipv6_firewall", false},
+	{"ipv6_rule", "firewall", "ipv6_rule", true},
+	{"port_forward", "firewall", "redirect", true},
+	{"port_trigger", "firewall", triggerTable, true},
+	{"guest_network", guestModule, "guest_2g", false},
+	{"iptv", "iptv", "iptv", false},
+	{"wireless", "wireless", "wlan_host_2g", false},
+	{"access_control", "access_control", "rule", true},
+	{"static_route", routeModule, "static_route", true},
+	{"dhcp", "dhcpd", "udhcpd", false},
+	{"dhcp_static", "dhcpd", "dhcp_static", true},
+	{"time", timeModule, timeSection, false},
+	{"wan_ipv6", routeModule, "wan_ipv6", false},
+}
+
+// 逐项探测路由器支持的功能：ok / unsupported / 错误信息
+func probeCapabilities() map[string]string {
+	results := map[string]string{}
+	if !routerConfigured() {
+		return results
+	}
+	for _, p := range capabilityProbes {
+		var err error
+		if p.Table {
+			_, err = queryTable(p.Module, p.Section)
+		} else {
+			_, err = queryRouter(p.Module, p.Section)
+		}
+		switch {
+		case err == nil:
+			results[p.Name] = "ok"
+		case errors.Is(err, ErrUnsupportedFirmware):
+			results[p.Name] = "unsupported"
+		default:
+			results[p.Name] = redactErr(err)
+		}
+	}
+	return results
+}
+
+// GET /api/debug/bundle：下载诊断包（zip），附在问题报告中
+func diagnosticsHandler(w http.ResponseWriter, r *http.Request) {
+	events, err := queryHistory(eventstore.Query{Last: diagHistoryLines})
+	if err != nil {
+		events = nil
+	}
+	exchangesMu.Lock()
+	recent := append([]routerExchange(nil), exchanges...)
+	exchangesMu.Unlock()
+	snapshot, syncState := trackedSnapshot()
+
+	name := fmt.Sprintf("tplinkfirewalloff-diag-%s.zip", time.Now().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+
+	zw := zip.NewWriter(w)
+	add := func(file string, data []byte) {
+		f, err := zw.Create(file)
+		if err != nil {
+			return
+		}
+		f.Write(data)
+	}
+	addJSON := func(file string, v interface{}) {
+		data, _ := json.MarshalIndent(v, "", "  ")
+		add(file, []byte(redact(string(data))))
+	}
+
+	addJSON("version.json", versionInfo())
+	add("config.json", redactedConfig())
+	addJSON("state.json", map[string]interface{}{"sync": syncState, "state": snapshot})
+	addJSON("runtime.json", selfReport())
+	addJSON("router_exchanges.json", recent)
+	addJSON("capabilities.json", probeCapabilities())
+	var history []byte
+	for _, e := range events {
+		line, _ := json.Marshal(e)
+		history = append(history, line...)
+		history = append(history, '\n')
+	}
+	add("history.jsonl", []byte(redact(string(history))))
+	if err := zw.Close(); err != nil {
+		warn("console.diagnostics_failed", err)
+		return
+	}
+	recordEvent("diagnostics", tr("diag.exported"), nil)
+}
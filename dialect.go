@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// 水星（Mercury）与迅捷（FAST）固件由TP-LINK固件改来，登录与 /ds 接口相同，
+// 只是登录地址和部分节名、字段名不同；用改名表在请求与响应之间转换
+type vendorDialect struct {
+	loginPath string            // 登录请求的路径
+	sections  map[string]string // TP-LINK节名 -> 该厂商节名
+	fields    map[string]string // "节名.字段名"（TP-LINK） -> 该厂商字段名
+}
+
+var dialects = map[string]vendorDialect{
+	// 水星 MW/MAC 系列
+	"mercury": {
+		loginPath: "/",
+		sections:  map[string]string{"ipv6_firewall": "ipv6_fw"},
+		fields:    map[string]string{"dmz.dest_ip": "ipaddr", "dmz.dest_ip6": "ipv6addr"},
+	},
+	// 迅捷 FW/FAC 系列
+	"fast": {
+		loginPath: "/login",
+		sections:  map[string]string{},
+		fields:    map[string]string{"dmz.wan_port": "wan_id"},
+	},
+}
+
+// 按厂商改名表转换请求的后端
+func dialectClient(name string) dsClient {
+	d := dialects[name]
+	return dsClient{
+		loginPath: d.loginPath,
+		post: func(host, stok string, requestBody map[string]interface{}) ([]byte, error) {
+			return dialectDS(&d, host, stok, requestBody)
+		},
+		caps: fullCapabilities,
+	}
+}
+
+// 按方向取改名表，toVendor 时为TP-LINK名到厂商名，否则反过来；字段表的键与值都是“节名.字段名”
+func (d *vendorDialect) tables(toVendor bool) (sections, fields map[string]string) {
+	sections, fields = map[string]string{}, map[string]string{}
+	for tp, v := range d.sections {
+		if toVendor {
+			sections[tp] = v
+		} else {
+			sections[v] = tp
+		}
+	}
+	for tp, v := range d.fields {
+		section := tp[:strings.Index(tp, ".")]
+		if s, ok := d.sections[section]; ok {
+			section = s
+		}
+		vendor := section + "." + v
+		if toVendor {
+			fields[tp] = vendor
+		} else {
+			fields[vendor] = tp
+		}
+	}
+	return sections, fields
+}
+
+// 改写请求或响应中的节名与字段名，只处理 模块 -> 节 -> 字段 三层
+func (d *vendorDialect) rename(body map[string]interface{}, toVendor bool) map[string]interface{} {
+	sections, fields := d.tables(toVendor)
+	name := func(m map[string]string, key string) string {
+		if v, ok := m[key]; ok {
+			return v
+		}
+		return key
+	}
+	out := map[string]interface{}{}
+	for module, v := range body {
+		secs, ok := v.(map[string]interface{})
+		if !ok {
+			out[module] = v
+			continue
+		}
+		renamed := map[string]interface{}{}
+		for sec, sv := range secs {
+			switch x := sv.(type) {
+			case map[string]interface{}:
+				rf := map[string]interface{}{}
+				for f, fv := range x {
+					full := name(fields, sec+"."+f)
+					rf[full[strings.LastIndex(full, ".")+1:]] = fv
+				}
+				renamed[name(sections, sec)] = rf
+			case []interface{}:
+				// get 请求中的 {"name": [节名...]}
+				for i, n := range x {
+					if s, ok := n.(string); ok {
+						x[i] = name(sections, s)
+					}
+				}
+				renamed[sec] = x
+			default:
+				renamed[name(sections, sec)] = sv
+			}
+		}
+		out[module] = renamed
+	}
+	return out
+}
+
+// 转换后发送到 /ds，再把响应转换回TP-LINK的节名与字段名
+func dialectDS(d *vendorDialect, host, stok string, requestBody map[string]interface{}) ([]byte, error) {
+	// 统一为通用类型，[]string 等也能按 []interface{} 处理
+	var generic map[string]interface{}
+	json.Unmarshal(mustJSON(requestBody), &generic)
+	raw, err := postDS(host, stok, d.rename(generic, true))
+	if err != nil || raw == nil {
+		return raw, err
+	}
+	var resp map[string]interface{}
+	if json.Unmarshal(raw, &resp) != nil {
+		return raw, nil
+	}
+	return mustJSON(d.rename(resp, false)), nil
+}
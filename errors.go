@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"tplinkfirewalloff/pkg/tplink"
+)
+
+// 路由器客户端返回的错误类别，调用方用 errors.Is 判断
+var (
+	ErrAuthExpired         error = errorKind("kind.auth_expired")
+	ErrUnreachable         error = errorKind("kind.unreachable")
+	ErrUnsupportedFirmware error = errorKind("kind.unsupported")
+	ErrBadParameter        error = errorKind("kind.bad_parameter")
+	ErrRouter              error = errorKind("kind.router")
+	ErrCircuitOpen         error = errorKind("kind.circuit_open")
+	ErrRateLimited         error = errorKind("kind.rate_limited")
+	ErrMaintenance         error = errorKind("kind.maintenance")
+	ErrIdentityMismatch    error = errorKind("kind.identity_mismatch")
+)
+
+// 错误类别，值为翻译键，按当前语言输出
+type errorKind string
+
+func (k errorKind) Error() string {
+	return tr(string(k))
+}
+
+// 带上下文的路由器错误，Kind 为上面的错误类别之一
+type RouterError struct {
+	Kind   error
+	Code   int    // 路由器返回的 error_code，未知时为0
+	Detail string // 已脱敏的细节
+}
+
+func (e *RouterError) Error() string {
+	msg := e.Kind.Error()
+	if e.Code != 0 {
+		msg = fmt.Sprintf("%s (error_code=%d)", msg, e.Code)
+	}
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+func (e *RouterError) Unwrap() error {
+	return e.Kind
+}
+
+// 构造路由器错误，细节自动脱敏
+func routerErr(kind error, code int, detail string) error {
+	return &RouterError{Kind: kind, Code: code, Detail: redact(detail)}
+}
+
+// 路由器常见error_code
+const (
+	codeOK             = tplink.CodeOK
+	codeSystem         = tplink.CodeSystem
+	codeTableFull      = tplink.CodeTableFull
+	codeBadFormat      = tplink.CodeBadFormat
+	codeInvalidParam   = tplink.CodeInvalidParam
+	codeUnsupported    = tplink.CodeUnsupported
+	codeBadCredentials = tplink.CodeBadCredentials
+	codeLoginLocked    = tplink.CodeLoginLocked
+	codeUnauthorized   = tplink.CodeUnauthorized
+	codeSessionExpired = tplink.CodeSessionExpired
+)
+
+// error_code 对应的错误类别与面向用户的说明（含处理建议）
+var routerCodes = map[int]struct {
+	kind error
+	key  string
+}{
+	codeSystem:         {ErrRouter, "code.busy"},
+	codeTableFull:      {ErrRouter, "code.table_full"},
+	codeBadFormat:      {ErrUnsupportedFirmware, "code.bad_format"},
+	codeInvalidParam:   {ErrBadParameter, "code.invalid_param"},
+	codeUnsupported:    {ErrUnsupportedFirmware, "code.unsupported"},
+	codeBadCredentials: {ErrAuthExpired, "code.bad_credentials"},
+	codeLoginLocked:    {ErrAuthExpired, "code.login_locked"},
+	codeUnauthorized:   {ErrAuthExpired, "code.unauthorized"},
+	codeSessionExpired: {ErrAuthExpired, "code.unauthorized"},
+}
+
+// 路由器 error_code 到错误类别的映射
+func errorForCode(code int) error {
+	if code == codeOK {
+		return nil
+	}
+	if c, ok := routerCodes[code]; ok {
+		return routerErr(c.kind, code, "")
+	}
+	return routerErr(ErrRouter, code, "")
+}
+
+// 协议客户端返回的错误转换为上面的错误类别
+func tplinkErr(err error) error {
+	var (
+		codeErr   *tplink.Error
+		statusErr *tplink.StatusError
+		typeErr   *tplink.ContentTypeError
+		readErr   *tplink.ReadError
+	)
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, new(*RouterError)):
+		// 已经分类的错误，如经 callRouter 发送失败
+		return err
+	case errors.As(err, &codeErr):
+		return errorForCode(codeErr.Code)
+	case errors.As(err, &statusErr):
+		status := fmt.Sprintf("%d %s", statusErr.StatusCode, http.StatusText(statusErr.StatusCode))
+		switch statusErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return routerErr(ErrAuthExpired, 0, status)
+		case http.StatusNotFound:
+			return routerErr(ErrUnsupportedFirmware, 0, status)
+		}
+		return routerErr(ErrRouter, 0, status+" "+string(statusErr.Body))
+	case errors.As(err, &typeErr):
+		return routerErr(ErrUnsupportedFirmware, 0, tr("response.content_type", typeErr.ContentType))
+	case errors.As(err, &readErr):
+		return routerErr(ErrRouter, 0, tr("response.read_failed", readErr.Err))
+	case errors.Is(err, tplink.ErrBadResponse):
+		return routerErr(ErrUnsupportedFirmware, 0, err.Error())
+	}
+	return routerErr(ErrUnreachable, 0, err.Error())
+}
+
+// error_code 的说明，表中没有时为空
+func codeMessage(err error) string {
+	var re *RouterError
+	if !errors.As(err, &re) || re.Code == 0 {
+		return ""
+	}
+	if c, ok := routerCodes[re.Code]; ok {
+		return tr(c.key)
+	}
+	return tr("code.unknown", re.Code)
+}
+
+// 错误类别对应的HTTP状态码
+func httpStatusFor(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrBadParameter):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrAuthExpired):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrUnsupportedFirmware):
+		return http.StatusNotImplemented
+	case errors.Is(err, ErrUnreachable):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrCircuitOpen):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrMaintenance):
+		return http.StatusLocked
+	case errors.Is(err, ErrIdentityMismatch):
+		return http.StatusConflict
+	}
+	return http.StatusBadGateway
+}
+
+// 错误类别对应的进程退出码
+func exitCodeFor(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, ErrBadParameter):
+		return 2
+	case errors.Is(err, ErrAuthExpired):
+		return 3
+	case errors.Is(err, ErrUnreachable), errors.Is(err, ErrCircuitOpen), errors.Is(err, ErrRateLimited):
+		return 4
+	case errors.Is(err, ErrUnsupportedFirmware):
+		return 5
+	case errors.Is(err, ErrMaintenance):
+		return 6
+	case errors.Is(err, ErrIdentityMismatch):
+		return 7
+	}
+	return 1
+}
+
+// 错误类别对应的机器可读代码，用于JSON接口
+func errorCode(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrBadParameter):
+		return "bad_parameter"
+	case errors.Is(err, ErrAuthExpired):
+		return "auth_expired"
+	case errors.Is(err, ErrUnreachable):
+		return "unreachable"
+	case errors.Is(err, ErrUnsupportedFirmware):
+		return "unsupported_firmware"
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrMaintenance):
+		return "maintenance"
+	case errors.Is(err, ErrIdentityMismatch):
+		return "identity_mismatch"
+	}
+	return "router_error"
+}
+
+// 面向用户的说明和处理建议；路由器返回了 error_code 时按错误码说明
+func userMessage(err error) string {
+	if msg := codeMessage(err); msg != "" {
+		return msg
+	}
+	switch {
+	case err == nil:
+		return tr("error.ok")
+	case errors.Is(err, ErrAuthExpired):
+		return tr("error.auth_expired")
+	case errors.Is(err, ErrUnreachable):
+		return tr("error.unreachable")
+	case errors.Is(err, ErrUnsupportedFirmware):
+		return tr("error.unsupported")
+	case errors.Is(err, ErrBadParameter):
+		return tr("error.bad_parameter")
+	case errors.Is(err, ErrCircuitOpen):
+		return tr("error.circuit_open")
+	case errors.Is(err, ErrRateLimited):
+		return tr("error.rate_limited")
+	case errors.Is(err, ErrMaintenance):
+		return tr("error.maintenance")
+	case errors.Is(err, ErrIdentityMismatch):
+		return tr("error.identity_mismatch")
+	}
+	return tr("error.router")
+}
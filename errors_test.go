@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"tplinkfirewalloff/pkg/tplink"
+)
+
+func TestTPLinkErr(t *testing.T) {
+	cases := []struct {
+		err  error
+		kind error
+	}{
+		{&tplink.Error{Code: codeUnauthorized}, ErrAuthExpired},
+		{&tplink.Error{Code: codeSessionExpired}, ErrAuthExpired},
+		{&tplink.Error{Code: codeInvalidParam}, ErrBadParameter},
+		{&tplink.Error{Code: -1}, ErrRouter},
+		{&tplink.StatusError{StatusCode: 403}, ErrAuthExpired},
+		{&tplink.StatusError{StatusCode: 404}, ErrUnsupportedFirmware},
+		{&tplink.StatusError{StatusCode: 500}, ErrRouter},
+		{&tplink.ContentTypeError{ContentType: "image/png"}, ErrUnsupportedFirmware},
+		{&tplink.ReadError{Err: errors.New("eof")}, ErrRouter},
+		{fmt.Errorf("%w: x", tplink.ErrBadResponse), ErrUnsupportedFirmware},
+		{&url.Error{Op: "Post", URL: "http://192.168.1.1/", Err: errors.New("refused")}, ErrUnreachable},
+	}
+	for _, c := range cases {
+		if got := tplinkErr(c.err); !errors.Is(got, c.kind) {
+			t.Errorf("tplinkErr(%v) = %v, want %v", c.err, got, c.kind)
+		}
+	}
+	if tplinkErr(nil) != nil {
+		t.Error("tplinkErr(nil) 应为 nil")
+	}
+}
+
+func TestErrorForCode(t *testing.T) {
+	if errorForCode(codeOK) != nil {
+		t.Error("error_code 0 不是错误")
+	}
+	var re *RouterError
+	if err := errorForCode(codeTableFull); !errors.As(err, &re) || re.Code != codeTableFull {
+		t.Errorf("errorForCode = %v", err)
+	}
+}
+
+// 会话超时（-40404）与stok无效一样，用保存的密码重新登录后重试
+func TestSessionExpiredRelogin(t *testing.T) {
+	setupTest(t)
+	var logins int
+	router := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case r.URL.Path == "/" && strings.Contains(string(body), `"login"`):
+			logins++
+			w.Write([]byte(`{"stok":"fresh","error_code":0}`))
+		case r.URL.Path == "/stok=fresh/ds":
+			w.Write([]byte(`{"error_code":0}`))
+		default:
+			w.Write([]byte(`{"error_code":-40404}`))
+		}
+	}))
+	defer router.Close()
+	config.RouterIP = strings.TrimPrefix(router.URL, "http://")
+	config.Stok = "stale"
+	config.RouterPassword = "secret"
+
+	body, err := callRouter("get", map[string]interface{}{"method": "get"})
+	if err != nil || !strings.Contains(string(body), `"error_code":0`) {
+		t.Fatalf("callRouter = %s, %v", body, err)
+	}
+	if logins != 1 || currentStok() != "fresh" {
+		t.Errorf("logins=%d stok=%q", logins, currentStok())
+	}
+	if _, err := decodeRouterResponse([]byte(`{"error_code":-40404}`)); !errors.Is(err, ErrAuthExpired) {
+		t.Errorf("-40404 = %v, want ErrAuthExpired", err)
+	}
+}
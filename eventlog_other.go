@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+// 事件日志只在Windows上可用，其他系统使用 syslog
+func openEventLog(source string) (logSink, error) {
+	return nil, errors.New(tr("log.eventlog_unsupported"))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32                  = syscall.NewLazyDLL("advapi32.dll")
+	procRegisterEventSource   = advapi32.NewProc("RegisterEventSourceW")
+	procDeregisterEventSource = advapi32.NewProc("DeregisterEventSource")
+	procReportEvent           = advapi32.NewProc("ReportEventW")
+)
+
+// ReportEvent 的事件类型
+const (
+	eventlogErrorType       = 0x0001
+	eventlogWarningType     = 0x0002
+	eventlogInformationType = 0x0004
+)
+
+// 写入Windows“应用程序”事件日志。来源未在注册表中登记消息文件时，
+// 事件查看器会提示找不到描述，但仍会显示日志内容
+type eventLogSink struct {
+	handle uintptr
+}
+
+func openEventLog(source string) (logSink, error) {
+	name, err := syscall.UTF16PtrFromString(source)
+	if err != nil {
+		return nil, err
+	}
+	h, _, err := procRegisterEventSource.Call(0, uintptr(unsafe.Pointer(name)))
+	if h == 0 {
+		return nil, err
+	}
+	return &eventLogSink{handle: h}, nil
+}
+
+func (e *eventLogSink) Log(l logLevel, msg string) error {
+	etype := eventlogInformationType
+	switch l {
+	case levelDebug:
+		// 调试日志量大，不写入事件日志
+		return nil
+	case levelWarn:
+		etype = eventlogWarningType
+	case levelError:
+		etype = eventlogErrorType
+	}
+	text, err := syscall.UTF16PtrFromString(msg)
+	if err != nil {
+		return err
+	}
+	strs := []*uint16{text}
+	r, _, err := procReportEvent.Call(e.handle, uintptr(etype), 0, 1, 0, 1, 0, uintptr(unsafe.Pointer(&strs[0])), 0)
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+func (e *eventLogSink) Close() error {
+	procDeregisterEventSource.Call(e.handle)
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 公网暴露后需要特别注意的常见服务端口
+var auditPorts = []struct {
+	Port    int
+	Service string
+	Risky   bool // 此类服务暴露到公网风险很高
+}{
+	{21, "FTP", true},
+	{22, "SSH", false},
+	{23, "Telnet", true},
+	{80, "HTTP", false},
+	{135, "RPC", true},
+	{139, "NetBIOS", true},
+	{443, "HTTPS", false},
+	{445, "SMB", true},
+	{1433, "MSSQL", true},
+	{3306, "MySQL", true},
+	{3389, "RDP", true},
+	{5900, "VNC", true},
+	{6379, "Redis", true},
+	{8080, "HTTP-alt", false},
+	{27017, "MongoDB", true},
+}
+
+// 一个开放端口的发现结果
+type exposureFinding struct {
+	Host    string
+	Port    int
+	Service string
+	Risky   bool
+}
+
+// 扫描DMZ目标主机的常见端口，返回开放的端口
+func auditExposure(hosts []string, timeout time.Duration) []exposureFinding {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		findings []exposureFinding
+	)
+	for _, host := range hosts {
+		if host == "" {
+			continue
+		}
+		for _, p := range auditPorts {
+			wg.Add(1)
+			go func(host string, port int, service string, risky bool) {
+				defer wg.Done()
+				conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), timeout)
+				if err != nil {
+					return
+				}
+				conn.Close()
+				mu.Lock()
+				findings = append(findings, exposureFinding{host, port, service, risky})
+				mu.Unlock()
+			}(host, p.Port, p.Service, p.Risky)
+		}
+	}
+	wg.Wait()
+
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Host != findings[j].Host {
+			return findings[i].Host < findings[j].Host
+		}
+		return findings[i].Port < findings[j].Port
+	})
+	return findings
+}
+
+// 设置完成后检查DMZ目标的暴露面，返回需要提示给用户的警告
+func exposureWarnings() []string {
+	cfg := globalConfig{}.Get()
+	if !cfg.ExposureAudit || cfg.DmzEnable != "1" {
+		return nil
+	}
+
+	var warnings []string
+	for _, f := range auditExposure([]string{cfg.DmzDestIP, cfg.DmzDestIP6}, time.Second) {
+		key := "warn.exposed_port"
+		if f.Risky {
+			key = "warn.exposed_risky_port"
+		}
+		msg := tr(key, net.JoinHostPort(f.Host, strconv.Itoa(f.Port)), f.Service)
+		say("console.exposure", msg)
+		warnings = append(warnings, msg)
+	}
+	return warnings
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// 预设开放时实际使用的方式
+const (
+	pathIPv6        = "ipv6"         // 关闭IPv6防火墙并开启DMZ
+	pathPortForward = "port_forward" // IPv4端口转发规则
+	pathUPnP        = "upnp"         // UPnP IPv4端口映射
+)
+
+// 回退时添加的端口转发规则名前缀，关闭时据此删除
+const fallbackRulePrefix = "tplinkoff_"
+
+// WAN口是否有可用的公网IPv6；固件不提供WAN信息或查询失败时按可用处理，
+// 由后续的设置请求报告真正的错误
+func publicIPv6Available() bool {
+	st, err := queryRouter(routeModule, "wan_ipv6")
+	if err != nil {
+		return true
+	}
+	addr, _ := st["wan_ipv6"]["ip6addr"].(string)
+	ip := net.ParseIP(strings.SplitN(addr, "/", 2)[0])
+	// 链路本地与ULA地址无法从公网访问
+	return ip != nil && ip.To4() == nil && ip.IsGlobalUnicast() && !isULA(ip)
+}
+
+func isULA(ip net.IP) bool {
+	return len(ip) == net.IPv6len && ip[0]&0xfe == 0xfc
+}
+
+// 执行预设动作，返回实际使用的方式。开放时若没有可用的公网IPv6且配置了
+// ipv4_fallback，改为按端口添加IPv4端口转发或UPnP映射；关闭时一并删除回退规则
+func applyProfile(p Profile, action string) (string, error) {
+	return applyProfileBy(p, action, "")
+}
+
+// 同 applyProfile，actor 记入修改历史
+func applyProfileBy(p Profile, action, actor string) (string, error) {
+	desired, ok := p.state(action)
+	if !ok {
+		return "", routerErr(ErrBadParameter, 0, tr("deeplink.unknown_action", action))
+	}
+	if action == actionClose {
+		if p.IPv4Fallback != "" {
+			removeFallback(p)
+		}
+	} else if p.IPv4Fallback != "" && !publicIPv6Available() {
+		msg := tr("fallback.no_ipv6", p.Name, pathLabel(p.IPv4Fallback))
+		logf("%s\n", msg)
+		err := openFallback(p)
+		recordEvent("ipv4_fallback", msg, map[string]interface{}{
+			"profile": p.Name,
+			"path":    p.IPv4Fallback,
+			"ports":   p.Ports,
+			"success": err == nil,
+		})
+		return p.IPv4Fallback, err
+	}
+
+	_, err := applyDesired(desired, sourceUser, actor, allSections)
+	return pathIPv6, err
+}
+
+func fallbackRuleName(p Profile, port portSpec) string {
+	return fmt.Sprintf("%s%s_%s%d", fallbackRulePrefix, p.Name, port.Proto, port.Port)
+}
+
+// 按预设的端口添加IPv4端口转发或UPnP映射，已存在的规则不重复添加
+func openFallback(p Profile) error {
+	ports, err := parsePorts(p.Ports)
+	if err != nil {
+		return err
+	}
+	switch p.IPv4Fallback {
+	case pathPortForward:
+		existing, err := queryTable("firewall", "redirect")
+		if err != nil {
+			return err
+		}
+		for _, port := range ports {
+			name := fallbackRuleName(p, port)
+			if hasEntry(existing, name) {
+				continue
+			}
+			err := addTableEntry("firewall", "redirect", map[string]interface{}{
+				"name":            name,
+				"enable":          "on",
+				"proto":           port.Proto,
+				"src_dport_start": strconv.Itoa(port.Port),
+				"src_dport_end":   strconv.Itoa(port.Port),
+				"dest_ip":         p.DmzDestIP,
+				"dest_port":       strconv.Itoa(port.Port),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	case pathUPnP:
+		for _, port := range ports {
+			if err := addUPnPMapping(p.DmzDestIP, port, 0, "tplinkfirewalloff "+p.Name); err != nil {
+				return routerErr(ErrUnsupportedFirmware, 0, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// 删除回退时添加的规则，失败只记录日志
+func removeFallback(p Profile) {
+	ports, _ := parsePorts(p.Ports)
+	switch p.IPv4Fallback {
+	case pathPortForward:
+		existing, err := queryTable("firewall", "redirect")
+		if err != nil {
+			sayDebug("debug.forwards_failed", err)
+			return
+		}
+		for _, port := range ports {
+			if name := fallbackRuleName(p, port); hasEntry(existing, name) {
+				if err := deleteTableEntry("firewall", "redirect", name); err != nil {
+					sayDebug("debug.forward_delete_failed", name, err)
+				}
+			}
+		}
+	case pathUPnP:
+		for _, port := range ports {
+			if err := deleteUPnPMapping(port); err != nil {
+				sayDebug("debug.upnp_delete_failed", port, err)
+			}
+		}
+	}
+}
+
+func hasEntry(entries []tableEntry, name string) bool {
+	for _, e := range entries {
+		if entryString(e, "name") == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 回退方式的说明，用于结果提示
+func pathLabel(path string) string {
+	return tr("fallback.path." + path)
+}
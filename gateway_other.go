@@ -0,0 +1,63 @@
+//go:build !windows
+
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// 读取 /proc/net/route 中的默认路由网关（仅Linux）
+func defaultGateways() ([]string, error) {
+	data, err := os.ReadFile("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	var gateways []string
+	for _, line := range strings.Split(string(data), "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != 4 {
+			continue
+		}
+		// 内核以主机字节序（小端）输出
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(raw))
+		if !ip.IsUnspecified() {
+			gateways = append(gateways, ip.String())
+		}
+	}
+	return gateways, nil
+}
+
+// 从 /proc/net/arp 中取网关的MAC地址（仅Linux）
+func gatewayMAC(ip string) (string, error) {
+	data, err := os.ReadFile("/proc/net/arp")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) >= 4 && fields[0] == ip {
+			return fields[3], nil
+		}
+	}
+	return "", fmt.Errorf("%s", tr("gateway.not_in_arp", ip))
+}
+
+// 通过 iwgetid 取当前SSID，未连接无线时为空
+func currentSSID() (string, error) {
+	out, err := exec.Command("iwgetid", "-r").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
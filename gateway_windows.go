@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+// 解析 route print 输出中目标为 0.0.0.0 的路由的网关
+func defaultGateways() ([]string, error) {
+	out, err := exec.Command("route", "print", "-4", "0.0.0.0").Output()
+	if err != nil {
+		return nil, err
+	}
+	var gateways []string
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[0] == "0.0.0.0" && fields[1] == "0.0.0.0" {
+			if ip := net.ParseIP(fields[2]); ip != nil && ip.To4() != nil {
+				gateways = append(gateways, fields[2])
+			}
+		}
+	}
+	return gateways, nil
+}
+
+// 从 arp -a 的输出中取网关的MAC地址
+func gatewayMAC(ip string) (string, error) {
+	out, err := exec.Command("arp", "-a", ip).Output()
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == ip {
+			return fields[1], nil
+		}
+	}
+	return "", fmt.Errorf("%s", tr("gateway.not_in_arp", ip))
+}
+
+// 从 netsh wlan show interfaces 的输出中取当前SSID，未连接无线时为空
+func currentSSID() (string, error) {
+	out, err := exec.Command("netsh", "wlan", "show", "interfaces").Output()
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if ok && strings.TrimSpace(key) == "SSID" {
+			return strings.TrimSpace(value), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"tplinkfirewalloff/internal/server"
+)
+
+// 访客网络所在模块与各频段的节名，单频路由器没有 guest_5g
+const guestModule = "guest_network"
+
+var guestBands = []string{"guest_2g", "guest_5g"}
+
+// 可通过本工具修改的访客网络选项，取值均为 on/off，留空时不修改
+type guestSettings struct {
+	Enable    *string `form:"enable,lower"`     // 启用访客网络
+	Isolate   *string `form:"isolate,lower"`    // 访客设备之间互相隔离
+	AccessLAN *string `form:"access_lan,lower"` // 允许访客访问主网络（内网）
+}
+
+var guestOptions = server.Names(guestSettings{})
+
+// /guest 提交的表单
+type guestForm struct {
+	Band string `form:"band"`
+	guestSettings
+}
+
+// 读取各频段访客网络设置，固件不支持的频段跳过
+func queryGuestNetwork() (sectionState, error) {
+	out := sectionState{}
+	for _, band := range guestBands {
+		st, err := queryRouter(guestModule, band)
+		if errors.Is(err, ErrUnsupportedFirmware) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out[band] = st[band]
+	}
+	if len(out) == 0 {
+		return nil, routerErr(ErrUnsupportedFirmware, 0, tr("guest.unsupported"))
+	}
+	return out, nil
+}
+
+// 修改某个频段的访客网络选项
+func setGuestNetwork(band string, fields map[string]interface{}) error {
+	return setSection(guestModule, band, fields)
+}
+
+// 访客网络是否允许访问内网；DMZ暴露主机时应关闭
+func guestCanReachLAN(st sectionState) []string {
+	var bands []string
+	for band, fields := range st {
+		if fields["enable"] == "on" && fields["access_lan"] == "on" {
+			bands = append(bands, band)
+		}
+	}
+	return bands
+}
+
+// /guest：查看并修改访客网络隔离设置
+func guestHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := globalConfig{}.Get()
+	data := map[string]interface{}{"CanEdit": canEdit(r), "Options": guestOptions}
+
+	if r.Method == http.MethodPost {
+		var form guestForm
+		if err := bindForm(formOf(r), &form); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		band := form.Band
+		valid := false
+		for _, b := range guestBands {
+			valid = valid || b == band
+		}
+		submitted := server.Submitted(form.guestSettings)
+		fields := map[string]interface{}{}
+		for _, opt := range guestOptions {
+			v := submitted[opt]
+			if v == "" {
+				continue
+			}
+			if v != "on" && v != "off" {
+				valid = false
+			}
+			fields[opt] = v
+		}
+		if !valid || len(fields) == 0 {
+			data["Error"] = tr("error.bad_parameter")
+			renderTemplate(w, http.StatusBadRequest, "guest.html", data)
+			return
+		}
+		if err := setGuestNetwork(band, fields); err != nil {
+			data["Error"] = userMessage(err) + ": " + err.Error()
+			renderTemplate(w, httpStatusFor(err), "guest.html", data)
+			return
+		}
+		recordEvent("guest_network", tr("guest.updated", band), map[string]interface{}{"band": band, "fields": fields})
+		data["Result"] = tr("guest.updated", band)
+	}
+
+	st, err := queryGuestNetwork()
+	if err != nil {
+		data["Error"] = userMessage(err)
+	}
+	data["Bands"] = st
+	if cfg.DmzEnable == "1" {
+		data["LANWarning"] = guestCanReachLAN(st)
+	}
+	renderTemplate(w, http.StatusOK, "guest.html", data)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// 无界面模式的命令行参数，未指定的项使用配置文件中的值
+// 路由器地址、stok、防火墙与DMZ（--ipv6-firewall、--dmz、--dmz-ip 等）由 registerConfigFlags 注册，
+// 读取配置时已写入 config
+type headlessFlags struct {
+	profile *string // --preset：profiles 中的预设；--profile 用于选择路由器
+	action  *string
+	dryRun  *bool
+	onlyFW  *bool
+	onlyDMZ *bool
+	all     *bool
+}
+
+func registerHeadlessFlags(fs *flag.FlagSet) headlessFlags {
+	return headlessFlags{
+		profile: fs.String("preset", "", tr("flag.preset")),
+		action:  fs.String("action", actionOpen, tr("flag.ctl_action")),
+		dryRun:  fs.Bool("dry-run", false, tr("flag.dry_run")),
+		onlyFW:  fs.Bool("only-firewall", false, tr("flag.only_firewall")),
+		onlyDMZ: fs.Bool("only-dmz", false, tr("flag.only_dmz")),
+		all:     fs.Bool("all-routers", false, tr("flag.all_routers")),
+	}
+}
+
+// 设置一次，失败时在错误前加上面向用户的说明，退出码仍按错误类别
+func applyOnce(f headlessFlags) error {
+	if err := runHeadless(f); err != nil {
+		return fmt.Errorf("%s: %w", userMessage(err), err)
+	}
+	return nil
+}
+
+// 发送一次设置后退出，不启动网页界面；管理员密码可通过 TPLINK_ROUTER_PASSWORD 提供
+func runHeadless(f headlessFlags) error {
+	if *f.all {
+		return runApplyAll(sourceCtl)
+	}
+	if !routerConfigured() && !(*f.dryRun && config.RouterIP != "") {
+		return routerErr(ErrBadParameter, 0, tr("headless.no_router"))
+	}
+
+	if *f.profile != "" {
+		profile := findProfile(*f.profile)
+		if profile == nil {
+			return routerErr(ErrBadParameter, 0, tr("deeplink.unknown_profile", *f.profile))
+		}
+		if *f.dryRun {
+			desired, ok := profile.state(*f.action)
+			if !ok {
+				return routerErr(ErrBadParameter, 0, tr("deeplink.unknown_action", *f.action))
+			}
+			printPreview(config.RouterIP, config.Stok, desired, allSections)
+			return nil
+		}
+		path, err := applyProfile(*profile, *f.action)
+		if err != nil {
+			return err
+		}
+		say("deeplink.done_path", profile.Name, *f.action, pathLabel(path))
+		return nil
+	}
+
+	// 只修改一部分时另一部分不发送给路由器
+	sections := sectionsFor(*f.onlyFW, *f.onlyDMZ)
+	desired := desiredFromConfig()
+	if desired.IPv6FirewallEnable != "on" && desired.IPv6FirewallEnable != "off" {
+		return routerErr(ErrBadParameter, 0, "ipv6_firewall_enable="+desired.IPv6FirewallEnable)
+	}
+	if desired.DmzEnable != "0" && desired.DmzEnable != "1" {
+		return routerErr(ErrBadParameter, 0, "dmz_enable="+desired.DmzEnable)
+	}
+
+	if *f.dryRun {
+		printPreview(config.RouterIP, config.Stok, desired, sections)
+		return nil
+	}
+	changed, err := applyDesired(desired, sourceCtl, "", sections)
+	if err != nil {
+		return err
+	}
+	d := desiredFromConfig()
+	if !changed {
+		say("apply.unchanged_state", d.IPv6FirewallEnable, d.DmzEnable, d.DmzDestIP, d.DmzDestIP6)
+		return nil
+	}
+	say("headless.applied", d.IPv6FirewallEnable, d.DmzEnable, d.DmzDestIP, d.DmzDestIP6)
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// 最近一次路由器请求的时间与是否成功，取各类操作中最新的一次
+func lastRouterContact() (at time.Time, ok bool) {
+	for _, t := range timingSnapshot() {
+		if t.LastAt.After(at) {
+			at, ok = t.LastAt, !t.LastFail
+		}
+	}
+	return at, ok
+}
+
+// 健康检查的内容：服务运行情况、最近一次路由器请求结果与最近一次成功修改的时间
+func healthReport() (map[string]interface{}, bool) {
+	contactAt, contactOK := lastRouterContact()
+	breakerState, _ := breaker.State()
+	s, sync := trackedSnapshot()
+
+	router := map[string]interface{}{
+		"contacted":       !contactAt.IsZero(),
+		"ok":              contactOK,
+		"circuit_breaker": breakerState,
+	}
+	if !contactAt.IsZero() {
+		router["last_contact_at"] = contactAt.Format(time.RFC3339)
+	}
+	resp := map[string]interface{}{
+		"status":         "ok",
+		"started_at":     startedAt.Format(time.RFC3339),
+		"uptime_seconds": int(time.Since(startedAt).Seconds()),
+		"router":         router,
+		"sync":           sync,
+	}
+	if !s.DesiredAt.IsZero() {
+		resp["last_successful_apply_at"] = s.DesiredAt.Format(time.RFC3339)
+	}
+	if s.LastApplyError != "" {
+		resp["last_apply_error"] = s.LastApplyError
+	}
+	// 还没有访问过路由器时视为就绪，避免刚启动就被判定为不健康
+	ready := (contactAt.IsZero() || contactOK) && breakerState == "closed"
+	if !ready {
+		resp["status"] = "router_unavailable"
+	}
+	return resp, ready
+}
+
+// /healthz：存活检查，服务在运行即返回200
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	resp, _ := healthReport()
+	writeHealth(w, http.StatusOK, resp)
+}
+
+// /readyz：就绪检查，最近一次路由器请求失败或熔断时返回503
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	resp, ready := healthReport()
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeHealth(w, status, resp)
+}
+
+func writeHealth(w http.ResponseWriter, status int, resp map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(resp)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"tplinkfirewalloff/internal/eventstore"
+)
+
+// 历史事件，以JSON保存在 history_file 事件库中
+type historyEvent struct {
+	Time    time.Time              `json:"time"`
+	Kind    string                 `json:"kind"` // router_down / router_up ...
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+var (
+	historyMu   sync.Mutex
+	historyDB   *eventstore.Store
+	historyPath string
+)
+
+// 打开 history_file 对应的事件库，未配置时返回 nil；路径变化后重新打开
+func openHistory() (*eventstore.Store, error) {
+	path := globalConfig{}.Get().HistoryFile
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	if historyDB != nil && historyPath == path {
+		return historyDB, nil
+	}
+	if historyDB != nil {
+		historyDB.Close()
+		historyDB, historyPath = nil, ""
+	}
+	if path == "" {
+		return nil, nil
+	}
+	if err := migrateHistory(path); err != nil {
+		return nil, err
+	}
+	db, err := eventstore.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	historyDB, historyPath = db, path
+	return db, nil
+}
+
+// 导入旧版本每行一个JSON的历史文件：history_file 本身是旧格式，
+// 或者事件库还不存在而同名的 .jsonl 文件存在（旧的默认 history.jsonl）。
+// 先导入到临时事件库再替换，旧文件保留为 .bak
+func migrateHistory(path string) error {
+	src := ""
+	if ok, err := eventstore.Recognized(path); err != nil {
+		return err
+	} else if !ok {
+		src = path
+	} else if _, err := os.Stat(path); os.IsNotExist(err) {
+		legacy := strings.TrimSuffix(path, filepath.Ext(path)) + ".jsonl"
+		if _, err := os.Stat(legacy); err == nil && legacy != path {
+			src = legacy
+		}
+	}
+	if src == "" {
+		return nil
+	}
+
+	tmp := path + ".import"
+	os.Remove(tmp)
+	defer os.Remove(tmp + ".lock")
+	db, err := eventstore.Open(tmp)
+	if err != nil {
+		return err
+	}
+	err = importHistory(db, src)
+	db.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(src, src+".bak"); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	say("console.history_migrated", src, path)
+	return nil
+}
+
+func importHistory(db *eventstore.Store, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var e historyEvent
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		if _, err := db.Append(e.Kind, e.Time, append([]byte(nil), scanner.Bytes()...)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// 追加一条历史事件，写入失败只记录日志，不影响主流程；
+// 事件数超过 history_max_events 的 1.25 倍时压缩到最新的 history_max_events 条
+func recordEvent(kind, message string, fields map[string]interface{}) {
+	event := historyEvent{Time: time.Now(), Kind: kind, Message: redact(message), Fields: fields}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	db, err := openHistory()
+	if err != nil {
+		warn("console.history_failed", err)
+		return
+	}
+	if db == nil {
+		return
+	}
+	if _, err := db.Append(kind, event.Time, line); err != nil {
+		warn("console.history_failed", err)
+		return
+	}
+	max := globalConfig{}.Get().HistoryMaxEvents
+	if max <= 0 {
+		return
+	}
+	if n, err := db.Len(); err == nil && n > max+max/4 {
+		if err := db.Compact(max); err != nil {
+			warn("console.history_failed", err)
+		}
+	}
+}
+
+// 记录一次设置操作：设置的路由器、请求的值、路由器的响应与结果
+func recordApplyEventFor(routerIP string, desired firewallState, source, actor string, elapsed time.Duration, response string, applyErr error) {
+	fields := map[string]interface{}{
+		"source":               source,
+		"actor":                actor,
+		"success":              applyErr == nil,
+		"duration_ms":          elapsed.Milliseconds(),
+		"router_ip":            routerIP,
+		"ipv6_firewall_enable": desired.IPv6FirewallEnable,
+		"dmz_enable":           desired.DmzEnable,
+		"dmz_dest_ip":          desired.DmzDestIP,
+		"dmz_dest_ip6":         desired.DmzDestIP6,
+	}
+	msg := userMessage(applyErr)
+	if applyErr != nil {
+		fields["error"] = redact(applyErr.Error())
+	}
+	if response != "" {
+		fields["router_response"] = truncate(redact(response), maxExchangeBytes)
+	}
+	recordEvent("apply", msg, fields)
+}
+
+// 读取全部历史事件，按时间顺序返回
+func readHistory() ([]historyEvent, error) {
+	return queryHistory(eventstore.Query{})
+}
+
+// 按条件读取历史事件，按时间顺序返回
+func queryHistory(q eventstore.Query) ([]historyEvent, error) {
+	db, err := openHistory()
+	if db == nil || err != nil {
+		return nil, err
+	}
+	recs, err := db.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]historyEvent, 0, len(recs))
+	for _, rec := range recs {
+		var e historyEvent
+		if json.Unmarshal(rec.Data, &e) == nil {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// 修改记录：历史事件中 kind=apply 的条目，按时间倒序，最多 limit 条
+func applyHistory(limit int) ([]historyEvent, error) {
+	events, err := queryHistory(eventstore.Query{Kind: "apply", Last: limit})
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
+
+// 一条修改记录的字段值，缺失时为空
+func (e historyEvent) Field(name string) string {
+	if v, ok := e.Fields[name]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// /history：最近的修改记录，包括来源、发起人、设置值与路由器响应
+func historyPageHandler(w http.ResponseWriter, r *http.Request) {
+	var query struct {
+		Limit int `form:"limit"`
+	}
+	if err := bindForm(formOf(r), &query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 200
+	}
+	events, err := applyHistory(limit)
+	if err != nil {
+		http.Error(w, redact(err.Error()), http.StatusInternalServerError)
+		return
+	}
+	renderTemplate(w, http.StatusOK, "history.html", map[string]interface{}{"Events": events, "Enabled": globalConfig{}.Get().HistoryFile != ""})
+}
+
+// history：在命令行列出最近的修改记录
+func runHistory(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	configPath := fs.String("config", "config.json", tr("flag.config"))
+	registerLogFlags(fs)
+	limit := fs.Int("limit", 20, tr("flag.history_limit"))
+	asJSON := fs.Bool("json", false, tr("flag.json"))
+	registerConfigFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := readConfig(*configPath); err != nil {
+		return err
+	}
+	if err := configureLogging(); err != nil {
+		return routerErr(ErrBadParameter, 0, err.Error())
+	}
+	events, err := applyHistory(*limit)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(events)
+	}
+	for _, e := range events {
+		result := "ok"
+		if e.Field("success") != "true" {
+			result = "FAIL"
+		}
+		actor := e.Field("actor")
+		if actor == "" {
+			actor = "-"
+		}
+		fmt.Printf("%s  %-9s %-15s %-4s ipv6_firewall=%s dmz=%s %s %s  %s\n",
+			e.Time.Local().Format("2006-01-02 15:04:05"), e.Field("source"), actor, result,
+			e.Field("ipv6_firewall_enable"), e.Field("dmz_enable"), e.Field("dmz_dest_ip"), e.Field("dmz_dest_ip6"), e.Message)
+	}
+	return nil
+}
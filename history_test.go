@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHistoryImportsLegacyFile(t *testing.T) {
+	setupTest(t)
+	dir := t.TempDir()
+	legacy := `{"time":"2024-01-01T00:00:00Z","kind":"apply","message":"a","fields":{"source":"web"}}` + "\n" +
+		`{"time":"2024-01-01T00:01:00Z","kind":"login","message":"b"}` + "\n" +
+		`不是JSON` + "\n" +
+		`{"time":"2024-01-01T00:02:00Z","kind":"apply","message":"c"}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "history.jsonl"), []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config.HistoryFile = filepath.Join(dir, "history.db")
+	config.HistoryMaxEvents = 4
+	t.Cleanup(func() {
+		config.HistoryFile = ""
+		openHistory()
+	})
+
+	events, err := applyHistory(10)
+	if err != nil || len(events) != 2 || events[0].Message != "c" || events[1].Field("source") != "web" {
+		t.Fatalf("applyHistory = %+v, %v", events, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "history.jsonl.bak")); err != nil {
+		t.Errorf("旧文件未保留: %v", err)
+	}
+
+	// 超过 history_max_events 的 1.25 倍后压缩
+	for i := 0; i < 3; i++ {
+		recordEvent("apply", "new", nil)
+	}
+	all, _ := readHistory()
+	if len(all) != 4 || all[0].Message != "c" || all[3].Message != "new" {
+		t.Errorf("压缩后 = %+v", all)
+	}
+}
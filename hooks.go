@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// 应用设置前后执行的用户命令
+type HooksConfig struct {
+	PreApply  []string `json:"pre_apply"`  // 任一命令失败则取消本次设置
+	PostApply []string `json:"post_apply"` // 设置完成后执行，无论成功与否
+	Timeout   string   `json:"timeout"`    // 单条命令超时，默认 30s
+}
+
+// 传给钩子的状态，同时以JSON写入命令的标准输入
+type hookPayload struct {
+	Phase              string `json:"phase"` // pre_apply / post_apply
+	RouterIP           string `json:"router_ip"`
+	IPv6FirewallEnable string `json:"ipv6_firewall_enable"`
+	DmzEnable          string `json:"dmz_enable"`
+	DmzDestIP          string `json:"dmz_dest_ip"`
+	DmzDestIP6         string `json:"dmz_dest_ip6"`
+	Success            bool   `json:"success"`
+	Error              string `json:"error,omitempty"`
+}
+
+// 依次执行某阶段的全部钩子；p 为要设置的路由器及其期望设置，applyErr 为设置结果，仅 post_apply 使用
+func runHooks(phase string, commands []string, p RouterProfile, applyErr error) error {
+	if len(commands) == 0 && len(pluginsOf(pluginHook)) == 0 {
+		return nil
+	}
+
+	timeout, err := time.ParseDuration(globalConfig{}.Get().Hooks.Timeout)
+	if err != nil || timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	payload := hookPayload{
+		Phase:              phase,
+		RouterIP:           p.RouterIP,
+		IPv6FirewallEnable: p.IPv6FirewallEnable,
+		DmzEnable:          p.DmzEnable,
+		DmzDestIP:          p.DmzDestIP,
+		DmzDestIP6:         p.DmzDestIP6,
+		Success:            phase == "post_apply" && applyErr == nil,
+	}
+	if applyErr != nil {
+		payload.Error = redact(applyErr.Error())
+	}
+	stdin, _ := json.Marshal(payload)
+
+	env := hookEnv(payload)
+	for _, command := range commands {
+		if err := runHook(command, env, stdin, timeout); err != nil {
+			return fmt.Errorf("%s", tr("hook.failed", phase, command, err))
+		}
+	}
+	return runPluginHooks(payload)
+}
+
+// 状态变量的前缀，与覆盖配置的 TPLINK_ 变量区分
+const hookEnvPrefix = envPrefix + "HOOK_"
+
+// 传给钩子的环境变量：继承系统环境，但去掉覆盖配置的变量（其中可能有密码、stok），
+// 再以 TPLINK_HOOK_ 前缀加入本次设置的状态
+func hookEnv(p hookPayload) []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		name := strings.ToUpper(strings.SplitN(kv, "=", 2)[0])
+		if strings.HasPrefix(name, envPrefix) || plainEnvName(name) {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return append(env,
+		hookEnvPrefix+"PHASE="+p.Phase,
+		hookEnvPrefix+"ROUTER_IP="+p.RouterIP,
+		hookEnvPrefix+"IPV6_FIREWALL_ENABLE="+p.IPv6FirewallEnable,
+		hookEnvPrefix+"DMZ_ENABLE="+p.DmzEnable,
+		hookEnvPrefix+"DMZ_DEST_IP="+p.DmzDestIP,
+		hookEnvPrefix+"DMZ_DEST_IP6="+p.DmzDestIP6,
+		fmt.Sprintf("%sSUCCESS=%t", hookEnvPrefix, p.Success),
+		hookEnvPrefix+"ERROR="+p.Error,
+	)
+}
+
+func runHook(command string, env []string, stdin []byte, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = env
+	cmd.Stdin = bytes.NewReader(stdin)
+
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	sayDebug("debug.hook_timing", command, time.Since(start).Round(time.Millisecond), output)
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s", tr("exec.timeout", timeout))
+	}
+	if err != nil {
+		if out := strings.TrimSpace(string(output)); out != "" {
+			return fmt.Errorf("%v: %s", err, out)
+		}
+		return err
+	}
+	return nil
+}
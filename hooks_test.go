@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHookEnvDropsOverrides(t *testing.T) {
+	t.Setenv("TPLINK_STOK", "secret-stok")
+	t.Setenv("TPLINK_ROUTER_PASSWORD", "secret-password")
+	t.Setenv("STOK", "plain-stok")
+	t.Setenv("HOOK_TEST_KEEP", "1")
+
+	env := strings.Join(hookEnv(hookPayload{Phase: "pre_apply", RouterIP: "192.168.0.1", DmzEnable: "1"}), "\n")
+	for _, secret := range []string{"secret-stok", "secret-password", "plain-stok"} {
+		if strings.Contains(env, secret) {
+			t.Errorf("钩子环境中不应有 %s", secret)
+		}
+	}
+	for _, want := range []string{"HOOK_TEST_KEEP=1", "TPLINK_HOOK_PHASE=pre_apply", "TPLINK_HOOK_ROUTER_IP=192.168.0.1", "TPLINK_HOOK_SUCCESS=false"} {
+		if !strings.Contains(env, want) {
+			t.Errorf("钩子环境中缺少 %s", want)
+		}
+	}
+}
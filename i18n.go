@@ -0,0 +1,1198 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 界面文案，键不存在时依次回退到中文和键名本身
+var messages = map[string]map[string]string{
+	"zh": {
+		"title":                          "TP-LINK IPv6防火墙设置",
+		"state.current":                  "路由器当前状态",
+		"state.ipv6_firewall":            "IPv6防火墙",
+		"breaker.open":                   "路由器连续无响应，请求已暂停",
+		"breaker.retry_in":               "%s 后重试",
+		"form.router_ip.placeholder":     "例如: 192.168.0.1 或 tplogin.cn",
+		"form.stok.placeholder":          "路由器认证令牌",
+		"form.router_password":           "路由器管理员密码",
+		"form.router_password.hint":      "可选，填写后自动登录获取stok",
+		"form.router_password.saved":     "已保存，留空不修改",
+		"login.bad_password":             "路由器管理员密码错误",
+		"console.login_ok":               "已登录路由器 %s 并获取新的stok",
+		"console.login_failed":           "自动登录路由器失败: %v",
+		"form.ipv6_firewall":             "IPv6 Firewall Enable (on=开启,off=关闭)",
+		"form.ipv6_firewall.placeholder": "on或off",
+		"form.dmz_enable":                "DMZ 启用状态 (1=启用,0=关闭)",
+		"form.dmz_enable.placeholder":    "0或1",
+		"form.example":                   "例如:",
+		"form.submit":                    "提交",
+		"success.message":                "操作成功！可关闭浏览器返回程序，按Enter退出。",
+		"error.failed":                   "操作失败",
+		"error.back":                     "返回",
+		"warn.dmz_enable":                "DMZ启用状态必须为0或1，已保持原有值: %s",
+		"warn.wan_port":                  "WAN口必须为非负整数，已保持原有值: %s",
+		"form.wan_port":                  "DMZ WAN口",
+		"form.wan_port.placeholder":      "单WAN填0，双WAN机型按路由器中的WAN口编号填写",
+		"console.breaker_recovered":      "路由器已恢复响应，熔断解除",
+		"console.breaker_open":           "路由器连续失败 %d 次，暂停请求 %v",
+		"console.cassette_write_failed":  "写入磁带文件失败: %v",
+		"console.kill_failed":            "警告: 无法终止进程 %d: %v",
+		"console.simulator_error":        "模拟路由器错误: %v",
+		"console.config_read_failed":     "读取配置文件错误: %v",
+		"console.config_reloaded":        "配置文件 %s 已修改，已重新读取",
+		"console.config_reload_failed":   "重新读取配置文件失败，继续使用原配置: %v",
+		"console.config_fallback":        "将允许通过网页输入配置，服务器使用默认端口 8080...",
+		"console.bad_duration":           "%s 格式错误（%v），使用默认值 %s",
+		"console.bad_cache_ttl":          "state_cache_ttl 格式错误（%v），不缓存状态查询",
+		"console.cassette_open_failed":   "打开磁带文件失败: %v",
+		"console.cassette_replay":        "回放模式：路由器响应来自 %s",
+		"console.cassette_record":        "录制模式：路由器交互将写入 %s",
+		"console.templates_failed":       "加载页面模板失败: %v",
+		"console.server_started":         "服务器启动，访问 %s",
+		"console.listen_failed":          "无法监听 %s: %v",
+		"input.method":                   "不支持的请求方法 %s",
+		"input.content_type":             "Content-Type 必须为 %s",
+		"input.bad_form":                 "表单解析失败: %v",
+		"console.browser_failed":         "自动打开浏览器失败，请手动访问: %s\n错误原因: %v",
+		"console.browser_opened":         "已自动打开默认浏览器，若未弹出请手动访问上述地址",
+		"console.server_error":           "服务器错误: %v",
+		"console.port_hint":              "提示：端口 %s 可能已被占用，请修改 config.json 中的 server_port 字段（如 8081）",
+		"console.press_enter":            "按Enter键关闭程序...",
+		"console.shutting_down":          "程序正在关闭...",
+		"console.unsupported_os":         "不支持的操作系统: %s",
+		"console.simulator_started":      "模拟路由器已启动: http://%s",
+		"console.simulator_usage":        "可直接使用 router_ip=%s stok=%s",
+		"console.template_failed":        "渲染模板 %s 失败: %v",
+		"console.hook_failed":            "警告: %v",
+		"console.exposure":               "暴露检查: %s",
+		"warn.exposed_port":              "%s（%s）现在可从公网访问",
+		"advisor.link":                   "只需开放几个端口？查看暴露面更小的方案",
+		"advisor.title":                  "开放方案建议",
+		"advisor.intro":                  "关闭IPv6防火墙并开启DMZ会把主机的全部端口暴露到公网。如果只需要开放几个端口，请填写端口，工具会推荐暴露面最小的方式。",
+		"advisor.ports":                  "需要开放的端口（如 tcp:443, udp:51820）",
+		"advisor.full_cone":              "需要主机全部端口可访问（如游戏主机需要全锥型NAT）",
+		"advisor.submit":                 "获取建议",
+		"advisor.option":                 "方案",
+		"advisor.exposure":               "暴露程度",
+		"advisor.exposure.low":           "仅指定端口",
+		"advisor.exposure.high":          "主机全部端口",
+		"advisor.apply":                  "应用",
+		"advisor.ipv6_rule":              "路由器IPv6放行规则",
+		"advisor.ipv6_rule.detail":       "保持IPv6防火墙开启，只放行 %s 到 %s",
+		"advisor.upnp_pinhole":           "UPnP IPv6针孔",
+		"advisor.upnp_pinhole.detail":    "通过UPnP IGDv2临时放行指定端口（24小时），多数路由器要求在目标主机上运行本工具",
+		"advisor.full_open":              "关闭IPv6防火墙 + DMZ",
+		"advisor.full_open.detail":       "目标主机的所有端口都将暴露到公网",
+		"advisor.reason.no_target":       "未配置DMZ目标IPv6地址",
+		"advisor.reason.no_ports":        "未填写需要开放的端口",
+		"advisor.result.rule":            "已添加IPv6放行规则 %s → %s",
+		"advisor.result.pinhole":         "已添加UPnP针孔 %s（ID %s）",
+		"advisor.result.full_open":       "已关闭IPv6防火墙并开启DMZ",
+		"warn.exposed_risky_port":        "危险：%s（%s）现在可从公网访问，此类服务常被扫描和攻击，建议关闭或改用端口转发",
+		"console.history_failed":         "写入历史记录失败: %v",
+		"console.history_migrated":       "已将旧格式的历史记录 %s 导入 %s",
+		"console.notify":                 "[通知] %s: %s",
+		"console.notify_failed":          "发送%s通知失败: %v",
+		"console.plugin_loaded":          "已加载插件 %s（%s）",
+		"console.plugin_failed":          "加载插件 %s 失败: %v",
+		"console.plugin_backend":         "后端插件 %s 可通过 firmware_type \"%s\" 使用",
+		"console.plugin_backend_taken":   "后端插件 %s 与已有的 firmware_type \"%s\" 重名，未登记",
+		"plugin.bad_describe":            "describe 输出不是有效的插件描述",
+		"notify.router_down":             "路由器离线",
+		"notify.location_changed":        "已切换网络",
+		"notify.location_changed.detail": "检测到当前位于 %s，改用路由器 %s",
+		"console.location_apply_failed":  "执行预设 %s 失败: %s",
+		"notify.reboot_soon":             "路由器即将重启",
+		"notify.reboot_soon.detail":      "路由器 %s 将于 %s 定时重启，重启期间网络会中断",
+		"notify.reboot_done":             "路由器已重启",
+		"notify.reboot_done.detail":      "路由器 %s 已重启并重新应用了设置",
+		"notify.reboot_failed":           "定时重启失败",
+		"notify.reboot_timeout.detail":   "路由器 %s 重启后未在等待时间内上线，设置未重新应用",
+		"notify.reboot_reapply.detail":   "路由器 %s 已重启，但重新应用设置失败: %s",
+		"console.reboot_sent":            "已向路由器 %s 发送重启命令，等待其重新上线",
+		"console.reboot_failed":          "定时重启失败: %v",
+		"console.reboot_skipped":         "跳过定时重启: %v",
+		"notify.router_down.detail":      "路由器 %s 连续 %d 次探测无响应，可能正在重启；重启后防火墙设置可能被恢复",
+		"notify.traffic":                 "流量超过阈值",
+		"notify.traffic_daily.detail":    "%s 今日流量 %d MB，超过每日阈值 %d MB",
+		"notify.traffic_monthly.detail":  "%s 本月流量 %d MB，超过每月阈值 %d MB",
+		"state.traffic":                  "%s 流量：今日 %s，本月 %s",
+		"notify.router_up":               "路由器恢复在线",
+		"notify.router_up.detail":        "路由器 %s 已恢复，离线时长 %v",
+		"notify.router_moved":            "路由器地址已变更",
+		"notify.router_moved.detail":     "路由器从 %s 迁移到 %s（%s），已改用新地址",
+		"state.router_down":              "路由器自 %s 起无法连接",
+		"conflict.title":                 "与路由器现有配置冲突",
+		"conflict.overwrite":             "仍然覆盖并继续",
+		"conflict.cancel":                "取消",
+		"conflict.dmz_target":            "DMZ当前已指向其他主机 %s，继续将改为新的目标",
+		"conflict.port_forward":          "端口 %s 已被端口转发规则 %s 使用（转发到 %s）",
+		"conflict.ipv6_rule":             "端口 %s 已有IPv6放行规则 %s（目标 %s）",
+		"conflict.upnp":                  "端口 %s 已有UPnP映射（%s，%s）",
+		"sync.label":                     "同步状态",
+		"sync.in_sync":                   "已同步",
+		"sync.drifted":                   "已偏离（路由器设置与期望不一致）",
+		"sync.unknown":                   "未知",
+		"sync.last_apply":                "上次设置",
+		"sync.confirmed_at":              "上次确认",
+		"console.state_load_failed":      "读取状态文件失败: %v",
+		"console.state_save_failed":      "保存状态文件失败: %v",
+		"console.config_save_failed":     "写回配置文件失败: %v",
+		"notify.target_down":             "DMZ目标主机离线",
+		"notify.target_down.detail":      "DMZ目标 %s 连续 %d 次检查无响应",
+		"notify.target_up":               "DMZ目标主机恢复",
+		"notify.target_up.detail":        "DMZ目标 %s 已恢复，离线时长 %v",
+		"notify.target_ipv6_gone":        "DMZ目标IPv6地址失效",
+		"notify.target_ipv6_gone.detail": "主机 %s 在线，但IPv6地址 %s 无响应，可能IPv6前缀已变化，请更新 dmz_dest_ip6",
+		"error.ok":                       "操作成功",
+		"code.busy":                      "路由器忙（可能正在保存配置、升级或重启），请稍等一分钟后重试",
+		"code.table_full":                "路由器中该类条目已达上限，请先在路由器管理页面删除不用的条目",
+		"code.bad_format":                "路由器无法识别请求格式，可能是固件版本不同，请反馈路由器型号与固件版本",
+		"code.invalid_param":             "路由器认为参数无效，请检查IP地址、开关值和WAN口是否正确",
+		"code.unsupported":               "当前路由器固件不支持该功能或字段，可尝试升级固件，或只修改IPv6防火墙/DMZ其中一项",
+		"code.bad_credentials":           "路由器管理员密码错误，请在设置中重新填写",
+		"code.login_locked":              "密码错误次数过多，路由器已暂时锁定登录，请稍后再试并确认密码",
+		"code.unauthorized":              "stok无效或已过期，请重新登录路由器管理页面获取新的stok，或填写管理员密码由程序自动登录",
+		"code.unknown":                   "路由器返回未知错误码 %d，请确认路由器状态后重试",
+		"error.auth_expired":             "stok无效或已过期，请重新登录路由器管理页面，用F12开发者工具获取新的stok，或填写路由器管理员密码由程序自动登录",
+		"error.unreachable":              "无法连接路由器，请检查Router IP是否正确、电脑是否连接在该路由器下",
+		"error.unsupported":              "当前路由器固件不支持该操作",
+		"error.bad_parameter":            "参数错误，请检查填写的内容",
+		"error.circuit_open":             "路由器连续多次无响应，已暂停发送请求，冷却结束后会自动重试",
+		"error.rate_limited":             "对路由器的请求过于频繁，请稍后再试",
+		"error.maintenance":              "当前处于维护时段，自动任务不会修改路由器",
+		"console.maintenance_skip":       "跳过自动修改: %v",
+		"console.config_invalid":         "配置项 %s 无效: %v",
+		"state.maintenance":              "维护时段 %s 中：自动任务只观察、不修改路由器",
+		"console.schedule_done":          "定时任务 %s 已执行",
+		"console.schedule_failed":        "定时任务 %s 执行失败: %v",
+		"state.next_schedule":            "下一个定时任务：%s，%s",
+		"state.location":                 "当前网络：%s",
+		"state.unchanged":                "（与配置一致，无需修改）",
+		"notify.suppressed":              "（期间另有 %d 条相同通知被抑制）",
+		"stats.title":                    "统计",
+		"preview.title":                  "请求预览",
+		"preview.button":                 "预览",
+		"preview.note":                   "以下请求尚未发送，可与固件实际使用的请求对照（stok已隐藏）",
+		"flag.dry_run":                   "只输出将发送给路由器的请求地址和内容，不实际发送",
+		"flag.router":                    "使用 routers 中指定名称的路由器",
+		"router.unknown":                 "routers 中没有名为 %q 的路由器",
+		"router.label":                   "路由器",
+		"router.default":                 "默认（顶层配置）",
+		"router.switch":                  "切换",
+		"mesh.satellite":                 "%s 是易展子路由，IPv6防火墙与DMZ需要在主路由（%s）上修改",
+		"mesh.use_primary":               "改为操作主路由 %s",
+		"mesh.redirected":                "%s 是易展子路由，已改为操作主路由 %s",
+		"mesh.not_satellite":             "当前路由器不是易展子路由，或未上报主路由地址",
+		"router.apply_all":               "应用到全部路由器",
+		"router.apply_all_summary":       "成功 %d 台，失败 %d 台",
+		"router.apply_all_partial":       "%d/%d 台路由器设置失败",
+		"router.result_ok":               "%s (%s): 成功，IPv6防火墙 %s，DMZ %s，耗时 %v",
+		"router.result_failed":           "%s (%s): 失败，%s",
+		"flag.all_routers":               "同时把各自的期望设置应用到顶层配置和 routers 中的全部路由器",
+		"flag.only_firewall":             "只修改IPv6防火墙，DMZ保持不变",
+		"flag.only_dmz":                  "只修改DMZ，IPv6防火墙保持不变",
+		"form.only_firewall":             "仅防火墙",
+		"form.only_dmz":                  "仅DMZ",
+		"rollback.button":                "撤销",
+		"rollback.previous":              "上次修改前：IPv6防火墙 %s，DMZ %s %s %s",
+		"rollback.none":                  "没有可撤销的修改",
+		"rollback.done":                  "已恢复上次修改前的设置：IPv6防火墙 %s，DMZ %s %s %s",
+		"history.title":                  "修改记录",
+		"history.disabled":               "未配置 history_file，不记录修改历史",
+		"history.empty":                  "暂无修改记录",
+		"history.time":                   "时间",
+		"history.source":                 "来源",
+		"history.actor":                  "发起人",
+		"history.result":                 "结果",
+		"history.failed":                 "失败",
+		"history.message":                "说明",
+		"history.response":               "路由器响应",
+		"flag.history_limit":             "最多列出的记录条数，0=全部",
+		"log.bad_level":                  "未知的日志级别 %q，可选 debug/info/warn/error",
+		"log.bad_format":                 "未知的日志格式 %q，可选 console/json",
+		"log.bad_rotation":               "log_rotation 中的数值不能为负数",
+		"log.bad_facility":               "未知的 syslog facility %q，可选 user/daemon/local0-local7",
+		"log.no_local_syslog":            "找不到本机 syslog，请在 syslog.network/address 中指定远程服务器",
+		"log.eventlog_unsupported":       "事件日志只在Windows上可用，请改用 syslog",
+		"flag.log_level":                 "日志级别 debug/info/warn/error，覆盖配置中的 log_level",
+		"flag.log_format":                "日志格式 console/json，覆盖配置中的 log_format",
+		"console.router_log_failed":      "写入路由器请求日志失败: %v",
+		"apidocs.title":                  "接口文档",
+		"apidocs.send":                   "发送",
+		"openapi.description":            "设置TP-LINK路由器IPv6防火墙与DMZ的JSON接口。失败时 error.code 为机器可读的错误代码",
+		"openapi.error":                  "失败，error 中给出错误代码与说明",
+		"openapi.not_ready":              "最近一次路由器请求失败或已熔断",
+		"openapi.status":                 "读取路由器当前设置并与期望状态比较",
+		"openapi.apply":                  "按预设或字段修改路由器设置",
+		"openapi.config_get":             "读取当前配置（不含stok与密码）",
+		"openapi.config_put":             "修改配置中给出的字段，不立即应用到路由器",
+		"openapi.healthz":                "存活检查",
+		"openapi.readyz":                 "就绪检查",
+		"openapi.metrics":                "Prometheus 指标",
+		"stats.period":                   "统计周期",
+		"stats.days":                     "天",
+		"stats.since":                    "自",
+		"stats.applies":                  "设置成功/总次数",
+		"stats.mean_apply":               "平均设置耗时",
+		"stats.watchdog":                 "守护自动修复次数",
+		"stats.prefix":                   "IPv6前缀变化次数",
+		"stats.per_week":                 "次/周",
+		"stats.outages":                  "路由器离线次数",
+		"stats.downtime":                 "路由器累计离线时长",
+		"stats.latency":                  "路由器操作耗时（本次运行）",
+		"stats.count":                    "次数",
+		"stats.errors":                   "失败",
+		"stats.avg":                      "平均",
+		"stats.max":                      "最大",
+		"auth.required":                  "需要登录",
+		"auth.forbidden":                 "当前账号为只读，无权修改设置",
+		"auth.cross_site":                "拒绝来自其他网站的修改请求，请在本程序的页面上操作",
+		"auth.user_incomplete":           "用户缺少 name 或 password",
+		"auth.bad_role":                  "用户 %s 的角色 %q 无效，应为 admin 或 viewer",
+		"auth.duplicate_user":            "用户 %s 重复",
+		"auth.signed_in":                 "当前用户：%s（%s）",
+		"auth.read_only":                 "只读账号，仅可查看状态",
+		"console.hash_password_usage":    "用法: hash-password <密码>",
+		"auth.logout":                    "退出登录",
+		"oidc.issuer_mismatch":           "提供方返回的issuer不一致: %s",
+		"oidc.client_id_required":        "启用OIDC时必须填写 client_id",
+		"oidc.unavailable":               "无法连接身份提供方: %s",
+		"oidc.bad_state":                 "登录状态无效或已过期，请重新登录",
+		"oidc.denied":                    "身份提供方拒绝了登录: %s",
+		"oidc.failed":                    "单点登录失败，详情见日志",
+		"oidc.token_unverifiable":        "access token 不是JWT，且提供方没有 introspection 端点，无法确认签发对象",
+		"oidc.token_inactive":            "access token 已失效",
+		"oidc.token_audience":            "access token 不是签发给本程序的",
+		"agent.title":                    "远程代理",
+		"agent.name":                     "名称",
+		"agent.status":                   "状态",
+		"agent.online":                   "在线",
+		"agent.offline":                  "离线",
+		"agent.last_seen":                "最后连接",
+		"agent.last_result":              "最近执行结果",
+		"agent.pending":                  "%d 条设置等待下发",
+		"agent.unknown":                  "未知的代理: %s",
+		"agent.queued":                   "已向代理 %s 下发设置",
+		"agent.result_ok":                "代理 %s 已应用设置",
+		"agent.result_failed":            "代理 %s 应用设置失败: %s",
+		"agent.weak_token":               "代理 %q 的令牌至少需要16个字符",
+		"agent.incomplete":               "agent 需要同时填写 name 和 token",
+		"agent.connected":                "已连接到中心控制端 %s",
+		"agent.connect_failed":           "与中心控制端的连接中断: %v",
+		"agent.applied":                  "执行中心下发的设置 %s: %s",
+		"guest.title":                    "访客网络",
+		"guest.enable":                   "启用访客网络",
+		"guest.isolate":                  "访客设备互相隔离",
+		"guest.access_lan":               "允许访问主网络",
+		"guest.updated":                  "已更新访客网络 %s",
+		"guest.lan_warning":              "DMZ已开启，但访客网络仍可访问主网络，建议关闭“允许访问主网络”",
+		"iptv.title":                     "IPTV/VLAN",
+		"iptv.intro":                     "光猫改桥接或使用DMZ时，通常需要同时调整IPTV与上网的VLAN绑定。留空的字段保持不变。",
+		"iptv.enable":                    "启用IPTV/VLAN",
+		"iptv.mode":                      "模式",
+		"iptv.internet_vid":              "上网VLAN ID",
+		"iptv.internet_prio":             "上网优先级",
+		"iptv.iptv_vid":                  "IPTV VLAN ID",
+		"iptv.iptv_prio":                 "IPTV优先级",
+		"iptv.igmp_snooping":             "IGMP Snooping",
+		"iptv.lan1":                      "LAN1 用途",
+		"iptv.lan2":                      "LAN2 用途",
+		"iptv.lan3":                      "LAN3 用途",
+		"iptv.lan4":                      "LAN4 用途",
+		"iptv.modes":                     "可选模式",
+		"iptv.invalid":                   "以下字段取值无效: %s",
+		"iptv.updated":                   "已更新IPTV/VLAN设置",
+		"quick.title":                    "快捷开关",
+		"quick.wifi_2g":                  "2.4G无线",
+		"quick.wifi_5g":                  "5G无线",
+		"quick.guest_2g":                 "2.4G访客",
+		"quick.guest_5g":                 "5G访客",
+		"quick.turn_on":                  "开启",
+		"quick.turn_off":                 "关闭",
+		"quick.done":                     "%s 已切换为 %s",
+		"access.title":                   "访问控制",
+		"access.alias":                   "备注",
+		"access.mode":                    "模式",
+		"access.mode.block":              "禁止上网",
+		"access.mode.schedule":           "仅限时段上网",
+		"access.time":                    "允许时段",
+		"access.enable":                  "启用",
+		"access.delete":                  "删除",
+		"access.add":                     "添加规则",
+		"access.empty":                   "暂无规则",
+		"access.bad_mac":                 "MAC地址无效: %s",
+		"access.added":                   "已添加 %s 的访问控制规则",
+		"access.deleted":                 "已删除访问控制规则 %s",
+		"access.toggled":                 "访问控制规则 %s 已设为 %s",
+		"routes.title":                   "静态路由",
+		"routes.target":                  "目标网络",
+		"routes.netmask":                 "子网掩码",
+		"routes.gateway":                 "网关",
+		"routes.interface":               "接口",
+		"routes.add":                     "添加路由",
+		"routes.empty":                   "暂无静态路由",
+		"routes.bad_target":              "目标网络无效: %s %s",
+		"routes.not_network":             "%s 不是 %s 对应的网络地址",
+		"routes.bad_gateway":             "网关地址无效: %s",
+		"routes.added":                   "已添加静态路由 %s/%s 经 %s",
+		"routes.deleted":                 "已删除静态路由 %s",
+		"dhcp.title":                     "DHCP服务器",
+		"dhcp.enable":                    "启用DHCP服务器",
+		"dhcp.pool_start":                "地址池起始",
+		"dhcp.pool_end":                  "地址池结束",
+		"dhcp.lease_time":                "租期（分钟）",
+		"dhcp.gateway":                   "网关",
+		"dhcp.pri_dns":                   "首选DNS",
+		"dhcp.snd_dns":                   "备用DNS",
+		"dhcp.updated":                   "已更新DHCP服务器设置",
+		"dhcp.reservations":              "地址保留",
+		"dhcp.reservations_hint":         "为DMZ主机保留固定地址，避免重新获取地址后DMZ指向其他设备",
+		"dhcp.reserve":                   "保留地址",
+		"dhcp.bad_reservation":           "MAC或IP地址无效",
+		"dhcp.reserved":                  "已为 %s 保留地址 %s",
+		"dhcp.unreserved":                "已删除地址保留 %s",
+		"wandmz.title":                   "多WAN DMZ",
+		"wandmz.intro":                   "多WAN路由器可以为每个WAN口分别设置DMZ主机，下表显示各WAN口当前暴露的主机。",
+		"wandmz.unsupported":             "当前固件只有一个WAN口的DMZ",
+		"wandmz.primary":                 "主DMZ",
+		"wandmz.exposes":                 "状态",
+		"wandmz.exposed":                 "已暴露",
+		"wandmz.closed":                  "未暴露",
+		"wandmz.enabled":                 "启用",
+		"wandmz.disabled":                "关闭",
+		"wandmz.add":                     "为其他WAN口设置DMZ",
+		"wandmz.bad_wan":                 "WAN口无效: %s",
+		"wandmz.bad_ip":                  "地址无效: %s",
+		"wandmz.done":                    "%s 的DMZ已设为 %s（%s）",
+		"trigger.title":                  "端口触发",
+		"trigger.intro":                  "内网设备访问触发端口时，路由器临时向其开放指定端口，适合动态开端口的游戏等程序。",
+		"trigger.unsupported":            "当前固件不支持端口触发",
+		"trigger.app":                    "应用",
+		"trigger.trigger_port":           "触发端口",
+		"trigger.open_port":              "开放端口",
+		"trigger.add":                    "添加规则",
+		"trigger.empty":                  "暂无端口触发规则",
+		"trigger.bad_port":               "端口无效: %s",
+		"trigger.added":                  "已添加端口触发 %s -> %s",
+		"trigger.deleted":                "已删除端口触发规则 %s",
+		"trigger.toggled":                "端口触发规则 %s 已设为 %s",
+		"time.title":                     "时间与NTP",
+		"time.router_time":               "路由器时间",
+		"time.local_time":                "本机时间",
+		"time.skew":                      "偏差",
+		"time.skew_warning":              "路由器时钟与本机相差 %s，路由器上的定时功能可能不准确，请检查NTP设置",
+		"time.tz_differs":                "路由器时区与本工具定时任务使用的时区不同",
+		"time.ntp_enable":                "自动同步时间（NTP）",
+		"time.ntp_server1":               "首选NTP服务器",
+		"time.ntp_server2":               "备用NTP服务器",
+		"time.timezone":                  "时区（如 +08:00）",
+		"time.updated":                   "已更新路由器时间设置",
+		"error.identity_mismatch":        "router_ip 上的设备与之前记录的路由器不一致（可能地址已变化），已拒绝修改。确认更换了路由器后请在错误页面中确认",
+		"identity.mismatch":              "%s 上的设备与记录不符：期望 %s，实际 %s",
+		"identity.forget":                "已更换路由器，重新记录身份",
+		"identity.forgotten":             "已清除记录的路由器身份，下次修改时重新记录",
+		"clipboard.paste":                "从剪贴板粘贴",
+		"clipboard.watch":                "监视剪贴板",
+		"clipboard.not_found":            "剪贴板中没有找到stok",
+		"clipboard.filled":               "已从剪贴板填入stok",
+		"clipboard.local_only":           "仅允许本机读取剪贴板",
+		"clipboard.unsupported":          "当前系统不支持由程序读取剪贴板",
+		"ctl.usage":                      "用法: ctl [-config 文件] [-server 地址] [-user 用户名] status | apply [-preset 名称 -action open|close] [-ipv6-firewall on|off] [-dmz 0|1] [-dmz-ip IP] [-dmz-ip6 IP] | logs [-f] [-n 行数]；密码从环境变量 TPLINK_CTL_PASSWORD 读取",
+		"ctl.unreachable":                "无法连接到 %s: %v",
+		"ctl.applied":                    "设置已应用",
+		"apply.unchanged":                "已是目标状态，未发送设置",
+		"apply.unchanged_state":          "已是目标状态：IPv6防火墙 %s，DMZ %s %s %s",
+		"ctl.apply_failed":               "ctl 应用设置失败: %s",
+		"deeplink.bad_profile":           "预设名称为空或重复: %q",
+		"deeplink.unknown_profile":       "未找到预设: %s",
+		"deeplink.unknown_action":        "未知的动作: %s（可用 open/close）",
+		"deeplink.confirm":               "确认对 %s 执行 %s？",
+		"deeplink.done":                  "已对 %s 执行 %s",
+		"deeplink.done_path":             "已对 %s 执行 %s（方式：%s）",
+		"fallback.no_ipv6":               "WAN口没有可用的公网IPv6，预设 %s 改用IPv4方式 %s",
+		"fallback.incomplete":            "预设 %s 配置了 ipv4_fallback，需要同时填写 ports 和 dmz_dest_ip",
+		"fallback.path.ipv6":             "IPv6（关闭防火墙+DMZ）",
+		"fallback.path.port_forward":     "IPv4端口转发",
+		"fallback.path.upnp":             "IPv4 UPnP端口映射",
+		"fallback.path.":                 "无",
+		"deeplink.failed":                "对 %s 执行 %s 失败: %s",
+		"flag.config":                    "配置文件路径（.json、.yaml、.toml），- 表示从标准输入读取JSON；默认的 config.json 不存在时依次使用 config.yaml、config.yml、config.toml",
+		"flag.convert_from":              "输入格式 json/yaml/toml，默认按扩展名，从标准输入读取时按内容判断",
+		"flag.convert_to":                "输出格式 json/yaml/toml，指定输出文件时按其扩展名",
+		"flag.convert_force":             "覆盖已存在的输出文件",
+		"config.usage":                   "用法: tplinkfirewalloff config convert [-from 格式] [-to json|yaml|toml] [-force] <输入文件|-> [输出文件] | config env（列出 TPLINK_ 环境变量）",
+		"env.bad_value":                  "环境变量 %s 的值无效: %v",
+		"flag.config_field":              "覆盖配置中的 %s",
+		"convert.usage":                  "用法: tplinkfirewalloff config convert [-from 格式] [-to json|yaml|toml] [-force] <输入文件|-> [输出文件]",
+		"convert.bad_format":             "不支持的配置格式 %q，应为 .json、.yaml、.yml 或 .toml",
+		"convert.exists":                 "%s 已存在，使用 -force 覆盖",
+		"convert.header":                 "由 %s 转换，可在此添加注释，程序写回设置时会保留",
+		"convert.done":                   "已将 %s 转换为 %s",
+		"flag.apply":                     "不启动网页界面，按配置和参数设置一次后退出",
+		"flag.daemon":                    "作为后台服务运行：不打开浏览器、不读取控制台，收到 SIGTERM 或 Ctrl+C 时退出",
+		"flag.no_browser":                "启动后不自动打开浏览器",
+		"flag.no_stdin":                  "不读取标准输入（不等待 Enter），只在收到 SIGTERM 或 Ctrl+C 时退出",
+		"flag.systemd_user":              "服务运行的用户",
+		"console.no_display":             "没有图形界面",
+		"headless.no_router":             "未配置 router_ip 和 stok（或管理员密码）",
+		"headless.applied":               "设置成功：IPv6防火墙 %s，DMZ %s %s %s",
+		"flag.json":                      "以JSON输出",
+		"cli.usage":                      "用法: tplinkfirewalloff [serve|apply|status|watch|history|rollback|login|discover|ctl|config|systemd-unit|simulator|hash-password] [参数]",
+		"flag.watch_interval":            "检查间隔，如 30s，默认取配置中的 watch.interval 或 60s",
+		"console.watch_started":          "开始守护路由器 %s，每 %v 检查一次，按Ctrl+C退出",
+		"console.watch_failed":           "重新设置失败: %s",
+		"notify.drift":                   "路由器设置被改回",
+		"notify.drift.detail":            "路由器 %s 当前为 IPv6防火墙 %s、DMZ %s，与配置的 %s、%s 不一致",
+		"watch.reapplied":                "已重新设置",
+		"status.router":                  "路由器: %s",
+		"status.current":                 "当前设置: IPv6防火墙 %s，DMZ %s %s %s",
+		"status.desired":                 "配置设置: IPv6防火墙 %s，DMZ %s %s %s",
+		"status.sync":                    "同步状态: %s",
+		"login.prompt":                   "路由器管理员密码: ",
+		"discover.found":                 "发现路由器 %s（%s）",
+		"discover.unknown":               "未登录，型号未知",
+		"discover.none":                  "默认网关和常见地址上都没有发现路由器",
+		"flag.ctl_server":                "运行中实例的地址，默认取配置中的本机监听地址",
+		"flag.ctl_user":                  "登录用户名",
+		"flag.preset":                    "要执行的预设名称（profiles 中的名称）",
+		"flag.ctl_action":                "预设动作 open/close",
+		"flag.ctl_follow":                "持续输出新事件",
+		"flag.ctl_lines":                 "显示最近的事件条数",
+		"console.press_ctrl_c":           "按 Ctrl+C 退出程序...",
+		"listener.cert_key":              "监听 %s 的 tls_cert 与 tls_key 需要同时填写",
+		"listener.bad_auth":              "监听 %s 的 auth %q 无效，应为 required 或 none",
+		"console.status_file_failed":     "写入状态文件失败: %v",
+		"console.diagnostics_failed":     "生成诊断包失败: %v",
+		"diag.link":                      "下载诊断包",
+		"diag.exported":                  "已导出诊断包",
+		"console.router_move_loaded":     "路由器地址已从 %s 变更为 %s，沿用新地址",
+		"console.resolve_fallback":       "无法解析 %s，使用上次的地址 %s",
+		"console.router_move_auth":       "%s 上有设备响应但stok无效，无法确认是否为原路由器，请重新登录后更新 router_ip",
+		"console.local_firewall_added":   "已添加本机防火墙入站规则 %s: %s",
+		"console.local_firewall_failed":  "同步本机防火墙规则失败（需要以管理员身份运行）: %v",
+		"local_firewall.all_ports":       "全部端口",
+		"local_firewall.unsupported":     "当前系统不支持自动配置本机防火墙",
+		"error.router":                   "路由器返回错误",
+		"advisor.bad_port":               "无效的端口: %s",
+		"advisor.unknown_plan":           "未知方案: %s",
+		"breaker.probing":                "正在试探路由器是否恢复",
+		"response.missing":               "响应中缺少 %s",
+		"response.missing_table":         "响应中缺少表 %s",
+		"response.content_type":          "响应类型异常: %s",
+		"response.read_failed":           "读取响应错误: %v",
+		"legacy.bad_dmz_page":            "DMZ页面格式无法识别",
+		"cassette.bad_file":              "磁带文件格式错误: %v",
+		"cassette.bad_mode":              "未知的 cassette_mode: %q（应为 record 或 replay）",
+		"cassette.no_match":              "磁带中没有匹配的记录: %s %s %s",
+		"cron.fields":                    "cron表达式应为5段（分 时 日 月 周）: %q",
+		"cron.out_of_range":              "cron字段 %q 超出范围 %d-%d",
+		"cron.bad_step":                  "cron步长无效: %q",
+		"cron.bad_range":                 "cron范围无效: %q",
+		"cron.never":                     "cron表达式没有可执行的时间",
+		"kind.auth_expired":              "stok无效或已过期",
+		"kind.unreachable":               "无法连接路由器",
+		"kind.unsupported":               "固件不支持该操作",
+		"kind.bad_parameter":             "参数错误",
+		"kind.router":                    "路由器返回错误",
+		"kind.circuit_open":              "路由器连续失败，已暂停请求",
+		"kind.rate_limited":              "请求过于频繁",
+		"kind.maintenance":               "处于维护时段，暂停自动修改",
+		"kind.identity_mismatch":         "路由器身份与记录不符",
+		"gateway.not_in_arp":             "ARP表中没有 %s",
+		"guest.unsupported":              "不支持访客网络",
+		"hook.failed":                    "%s 钩子 %q 执行失败: %v",
+		"exec.timeout":                   "超时（%v）",
+		"plugin.failed":                  "%s 插件 %s 执行失败: %v",
+		"limits.too_large":               "数据超过 %d 字节上限",
+		"limits.body_too_large":          "请求体过大",
+		"location.bad_name":              "网络名称为空或重复: %q",
+		"location.incomplete":            "%s: 需要填写 gateway_mac 或 ssid",
+		"location.bad_mac":               "%s: 无效的MAC地址 %q",
+		"maintenance.bad_time":           "时间格式应为 HH:MM: %q",
+		"console.oidc_failed":            "OIDC登录失败: %v",
+		"oidc.id_token_issuer":           "id_token 的 iss 不匹配: %s",
+		"oidc.id_token_audience":         "id_token 的 aud 不匹配",
+		"oidc.id_token_expired":          "id_token已过期",
+		"oidc.id_token_nonce":            "id_token 的 nonce 不匹配",
+		"oidc.bad_jwt":                   "令牌不是有效的JWT",
+		"persist.not_object":             "配置文件顶层不是JSON对象",
+		"ratelimit.wait":                 "需等待 %v，超过上限 %v",
+		"tls.no_pem":                     "ca_file %s: 没有可用的PEM证书",
+		"routers.bad_name":               "路由器名称为空或重复: %q",
+		"routers.no_ip":                  "%s: 需要填写 router_ip",
+		"schedule.bad_day":               "无法识别的星期: %q",
+		"schedule.never":                 "定时任务 %q 没有可执行的日期",
+		"schedule.bad_firewall":          "%s: ipv6_firewall_enable 应为 on 或 off",
+		"schedule.bad_dmz":               "%s: dmz_enable 应为 0 或 1",
+		"flag.sim_addr":                  "监听地址",
+		"flag.sim_password":              "管理员密码",
+		"flag.sim_stok_ttl":              "stok有效期（如 10m），0为不过期",
+		"flag.sim_mesh_primary":          "模拟易展子路由，值为主路由地址",
+		"template.failed":                "模板渲染失败",
+		"traffic.no_host":                "路由器没有主机 %s 的流量统计",
+		"upnp.not_found":                 "未发现支持 %s 的UPnP设备",
+		"upnp.bad_description":           "UPnP设备描述解析失败: %v",
+		"upnp.no_service":                "UPnP设备不提供 %s 服务",
+		"upnp.failed":                    "UPnP %s 失败: %s %s",
+		"wifi.unknown_switch":            "未知的开关 %s",
+		"punct.colon":                    "：",
+		"punct.comma":                    "，",
+		"punct.open":                     "（",
+		"punct.close":                    "）",
+
+		// 调试日志
+		"debug.resolved":              "%s 解析为 %s",
+		"debug.gateway_mac_failed":    "读取网关 %s 的MAC失败: %v",
+		"debug.no_location":           "当前网络未匹配任何位置: 网关 %s %s, SSID %q",
+		"debug.notify_suppressed":     "通知已抑制: %s %s",
+		"debug.relocate_no_identity":  "没有记录的路由器身份，不自动查找新地址",
+		"debug.relocate_mismatch":     "%s 不是原来的路由器: %v %v",
+		"debug.gateway_failed":        "读取默认网关失败: %v",
+		"debug.rate_limited":          "路由器请求限流，排队 %v",
+		"debug.config_saved":          "已写回配置文件",
+		"debug.identity_unsupported":  "固件不提供设备信息，跳过身份校验",
+		"debug.localfw_removed":       "已删除本机防火墙规则 %s",
+		"debug.upnp_read_failed":      "读取UPnP映射失败: %v",
+		"debug.router_timing":         "路由器操作 %s 耗时 %v",
+		"debug.already_desired":       "路由器已是目标状态，跳过设置",
+		"debug.stok_expired":          "stok已失效，重新登录路由器",
+		"debug.router_request":        "请求路由器 %s: %s",
+		"debug.router_response":       "路由器响应: %s",
+		"debug.state_read_failed":     "读取路由器状态失败: %v",
+		"debug.plugin_timing":         "插件 %s %s 耗时 %v，错误输出: %s",
+		"debug.plugin_response":       "插件 %s 响应: %s",
+		"debug.traffic_failed":        "读取流量失败: %v",
+		"debug.router_switched":       "已切换到路由器 %q (%s)",
+		"debug.sd_notify_failed":      "sd_notify 失败: %v",
+		"debug.oidc_token_invalid":    "access token无效: %v",
+		"debug.oidc_userinfo_failed":  "userinfo请求失败: %v",
+		"debug.wifi_read_failed":      "读取 %s 失败: %v",
+		"debug.retry":                 "%s 失败，%v 后第%d次重试: %v",
+		"debug.forwards_failed":       "读取端口转发规则失败: %v",
+		"debug.forward_delete_failed": "删除端口转发规则 %s 失败: %v",
+		"debug.upnp_delete_failed":    "删除UPnP映射 %s 失败: %v",
+		"debug.next_reboot":           "下一次定时重启于 %s",
+		"debug.watch_failed":          "守护读取路由器状态失败: %v",
+		"debug.agent_connect_failed":  "连接中心失败: %v",
+		"debug.hook_timing":           "钩子 %q 耗时 %v，输出: %s",
+		"debug.next_schedule":         "下一个定时任务 %s 于 %s 执行",
+		"debug.form":                  "表单: %s",
+	},
+	"en": {
+		"title":                          "TP-LINK IPv6 Firewall Settings",
+		"state.current":                  "Current router state",
+		"state.ipv6_firewall":            "IPv6 firewall",
+		"breaker.open":                   "Router keeps failing, requests are paused",
+		"breaker.retry_in":               "retry in %s",
+		"form.router_ip.placeholder":     "e.g. 192.168.0.1 or tplinkwifi.net",
+		"form.stok.placeholder":          "router session token",
+		"form.router_password":           "Router admin password",
+		"form.router_password.hint":      "optional; logs in to obtain the stok automatically",
+		"form.router_password.saved":     "saved, leave empty to keep",
+		"login.bad_password":             "wrong router admin password",
+		"console.login_ok":               "Logged in to router %s and obtained a new stok",
+		"console.login_failed":           "Automatic router login failed: %v",
+		"form.ipv6_firewall":             "IPv6 Firewall Enable (on/off)",
+		"form.ipv6_firewall.placeholder": "on or off",
+		"form.dmz_enable":                "DMZ Enable (1=on, 0=off)",
+		"form.dmz_enable.placeholder":    "0 or 1",
+		"form.example":                   "e.g.",
+		"form.submit":                    "Submit",
+		"success.message":                "Done! You can close the browser and press Enter in the program to exit.",
+		"error.failed":                   "Operation failed",
+		"error.back":                     "Back",
+		"warn.dmz_enable":                "DMZ enable must be 0 or 1, keeping previous value: %s",
+		"warn.wan_port":                  "WAN port must be a non-negative integer, keeping previous value: %s",
+		"form.wan_port":                  "DMZ WAN port",
+		"form.wan_port.placeholder":      "0 for single-WAN; on dual-WAN models use the WAN number shown by the router",
+		"console.breaker_recovered":      "Router is responding again, circuit closed",
+		"console.breaker_open":           "Router failed %d times in a row, pausing requests for %v",
+		"console.cassette_write_failed":  "Failed to write cassette file: %v",
+		"console.kill_failed":            "Warning: cannot terminate process %d: %v",
+		"console.simulator_error":        "Simulator error: %v",
+		"console.config_read_failed":     "Failed to read config file: %v",
+		"console.config_reloaded":        "Config file %s changed, reloaded",
+		"console.config_reload_failed":   "Failed to reload config file, keeping the current config: %v",
+		"console.config_fallback":        "You can enter the settings in the web page; the server uses default port 8080...",
+		"console.bad_duration":           "Invalid %s (%v), using default %s",
+		"console.bad_cache_ttl":          "Invalid state_cache_ttl (%v), state queries will not be cached",
+		"console.cassette_open_failed":   "Failed to open cassette file: %v",
+		"console.cassette_replay":        "Replay mode: router responses come from %s",
+		"console.cassette_record":        "Record mode: router interactions are written to %s",
+		"console.templates_failed":       "Failed to load page templates: %v",
+		"console.server_started":         "Server started, visit %s",
+		"console.listen_failed":          "Cannot listen on %s: %v",
+		"input.method":                   "Method %s not allowed",
+		"input.content_type":             "Content-Type must be %s",
+		"input.bad_form":                 "Invalid form: %v",
+		"console.browser_failed":         "Could not open the browser, please visit %s manually\nReason: %v",
+		"console.browser_opened":         "Opened the default browser; if nothing shows up, visit the address above manually",
+		"console.server_error":           "Server error: %v",
+		"console.port_hint":              "Hint: port %s may be in use, change server_port in config.json (e.g. 8081)",
+		"console.press_enter":            "Press Enter to exit...",
+		"console.shutting_down":          "Shutting down...",
+		"console.unsupported_os":         "Unsupported operating system: %s",
+		"console.simulator_started":      "Router simulator listening on http://%s",
+		"console.simulator_usage":        "Use router_ip=%s stok=%s",
+		"console.template_failed":        "Failed to render template %s: %v",
+		"console.hook_failed":            "Warning: %v",
+		"console.exposure":               "Exposure audit: %s",
+		"warn.exposed_port":              "%s (%s) is now reachable from the internet",
+		"advisor.link":                   "Only need a few ports? See options with less exposure",
+		"advisor.title":                  "Exposure advisor",
+		"advisor.intro":                  "Turning the IPv6 firewall off and enabling DMZ exposes every port of the host to the internet. If you only need a few ports, list them and the least-exposure option will be recommended.",
+		"advisor.ports":                  "Ports to open (e.g. tcp:443, udp:51820)",
+		"advisor.full_cone":              "All ports of the host must be reachable (e.g. a console needing full-cone NAT)",
+		"advisor.submit":                 "Get advice",
+		"advisor.option":                 "Option",
+		"advisor.exposure":               "Exposure",
+		"advisor.exposure.low":           "listed ports only",
+		"advisor.exposure.high":          "all ports of the host",
+		"advisor.apply":                  "Apply",
+		"advisor.ipv6_rule":              "Router IPv6 allow rule",
+		"advisor.ipv6_rule.detail":       "Keep the IPv6 firewall on and only allow %s to %s",
+		"advisor.upnp_pinhole":           "UPnP IPv6 pinhole",
+		"advisor.upnp_pinhole.detail":    "Temporarily open the ports via UPnP IGDv2 (24 hours); most routers require running this tool on the target host",
+		"advisor.full_open":              "IPv6 firewall off + DMZ",
+		"advisor.full_open.detail":       "Every port of the target host becomes reachable from the internet",
+		"advisor.reason.no_target":       "No DMZ destination IPv6 configured",
+		"advisor.reason.no_ports":        "No ports listed",
+		"advisor.result.rule":            "Added IPv6 allow rule %s → %s",
+		"advisor.result.pinhole":         "Added UPnP pinhole %s (ID %s)",
+		"advisor.result.full_open":       "IPv6 firewall turned off and DMZ enabled",
+		"warn.exposed_risky_port":        "DANGER: %s (%s) is now reachable from the internet; such services are constantly scanned and attacked, consider closing it or using port forwarding instead",
+		"console.history_failed":         "Failed to write history: %v",
+		"console.history_migrated":       "Imported legacy history %s into %s",
+		"console.notify":                 "[notify] %s: %s",
+		"console.notify_failed":          "Failed to send %s notification: %v",
+		"console.plugin_loaded":          "Loaded plugin %s (%s)",
+		"console.plugin_failed":          "Failed to load plugin %s: %v",
+		"console.plugin_backend":         "Backend plugin %s is available as firmware_type \"%s\"",
+		"console.plugin_backend_taken":   "Backend plugin %s clashes with the existing firmware_type \"%s\" and was not registered",
+		"plugin.bad_describe":            "describe output is not a valid plugin description",
+		"notify.router_down":             "Router offline",
+		"notify.location_changed":        "Network changed",
+		"notify.location_changed.detail": "Now on %s, using router %s",
+		"console.location_apply_failed":  "Running profile %s failed: %s",
+		"notify.reboot_soon":             "Router reboot soon",
+		"notify.reboot_soon.detail":      "Router %s will reboot as scheduled at %s; the network will be down meanwhile",
+		"notify.reboot_done":             "Router rebooted",
+		"notify.reboot_done.detail":      "Router %s rebooted and the settings were applied again",
+		"notify.reboot_failed":           "Scheduled reboot failed",
+		"notify.reboot_timeout.detail":   "Router %s did not come back in time after rebooting; settings were not applied again",
+		"notify.reboot_reapply.detail":   "Router %s rebooted but applying the settings again failed: %s",
+		"console.reboot_sent":            "Reboot command sent to router %s, waiting for it to come back",
+		"console.reboot_failed":          "Scheduled reboot failed: %v",
+		"console.reboot_skipped":         "Skipped scheduled reboot: %v",
+		"notify.router_down.detail":      "Router %s did not answer %d probes in a row; it may be rebooting and the firewall settings may be reverted",
+		"notify.traffic":                 "Traffic threshold exceeded",
+		"notify.traffic_daily.detail":    "%s used %d MB today, above the daily threshold of %d MB",
+		"notify.traffic_monthly.detail":  "%s used %d MB this month, above the monthly threshold of %d MB",
+		"state.traffic":                  "Traffic for %s: %s today, %s this month",
+		"notify.router_up":               "Router back online",
+		"notify.router_up.detail":        "Router %s is back after %v of downtime",
+		"notify.router_moved":            "Router address changed",
+		"notify.router_moved.detail":     "Router moved from %s to %s (%s); now using the new address",
+		"state.router_down":              "Router unreachable since %s",
+		"conflict.title":                 "Conflicts with the router's current configuration",
+		"conflict.overwrite":             "Overwrite and continue",
+		"conflict.cancel":                "Cancel",
+		"conflict.dmz_target":            "DMZ currently points at another host %s; continuing will switch it to the new target",
+		"conflict.port_forward":          "Port %s is already used by port forward %s (to %s)",
+		"conflict.ipv6_rule":             "Port %s already has IPv6 allow rule %s (target %s)",
+		"conflict.upnp":                  "Port %s already has a UPnP mapping (%s, %s)",
+		"sync.label":                     "Sync",
+		"sync.in_sync":                   "in sync",
+		"sync.drifted":                   "drifted (router differs from desired state)",
+		"sync.unknown":                   "unknown",
+		"sync.last_apply":                "last apply",
+		"sync.confirmed_at":              "last confirmed",
+		"console.state_load_failed":      "Failed to read state file: %v",
+		"console.state_save_failed":      "Failed to save state file: %v",
+		"console.config_save_failed":     "Failed to write settings back to the config file: %v",
+		"notify.target_down":             "DMZ target offline",
+		"notify.target_down.detail":      "DMZ target %s did not answer %d checks in a row",
+		"notify.target_up":               "DMZ target back online",
+		"notify.target_up.detail":        "DMZ target %s is back after %v of downtime",
+		"notify.target_ipv6_gone":        "DMZ target IPv6 address gone",
+		"notify.target_ipv6_gone.detail": "Host %s is up but IPv6 address %s does not answer; the IPv6 prefix probably changed, update dmz_dest_ip6",
+		"error.ok":                       "Success",
+		"code.busy":                      "The router is busy (maybe saving settings, upgrading or rebooting). Wait a minute and try again",
+		"code.table_full":                "The router has reached the maximum number of entries. Delete unused entries in the router admin page first",
+		"code.bad_format":                "The router did not understand the request format, likely a different firmware. Please report the router model and firmware version",
+		"code.invalid_param":             "The router rejected a parameter. Check the IP addresses, on/off values and WAN port",
+		"code.unsupported":               "The router firmware does not support this feature or field. Try upgrading the firmware, or change only the IPv6 firewall or only DMZ",
+		"code.bad_credentials":           "Wrong router admin password; enter it again in the settings",
+		"code.login_locked":              "Too many wrong passwords; the router has locked logins for a while. Try again later with the correct password",
+		"code.unauthorized":              "The stok is invalid or expired. Copy a new stok from the router admin page, or fill in the admin password to log in automatically",
+		"code.unknown":                   "The router returned unknown error code %d; check the router and try again",
+		"error.auth_expired":             "The stok is invalid or expired. Log in to the router admin page again and copy a new stok with the F12 developer tools, or fill in the router admin password to log in automatically",
+		"error.unreachable":              "Cannot reach the router. Check that Router IP is correct and this computer is connected to that router",
+		"error.unsupported":              "The router firmware does not support this operation",
+		"error.bad_parameter":            "Invalid parameter, please check your input",
+		"error.circuit_open":             "The router failed repeatedly; requests are paused and will resume after the cooldown",
+		"error.rate_limited":             "Too many requests to the router, please try again later",
+		"error.maintenance":              "A maintenance window is active; automatic tasks will not modify the router",
+		"console.maintenance_skip":       "Skipping automatic change: %v",
+		"console.config_invalid":         "Invalid config %s: %v",
+		"state.maintenance":              "Maintenance window %s active: automatic tasks observe only",
+		"console.schedule_done":          "Schedule %s applied",
+		"console.schedule_failed":        "Schedule %s failed: %v",
+		"state.next_schedule":            "Next schedule: %s at %s",
+		"state.location":                 "Current network: %s",
+		"state.unchanged":                "(matches the configuration, no change needed)",
+		"notify.suppressed":              "(%d more identical notifications were suppressed)",
+		"stats.title":                    "Statistics",
+		"preview.title":                  "Request preview",
+		"preview.button":                 "Preview",
+		"preview.note":                   "This request has not been sent; compare it with what your firmware uses (stok hidden)",
+		"flag.dry_run":                   "print the URL and body that would be sent to the router without sending them",
+		"flag.router":                    "use the router with this name from routers",
+		"router.unknown":                 "no router named %q in routers",
+		"router.label":                   "Router",
+		"router.default":                 "Default (top-level config)",
+		"router.switch":                  "Switch",
+		"mesh.satellite":                 "%s is a mesh satellite; the IPv6 firewall and DMZ must be changed on the primary router (%s)",
+		"mesh.use_primary":               "Use primary router %s",
+		"mesh.redirected":                "%s is a mesh satellite; switched to the primary router %s",
+		"mesh.not_satellite":             "The router is not a mesh satellite or did not report its primary router",
+		"router.apply_all":               "Apply to all routers",
+		"router.apply_all_summary":       "%d succeeded, %d failed",
+		"router.apply_all_partial":       "%d/%d routers failed",
+		"router.result_ok":               "%s (%s): OK, IPv6 firewall %s, DMZ %s, took %v",
+		"router.result_failed":           "%s (%s): failed, %s",
+		"flag.all_routers":               "apply each router's desired settings to the top-level router and every entry in routers concurrently",
+		"flag.only_firewall":             "change only the IPv6 firewall and leave DMZ untouched",
+		"flag.only_dmz":                  "change only DMZ and leave the IPv6 firewall untouched",
+		"form.only_firewall":             "Only firewall",
+		"form.only_dmz":                  "Only DMZ",
+		"rollback.button":                "Undo",
+		"rollback.previous":              "Before the last change: IPv6 firewall %s, DMZ %s %s %s",
+		"rollback.none":                  "There is no change to undo",
+		"rollback.done":                  "Restored the settings from before the last change: IPv6 firewall %s, DMZ %s %s %s",
+		"history.title":                  "Change history",
+		"history.disabled":               "history_file is not set, changes are not recorded",
+		"history.empty":                  "No changes recorded yet",
+		"history.time":                   "Time",
+		"history.source":                 "Source",
+		"history.actor":                  "By",
+		"history.result":                 "Result",
+		"history.failed":                 "failed",
+		"history.message":                "Message",
+		"history.response":               "Router response",
+		"flag.history_limit":             "maximum number of entries to list, 0 = all",
+		"log.bad_level":                  "unknown log level %q, expected debug/info/warn/error",
+		"log.bad_format":                 "unknown log format %q, expected console/json",
+		"log.bad_rotation":               "values in log_rotation must not be negative",
+		"log.bad_facility":               "unknown syslog facility %q, expected user/daemon/local0-local7",
+		"log.no_local_syslog":            "no local syslog found; set syslog.network and syslog.address to a remote server",
+		"log.eventlog_unsupported":       "the event log is only available on Windows; use syslog instead",
+		"flag.log_level":                 "log level debug/info/warn/error, overrides log_level in the config",
+		"flag.log_format":                "log format console/json, overrides log_format in the config",
+		"console.router_log_failed":      "Could not write the router request log: %v",
+		"apidocs.title":                  "API documentation",
+		"apidocs.send":                   "Send",
+		"openapi.description":            "JSON API for setting the IPv6 firewall and DMZ of a TP-LINK router. On failure error.code is a machine-readable error code",
+		"openapi.error":                  "Failure; error carries the error code and message",
+		"openapi.not_ready":              "The last router request failed or the circuit breaker is open",
+		"openapi.status":                 "Read the router's current settings and compare them with the desired state",
+		"openapi.apply":                  "Change the router settings by profile or by field",
+		"openapi.config_get":             "Read the current configuration (without stok and password)",
+		"openapi.config_put":             "Change the given configuration fields without applying them to the router",
+		"openapi.healthz":                "Liveness check",
+		"openapi.readyz":                 "Readiness check",
+		"openapi.metrics":                "Prometheus metrics",
+		"stats.period":                   "Period",
+		"stats.days":                     "days",
+		"stats.since":                    "since",
+		"stats.applies":                  "Successful / total applies",
+		"stats.mean_apply":               "Mean apply time",
+		"stats.watchdog":                 "Watchdog interventions",
+		"stats.prefix":                   "IPv6 prefix changes",
+		"stats.per_week":                 "per week",
+		"stats.outages":                  "Router outages",
+		"stats.downtime":                 "Total router downtime",
+		"stats.latency":                  "Router operation latency (this run)",
+		"stats.count":                    "Count",
+		"stats.errors":                   "Errors",
+		"stats.avg":                      "Avg",
+		"stats.max":                      "Max",
+		"auth.required":                  "Authentication required",
+		"auth.forbidden":                 "This account is read-only and cannot change settings",
+		"auth.cross_site":                "Refusing a change submitted from another site; use this program's own pages",
+		"auth.user_incomplete":           "a user is missing name or password",
+		"auth.bad_role":                  "user %s has invalid role %q, expected admin or viewer",
+		"auth.duplicate_user":            "duplicate user %s",
+		"auth.signed_in":                 "Signed in as %s (%s)",
+		"auth.read_only":                 "Read-only account: status view only",
+		"console.hash_password_usage":    "usage: hash-password <password>",
+		"auth.logout":                    "Sign out",
+		"oidc.issuer_mismatch":           "provider reported a different issuer: %s",
+		"oidc.client_id_required":        "client_id is required when OIDC is enabled",
+		"oidc.unavailable":               "Cannot reach the identity provider: %s",
+		"oidc.bad_state":                 "Login state is invalid or expired, please sign in again",
+		"oidc.denied":                    "The identity provider denied the login: %s",
+		"oidc.failed":                    "Single sign-on failed, see the log for details",
+		"oidc.token_unverifiable":        "the access token is not a JWT and the provider has no introspection endpoint, so its audience cannot be checked",
+		"oidc.token_inactive":            "the access token has expired or been revoked",
+		"oidc.token_audience":            "the access token was not issued to this client",
+		"agent.title":                    "Remote agents",
+		"agent.name":                     "Name",
+		"agent.status":                   "Status",
+		"agent.online":                   "online",
+		"agent.offline":                  "offline",
+		"agent.last_seen":                "Last seen",
+		"agent.last_result":              "Last result",
+		"agent.pending":                  "%d change(s) waiting for delivery",
+		"agent.unknown":                  "Unknown agent: %s",
+		"agent.queued":                   "Queued settings for agent %s",
+		"agent.result_ok":                "Agent %s applied the settings",
+		"agent.result_failed":            "Agent %s failed to apply the settings: %s",
+		"agent.weak_token":               "token for agent %q must be at least 16 characters",
+		"agent.incomplete":               "agent requires both name and token",
+		"agent.connected":                "Connected to controller %s",
+		"agent.connect_failed":           "Lost connection to controller: %v",
+		"agent.applied":                  "Applied settings %s from controller: %s",
+		"guest.title":                    "Guest network",
+		"guest.enable":                   "Guest network enabled",
+		"guest.isolate":                  "Isolate guest devices",
+		"guest.access_lan":               "Allow access to main network",
+		"guest.updated":                  "Updated guest network %s",
+		"guest.lan_warning":              "DMZ is enabled while guests can still reach the main network; consider disabling \"Allow access to main network\"",
+		"iptv.title":                     "IPTV/VLAN",
+		"iptv.intro":                     "Switching the ISP gateway to bridge mode or using DMZ often requires adjusting the IPTV and internet VLAN bindings. Empty fields are left unchanged.",
+		"iptv.enable":                    "IPTV/VLAN enabled",
+		"iptv.mode":                      "Mode",
+		"iptv.internet_vid":              "Internet VLAN ID",
+		"iptv.internet_prio":             "Internet priority",
+		"iptv.iptv_vid":                  "IPTV VLAN ID",
+		"iptv.iptv_prio":                 "IPTV priority",
+		"iptv.igmp_snooping":             "IGMP snooping",
+		"iptv.lan1":                      "LAN1 usage",
+		"iptv.lan2":                      "LAN2 usage",
+		"iptv.lan3":                      "LAN3 usage",
+		"iptv.lan4":                      "LAN4 usage",
+		"iptv.modes":                     "Available modes",
+		"iptv.invalid":                   "Invalid values for: %s",
+		"iptv.updated":                   "Updated IPTV/VLAN settings",
+		"quick.title":                    "Quick toggles",
+		"quick.wifi_2g":                  "2.4G Wi-Fi",
+		"quick.wifi_5g":                  "5G Wi-Fi",
+		"quick.guest_2g":                 "2.4G guest",
+		"quick.guest_5g":                 "5G guest",
+		"quick.turn_on":                  "Turn on",
+		"quick.turn_off":                 "Turn off",
+		"quick.done":                     "%s switched %s",
+		"access.title":                   "Access control",
+		"access.alias":                   "Note",
+		"access.mode":                    "Mode",
+		"access.mode.block":              "Block internet",
+		"access.mode.schedule":           "Internet only during schedule",
+		"access.time":                    "Allowed time",
+		"access.enable":                  "Enabled",
+		"access.delete":                  "Delete",
+		"access.add":                     "Add rule",
+		"access.empty":                   "No rules",
+		"access.bad_mac":                 "Invalid MAC address: %s",
+		"access.added":                   "Added access control rule for %s",
+		"access.deleted":                 "Deleted access control rule %s",
+		"access.toggled":                 "Access control rule %s set to %s",
+		"routes.title":                   "Static routes",
+		"routes.target":                  "Destination",
+		"routes.netmask":                 "Netmask",
+		"routes.gateway":                 "Gateway",
+		"routes.interface":               "Interface",
+		"routes.add":                     "Add route",
+		"routes.empty":                   "No static routes",
+		"routes.bad_target":              "Invalid destination: %s %s",
+		"routes.not_network":             "%s is not the network address for %s",
+		"routes.bad_gateway":             "Invalid gateway: %s",
+		"routes.added":                   "Added static route %s/%s via %s",
+		"routes.deleted":                 "Deleted static route %s",
+		"dhcp.title":                     "DHCP server",
+		"dhcp.enable":                    "DHCP server enabled",
+		"dhcp.pool_start":                "Pool start",
+		"dhcp.pool_end":                  "Pool end",
+		"dhcp.lease_time":                "Lease time (minutes)",
+		"dhcp.gateway":                   "Gateway",
+		"dhcp.pri_dns":                   "Primary DNS",
+		"dhcp.snd_dns":                   "Secondary DNS",
+		"dhcp.updated":                   "Updated DHCP server settings",
+		"dhcp.reservations":              "Address reservations",
+		"dhcp.reservations_hint":         "Reserve a fixed address for the DMZ host so DMZ keeps pointing at it after lease renewal",
+		"dhcp.reserve":                   "Reserve",
+		"dhcp.bad_reservation":           "Invalid MAC or IP address",
+		"dhcp.reserved":                  "Reserved %[2]s for %[1]s",
+		"dhcp.unreserved":                "Deleted reservation %s",
+		"wandmz.title":                   "Multi-WAN DMZ",
+		"wandmz.intro":                   "Multi-WAN routers can expose a separate DMZ host on each WAN. The table shows which host each WAN currently exposes.",
+		"wandmz.unsupported":             "This firmware only has a DMZ for one WAN",
+		"wandmz.primary":                 "main DMZ",
+		"wandmz.exposes":                 "Status",
+		"wandmz.exposed":                 "Exposed",
+		"wandmz.closed":                  "Not exposed",
+		"wandmz.enabled":                 "Enabled",
+		"wandmz.disabled":                "Disabled",
+		"wandmz.add":                     "Set a DMZ for another WAN",
+		"wandmz.bad_wan":                 "Invalid WAN: %s",
+		"wandmz.bad_ip":                  "Invalid address: %s",
+		"wandmz.done":                    "DMZ on %s set to %s (%s)",
+		"trigger.title":                  "Port triggering",
+		"trigger.intro":                  "When a LAN device connects out on the trigger port, the router temporarily opens the listed ports to it. Useful for games that open dynamic ports.",
+		"trigger.unsupported":            "This firmware does not support port triggering",
+		"trigger.app":                    "Application",
+		"trigger.trigger_port":           "Trigger port",
+		"trigger.open_port":              "Open ports",
+		"trigger.add":                    "Add rule",
+		"trigger.empty":                  "No port triggering rules",
+		"trigger.bad_port":               "Invalid port: %s",
+		"trigger.added":                  "Added port trigger %s -> %s",
+		"trigger.deleted":                "Deleted port triggering rule %s",
+		"trigger.toggled":                "Port triggering rule %s set to %s",
+		"time.title":                     "Time & NTP",
+		"time.router_time":               "Router time",
+		"time.local_time":                "Local time",
+		"time.skew":                      "Skew",
+		"time.skew_warning":              "The router clock is off by %s; schedules on the router may misfire. Check the NTP settings",
+		"time.tz_differs":                "The router timezone differs from the timezone used by this tool's schedules",
+		"time.ntp_enable":                "Sync time automatically (NTP)",
+		"time.ntp_server1":               "Primary NTP server",
+		"time.ntp_server2":               "Secondary NTP server",
+		"time.timezone":                  "Timezone (e.g. +08:00)",
+		"time.updated":                   "Updated router time settings",
+		"error.identity_mismatch":        "The device at router_ip does not match the recorded router (its address may have changed); the change was refused. If you replaced the router, confirm it on the error page",
+		"identity.mismatch":              "Device at %s does not match: expected %s, got %s",
+		"identity.forget":                "I replaced the router, re-learn its identity",
+		"identity.forgotten":             "Forgot the recorded router identity; it will be re-learned on the next change",
+		"clipboard.paste":                "Paste from clipboard",
+		"clipboard.watch":                "Watch clipboard",
+		"clipboard.not_found":            "No stok found in the clipboard",
+		"clipboard.filled":               "Filled stok from the clipboard",
+		"clipboard.local_only":           "The clipboard can only be read from this computer",
+		"clipboard.unsupported":          "Reading the clipboard is not supported on this system",
+		"ctl.usage":                      "usage: ctl [-config file] [-server url] [-user name] status | apply [-preset name -action open|close] [-ipv6-firewall on|off] [-dmz 0|1] [-dmz-ip IP] [-dmz-ip6 IP] | logs [-f] [-n lines]; the password is read from TPLINK_CTL_PASSWORD",
+		"ctl.unreachable":                "Cannot connect to %s: %v",
+		"ctl.applied":                    "Settings applied",
+		"apply.unchanged":                "Already in the desired state, nothing was sent",
+		"apply.unchanged_state":          "Already in the desired state: IPv6 firewall %s, DMZ %s %s %s",
+		"ctl.apply_failed":               "ctl apply failed: %s",
+		"deeplink.bad_profile":           "profile name is empty or duplicated: %q",
+		"deeplink.unknown_profile":       "Profile not found: %s",
+		"deeplink.unknown_action":        "Unknown action: %s (use open/close)",
+		"deeplink.confirm":               "Run %[2]s for %[1]s?",
+		"deeplink.done":                  "Ran %[2]s for %[1]s",
+		"deeplink.done_path":             "Ran %[2]s for %[1]s via %[3]s",
+		"fallback.no_ipv6":               "No public IPv6 on the WAN; profile %s falls back to IPv4 via %s",
+		"fallback.incomplete":            "Profile %s sets ipv4_fallback and needs both ports and dmz_dest_ip",
+		"fallback.path.ipv6":             "IPv6 (firewall off + DMZ)",
+		"fallback.path.port_forward":     "IPv4 port forwarding",
+		"fallback.path.upnp":             "IPv4 UPnP port mapping",
+		"fallback.path.":                 "none",
+		"deeplink.failed":                "Running %[2]s for %[1]s failed: %[3]s",
+		"flag.config":                    "config file path (.json, .yaml, .toml), - to read JSON from stdin; if the default config.json is missing, config.yaml, config.yml and config.toml are tried in turn",
+		"flag.convert_from":              "input format json/yaml/toml; taken from the extension, or guessed from the content when reading stdin",
+		"flag.convert_to":                "output format json/yaml/toml; taken from the extension when an output file is given",
+		"flag.convert_force":             "overwrite an existing output file",
+		"config.usage":                   "usage: tplinkfirewalloff config convert [-from format] [-to json|yaml|toml] [-force] <input|-> [output] | config env (list TPLINK_ environment variables)",
+		"env.bad_value":                  "invalid value in environment variable %s: %v",
+		"flag.config_field":              "overrides %s in the config",
+		"convert.usage":                  "usage: tplinkfirewalloff config convert [-from format] [-to json|yaml|toml] [-force] <input|-> [output]",
+		"convert.bad_format":             "unsupported config format %q, expected .json, .yaml, .yml or .toml",
+		"convert.exists":                 "%s already exists, use -force to overwrite",
+		"convert.header":                 "Converted from %s; comments added here are kept when settings are written back",
+		"convert.done":                   "Converted %s to %s",
+		"flag.apply":                     "apply the settings from the config and flags once and exit without the web UI",
+		"flag.daemon":                    "run as a background service: no browser, no console input, exit on SIGTERM or Ctrl+C",
+		"flag.no_browser":                "do not open the browser on startup",
+		"flag.no_stdin":                  "do not read standard input (no waiting for Enter); exit only on SIGTERM or Ctrl+C",
+		"flag.systemd_user":              "user the service runs as",
+		"console.no_display":             "no graphical display",
+		"headless.no_router":             "router_ip and stok (or the admin password) are not configured",
+		"headless.applied":               "Applied: IPv6 firewall %s, DMZ %s %s %s",
+		"flag.json":                      "print JSON",
+		"cli.usage":                      "usage: tplinkfirewalloff [serve|apply|status|watch|history|rollback|login|discover|ctl|config|systemd-unit|simulator|hash-password] [flags]",
+		"flag.watch_interval":            "check interval such as 30s; defaults to watch.interval from the config or 60s",
+		"console.watch_started":          "Watching router %s every %v, press Ctrl+C to exit",
+		"console.watch_failed":           "re-applying failed: %s",
+		"notify.drift":                   "Router settings reverted",
+		"notify.drift.detail":            "Router %s has IPv6 firewall %s and DMZ %s instead of the configured %s and %s",
+		"watch.reapplied":                "re-applied the settings",
+		"status.router":                  "Router: %s",
+		"status.current":                 "Current: IPv6 firewall %s, DMZ %s %s %s",
+		"status.desired":                 "Configured: IPv6 firewall %s, DMZ %s %s %s",
+		"status.sync":                    "Sync: %s",
+		"login.prompt":                   "Router admin password: ",
+		"discover.found":                 "Found router %s (%s)",
+		"discover.unknown":               "not logged in, model unknown",
+		"discover.none":                  "No router found on the default gateway or common addresses",
+		"flag.ctl_server":                "URL of the running instance, defaults to the local listener from the config",
+		"flag.ctl_user":                  "user name to log in with",
+		"flag.preset":                    "preset from profiles to apply",
+		"flag.ctl_action":                "profile action open/close",
+		"flag.ctl_follow":                "keep printing new events",
+		"flag.ctl_lines":                 "number of recent events to show",
+		"console.press_ctrl_c":           "Press Ctrl+C to exit...",
+		"listener.cert_key":              "listener %s needs both tls_cert and tls_key",
+		"listener.bad_auth":              "listener %s has invalid auth %q, expected required or none",
+		"console.status_file_failed":     "Failed to write status file: %v",
+		"console.diagnostics_failed":     "Failed to build diagnostic bundle: %v",
+		"diag.link":                      "Download diagnostic bundle",
+		"diag.exported":                  "Diagnostic bundle exported",
+		"console.router_move_loaded":     "Router address changed from %s to %s; using the new address",
+		"console.resolve_fallback":       "Cannot resolve %s, using last known address %s",
+		"console.router_move_auth":       "A device at %s responded but the stok is invalid, so it cannot be confirmed as the same router; log in again and update router_ip",
+		"console.local_firewall_added":   "Added local inbound firewall rule %s: %s",
+		"console.local_firewall_failed":  "Failed to sync local firewall rule (run as administrator): %v",
+		"local_firewall.all_ports":       "all ports",
+		"local_firewall.unsupported":     "Configuring the local firewall is not supported on this system",
+		"error.router":                   "The router returned an error",
+		"advisor.bad_port":               "Invalid port: %s",
+		"advisor.unknown_plan":           "Unknown plan: %s",
+		"breaker.probing":                "Probing whether the router has recovered",
+		"response.missing":               "The response has no %s",
+		"response.missing_table":         "The response has no table %s",
+		"response.content_type":          "Unexpected response type: %s",
+		"response.read_failed":           "Failed to read the response: %v",
+		"legacy.bad_dmz_page":            "Unrecognized DMZ page format",
+		"cassette.bad_file":              "Invalid cassette file: %v",
+		"cassette.bad_mode":              "Unknown cassette_mode %q (expected record or replay)",
+		"cassette.no_match":              "No matching cassette entry: %s %s %s",
+		"cron.fields":                    "A cron expression needs 5 fields (minute hour day month weekday): %q",
+		"cron.out_of_range":              "Cron field %q is outside %d-%d",
+		"cron.bad_step":                  "Invalid cron step: %q",
+		"cron.bad_range":                 "Invalid cron range: %q",
+		"cron.never":                     "The cron expression never fires",
+		"kind.auth_expired":              "stok invalid or expired",
+		"kind.unreachable":               "Cannot reach the router",
+		"kind.unsupported":               "Not supported by the firmware",
+		"kind.bad_parameter":             "Invalid parameter",
+		"kind.router":                    "The router returned an error",
+		"kind.circuit_open":              "The router failed repeatedly; requests are paused",
+		"kind.rate_limited":              "Too many requests",
+		"kind.maintenance":               "Maintenance window; automatic changes are paused",
+		"kind.identity_mismatch":         "The router identity does not match the record",
+		"gateway.not_in_arp":             "%s is not in the ARP table",
+		"guest.unsupported":              "Guest network is not supported",
+		"hook.failed":                    "%s hook %q failed: %v",
+		"exec.timeout":                   "timed out after %v",
+		"plugin.failed":                  "%s plugin %s failed: %v",
+		"limits.too_large":               "Data exceeds the %d byte limit",
+		"limits.body_too_large":          "Request body too large",
+		"location.bad_name":              "Network name is empty or duplicated: %q",
+		"location.incomplete":            "%s: gateway_mac or ssid is required",
+		"location.bad_mac":               "%s: invalid MAC address %q",
+		"maintenance.bad_time":           "Time must be HH:MM: %q",
+		"console.oidc_failed":            "OIDC login failed: %v",
+		"oidc.id_token_issuer":           "id_token iss mismatch: %s",
+		"oidc.id_token_audience":         "id_token aud mismatch",
+		"oidc.id_token_expired":          "id_token expired",
+		"oidc.id_token_nonce":            "id_token nonce mismatch",
+		"oidc.bad_jwt":                   "The token is not a valid JWT",
+		"persist.not_object":             "The top level of the config file is not a JSON object",
+		"ratelimit.wait":                 "Would wait %v, more than the %v limit",
+		"tls.no_pem":                     "ca_file %s: no usable PEM certificates",
+		"routers.bad_name":               "Router name is empty or duplicated: %q",
+		"routers.no_ip":                  "%s: router_ip is required",
+		"schedule.bad_day":               "Unknown weekday: %q",
+		"schedule.never":                 "Schedule %q never runs",
+		"schedule.bad_firewall":          "%s: ipv6_firewall_enable must be on or off",
+		"schedule.bad_dmz":               "%s: dmz_enable must be 0 or 1",
+		"flag.sim_addr":                  "Listen address",
+		"flag.sim_password":              "Admin password",
+		"flag.sim_stok_ttl":              "stok lifetime (e.g. 10m), 0 never expires",
+		"flag.sim_mesh_primary":          "Act as a mesh satellite of this primary router address",
+		"template.failed":                "Failed to render the page",
+		"traffic.no_host":                "The router has no traffic statistics for host %s",
+		"upnp.not_found":                 "No UPnP device offers %s",
+		"upnp.bad_description":           "Failed to parse the UPnP device description: %v",
+		"upnp.no_service":                "The UPnP device does not offer %s",
+		"upnp.failed":                    "UPnP %s failed: %s %s",
+		"wifi.unknown_switch":            "Unknown switch %s",
+		"punct.colon":                    ": ",
+		"punct.comma":                    ", ",
+		"punct.open":                     " (",
+		"punct.close":                    ")",
+
+		// 调试日志
+		"debug.resolved":              "%s resolved to %s",
+		"debug.gateway_mac_failed":    "Failed to read the MAC of gateway %s: %v",
+		"debug.no_location":           "The current network matches no location: gateway %s %s, SSID %q",
+		"debug.notify_suppressed":     "Notification suppressed: %s %s",
+		"debug.relocate_no_identity":  "No router identity recorded, not looking for a new address",
+		"debug.relocate_mismatch":     "%s is not the original router: %v %v",
+		"debug.gateway_failed":        "Failed to read the default gateway: %v",
+		"debug.rate_limited":          "Router request rate limited, queued for %v",
+		"debug.config_saved":          "Config file written back",
+		"debug.identity_unsupported":  "Firmware does not report device info, skipping the identity check",
+		"debug.localfw_removed":       "Removed local firewall rule %s",
+		"debug.upnp_read_failed":      "Failed to read UPnP mappings: %v",
+		"debug.router_timing":         "Router operation %s took %v",
+		"debug.already_desired":       "Router already in the desired state, skipping the set",
+		"debug.stok_expired":          "stok expired, logging in to the router again",
+		"debug.router_request":        "Router request %s: %s",
+		"debug.router_response":       "Router response: %s",
+		"debug.state_read_failed":     "Failed to read the router state: %v",
+		"debug.plugin_timing":         "Plugin %s %s took %v, stderr: %s",
+		"debug.plugin_response":       "Plugin %s response: %s",
+		"debug.traffic_failed":        "Failed to read traffic: %v",
+		"debug.router_switched":       "Switched to router %q (%s)",
+		"debug.sd_notify_failed":      "sd_notify failed: %v",
+		"debug.oidc_token_invalid":    "Invalid access token: %v",
+		"debug.oidc_userinfo_failed":  "userinfo request failed: %v",
+		"debug.wifi_read_failed":      "Failed to read %s: %v",
+		"debug.retry":                 "%s failed, retry %[3]d in %[2]v: %[4]v",
+		"debug.forwards_failed":       "Failed to read port forwarding rules: %v",
+		"debug.forward_delete_failed": "Failed to delete port forwarding rule %s: %v",
+		"debug.upnp_delete_failed":    "Failed to delete UPnP mapping %s: %v",
+		"debug.next_reboot":           "Next scheduled reboot at %s",
+		"debug.watch_failed":          "Watch failed to read the router state: %v",
+		"debug.agent_connect_failed":  "Failed to connect to the hub: %v",
+		"debug.hook_timing":           "Hook %q took %v, output: %s",
+		"debug.next_schedule":         "Next scheduled task %s runs at %s",
+		"debug.form":                  "Form: %s",
+	},
+}
+
+// 当前界面和终端语言：优先使用配置，其次是系统locale，都不支持时使用中文
+func currentLanguage() string {
+	configMu.RLock()
+	lang := strings.ToLower(config.Language)
+	configMu.RUnlock()
+	if lang == "" {
+		for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+			if v := os.Getenv(env); v != "" && v != "C" && v != "POSIX" {
+				lang = strings.ToLower(v)
+				break
+			}
+		}
+	}
+	if i := strings.IndexAny(lang, "-_"); i > 0 {
+		lang = lang[:i]
+	}
+	if _, ok := messages[lang]; ok {
+		return lang
+	}
+	return "zh"
+}
+
+// 翻译文案，args 用于格式化占位符
+func tr(key string, args ...interface{}) string {
+	msg, ok := messages[currentLanguage()][key]
+	if !ok {
+		if msg, ok = messages["zh"][key]; !ok {
+			msg = key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// 按当前语言输出一行终端信息（经过脱敏）
+func say(key string, args ...interface{}) {
+	logf("%s\n", tr(key, args...))
+}
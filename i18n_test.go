@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// 每个翻译键在中英文目录中都要有
+func TestCatalogsHaveSameKeys(t *testing.T) {
+	for key := range messages["zh"] {
+		if _, ok := messages["en"][key]; !ok {
+			t.Errorf("%s 缺少英文", key)
+		}
+	}
+	for key := range messages["en"] {
+		if _, ok := messages["zh"][key]; !ok {
+			t.Errorf("%s 缺少中文", key)
+		}
+	}
+}
+
+// 错误类别按当前语言输出，errors.Is 不受影响
+func TestErrorKindTranslated(t *testing.T) {
+	prev := config.Language
+	defer func() { config.Language = prev }()
+	err := routerErr(ErrBadParameter, 0, "x")
+	config.Language = "en"
+	if got := err.Error(); got != "Invalid parameter: x" {
+		t.Errorf("英文: %q", got)
+	}
+	config.Language = "zh"
+	if got := err.Error(); got != "参数错误: x" {
+		t.Errorf("中文: %q", got)
+	}
+	if !errors.Is(err, ErrBadParameter) || errors.Is(err, ErrRouter) {
+		t.Error("errors.Is 应按类别判断")
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 路由器身份。配置了 model/mac 时按配置校验；
+// 否则首次成功读取时记住该地址对应的设备，之后同一地址换了设备就拒绝修改
+type RouterIdentity struct {
+	RouterIP string `json:"router_ip,omitempty"` // 仅用于记住的身份
+	Model    string `json:"model,omitempty"`
+	MAC      string `json:"mac,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"` // 关闭身份校验
+}
+
+func (id RouterIdentity) String() string {
+	return fmt.Sprintf("%s %s", id.Model, id.MAC)
+}
+
+// 读取路由器报告的型号与MAC
+func queryRouterIdentity() (RouterIdentity, error) {
+	return queryIdentityVia(queryRouter, globalConfig{}.Get().RouterIP)
+}
+
+// 经 query 读取 ip 上路由器报告的型号与MAC
+func queryIdentityVia(query func(module string, sections ...string) (sectionState, error), ip string) (RouterIdentity, error) {
+	var st sectionState
+	err := withRetry("get", func() (err error) {
+		st, err = query("device_info", "info")
+		return err
+	})
+	if err != nil {
+		return RouterIdentity{}, err
+	}
+	return identityFromInfo(ip, st["info"]), nil
+}
+
+func identityFromInfo(ip string, info map[string]interface{}) RouterIdentity {
+	id := RouterIdentity{RouterIP: ip}
+	id.Model, _ = info["device_model"].(string)
+	if mac, ok := normalizeMAC(fmt.Sprint(info["mac"])); ok {
+		id.MAC = mac
+	}
+	return id
+}
+
+// 期望的身份是否与实际相符，期望中留空的字段不比较
+func (id RouterIdentity) matches(actual RouterIdentity) bool {
+	if id.Model != "" && !strings.EqualFold(id.Model, actual.Model) {
+		return false
+	}
+	if id.MAC != "" {
+		want, _ := normalizeMAC(id.MAC)
+		if want != actual.MAC {
+			return false
+		}
+	}
+	return true
+}
+
+// 修改路由器设置前调用，确认 router_ip 上仍是预期的设备
+func verifyRouterIdentity() error {
+	cfg := globalConfig{}.Get()
+	expected := cfg.RouterIdentity
+	if expected.Disabled {
+		return nil
+	}
+	configured := expected.Model != "" || expected.MAC != ""
+
+	actual, err := queryRouterIdentity()
+	if errors.Is(err, ErrUnsupportedFirmware) && !configured {
+		sayDebug("debug.identity_unsupported")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !configured {
+		trackedMu.Lock()
+		learned := tracked.Identity
+		if learned == nil || learned.RouterIP != cfg.RouterIP {
+			tracked.Identity = &actual
+			trackedMu.Unlock()
+			saveTrackedState()
+			return nil
+		}
+		trackedMu.Unlock()
+		expected = *learned
+	}
+
+	if !expected.matches(actual) {
+		return identityMismatch(cfg.RouterIP, expected, actual)
+	}
+	return nil
+}
+
+// 不是当前选中的路由器：只按 routers 中为它配置的 router_identity 校验，不记住身份
+func verifyProfileIdentity(client RouterClient, p RouterProfile) error {
+	expected := p.RouterIdentity
+	if expected.Disabled || expected.Model == "" && expected.MAC == "" {
+		return nil
+	}
+	c, ok := client.(dsClient)
+	if !ok {
+		return routerErr(ErrUnsupportedFirmware, codeUnsupported, "router_identity")
+	}
+	actual, err := queryIdentityVia(c.query, p.RouterIP)
+	if err != nil {
+		return err
+	}
+	if !expected.matches(actual) {
+		return identityMismatch(p.RouterIP, expected, actual)
+	}
+	return nil
+}
+
+// 记录身份不符并返回对应的错误
+func identityMismatch(ip string, expected, actual RouterIdentity) error {
+	msg := tr("identity.mismatch", ip, expected, actual)
+	logf("%s\n", msg)
+	recordEvent("identity_mismatch", msg, map[string]interface{}{
+		"router_ip": ip,
+		"expected":  expected.String(),
+		"actual":    actual.String(),
+	})
+	return routerErr(ErrIdentityMismatch, 0, msg)
+}
+
+// POST /identity/forget：用户确认更换了路由器后，忘记记住的身份
+func identityForgetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	trackedMu.Lock()
+	tracked.Identity = nil
+	trackedMu.Unlock()
+	saveTrackedState()
+
+	msg := tr("identity.forgotten")
+	logf("%s\n", msg)
+	recordEvent("identity_forgotten", msg, map[string]interface{}{"router_ip": globalConfig{}.Get().RouterIP})
+	renderTemplate(w, http.StatusOK, "success.html", map[string]interface{}{"Warnings": []string{msg}})
+}
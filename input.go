@@ -0,0 +1,66 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"tplinkfirewalloff/internal/server"
+)
+
+// 注册表单接口：只接受 methods 中的方法（允许GET时也允许HEAD），
+// POST 请求统一校验Content-Type并严格解析表单后再交给处理函数
+func handle(path string, h http.HandlerFunc, methods ...string) {
+	http.HandleFunc(path, checkInput(h, "application/x-www-form-urlencoded", methods))
+}
+
+// 注册JSON接口，未指定方法时只接受POST
+func handleJSON(path string, h http.HandlerFunc, methods ...string) {
+	if len(methods) == 0 {
+		methods = []string{http.MethodPost}
+	}
+	http.HandleFunc(path, checkInput(h, "application/json", methods))
+}
+
+func checkInput(h http.HandlerFunc, contentType string, methods []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !server.MethodAllowed(r.Method, methods) {
+			w.Header().Set("Allow", strings.Join(methods, ", "))
+			http.Error(w, tr("input.method", r.Method), http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			h(w, r)
+			return
+		}
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != contentType {
+			http.Error(w, tr("input.content_type", contentType), http.StatusUnsupportedMediaType)
+			return
+		}
+		if contentType == "application/x-www-form-urlencoded" {
+			if err := r.ParseForm(); err != nil {
+				http.Error(w, tr("input.bad_form", err), http.StatusBadRequest)
+				return
+			}
+			if err := server.CheckForm(r.PostForm); err != nil {
+				http.Error(w, tr("input.bad_form", err), http.StatusBadRequest)
+				return
+			}
+		}
+		h(w, r)
+	}
+}
+
+// 表单字段的读取与绑定在 server 包中
+type formData = server.Form
+
+var formOf = server.FormOf
+
+// 同 server.Bind，格式错误时返回 ErrBadParameter
+func bindForm(f formData, dst interface{}) error {
+	if err := server.Bind(f, dst); err != nil {
+		return routerErr(ErrBadParameter, 0, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+// Package browser 在本机默认浏览器中打开网址，并跟踪启动的进程，程序退出时一并清理
+package browser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoDisplay   = errors.New("browser: no graphical display")
+	ErrUnsupported = errors.New("browser: unsupported operating system")
+)
+
+// 终止之前启动的进程失败
+type KillError struct {
+	PID int
+	Err error
+}
+
+func (e *KillError) Error() string {
+	return fmt.Sprintf("browser: cannot terminate process %d: %v", e.PID, e.Err)
+}
+
+func (e *KillError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	mu    sync.Mutex  // 确保进程操作线程安全
+	child *os.Process // 跟踪子进程
+	group int         // 子进程所在的进程组ID
+)
+
+// 在 goos 上打开 url 的命令；getenv 用于判断有没有图形界面
+func Command(goos, url string, getenv func(string) string) (string, []string, error) {
+	switch goos {
+	case "windows":
+		// 使用start命令的/b参数不创建新窗口，减少进程残留
+		return "cmd", []string{"/c", "start", "/b", url}, nil
+	case "darwin":
+		return "open", []string{url}, nil
+	case "linux", "freebsd", "openbsd", "netbsd":
+		// 没有图形界面（如服务器、NAS）时无法打开
+		if getenv("DISPLAY") == "" && getenv("WAYLAND_DISPLAY") == "" {
+			return "", nil, ErrNoDisplay
+		}
+		return "xdg-open", []string{url}, nil
+	}
+	return "", nil, ErrUnsupported
+}
+
+// 打开浏览器
+func Open(url string) error {
+	name, args, err := Command(runtime.GOOS, url, os.Getenv)
+	if err != nil {
+		return err
+	}
+	return start(name, args...)
+}
+
+// 安全执行命令并跟踪进程组
+func start(name string, args ...string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	// 先终止任何已存在的子进程和进程组
+	cleanup()
+
+	cmd := exec.Command(name, args...)
+	// 子进程放入新的进程组，退出时可一并终止
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	child = cmd.Process
+	group = cmd.Process.Pid
+
+	// 启动goroutine监控进程，确保完成后清理引用
+	go func() {
+		cmd.Wait()
+		mu.Lock()
+		child = nil
+		group = 0
+		mu.Unlock()
+	}()
+	return nil
+}
+
+// 程序退出前终止启动的进程
+func Cleanup() error {
+	mu.Lock()
+	defer mu.Unlock()
+	return cleanup()
+}
+
+func cleanup() error {
+	if child == nil || child.Pid <= 0 {
+		return nil
+	}
+	// 先尝试优雅关闭，等待1秒给进程退出时间，仍在运行则强制终止
+	child.Signal(os.Interrupt)
+	time.Sleep(1 * time.Second)
+	var err error
+	if killErr := child.Signal(os.Kill); killErr != nil {
+		err = &KillError{PID: child.Pid, Err: killErr}
+	}
+	// 终止整个进程组
+	if group > 0 {
+		killProcessGroup(group)
+	}
+	child = nil
+	group = 0
+	return err
+}
@@ -0,0 +1,39 @@
+package browser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+	cases := []struct {
+		goos string
+		env  map[string]string
+		name string
+		args []string
+		err  error
+	}{
+		{"windows", nil, "cmd", []string{"/c", "start", "/b", "http://x"}, nil},
+		{"darwin", nil, "open", []string{"http://x"}, nil},
+		{"linux", map[string]string{"DISPLAY": ":0"}, "xdg-open", []string{"http://x"}, nil},
+		{"linux", map[string]string{"WAYLAND_DISPLAY": "wayland-0"}, "xdg-open", []string{"http://x"}, nil},
+		{"linux", nil, "", nil, ErrNoDisplay},
+		{"plan9", nil, "", nil, ErrUnsupported},
+	}
+	for _, c := range cases {
+		name, args, err := Command(c.goos, "http://x", env(c.env))
+		if name != c.name || !reflect.DeepEqual(args, c.args) || !errors.Is(err, c.err) {
+			t.Errorf("Command(%s, %v) = %q %q %v, want %q %q %v", c.goos, c.env, name, args, err, c.name, c.args, c.err)
+		}
+	}
+}
+
+func TestCleanupWithoutProcess(t *testing.T) {
+	if err := Cleanup(); err != nil {
+		t.Fatalf("Cleanup() = %v", err)
+	}
+}
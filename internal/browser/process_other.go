@@ -0,0 +1,17 @@
+//go:build !windows
+
+package browser
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// 子进程单独成组（setpgid），终端里的 Ctrl+C 不会波及它启动的浏览器
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// xdg-open 可能直接在前台运行浏览器，整组终止会关掉用户的浏览器，
+// 这里只终止子进程本身（由调用方完成）
+func killProcessGroup(pid int) {}
@@ -0,0 +1,35 @@
+package browser
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// 创建新的进程组
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP,
+	}
+}
+
+// 通过 kernel32 的 TerminateProcess 终止进程组
+func killProcessGroup(pid int) {
+	kernel32, err := syscall.LoadLibrary("kernel32.dll")
+	if err != nil {
+		return
+	}
+	defer syscall.FreeLibrary(kernel32)
+
+	terminateProc, err := syscall.GetProcAddress(kernel32, "TerminateProcess")
+	if err != nil {
+		return
+	}
+	// 打开进程组
+	handle, err := syscall.OpenProcess(syscall.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		return
+	}
+	defer syscall.CloseHandle(handle)
+
+	syscall.Syscall(terminateProc, 2, uintptr(handle), 0, 0)
+}
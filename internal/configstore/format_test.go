@@ -0,0 +1,206 @@
+package configstore
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type nested struct {
+	RouterIP  string            `json:"router_ip"`
+	Port      string            `json:"server_port"`
+	DmzEnable string            `json:"dmz_enable"`
+	RateLimit float64           `json:"rate_limit"`
+	Debug     bool              `json:"debug"`
+	Headers   map[string]string `json:"headers"`
+	Hooks     struct {
+		PreApply string `json:"pre_apply"`
+	} `json:"hooks"`
+	Users []struct {
+		Name string `json:"name"`
+		Role string `json:"role"`
+	} `json:"users"`
+	Tags []string `json:"tags"`
+}
+
+const sampleYAML = `# 路由器
+router_ip: 192.168.1.1   # 管理地址
+server_port: 8080
+dmz_enable: 1
+rate_limit: 0.5
+debug: true
+headers: {Referer: "http://192.168.1.1/", X-Test: 'it''s'}
+hooks:
+  pre_apply: |
+    echo one
+    echo "two # not a comment"
+users:
+  - name: admin
+    role: admin
+  - name: "guest"
+    role: viewer
+tags: [a, "b c"]
+`
+
+const sampleTOML = `# 路由器
+router_ip = "192.168.1.1"   # 管理地址
+server_port = 8080
+dmz_enable = 1
+rate_limit = 0.5
+debug = true
+tags = ["a", "b c"]
+
+[headers]
+Referer = "http://192.168.1.1/"
+X-Test = "it's"
+
+[hooks]
+pre_apply = """
+echo one
+echo "two # not a comment"
+"""
+
+[[users]]
+name = "admin"
+role = "admin"
+
+[[users]]
+name = "guest"
+role = "viewer"
+`
+
+func checkNested(t *testing.T, c nested) {
+	t.Helper()
+	if c.RouterIP != "192.168.1.1" || c.Port != "8080" || c.DmzEnable != "1" || c.RateLimit != 0.5 || !c.Debug {
+		t.Errorf("标量 = %+v", c)
+	}
+	if c.Headers["Referer"] != "http://192.168.1.1/" {
+		t.Errorf("headers = %v", c.Headers)
+	}
+	if c.Hooks.PreApply != "echo one\necho \"two # not a comment\"\n" {
+		t.Errorf("pre_apply = %q", c.Hooks.PreApply)
+	}
+	if len(c.Users) != 2 || c.Users[1].Name != "guest" || c.Users[1].Role != "viewer" {
+		t.Errorf("users = %+v", c.Users)
+	}
+	if strings.Join(c.Tags, "|") != "a|b c" {
+		t.Errorf("tags = %q", c.Tags)
+	}
+}
+
+func TestLoadYAMLAndTOML(t *testing.T) {
+	for name, content := range map[string]string{"config.yaml": sampleYAML, "config.toml": sampleTOML} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			os.WriteFile(path, []byte(content), 0600)
+			var c nested
+			if err := NewFile(path).Load(&c); err != nil {
+				t.Fatal(err)
+			}
+			checkNested(t, c)
+			if c.Headers["X-Test"] != "it's" {
+				t.Errorf("X-Test = %q", c.Headers["X-Test"])
+			}
+		})
+	}
+}
+
+// 保存时只改动变化的值，注释与其余内容不变
+func TestSaveKeepsComments(t *testing.T) {
+	for name, content := range map[string]string{"config.yaml": sampleYAML, "config.toml": sampleTOML} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			os.WriteFile(path, []byte(content), 0600)
+			store := NewFile(path)
+			var c nested
+			store.Load(&c)
+			c.RouterIP = "10.0.0.1"
+			if err := store.Save(c); err != nil {
+				t.Fatal(err)
+			}
+			data, _ := os.ReadFile(path)
+			if !strings.Contains(string(data), "# 管理地址") || !strings.Contains(string(data), "# 路由器") {
+				t.Errorf("注释丢失:\n%s", data)
+			}
+			if strings.Count(string(data), "\n") != strings.Count(content, "\n") {
+				t.Errorf("改动了其他行:\n%s", data)
+			}
+			var got nested
+			if err := store.Load(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.RouterIP != "10.0.0.1" {
+				t.Errorf("router_ip = %q", got.RouterIP)
+			}
+			got.RouterIP = "192.168.1.1"
+			checkNested(t, got)
+		})
+	}
+}
+
+// JSON、YAML、TOML之间转换后内容不变
+func TestEncodeRoundTrip(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(src, []byte(sampleYAML), 0600)
+	var c nested
+	if err := NewFile(src).Load(&c); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"out.json", "out.yaml", "out.toml"} {
+		data, err := Encode(name, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(t.TempDir(), name)
+		os.WriteFile(path, data, 0600)
+		var got nested
+		if err := NewFile(path).Load(&got); err != nil {
+			t.Fatalf("%s: %v\n%s", name, err, data)
+		}
+		checkNested(t, got)
+	}
+}
+
+func TestYAMLErrors(t *testing.T) {
+	for _, bad := range []string{
+		"a: 1\n  b: 2\n",
+		"a: &x 1\n",
+		"a: [1, 2\n",
+		"a: 1\na: 2\n",
+		"\ta: 1\n",
+	} {
+		if _, err := parseYAML([]byte(bad)); err == nil {
+			t.Errorf("%q 应报错", bad)
+		}
+	}
+}
+
+func TestSniffFormat(t *testing.T) {
+	for in, want := range map[string]*format{
+		"\ufeff { \"router_ip\": \"1\" }":            nil,
+		"router_ip = \"1\"\n[watch]\nenabled = true": formats[".toml"],
+		"# 注释\nrouter_ip: 192.168.0.1\n":             formats[".yaml"],
+	} {
+		if got := sniff([]byte(in)); got != want {
+			t.Errorf("sniff(%q) 判断错误", in)
+		}
+	}
+}
+
+func TestLoadAsKeepsOrderAndCoerces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.txt")
+	os.WriteFile(path, []byte("server_port: 8080\nrouter_ip: 192.168.0.1\n"), 0600)
+	var raw json.RawMessage
+	if err := (&File{Path: path, Format: "yaml"}).LoadAs(&raw, reflect.TypeOf(sample{})); err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != `{"server_port":"8080","router_ip":"192.168.0.1"}` {
+		t.Errorf("LoadAs = %s", raw)
+	}
+	if err := (&File{Path: path, Format: "ini"}).Load(&raw); err == nil {
+		t.Error("不支持的格式没有报错")
+	}
+}
@@ -0,0 +1,224 @@
+// Package configstore 读写配置文件，调用方只依赖 Store 接口，测试时可换成 Memory。
+// 文件按扩展名可为 JSON、YAML（.yaml/.yml）或 TOML（.toml），调用方一律按 json 标签读写
+package configstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// 配置的存放位置
+type Store interface {
+	// 把保存的配置解码到 v；配置不存在时返回的错误满足 os.IsNotExist
+	Load(v interface{}) error
+	// 保存 v，写入失败时原有配置保持不变
+	Save(v interface{}) error
+}
+
+// 配置文件；路径为 "-" 时从标准输入读取，且不能保存。
+// YAML、TOML文件保存时只改动变化了的顶层值，注释保持不变；其他改动会重写整个文件
+type File struct {
+	Path   string
+	Format string // json/yaml/yml/toml，为空时按扩展名；从标准输入读取时按内容判断
+}
+
+func NewFile(path string) *File {
+	return &File{Path: path}
+}
+
+func (f *File) Load(v interface{}) error {
+	return f.LoadAs(v, reflect.TypeOf(v))
+}
+
+// 同 Load，YAML、TOML中的数字与布尔值按 t 的字段类型转换；
+// v 为 *json.RawMessage 时可保持原有的字段顺序，如转换格式时 t 为配置结构体的类型
+func (f *File) LoadAs(v interface{}, t reflect.Type) error {
+	var r io.Reader = os.Stdin
+	if f.Path != "-" {
+		file, err := os.Open(f.Path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	format, err := f.format(data)
+	if err != nil {
+		return err
+	}
+	if format == nil {
+		return json.Unmarshal(data, v)
+	}
+	tree, err := format.parse(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(toJSON(coerce(tree, t)), v)
+}
+
+// 文件的格式，JSON 返回 nil：优先用 Format，其次按扩展名，标准输入按内容判断
+func (f *File) format(data []byte) (*format, error) {
+	switch name := strings.ToLower(f.Format); {
+	case name == "json":
+		return nil, nil
+	case name != "":
+		if fm, ok := formats["."+name]; ok {
+			return fm, nil
+		}
+		return nil, &os.PathError{Op: "format " + f.Format, Path: f.Path, Err: os.ErrInvalid}
+	case f.Path == "-":
+		return sniff(data), nil
+	}
+	return formatOf(f.Path), nil
+}
+
+// 先写同目录下的临时文件再改名，避免中途退出留下损坏的配置；沿用原文件的权限
+func (f *File) Save(v interface{}) error {
+	if f.Path == "-" {
+		return &os.PathError{Op: "save", Path: f.Path, Err: os.ErrInvalid}
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	format, err := f.format(nil)
+	if err != nil {
+		return err
+	}
+	if format != nil {
+		if data, err = format.save(f.Path, data); err != nil {
+			return err
+		}
+	}
+	mode := os.FileMode(0600)
+	if fi, err := os.Stat(f.Path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+// YAML或TOML格式
+type format struct {
+	parse  func([]byte) (interface{}, error)
+	encode func(*mapping) []byte
+	// 在原文件内容上只改动变化了的部分，不能时 ok 为 false
+	update func(old []byte, prev, next *mapping) (data []byte, ok bool)
+}
+
+var formats = map[string]*format{
+	".yaml": {parseYAML, encodeYAML, updateYAML},
+	".yml":  {parseYAML, encodeYAML, updateYAML},
+	".toml": {parseTOML, encodeTOML, updateTOML},
+}
+
+// 按扩展名选择格式，JSON 返回 nil
+func formatOf(path string) *format {
+	return formats[strings.ToLower(filepath.Ext(path))]
+}
+
+// 按内容判断格式，JSON 返回 nil：以 { 开头为JSON，能按TOML解析为TOML，其余为YAML
+func sniff(data []byte) *format {
+	s := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\ufeff")), " \t\r\n")
+	if len(s) == 0 || s[0] == '{' {
+		return nil
+	}
+	if _, err := parseTOML(data); err == nil {
+		return formats[".toml"]
+	}
+	return formats[".yaml"]
+}
+
+// 把JSON编码的配置转为该格式；原文件存在时尽量只改动其中变化的值
+func (fm *format) save(path string, data []byte) ([]byte, error) {
+	v, err := fromJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	next, ok := v.(*mapping)
+	if !ok {
+		return nil, &os.PathError{Op: "save", Path: path, Err: os.ErrInvalid}
+	}
+	if old, err := os.ReadFile(path); err == nil {
+		if prev, err := fm.parse(old); err == nil {
+			if prev, ok := prev.(*mapping); ok {
+				if data, ok := fm.update(old, prev, next); ok {
+					return data, nil
+				}
+			}
+		}
+	}
+	return fm.encode(next), nil
+}
+
+// 按 path 的扩展名把 v 编码为JSON、YAML或TOML
+func Encode(path string, v interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	format := formatOf(path)
+	if format == nil {
+		return append(data, '\n'), nil
+	}
+	tree, err := fromJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := tree.(*mapping)
+	if !ok {
+		return nil, &os.PathError{Op: "encode", Path: path, Err: os.ErrInvalid}
+	}
+	return format.encode(m), nil
+}
+
+// 内存中的配置，用于测试
+type Memory struct {
+	mu   sync.Mutex
+	Data []byte // 为 nil 时表示没有保存过配置
+}
+
+func (m *Memory) Load(v interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Data == nil {
+		return os.ErrNotExist
+	}
+	return json.Unmarshal(m.Data, v)
+}
+
+func (m *Memory) Save(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.Data = data
+	m.mu.Unlock()
+	return nil
+}
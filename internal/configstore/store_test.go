@@ -0,0 +1,80 @@
+package configstore
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+type sample struct {
+	RouterIP string `json:"router_ip"`
+	Port     string `json:"server_port"`
+}
+
+func TestFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"router_ip":"192.168.1.1"}`), 0640); err != nil {
+		t.Fatal(err)
+	}
+	store := NewFile(path)
+	var c sample
+	if err := store.Load(&c); err != nil || c.RouterIP != "192.168.1.1" {
+		t.Fatalf("Load = %+v, %v", c, err)
+	}
+	c.Port = "9090"
+	if err := store.Save(c); err != nil {
+		t.Fatal(err)
+	}
+	var got sample
+	if err := store.Load(&got); err != nil || got != c {
+		t.Fatalf("Load after Save = %+v, %v; want %+v", got, err, c)
+	}
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if runtime.GOOS != "windows" && fi.Mode().Perm() != 0640 {
+		t.Errorf("mode = %v, want 0640", fi.Mode().Perm())
+	}
+	// 临时文件不应残留
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("目录中有 %d 个文件", len(entries))
+	}
+}
+
+func TestFileMissing(t *testing.T) {
+	var c sample
+	err := NewFile(filepath.Join(t.TempDir(), "none.json")).Load(&c)
+	if !os.IsNotExist(err) {
+		t.Fatalf("Load = %v, want not exist", err)
+	}
+}
+
+func TestFileBadJSONKeepsOriginal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"router_ip":"10.0.0.1"}`), 0600)
+	if err := NewFile(path).Save(func() {}); err == nil {
+		t.Fatal("Save(func) 应失败")
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != `{"router_ip":"10.0.0.1"}` {
+		t.Errorf("原配置被修改: %s", data)
+	}
+}
+
+func TestStdinCannotSave(t *testing.T) {
+	if err := NewFile("-").Save(sample{}); err == nil {
+		t.Fatal("Save 到标准输入应失败")
+	}
+}
+
+func TestMemory(t *testing.T) {
+	var m Memory
+	var c sample
+	if err := m.Load(&c); !os.IsNotExist(err) {
+		t.Fatalf("空 Memory Load = %v", err)
+	}
+	m.Save(sample{RouterIP: "192.168.0.1"})
+	if err := m.Load(&c); err != nil || c.RouterIP != "192.168.0.1" {
+		t.Fatalf("Load = %+v, %v", c, err)
+	}
+}
@@ -0,0 +1,615 @@
+package configstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TOML的常用子集：键值、[表]、[[表数组]]、点分键、字符串（含多行）、数字、布尔、数组与内联表。
+// 日期时间按字符串读取
+type tomlParser struct {
+	s    string
+	i    int
+	root *mapping
+	// 第一个表头的位置，没有表头时为 -1
+	firstHeader int
+	// 表头之前的顶层单行键值中值的位置，用于只改动这些值
+	spans map[string][2]int
+}
+
+func parseTOML(data []byte) (interface{}, error) {
+	p, err := newTOMLParser(data)
+	if err != nil {
+		return nil, err
+	}
+	return p.root, nil
+}
+
+func newTOMLParser(data []byte) (*tomlParser, error) {
+	p := &tomlParser{
+		s:           strings.TrimPrefix(string(data), "\ufeff"),
+		root:        newMapping(),
+		firstHeader: -1,
+		spans:       map[string][2]int{},
+	}
+	return p, p.parse()
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	line := strings.Count(p.s[:p.i], "\n") + 1
+	return fmt.Errorf("TOML line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) parse() error {
+	cur := p.root
+	for {
+		p.skipBlank(true)
+		if p.i == len(p.s) {
+			return nil
+		}
+		if p.s[p.i] == '[' {
+			if p.firstHeader < 0 {
+				p.firstHeader = p.i
+			}
+			array := strings.HasPrefix(p.s[p.i:], "[[")
+			if array {
+				p.i += 2
+			} else {
+				p.i++
+			}
+			path, err := p.key()
+			if err != nil {
+				return err
+			}
+			closing := "]"
+			if array {
+				closing = "]]"
+			}
+			p.skipBlank(false)
+			if !strings.HasPrefix(p.s[p.i:], closing) {
+				return p.errorf("table header must end with %s", closing)
+			}
+			p.i += len(closing)
+			if cur, err = p.table(path, array); err != nil {
+				return err
+			}
+		} else {
+			start := p.i
+			path, err := p.key()
+			if err != nil {
+				return err
+			}
+			p.skipBlank(false)
+			if p.i == len(p.s) || p.s[p.i] != '=' {
+				return p.errorf("expected = after key %s", strings.Join(path, "."))
+			}
+			p.i++
+			p.skipBlank(false)
+			valueStart := p.i
+			v, err := p.value()
+			if err != nil {
+				return err
+			}
+			if cur == p.root && len(path) == 1 && isScalar(v) && !strings.Contains(p.s[start:p.i], "\n") {
+				p.spans[path[0]] = [2]int{valueStart, p.i}
+			}
+			if err := p.assign(cur, path, v); err != nil {
+				return err
+			}
+		}
+		// 每项之后只能有注释和换行
+		p.skipBlank(false)
+		if p.i < len(p.s) && p.s[p.i] != '\n' && p.s[p.i] != '\r' {
+			return p.errorf("unexpected trailing content")
+		}
+	}
+}
+
+// 跳过空白与注释，newlines 为 true 时也跳过换行
+func (p *tomlParser) skipBlank(newlines bool) {
+	for p.i < len(p.s) {
+		switch c := p.s[p.i]; {
+		case c == ' ' || c == '\t':
+			p.i++
+		case c == '#':
+			for p.i < len(p.s) && p.s[p.i] != '\n' {
+				p.i++
+			}
+		case newlines && (c == '\n' || c == '\r'):
+			p.i++
+		default:
+			return
+		}
+	}
+}
+
+// 可以是点分的键
+func (p *tomlParser) key() ([]string, error) {
+	var path []string
+	for {
+		p.skipBlank(false)
+		if p.i == len(p.s) {
+			return nil, p.errorf("missing key")
+		}
+		var part string
+		switch p.s[p.i] {
+		case '"', '\'':
+			s, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			part = s
+		default:
+			start := p.i
+			for p.i < len(p.s) && isBareKeyChar(p.s[p.i]) {
+				p.i++
+			}
+			if p.i == start {
+				return nil, p.errorf("invalid key")
+			}
+			part = p.s[start:p.i]
+		}
+		path = append(path, part)
+		p.skipBlank(false)
+		if p.i == len(p.s) || p.s[p.i] != '.' {
+			return path, nil
+		}
+		p.i++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// 表头对应的表，[[a]] 在表数组 a 中追加一项
+func (p *tomlParser) table(path []string, array bool) (*mapping, error) {
+	m := p.root
+	for i, k := range path {
+		v, ok := m.get(k)
+		last := i == len(path)-1
+		switch {
+		case !ok && last && array:
+			next := newMapping()
+			m.set(k, []interface{}{next})
+			return next, nil
+		case !ok:
+			next := newMapping()
+			m.set(k, next)
+			m = next
+			continue
+		case last && array:
+			list, isList := v.([]interface{})
+			if !isList {
+				return nil, p.errorf("%s is not an array of tables", strings.Join(path, "."))
+			}
+			next := newMapping()
+			m.values[k] = append(list, next)
+			return next, nil
+		}
+		switch v := v.(type) {
+		case *mapping:
+			m = v
+		case []interface{}:
+			// 表数组中最后一项
+			if len(v) == 0 {
+				return nil, p.errorf("%s is not a table", strings.Join(path[:i+1], "."))
+			}
+			sub, isMap := v[len(v)-1].(*mapping)
+			if !isMap {
+				return nil, p.errorf("%s is not a table", strings.Join(path[:i+1], "."))
+			}
+			m = sub
+		default:
+			return nil, p.errorf("%s is not a table", strings.Join(path[:i+1], "."))
+		}
+	}
+	return m, nil
+}
+
+// 点分键逐级建表后赋值
+func (p *tomlParser) assign(m *mapping, path []string, v interface{}) error {
+	for _, k := range path[:len(path)-1] {
+		sub, ok := m.get(k)
+		if !ok {
+			next := newMapping()
+			m.set(k, next)
+			m = next
+			continue
+		}
+		next, isMap := sub.(*mapping)
+		if !isMap {
+			return p.errorf("%s is not a table", k)
+		}
+		m = next
+	}
+	k := path[len(path)-1]
+	if _, dup := m.get(k); dup {
+		return p.errorf("duplicate key %q", strings.Join(path, "."))
+	}
+	m.set(k, v)
+	return nil
+}
+
+var (
+	tomlDateTime = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}([Tt ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?([Zz]|[-+]\d{2}:\d{2})?)?$|^\d{2}:\d{2}:\d{2}(\.\d+)?$`)
+	tomlDate     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	tomlTime     = regexp.MustCompile(`^ \d{2}:`)
+	tomlDecimal  = regexp.MustCompile(`^[-+]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][-+]?[0-9](_?[0-9])*)?$`)
+	// 多行基本字符串中行尾的 \ 连同之后的空白与换行一起去掉
+	tomlLineEnd = regexp.MustCompile(`\\[ \t]*\n[ \t\n]*`)
+)
+
+func (p *tomlParser) value() (interface{}, error) {
+	if p.i == len(p.s) {
+		return nil, p.errorf("missing value")
+	}
+	switch c := p.s[p.i]; {
+	case c == '"' || c == '\'':
+		return p.str()
+	case c == '[':
+		return p.array()
+	case c == '{':
+		return p.inlineTable()
+	case strings.HasPrefix(p.s[p.i:], "true"):
+		p.i += 4
+		return true, nil
+	case strings.HasPrefix(p.s[p.i:], "false"):
+		p.i += 5
+		return false, nil
+	}
+	start := p.i
+	for p.i < len(p.s) && strings.IndexByte(" \t\r\n,]}#", p.s[p.i]) < 0 {
+		p.i++
+	}
+	// 日期与时间之间可以用空格分隔
+	if tomlDate.MatchString(p.s[start:p.i]) && tomlTime.MatchString(p.s[p.i:]) {
+		p.i++
+		for p.i < len(p.s) && strings.IndexByte(" \t\r\n,]}#", p.s[p.i]) < 0 {
+			p.i++
+		}
+	}
+	tok := p.s[start:p.i]
+	switch {
+	case tomlDateTime.MatchString(tok):
+		return tok, nil
+	case tomlDecimal.MatchString(tok):
+		n := strings.ReplaceAll(strings.TrimPrefix(tok, "+"), "_", "")
+		if !json.Valid([]byte(n)) {
+			x, err := strconv.ParseFloat(n, 64)
+			if err != nil {
+				return nil, p.errorf("invalid number %s", tok)
+			}
+			n = strconv.FormatFloat(x, 'g', -1, 64)
+		}
+		return json.Number(n), nil
+	case len(tok) > 2 && tok[0] == '0' && strings.IndexByte("xob", tok[1]) >= 0:
+		base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[tok[1]]
+		n, err := strconv.ParseInt(strings.ReplaceAll(tok[2:], "_", ""), base, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %s", tok)
+		}
+		return json.Number(strconv.FormatInt(n, 10)), nil
+	case tok == "":
+		return nil, p.errorf("missing value")
+	}
+	return nil, p.errorf("unsupported value %s", tok)
+}
+
+// 基本字符串、字面字符串及各自的多行形式
+func (p *tomlParser) str() (string, error) {
+	q := p.s[p.i]
+	if strings.HasPrefix(p.s[p.i:], strings.Repeat(string(q), 3)) {
+		p.i += 3
+		// 紧跟开头引号的换行不计入内容
+		if strings.HasPrefix(p.s[p.i:], "\r\n") {
+			p.i += 2
+		} else if strings.HasPrefix(p.s[p.i:], "\n") {
+			p.i++
+		}
+		end := strings.Index(p.s[p.i:], strings.Repeat(string(q), 3))
+		for q == '"' && end > 0 && escapedAt(p.s[p.i:], end) {
+			next := strings.Index(p.s[p.i+end+1:], `"""`)
+			if next < 0 {
+				end = -1
+				break
+			}
+			end += 1 + next
+		}
+		if end < 0 {
+			return "", p.errorf("unterminated multi-line string")
+		}
+		// 结束处可以多出一两个引号，属于内容
+		for k := 0; k < 2 && p.i+end+3 < len(p.s) && p.s[p.i+end+3] == q; k++ {
+			end++
+		}
+		body := strings.ReplaceAll(p.s[p.i:p.i+end], "\r\n", "\n")
+		p.i += end + 3
+		if q == '\'' {
+			return body, nil
+		}
+		s, err := unescape(tomlLineEnd.ReplaceAllString(body, ""))
+		if err != nil {
+			return "", p.errorf("%v", err)
+		}
+		return s, nil
+	}
+	start := p.i + 1
+	for i := start; i < len(p.s) && p.s[i] != '\n'; i++ {
+		switch {
+		case q == '"' && p.s[i] == '\\':
+			i++
+		case p.s[i] == q:
+			p.i = i + 1
+			if q == '\'' {
+				return p.s[start:i], nil
+			}
+			s, err := unescape(p.s[start:i])
+			if err != nil {
+				return "", p.errorf("%v", err)
+			}
+			return s, nil
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// s[i] 之前是否有奇数个反斜杠
+func escapedAt(s string, i int) bool {
+	n := 0
+	for i > 0 && s[i-1] == '\\' {
+		n++
+		i--
+	}
+	return n%2 == 1
+}
+
+func (p *tomlParser) array() (interface{}, error) {
+	p.i++
+	list := []interface{}{}
+	for {
+		p.skipBlank(true)
+		if p.i == len(p.s) {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.s[p.i] == ']' {
+			p.i++
+			return list, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+		p.skipBlank(true)
+		if p.i < len(p.s) && p.s[p.i] == ',' {
+			p.i++
+		} else if p.i < len(p.s) && p.s[p.i] != ']' {
+			return nil, p.errorf("expected , between array elements")
+		}
+	}
+}
+
+func (p *tomlParser) inlineTable() (interface{}, error) {
+	p.i++
+	m := newMapping()
+	for {
+		p.skipBlank(false)
+		if p.i == len(p.s) || p.s[p.i] == '\n' {
+			return nil, p.errorf("inline table must be on one line")
+		}
+		if p.s[p.i] == '}' {
+			p.i++
+			return m, nil
+		}
+		path, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		p.skipBlank(false)
+		if p.i == len(p.s) || p.s[p.i] != '=' {
+			return nil, p.errorf("expected = after key %s", strings.Join(path, "."))
+		}
+		p.i++
+		p.skipBlank(false)
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.assign(m, path, v); err != nil {
+			return nil, err
+		}
+		p.skipBlank(false)
+		if p.i < len(p.s) && p.s[p.i] == ',' {
+			p.i++
+		} else if p.i < len(p.s) && p.s[p.i] != '}' {
+			return nil, p.errorf("expected , between inline table entries")
+		}
+	}
+}
+
+// 编码为TOML：先写顶层的值，再按顺序写 [表] 与 [[表数组]]；null 没有对应写法，省略
+func encodeTOML(m *mapping) []byte {
+	var b strings.Builder
+	writeTOMLTable(&b, m, nil)
+	return []byte(strings.TrimPrefix(b.String(), "\n"))
+}
+
+func writeTOMLTable(b *strings.Builder, m *mapping, path []string) {
+	for _, k := range m.keys {
+		v := m.values[k]
+		if v == nil || isTOMLTable(v) || isTOMLTableArray(v) {
+			continue
+		}
+		b.WriteString(tomlKey(k))
+		b.WriteString(" = ")
+		b.WriteString(tomlInline(v))
+		b.WriteByte('\n')
+	}
+	for _, k := range m.keys {
+		sub := append(append([]string(nil), path...), k)
+		switch v := m.values[k].(type) {
+		case *mapping:
+			b.WriteString("\n[" + tomlPath(sub) + "]\n")
+			writeTOMLTable(b, v, sub)
+		case []interface{}:
+			if !isTOMLTableArray(v) {
+				continue
+			}
+			for _, item := range v {
+				b.WriteString("\n[[" + tomlPath(sub) + "]]\n")
+				writeTOMLTable(b, item.(*mapping), sub)
+			}
+		}
+	}
+}
+
+func isTOMLTable(v interface{}) bool {
+	_, ok := v.(*mapping)
+	return ok
+}
+
+// 全部由表组成的非空数组写为 [[表数组]]
+func isTOMLTableArray(v interface{}) bool {
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return false
+	}
+	for _, item := range list {
+		if !isTOMLTable(item) {
+			return false
+		}
+	}
+	return true
+}
+
+func tomlPath(path []string) string {
+	keys := make([]string, len(path))
+	for i, k := range path {
+		keys[i] = tomlKey(k)
+	}
+	return strings.Join(keys, ".")
+}
+
+func tomlKey(k string) string {
+	for i := 0; i < len(k); i++ {
+		if !isBareKeyChar(k[i]) {
+			return quote(k)
+		}
+	}
+	if k == "" {
+		return `""`
+	}
+	return k
+}
+
+// 单行的值，表写为内联表
+func tomlInline(v interface{}) string {
+	switch v := v.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return string(v)
+	case string:
+		return quote(v)
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if item != nil {
+				items = append(items, tomlInline(item))
+			}
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case *mapping:
+		items := make([]string, 0, len(v.keys))
+		for _, k := range v.keys {
+			if v.values[k] != nil {
+				items = append(items, tomlKey(k)+" = "+tomlInline(v.values[k]))
+			}
+		}
+		if len(items) == 0 {
+			return "{}"
+		}
+		return "{ " + strings.Join(items, ", ") + " }"
+	}
+	return quote(fmt.Sprint(v))
+}
+
+// 只改动表头之前变化了的顶层单行值，新增的值插在第一个表头之前，注释与其余内容保持不变；
+// 有其他改动时 ok 为 false
+func updateTOML(old []byte, prev, next *mapping) ([]byte, bool) {
+	p, err := newTOMLParser(old)
+	if err != nil {
+		return nil, false
+	}
+	type edit struct {
+		start, end int
+		text       string
+	}
+	var edits []edit
+	var added []string
+	for _, k := range prev.keys {
+		if _, ok := next.get(k); !ok {
+			return nil, false
+		}
+	}
+	for _, k := range next.keys {
+		v := next.values[k]
+		old, exists := prev.get(k)
+		if exists && equalValue(old, v) {
+			continue
+		}
+		if v == nil || !isScalar(v) {
+			return nil, false
+		}
+		if !exists {
+			added = append(added, tomlKey(k)+" = "+tomlInline(v))
+			continue
+		}
+		span, ok := p.spans[k]
+		if !ok {
+			return nil, false
+		}
+		edits = append(edits, edit{span[0], span[1], tomlInline(v)})
+	}
+	newline := "\n"
+	if strings.Contains(p.s, "\r\n") {
+		newline = "\r\n"
+	}
+	if len(added) > 0 {
+		at := p.firstHeader
+		if at < 0 {
+			at = len(p.s)
+		} else {
+			// 表头上方紧挨着的注释属于该表
+			at = strings.LastIndex(p.s[:at], "\n") + 1
+			for at > 0 {
+				above := strings.LastIndex(p.s[:at-1], "\n") + 1
+				if !strings.HasPrefix(strings.TrimSpace(p.s[above:at]), "#") {
+					break
+				}
+				at = above
+			}
+		}
+		text := strings.Join(added, newline) + newline
+		if at > 0 && p.s[at-1] != '\n' {
+			text = newline + text
+		}
+		edits = append(edits, edit{at, at, text})
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	s := p.s
+	for _, e := range edits {
+		s = s[:e.start] + e.text + s[e.end:]
+	}
+	data := []byte(s)
+	// 改动后读回校验，结果不同时退回完整重写
+	if got, err := parseTOML(data); err != nil || !equalValue(got, next) {
+		return nil, false
+	}
+	return data, true
+}
@@ -0,0 +1,322 @@
+package configstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 各格式之间转换用的中间形式：*mapping、[]interface{}、string、json.Number、bool、nil
+type mapping struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newMapping() *mapping {
+	return &mapping{values: map[string]interface{}{}}
+}
+
+func (m *mapping) get(key string) (interface{}, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+// 新的键追加在最后，已有的键保持原来的位置
+func (m *mapping) set(key string, v interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = v
+}
+
+// 解析JSON，对象保持字段顺序
+func fromJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected content after JSON")
+	}
+	return v, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case json.Delim('{'):
+		m := newMapping()
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			m.set(k.(string), v)
+		}
+		_, err := dec.Token()
+		return m, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for dec.More() {
+			v, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		_, err := dec.Token()
+		return list, err
+	}
+	return t, nil
+}
+
+// 编码为JSON，对象保持字段顺序
+func toJSON(v interface{}) []byte {
+	var buf bytes.Buffer
+	writeJSON(&buf, v)
+	return buf.Bytes()
+}
+
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case *mapping:
+		buf.WriteByte('{')
+		for i, k := range v.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(k)
+			buf.Write(key)
+			buf.WriteByte(':')
+			writeJSON(buf, v.values[k])
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSON(buf, item)
+		}
+		buf.WriteByte(']')
+	default:
+		data, _ := json.Marshal(v)
+		buf.Write(data)
+	}
+}
+
+// YAML、TOML中未加引号的数字和布尔值写入字符串类型的字段时按原样转为字符串，
+// 如 server_port: 8080、dmz_enable: 1
+func coerce(v interface{}, t reflect.Type) interface{} {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return v
+	}
+	switch v := v.(type) {
+	case *mapping:
+		for _, k := range v.keys {
+			switch t.Kind() {
+			case reflect.Struct:
+				if f, ok := fieldByTag(t, k); ok {
+					v.values[k] = coerce(v.values[k], f.Type)
+				}
+			case reflect.Map:
+				v.values[k] = coerce(v.values[k], t.Elem())
+			}
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i := range v {
+				v[i] = coerce(v[i], t.Elem())
+			}
+		}
+	case json.Number:
+		if t.Kind() == reflect.String {
+			return string(v)
+		}
+	case bool:
+		if t.Kind() == reflect.String {
+			return strconv.FormatBool(v)
+		}
+	}
+	return v
+}
+
+// 按 json 标签（或字段名，不区分大小写）找结构体字段，与 encoding/json 的规则一致
+func fieldByTag(t reflect.Type, key string) (reflect.StructField, bool) {
+	var fold *reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if name == key {
+			return f, true
+		}
+		if fold == nil && strings.EqualFold(name, key) {
+			fold = &f
+		}
+	}
+	if fold != nil {
+		return *fold, true
+	}
+	return reflect.StructField{}, false
+}
+
+// 两个值是否相同：对象不计字段顺序，标量按字面比较，
+// 因此 8080 与 "8080" 视为相同（读取时会转为字段的类型）
+func equalValue(a, b interface{}) bool {
+	switch a := a.(type) {
+	case *mapping:
+		b, ok := b.(*mapping)
+		if !ok || len(a.keys) != len(b.keys) {
+			return false
+		}
+		for _, k := range a.keys {
+			v, ok := b.get(k)
+			if !ok || !equalValue(a.values[k], v) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equalValue(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if !isScalar(b) {
+		return false
+	}
+	return literal(a) == literal(b)
+}
+
+// 标量的字面文本，null 与空字符串不同
+func literal(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "\x00null"
+	case string:
+		return v
+	case json.Number:
+		return string(v)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return string(toJSON(v))
+}
+
+// 是否为单行可写的标量
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case *mapping, []interface{}:
+		return false
+	}
+	return true
+}
+
+// 按JSON转义规则引用字符串，YAML双引号与TOML基本字符串都能读取
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				b.WriteString(`\u00`)
+				b.WriteString(strconv.FormatInt(int64(r)>>4, 16))
+				b.WriteString(strconv.FormatInt(int64(r)&0xf, 16))
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// 解析双引号字符串中的转义，YAML与TOML共用
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", errors.New(`string ends with \`)
+		}
+		switch c := s[i]; c {
+		case '"', '\\', '/':
+			b.WriteByte(c)
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'e':
+			b.WriteByte(0x1b)
+		case '0':
+			b.WriteByte(0)
+		case 'x', 'u', 'U':
+			n := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+			if i+n >= len(s) {
+				return "", fmt.Errorf(`\%c must be followed by %d hex digits`, c, n)
+			}
+			r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf(`\%c must be followed by %d hex digits`, c, n)
+			}
+			b.WriteRune(rune(r))
+			i += n
+		default:
+			return "", fmt.Errorf(`unsupported escape \%c`, c)
+		}
+	}
+	return b.String(), nil
+}
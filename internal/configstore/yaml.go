@@ -0,0 +1,642 @@
+package configstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// YAML的常用子集：块映射与块序列、单行的流式 [] {}、引号与普通标量、| > 多行字符串和注释。
+// 不支持锚点、别名、标签和多文档
+type yamlParser struct {
+	lines []string
+	pos   int
+}
+
+func parseYAML(data []byte) (interface{}, error) {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	p := &yamlParser{lines: strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")}
+	if i, ok := p.peek(); ok && p.lines[i] == "---" {
+		p.pos = i + 1
+	}
+	if _, ok := p.peek(); !ok {
+		return newMapping(), nil
+	}
+	v, err := p.node(0)
+	if err != nil {
+		return nil, err
+	}
+	if i, ok := p.peek(); ok {
+		return nil, p.errorf(i, "bad indentation or trailing content")
+	}
+	return v, nil
+}
+
+func (p *yamlParser) errorf(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("YAML line %d: %s", line+1, fmt.Sprintf(format, args...))
+}
+
+// 下一个有内容的行，跳过空行和注释行
+func (p *yamlParser) peek() (int, bool) {
+	for ; p.pos < len(p.lines); p.pos++ {
+		if stripYAMLComment(p.lines[p.pos]) != "" {
+			return p.pos, true
+		}
+	}
+	return p.pos, false
+}
+
+// 行首空格数与去掉缩进和注释后的内容；缩进中不能有制表符
+func (p *yamlParser) line(i int) (int, string, error) {
+	raw := p.lines[i]
+	text := strings.TrimLeft(raw, " ")
+	indent := len(raw) - len(text)
+	if strings.HasPrefix(text, "\t") {
+		return 0, "", p.errorf(i, "tabs are not allowed in indentation")
+	}
+	return indent, stripYAMLComment(text), nil
+}
+
+// 从当前行开始读取一个缩进不小于 atLeast 的节点
+func (p *yamlParser) node(atLeast int) (interface{}, error) {
+	i, _ := p.peek()
+	indent, text, err := p.line(i)
+	if err != nil {
+		return nil, err
+	}
+	if indent < atLeast {
+		return nil, nil
+	}
+	if isSeqItem(text) {
+		return p.sequence(indent)
+	}
+	if _, _, ok, err := splitYAMLKey(text); err != nil {
+		return nil, p.errorf(i, "%v", err)
+	} else if ok {
+		return p.mapping(indent)
+	}
+	if text == "---" || text == "..." {
+		return nil, p.errorf(i, "multiple documents are not supported")
+	}
+	p.pos++
+	return p.inline(i, text)
+}
+
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	m := newMapping()
+	for {
+		i, ok := p.peek()
+		if !ok {
+			return m, nil
+		}
+		n, text, err := p.line(i)
+		if err != nil {
+			return nil, err
+		}
+		if n < indent || n == indent && isSeqItem(text) {
+			return m, nil
+		}
+		if n > indent {
+			return nil, p.errorf(i, "bad indentation")
+		}
+		key, rest, ok, err := splitYAMLKey(text)
+		if err != nil {
+			return nil, p.errorf(i, "%v", err)
+		}
+		if !ok {
+			return nil, p.errorf(i, "expected key: value")
+		}
+		if _, dup := m.get(key); dup {
+			return nil, p.errorf(i, "duplicate key %q", key)
+		}
+		p.pos++
+		v, err := p.value(i, rest, indent, true)
+		if err != nil {
+			return nil, err
+		}
+		m.set(key, v)
+	}
+}
+
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	list := []interface{}{}
+	for {
+		i, ok := p.peek()
+		if !ok {
+			return list, nil
+		}
+		n, text, err := p.line(i)
+		if err != nil {
+			return nil, err
+		}
+		if n < indent || !isSeqItem(text) {
+			return list, nil
+		}
+		if n > indent {
+			return nil, p.errorf(i, "bad indentation")
+		}
+		content := strings.TrimLeft(text[1:], " ")
+		_, _, isKey, _ := splitYAMLKey(content)
+		if content != "" && (isKey || isSeqItem(content)) {
+			// "- key: value" 与 "- - item"：把该行视为在内容所在列开始的节点
+			col := n + len(text) - len(content)
+			p.lines[i] = strings.Repeat(" ", col) + content
+			v, err := p.node(col)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			continue
+		}
+		p.pos++
+		v, err := p.value(i, content, indent, false)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+}
+
+// 键或序列项之后的值：同行的标量、流式集合、多行字符串，或下面缩进更深的块
+func (p *yamlParser) value(i int, rest string, indent int, inMapping bool) (interface{}, error) {
+	if rest == "" {
+		j, ok := p.peek()
+		if !ok {
+			return nil, nil
+		}
+		n, text, err := p.line(j)
+		if err != nil {
+			return nil, err
+		}
+		// 映射的值可以是与键同样缩进的序列
+		if n > indent || inMapping && n == indent && isSeqItem(text) {
+			return p.node(n)
+		}
+		return nil, nil
+	}
+	if rest[0] == '|' || rest[0] == '>' {
+		return p.blockScalar(i, rest, indent)
+	}
+	return p.inline(i, rest)
+}
+
+// | 保留换行，> 把相邻行合并为一行；- 去掉末尾换行，+ 保留全部末尾空行
+func (p *yamlParser) blockScalar(i int, header string, indent int) (interface{}, error) {
+	chomp := header[1:]
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return nil, p.errorf(i, "unsupported block scalar header %q", header)
+	}
+	var lines []string
+	block := -1
+	for ; p.pos < len(p.lines); p.pos++ {
+		raw := p.lines[p.pos]
+		text := strings.TrimLeft(raw, " ")
+		if text == "" {
+			lines = append(lines, "")
+			continue
+		}
+		n := len(raw) - len(text)
+		if block < 0 {
+			if n <= indent {
+				break
+			}
+			block = n
+		}
+		if n < block {
+			break
+		}
+		lines = append(lines, raw[block:])
+	}
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	// 末尾的空行可能属于后面的内容，退回去
+	p.pos -= trailing
+	if p.pos < i+1 {
+		p.pos = i + 1
+	}
+	var s string
+	if header[0] == '|' {
+		s = strings.Join(lines, "\n")
+	} else {
+		var b strings.Builder
+		for k, l := range lines {
+			switch {
+			case k == 0:
+			case l == "" || lines[k-1] == "":
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(' ')
+			}
+			b.WriteString(l)
+		}
+		s = b.String()
+	}
+	switch {
+	case len(lines) == 0:
+	case chomp == "-":
+	case chomp == "+":
+		s += "\n" + strings.Repeat("\n", trailing)
+	default:
+		s += "\n"
+	}
+	return s, nil
+}
+
+// 单行的值：引号字符串、流式集合或普通标量
+func (p *yamlParser) inline(i int, s string) (interface{}, error) {
+	f := &flowScanner{s: s}
+	v, err := f.value(false)
+	if err == nil {
+		f.skipSpace()
+		if f.i < len(f.s) {
+			err = fmt.Errorf("unexpected trailing content %q", f.s[f.i:])
+		}
+	}
+	if err != nil {
+		return nil, p.errorf(i, "%v", err)
+	}
+	return v, nil
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// 拆出 "key: value" 的键与值；不是映射行时 ok 为 false
+func splitYAMLKey(text string) (key, rest string, ok bool, err error) {
+	if text == "" || strings.ContainsRune("[{&*!|>%@`", rune(text[0])) {
+		return "", "", false, nil
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		end, err := quotedEnd(text)
+		if err != nil {
+			return "", "", false, err
+		}
+		after := strings.TrimLeft(text[end:], " ")
+		if !strings.HasPrefix(after, ":") || len(after) > 1 && after[1] != ' ' {
+			return "", "", false, nil
+		}
+		f := &flowScanner{s: text[:end]}
+		k, err := f.quoted()
+		if err != nil {
+			return "", "", false, err
+		}
+		return k, strings.TrimSpace(after[1:]), true, nil
+	}
+	if text == "?" || strings.HasPrefix(text, "? ") {
+		return "", "", false, fmt.Errorf("complex keys are not supported")
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimRight(text[:i], " "), strings.TrimSpace(text[i+1:]), true, nil
+		}
+	}
+	return "", "", false, nil
+}
+
+// 去掉注释与行尾空格；# 在行首或空白之后、且不在引号内时为注释
+func stripYAMLComment(s string) string {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			s = s[:i]
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" [{,:", s[i-1]) >= 0):
+			if end, err := quotedEnd(s[i:]); err == nil {
+				i += end - 1
+			}
+		}
+	}
+	return strings.TrimRight(s, " \t")
+}
+
+// s 以引号开头，返回结束引号之后的位置
+func quotedEnd(s string) (int, error) {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case s[i] == q && q == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated quoted string")
+}
+
+// 单行内的值与流式集合 [a, b]、{k: v}
+type flowScanner struct {
+	s string
+	i int
+}
+
+func (f *flowScanner) skipSpace() {
+	for f.i < len(f.s) && (f.s[f.i] == ' ' || f.s[f.i] == '\t') {
+		f.i++
+	}
+}
+
+// inFlow 为 true 时普通标量在 , ] } 处结束
+func (f *flowScanner) value(inFlow bool) (interface{}, error) {
+	f.skipSpace()
+	if f.i == len(f.s) {
+		return nil, nil
+	}
+	switch c := f.s[f.i]; c {
+	case '"', '\'':
+		return f.quoted()
+	case '[':
+		return f.list()
+	case '{':
+		return f.object()
+	case '&', '*', '!', '%', '@', '`':
+		return nil, fmt.Errorf("unsupported YAML syntax %q", c)
+	}
+	start := f.i
+	for f.i < len(f.s) && !(inFlow && strings.IndexByte(",]}", f.s[f.i]) >= 0) {
+		f.i++
+	}
+	return yamlPlain(strings.TrimSpace(f.s[start:f.i])), nil
+}
+
+func (f *flowScanner) quoted() (string, error) {
+	end, err := quotedEnd(f.s[f.i:])
+	if err != nil {
+		return "", err
+	}
+	inner := f.s[f.i+1 : f.i+end-1]
+	q := f.s[f.i]
+	f.i += end
+	if q == '\'' {
+		return strings.ReplaceAll(inner, "''", "'"), nil
+	}
+	return unescape(inner)
+}
+
+func (f *flowScanner) list() (interface{}, error) {
+	f.i++
+	list := []interface{}{}
+	for {
+		f.skipSpace()
+		if f.i == len(f.s) {
+			return nil, fmt.Errorf("unterminated [ (flow collections must fit on one line)")
+		}
+		if f.s[f.i] == ']' {
+			f.i++
+			return list, nil
+		}
+		v, err := f.value(true)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+		if err := f.separator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *flowScanner) object() (interface{}, error) {
+	f.i++
+	m := newMapping()
+	for {
+		f.skipSpace()
+		if f.i == len(f.s) {
+			return nil, fmt.Errorf("unterminated { (flow collections must fit on one line)")
+		}
+		if f.s[f.i] == '}' {
+			f.i++
+			return m, nil
+		}
+		var key string
+		if c := f.s[f.i]; c == '"' || c == '\'' {
+			k, err := f.quoted()
+			if err != nil {
+				return nil, err
+			}
+			key = k
+		} else {
+			start := f.i
+			for f.i < len(f.s) && f.s[f.i] != ':' && f.s[f.i] != ',' && f.s[f.i] != '}' {
+				f.i++
+			}
+			key = strings.TrimSpace(f.s[start:f.i])
+		}
+		f.skipSpace()
+		if f.i == len(f.s) || f.s[f.i] != ':' {
+			return nil, fmt.Errorf("expected : after key %q", key)
+		}
+		f.i++
+		v, err := f.value(true)
+		if err != nil {
+			return nil, err
+		}
+		m.set(key, v)
+		if err := f.separator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// 流式集合的元素之后应为 , 或结束符
+func (f *flowScanner) separator(end byte) error {
+	f.skipSpace()
+	if f.i < len(f.s) && f.s[f.i] == ',' {
+		f.i++
+		return nil
+	}
+	if f.i < len(f.s) && f.s[f.i] == end {
+		return nil
+	}
+	return fmt.Errorf("expected , or %c", end)
+}
+
+var yaml11Bool = map[string]bool{"y": true, "n": true, "yes": true, "no": true, "on": true, "off": true}
+
+var (
+	yamlInt   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// 普通标量：null、布尔、数字，其余为字符串
+func yamlPlain(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if yamlInt.MatchString(s) || yamlFloat.MatchString(s) {
+		if json.Valid([]byte(s)) {
+			return json.Number(s)
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return json.Number(strconv.FormatInt(n, 10))
+		}
+		if x, err := strconv.ParseFloat(s, 64); err == nil {
+			return json.Number(strconv.FormatFloat(x, 'g', -1, 64))
+		}
+	}
+	return s
+}
+
+// 编码为YAML，2个空格缩进
+func encodeYAML(m *mapping) []byte {
+	if len(m.keys) == 0 {
+		return []byte("{}\n")
+	}
+	var b strings.Builder
+	writeYAMLMapping(&b, m, 0)
+	return []byte(b.String())
+}
+
+func writeYAMLMapping(b *strings.Builder, m *mapping, indent int) {
+	for _, k := range m.keys {
+		b.WriteString(strings.Repeat(" ", indent))
+		b.WriteString(yamlString(k))
+		b.WriteByte(':')
+		writeYAMLValue(b, m.values[k], indent)
+	}
+}
+
+func writeYAMLValue(b *strings.Builder, v interface{}, indent int) {
+	switch v := v.(type) {
+	case *mapping:
+		if len(v.keys) > 0 {
+			b.WriteByte('\n')
+			writeYAMLMapping(b, v, indent+2)
+			return
+		}
+	case []interface{}:
+		if len(v) > 0 {
+			b.WriteByte('\n')
+			writeYAMLSequence(b, v, indent+2)
+			return
+		}
+	}
+	b.WriteByte(' ')
+	b.WriteString(yamlScalar(v))
+	b.WriteByte('\n')
+}
+
+func writeYAMLSequence(b *strings.Builder, list []interface{}, indent int) {
+	for _, item := range list {
+		b.WriteString(strings.Repeat(" ", indent))
+		b.WriteByte('-')
+		var sub strings.Builder
+		switch item := item.(type) {
+		case *mapping:
+			if len(item.keys) > 0 {
+				writeYAMLMapping(&sub, item, indent+2)
+			}
+		case []interface{}:
+			if len(item) > 0 {
+				writeYAMLSequence(&sub, item, indent+2)
+			}
+		}
+		if sub.Len() == 0 {
+			b.WriteByte(' ')
+			b.WriteString(yamlScalar(item))
+			b.WriteByte('\n')
+			continue
+		}
+		// 第一行写在 "- " 之后
+		b.WriteByte(' ')
+		b.WriteString(sub.String()[indent+2:])
+	}
+}
+
+// 单行的值，空集合写为 {} 与 []
+func yamlScalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return string(v)
+	case string:
+		return yamlString(v)
+	case *mapping:
+		return "{}"
+	case []interface{}:
+		return "[]"
+	}
+	return fmt.Sprint(v)
+}
+
+// 能原样读回时不加引号；on/off、yes/no 在 YAML 1.1 中是布尔值，也加引号以便其他工具读取
+func yamlString(s string) string {
+	if plain, ok := yamlPlain(s).(string); !ok || plain != s || yaml11Bool[strings.ToLower(s)] ||
+		strings.ContainsRune("-?:,[]{}#&*!|>'\"%@` ", rune(s[0])) ||
+		strings.HasSuffix(s, ":") || strings.HasSuffix(s, " ") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") ||
+		strings.IndexFunc(s, func(r rune) bool { return r < 0x20 || r == 0x7f }) >= 0 {
+		return quote(s)
+	}
+	return s
+}
+
+// 只改动顶层变化了的单行标量，新增的标量追加在末尾，注释与其余内容保持不变；
+// 有其他改动时 ok 为 false
+func updateYAML(old []byte, prev, next *mapping) ([]byte, bool) {
+	text := string(old)
+	newline := "\n"
+	if strings.Contains(text, "\r\n") {
+		newline = "\r\n"
+	}
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for _, k := range prev.keys {
+		if _, ok := next.get(k); !ok {
+			return nil, false
+		}
+	}
+	for _, k := range next.keys {
+		v := next.values[k]
+		old, exists := prev.get(k)
+		if exists && equalValue(old, v) {
+			continue
+		}
+		if !isScalar(v) || exists && !isScalar(old) {
+			return nil, false
+		}
+		if !exists {
+			if n := len(lines); n > 0 && lines[n-1] == "" {
+				lines = lines[:n-1]
+			}
+			lines = append(lines, yamlString(k)+": "+yamlScalar(v), "")
+			continue
+		}
+		found := false
+		for i, raw := range lines {
+			if raw == "" || raw[0] == ' ' || raw[0] == '#' {
+				continue
+			}
+			text := stripYAMLComment(raw)
+			key, rest, ok, _ := splitYAMLKey(text)
+			if !ok || key != k || rest == "" || rest[0] == '|' || rest[0] == '>' {
+				continue
+			}
+			lines[i] = text[:len(text)-len(rest)] + yamlScalar(v) + raw[len(text):]
+			found = true
+			break
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	data := []byte(strings.Join(lines, newline))
+	// 改动后读回校验，结果不同时退回完整重写
+	if got, err := parseYAML(data); err != nil || !equalValue(got, next) {
+		return nil, false
+	}
+	return data, true
+}
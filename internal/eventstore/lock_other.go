@@ -0,0 +1,22 @@
+//go:build !windows
+
+package eventstore
+
+import (
+	"os"
+	"syscall"
+)
+
+// 独占锁，其他进程的 lockFile 会等待
+func lockFile(f *os.File) error {
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
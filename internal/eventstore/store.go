@@ -0,0 +1,404 @@
+// Package eventstore 是只追加的嵌入式事件库。记录带CRC追加写入单个文件，
+// 打开时建立按序号和按类别的索引，查询只读取命中的记录；
+// 每次读写都持有文件锁，多个进程（如常驻服务与命令行）可以同时写入；
+// 压缩先写出完整的新内容再覆盖原文件，中途退出时下次访问会继续完成。
+package eventstore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// 文件头：魔数与压缩代数。其他进程压缩后代数改变，据此重建索引
+const (
+	magic      = "TPEV1\n"
+	headerSize = int64(len(magic) + 8)
+	frameSize  = 8       // 每条记录前的长度与CRC
+	maxRecord  = 4 << 20 // 单条记录的上限，超过视为文件损坏
+)
+
+// ErrTooLarge 表示单条记录超过上限
+var ErrTooLarge = errors.New("eventstore: record too large")
+
+// Record 是一条事件
+type Record struct {
+	Seq  uint64 // 追加时分配，递增且不复用
+	Time time.Time
+	Kind string
+	Data []byte
+}
+
+// Query 是查询条件，零值返回全部记录
+type Query struct {
+	Kind  string    // 只返回该类别
+	After time.Time // 只返回此时间之后的记录
+	Last  int       // >0 时只返回符合条件的最后几条
+}
+
+// 索引项
+type entry struct {
+	seq  uint64
+	time int64
+	kind string
+	off  int64 // 记录（含长度与CRC）在文件中的位置
+	size int64
+}
+
+// Store 是打开的事件库，可在多个 goroutine 中使用
+type Store struct {
+	path string
+
+	mu      sync.Mutex
+	f       *os.File
+	lock    *os.File
+	gen     uint64 // 索引对应的压缩代数
+	end     int64  // 已索引的文件长度
+	entries []entry
+	byKind  map[string][]int // 类别 -> entries 下标
+}
+
+// Open 打开或创建事件库。已有文件不是事件库格式时返回错误
+func Open(path string) (*Store, error) {
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	s := &Store{path: path, f: f, lock: lock}
+	err = s.locked(func() error { return nil })
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Recognized 判断文件是否为事件库（不存在或为空时也视为是）
+func Recognized(path string) (bool, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	head := make([]byte, len(magic))
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	// 写文件头时中断留下的残缺文件头也算
+	return string(head[:n]) == magic[:n], nil
+}
+
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.lock.Close()
+	s.f, s.lock = nil, nil
+	return err
+}
+
+// 持有进程内与跨进程的锁，并在执行前使索引与文件一致
+func (s *Store) locked(fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return os.ErrClosed
+	}
+	if err := lockFile(s.lock); err != nil {
+		return err
+	}
+	defer unlockFile(s.lock)
+	if err := s.recover(); err != nil {
+		return err
+	}
+	if err := s.refresh(); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// 完成上次中断的压缩
+func (s *Store) recover() error {
+	data, err := os.ReadFile(s.path + ".compact")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.overwrite(data); err != nil {
+		return err
+	}
+	return os.Remove(s.path + ".compact")
+}
+
+// 用 data 覆盖事件库文件
+func (s *Store) overwrite(data []byte) error {
+	if _, err := s.f.WriteAt(data, 0); err != nil {
+		return err
+	}
+	if err := s.f.Truncate(int64(len(data))); err != nil {
+		return err
+	}
+	return s.f.Sync()
+}
+
+// 读取其他进程追加的记录；文件被压缩或截断过时重建索引
+func (s *Store) refresh() error {
+	info, err := s.f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < headerSize {
+		// 新文件，或写文件头时中断
+		s.reset(0)
+		return s.writeHeader(0)
+	}
+	head := make([]byte, headerSize)
+	if _, err := s.f.ReadAt(head, 0); err != nil || string(head[:len(magic)]) != magic {
+		return fmt.Errorf("eventstore: %s is not an event store", s.path)
+	}
+	gen := binary.LittleEndian.Uint64(head[len(magic):])
+	if gen != s.gen || info.Size() < s.end || s.end == 0 {
+		s.reset(gen)
+	}
+	if info.Size() == s.end {
+		return nil
+	}
+	return s.scan(info.Size())
+}
+
+func (s *Store) reset(gen uint64) {
+	s.gen = gen
+	s.end = headerSize
+	s.entries = nil
+	s.byKind = map[string][]int{}
+}
+
+func (s *Store) writeHeader(gen uint64) error {
+	head := make([]byte, headerSize)
+	copy(head, magic)
+	binary.LittleEndian.PutUint64(head[len(magic):], gen)
+	if _, err := s.f.WriteAt(head, 0); err != nil {
+		return err
+	}
+	s.end = headerSize
+	return nil
+}
+
+// 从已索引的位置读到 size；末尾不完整或校验失败的记录（写入时中断）被截掉
+func (s *Store) scan(size int64) error {
+	r := bufio.NewReader(io.NewSectionReader(s.f, s.end, size-s.end))
+	off := s.end
+	for off < size {
+		rec, n, err := readRecord(r)
+		if err != nil {
+			if err := s.f.Truncate(off); err != nil {
+				return err
+			}
+			break
+		}
+		s.add(entry{seq: rec.Seq, time: rec.Time.UnixNano(), kind: rec.Kind, off: off, size: n})
+		off += n
+	}
+	s.end = off
+	return nil
+}
+
+func (s *Store) add(e entry) {
+	s.byKind[e.kind] = append(s.byKind[e.kind], len(s.entries))
+	s.entries = append(s.entries, e)
+}
+
+func encodeRecord(rec Record) ([]byte, error) {
+	size := 8 + 8 + 2 + len(rec.Kind) + len(rec.Data)
+	if size > maxRecord || len(rec.Kind) > 0xffff {
+		return nil, ErrTooLarge
+	}
+	buf := make([]byte, frameSize+size)
+	p := buf[frameSize:]
+	binary.LittleEndian.PutUint64(p, rec.Seq)
+	binary.LittleEndian.PutUint64(p[8:], uint64(rec.Time.UnixNano()))
+	binary.LittleEndian.PutUint16(p[16:], uint16(len(rec.Kind)))
+	copy(p[18:], rec.Kind)
+	copy(p[18+len(rec.Kind):], rec.Data)
+	binary.LittleEndian.PutUint32(buf, uint32(size))
+	binary.LittleEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(p))
+	return buf, nil
+}
+
+// 读取一条记录，返回记录与占用的字节数
+func readRecord(r io.Reader) (Record, int64, error) {
+	var frame [frameSize]byte
+	if _, err := io.ReadFull(r, frame[:]); err != nil {
+		return Record{}, 0, err
+	}
+	size := binary.LittleEndian.Uint32(frame[:])
+	if size < 18 || size > maxRecord {
+		return Record{}, 0, errors.New("eventstore: bad record length")
+	}
+	p := make([]byte, size)
+	if _, err := io.ReadFull(r, p); err != nil {
+		return Record{}, 0, err
+	}
+	if crc32.ChecksumIEEE(p) != binary.LittleEndian.Uint32(frame[4:]) {
+		return Record{}, 0, errors.New("eventstore: checksum mismatch")
+	}
+	kindLen := int(binary.LittleEndian.Uint16(p[16:]))
+	if 18+kindLen > len(p) {
+		return Record{}, 0, errors.New("eventstore: bad record")
+	}
+	return Record{
+		Seq:  binary.LittleEndian.Uint64(p),
+		Time: time.Unix(0, int64(binary.LittleEndian.Uint64(p[8:]))),
+		Kind: string(p[18 : 18+kindLen]),
+		Data: p[18+kindLen:],
+	}, int64(frameSize + size), nil
+}
+
+func (s *Store) read(e entry) (Record, error) {
+	rec, _, err := readRecord(io.NewSectionReader(s.f, e.off, e.size))
+	return rec, err
+}
+
+// Append 追加一条事件，返回分配的序号
+func (s *Store) Append(kind string, t time.Time, data []byte) (uint64, error) {
+	var seq uint64
+	err := s.locked(func() error {
+		seq = 1
+		if n := len(s.entries); n > 0 {
+			seq = s.entries[n-1].seq + 1
+		}
+		buf, err := encodeRecord(Record{Seq: seq, Time: t, Kind: kind, Data: data})
+		if err != nil {
+			return err
+		}
+		if _, err := s.f.WriteAt(buf, s.end); err != nil {
+			return err
+		}
+		if err := s.f.Sync(); err != nil {
+			return err
+		}
+		s.add(entry{seq: seq, time: t.UnixNano(), kind: kind, off: s.end, size: int64(len(buf))})
+		s.end += int64(len(buf))
+		return nil
+	})
+	return seq, err
+}
+
+// Query 按追加顺序返回符合条件的记录
+func (s *Store) Query(q Query) ([]Record, error) {
+	var out []Record
+	err := s.locked(func() error {
+		idx := s.match(q)
+		out = make([]Record, 0, len(idx))
+		for _, i := range idx {
+			rec, err := s.read(s.entries[i])
+			if err != nil {
+				return err
+			}
+			out = append(out, rec)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// 由索引找出符合条件的记录下标
+func (s *Store) match(q Query) []int {
+	var idx []int
+	if q.Kind != "" {
+		idx = s.byKind[q.Kind]
+	} else {
+		idx = make([]int, len(s.entries))
+		for i := range idx {
+			idx[i] = i
+		}
+	}
+	var out []int
+	after := q.After.UnixNano()
+	// 从后往前找，只需最后几条时不必遍历全部
+	for j := len(idx) - 1; j >= 0 && (q.Last <= 0 || len(out) < q.Last); j-- {
+		if e := s.entries[idx[j]]; q.After.IsZero() || e.time > after {
+			out = append(out, idx[j])
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// Len 返回记录数
+func (s *Store) Len() (int, error) {
+	var n int
+	err := s.locked(func() error {
+		n = len(s.entries)
+		return nil
+	})
+	return n, err
+}
+
+// Compact 只保留最新的 keep 条记录，序号不变
+func (s *Store) Compact(keep int) error {
+	return s.locked(func() error {
+		if len(s.entries) <= keep {
+			return nil
+		}
+		kept := s.entries[len(s.entries)-keep:]
+		head := make([]byte, headerSize)
+		copy(head, magic)
+		binary.LittleEndian.PutUint64(head[len(magic):], s.gen+1)
+		data := head
+		for _, e := range kept {
+			buf := make([]byte, e.size)
+			if _, err := s.f.ReadAt(buf, e.off); err != nil {
+				return err
+			}
+			data = append(data, buf...)
+		}
+		// 新内容先完整写入 .compact，覆盖原文件时中断的话下次访问由 recover 完成
+		tmp := s.path + ".compact.tmp"
+		if err := writeSynced(tmp, data); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, s.path+".compact"); err != nil {
+			return err
+		}
+		return s.recover()
+	})
+}
+
+func writeSynced(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,211 @@
+package eventstore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func openTemp(t *testing.T) (*Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "history.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, path
+}
+
+func seqs(recs []Record) []uint64 {
+	out := make([]uint64, len(recs))
+	for i, r := range recs {
+		out[i] = r.Seq
+	}
+	return out
+}
+
+func TestAppendQuery(t *testing.T) {
+	s, path := openTemp(t)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		kind := "apply"
+		if i%2 == 1 {
+			kind = "login"
+		}
+		if _, err := s.Append(kind, base.Add(time.Duration(i)*time.Minute), []byte(fmt.Sprintf(`{"n":%d}`, i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	all, err := s.Query(Query{})
+	if err != nil || fmt.Sprint(seqs(all)) != "[1 2 3 4 5 6]" {
+		t.Fatalf("全部 = %v, %v", seqs(all), err)
+	}
+	if string(all[2].Data) != `{"n":2}` || all[2].Kind != "apply" || !all[2].Time.Equal(base.Add(2*time.Minute)) {
+		t.Errorf("记录 = %+v", all[2])
+	}
+	applies, _ := s.Query(Query{Kind: "apply", Last: 2})
+	if fmt.Sprint(seqs(applies)) != "[3 5]" {
+		t.Errorf("最后两条 apply = %v", seqs(applies))
+	}
+	after, _ := s.Query(Query{After: base.Add(3 * time.Minute)})
+	if fmt.Sprint(seqs(after)) != "[5 6]" {
+		t.Errorf("之后的记录 = %v", seqs(after))
+	}
+
+	// 重新打开时由文件重建索引
+	s.Close()
+	s2, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s2.Close()
+	logins, _ := s2.Query(Query{Kind: "login"})
+	if fmt.Sprint(seqs(logins)) != "[2 4 6]" {
+		t.Errorf("重新打开后 login = %v", seqs(logins))
+	}
+	if seq, _ := s2.Append("apply", base, nil); seq != 7 {
+		t.Errorf("重新打开后序号 = %d, want 7", seq)
+	}
+}
+
+func TestTornTail(t *testing.T) {
+	s, path := openTemp(t)
+	for i := 0; i < 3; i++ {
+		s.Append("apply", time.Now(), []byte("{}"))
+	}
+	s.Close()
+	// 模拟写最后一条记录时断电
+	fi, _ := os.Stat(path)
+	if err := os.Truncate(path, fi.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+	s2, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s2.Close()
+	if n, _ := s2.Len(); n != 2 {
+		t.Fatalf("残缺记录后的条数 = %d, want 2", n)
+	}
+	if seq, _ := s2.Append("apply", time.Now(), []byte("{}")); seq != 3 {
+		t.Errorf("序号 = %d, want 3", seq)
+	}
+	all, _ := s2.Query(Query{})
+	if fmt.Sprint(seqs(all)) != "[1 2 3]" {
+		t.Errorf("记录 = %v", seqs(all))
+	}
+}
+
+func TestCompact(t *testing.T) {
+	s, path := openTemp(t)
+	// 另一个实例相当于另一个进程
+	other, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	for i := 0; i < 10; i++ {
+		s.Append("apply", time.Now(), []byte(fmt.Sprint(i)))
+	}
+	if n, _ := other.Len(); n != 10 {
+		t.Fatalf("另一实例看到 %d 条", n)
+	}
+	before, _ := os.Stat(path)
+	if err := s.Compact(3); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size() {
+		t.Errorf("压缩后大小 %d >= %d", after.Size(), before.Size())
+	}
+	recs, _ := other.Query(Query{})
+	if fmt.Sprint(seqs(recs)) != "[8 9 10]" || string(recs[0].Data) != "7" {
+		t.Fatalf("另一实例压缩后 = %v", seqs(recs))
+	}
+	if seq, _ := other.Append("apply", time.Now(), nil); seq != 11 {
+		t.Errorf("压缩后序号 = %d, want 11", seq)
+	}
+	if _, err := os.Stat(path + ".compact"); !os.IsNotExist(err) {
+		t.Errorf(".compact 残留: %v", err)
+	}
+}
+
+func TestCompactResumed(t *testing.T) {
+	s, path := openTemp(t)
+	for i := 0; i < 5; i++ {
+		s.Append("apply", time.Now(), nil)
+	}
+	s.Close()
+	// 模拟 .compact 写好后、覆盖原文件前退出
+	data, _ := os.ReadFile(path)
+	s2, _ := Open(path)
+	s2.Compact(2)
+	s2.Close()
+	compacted, _ := os.ReadFile(path)
+	os.WriteFile(path, data, 0600)
+	os.WriteFile(path+".compact", compacted, 0600)
+
+	s3, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s3.Close()
+	recs, _ := s3.Query(Query{})
+	if fmt.Sprint(seqs(recs)) != "[4 5]" {
+		t.Errorf("继续压缩后 = %v", seqs(recs))
+	}
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	_, path := openTemp(t)
+	const writers, each = 4, 25
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := Open(path)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer s.Close()
+			for i := 0; i < each; i++ {
+				if _, err := s.Append("apply", time.Now(), []byte("{}")); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	s, _ := Open(path)
+	defer s.Close()
+	recs, err := s.Query(Query{})
+	if err != nil || len(recs) != writers*each {
+		t.Fatalf("条数 = %d, %v", len(recs), err)
+	}
+	for i, r := range recs {
+		if r.Seq != uint64(i+1) {
+			t.Fatalf("第 %d 条序号 = %d", i, r.Seq)
+		}
+	}
+}
+
+func TestRecognized(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "history.jsonl")
+	os.WriteFile(legacy, []byte(`{"kind":"apply"}`+"\n"), 0600)
+	if ok, _ := Recognized(legacy); ok {
+		t.Error("JSONL 文件被当成事件库")
+	}
+	if _, err := Open(legacy); err == nil {
+		t.Error("打开 JSONL 文件应失败")
+	}
+	if ok, _ := Recognized(filepath.Join(dir, "missing.db")); !ok {
+		t.Error("不存在的文件应可创建")
+	}
+}
@@ -0,0 +1,82 @@
+// Package router 定义路由器后端的接口与IPv6防火墙、DMZ设置的数据类型。
+// 各系列固件的实现在主程序中按 firmware_type 登记，网页、命令行等上层只通过 Client 操作路由器
+package router
+
+// 防火墙与DMZ的一组设置值
+type State struct {
+	IPv6FirewallEnable string `json:"ipv6_firewall_enable"`
+	DmzEnable          string `json:"dmz_enable"`
+	DmzDestIP          string `json:"dmz_dest_ip"`
+	DmzDestIP6         string `json:"dmz_dest_ip6"`
+}
+
+// 两组设置是否等效；DMZ关闭时目标地址不参与比较
+func (a State) Matches(b State) bool {
+	if a.IPv6FirewallEnable != b.IPv6FirewallEnable || a.DmzEnable != b.DmzEnable {
+		return false
+	}
+	if a.DmzEnable != "1" {
+		return true
+	}
+	return a.DmzDestIP == b.DmzDestIP && a.DmzDestIP6 == b.DmzDestIP6
+}
+
+// 一次设置写入的部分，未选中的部分在请求中省略，路由器上保持不变
+type Sections struct {
+	Firewall bool // ipv6_firewall
+	DMZ      bool // dmz
+}
+
+var AllSections = Sections{Firewall: true, DMZ: true}
+
+// 由“仅防火墙”“仅DMZ”选项得到写入的部分；都未选或都选时两部分都写
+func SectionsFor(onlyFirewall, onlyDMZ bool) Sections {
+	if onlyFirewall == onlyDMZ {
+		return AllSections
+	}
+	return Sections{Firewall: onlyFirewall, DMZ: onlyDMZ}
+}
+
+// 只比较要写入的部分
+func (s Sections) Matches(a, b State) bool {
+	if !s.Firewall {
+		a.IPv6FirewallEnable = b.IPv6FirewallEnable
+	}
+	if !s.DMZ {
+		a.DmzEnable, a.DmzDestIP, a.DmzDestIP6 = b.DmzEnable, b.DmzDestIP, b.DmzDestIP6
+	}
+	return a.Matches(b)
+}
+
+// 路由器后端：一类固件的登录与读写方式
+type Client interface {
+	// 用管理员密码登录，返回之后请求使用的stok（或等价的会话令牌）
+	Login(host, password string) (string, error)
+	// 读取当前路由器的IPv6防火墙与DMZ设置
+	GetFirewall() (State, error)
+	// 写入IPv6防火墙开关，返回路由器的原始响应
+	SetFirewall(fs State) ([]byte, error)
+	// 写入DMZ设置，返回路由器的原始响应
+	SetDMZ(fs State, wanPort string) ([]byte, error)
+	Capabilities() Capabilities
+}
+
+// 后端支持的设置项
+type Capabilities struct {
+	IPv6Firewall bool `json:"ipv6_firewall"` // 可开关IPv6防火墙
+	DMZ          bool `json:"dmz"`           // 可设置DMZ
+	DMZIPv6      bool `json:"dmz_ipv6"`      // DMZ可指定IPv6主机
+	WANPort      bool `json:"wan_port"`      // 可指定DMZ作用的WAN口
+}
+
+var FullCapabilities = Capabilities{IPv6Firewall: true, DMZ: true, DMZIPv6: true, WANPort: true}
+
+// 可发送 /ds 形式请求的后端，访客网络、IPTV等其余功能经由它访问路由器
+type DSRequester interface {
+	DS(host, stok string, requestBody map[string]interface{}) ([]byte, error)
+}
+
+// 可把两部分设置合并为一次请求写入的后端
+type SectionsSetter interface {
+	SetSections(fs State, sections Sections, wanPort string) ([]byte, error)
+}
@@ -0,0 +1,42 @@
+package router
+
+import "testing"
+
+func TestSectionsFor(t *testing.T) {
+	cases := []struct {
+		onlyFirewall, onlyDMZ bool
+		want                  Sections
+	}{
+		{false, false, AllSections},
+		{true, true, AllSections},
+		{true, false, Sections{Firewall: true}},
+		{false, true, Sections{DMZ: true}},
+	}
+	for _, c := range cases {
+		if got := SectionsFor(c.onlyFirewall, c.onlyDMZ); got != c.want {
+			t.Errorf("SectionsFor(%v, %v) = %+v, want %+v", c.onlyFirewall, c.onlyDMZ, got, c.want)
+		}
+	}
+}
+
+func TestStateMatches(t *testing.T) {
+	a := State{IPv6FirewallEnable: "off", DmzEnable: "0", DmzDestIP: "192.168.0.2"}
+	b := State{IPv6FirewallEnable: "off", DmzEnable: "0", DmzDestIP: "192.168.0.3"}
+	if !a.Matches(b) {
+		t.Error("DMZ关闭时目标地址不应参与比较")
+	}
+	a.DmzEnable, b.DmzEnable = "1", "1"
+	if a.Matches(b) {
+		t.Error("DMZ开启时目标地址不同应不等效")
+	}
+	if !(Sections{Firewall: true}).Matches(a, b) {
+		t.Error("只写防火墙时不应比较DMZ")
+	}
+	b.IPv6FirewallEnable = "on"
+	if (Sections{Firewall: true}).Matches(a, b) {
+		t.Error("防火墙开关不同应不等效")
+	}
+	if !(Sections{DMZ: true}).Matches(a, State{IPv6FirewallEnable: "on", DmzEnable: "1", DmzDestIP: "192.168.0.2"}) {
+		t.Error("只写DMZ时不应比较防火墙")
+	}
+}
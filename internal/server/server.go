@@ -0,0 +1,206 @@
+// Package server 提供网页与接口处理器共用的请求处理：严格的表单检查、按标签绑定表单与同源检查。
+// 提示文字由调用方翻译，这里只返回错误
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MaxFormFields     = 64   // 单个表单最多的字段数
+	MaxFormValueBytes = 4096 // 单个字段值的长度上限
+)
+
+// methods 中是否包含 method；允许GET时也允许HEAD
+func MethodAllowed(method string, methods []string) bool {
+	for _, m := range methods {
+		if m == method || (m == http.MethodGet && method == http.MethodHead) {
+			return true
+		}
+	}
+	return false
+}
+
+// 严格检查表单：字段数与长度有上限，同名字段只能出现一次，不允许控制字符
+func CheckForm(values url.Values) error {
+	if len(values) > MaxFormFields {
+		return fmt.Errorf("too many fields (%d)", len(values))
+	}
+	for name, vs := range values {
+		if len(vs) > 1 {
+			return fmt.Errorf("field %q repeated", name)
+		}
+		for _, v := range vs {
+			if len(v) > MaxFormValueBytes {
+				return fmt.Errorf("field %q too long", name)
+			}
+			if !utf8.ValidString(v) {
+				return fmt.Errorf("field %q is not valid UTF-8", name)
+			}
+			for _, c := range v {
+				if unicode.IsControl(c) && c != '\t' && c != '\r' && c != '\n' {
+					return fmt.Errorf("field %q contains control characters", name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// 请求中的表单字段：POST 只读取请求体，不会被URL中的同名参数覆盖；其他方法读取查询参数
+type Form url.Values
+
+func FormOf(r *http.Request) Form {
+	if r.Method == http.MethodPost {
+		return Form(r.PostForm)
+	}
+	return Form(r.URL.Query())
+}
+
+// 字段原值，不存在时为空
+func (f Form) Get(name string) string {
+	return url.Values(f).Get(name)
+}
+
+// 去掉首尾空白后的值
+func (f Form) Trimmed(name string) string {
+	return strings.TrimSpace(f.Get(name))
+}
+
+// 去掉空白并转为小写，用于 on/off 等枚举值
+func (f Form) Lower(name string) string {
+	return strings.ToLower(f.Trimmed(name))
+}
+
+func (f Form) Has(name string) bool {
+	_, ok := f[name]
+	return ok
+}
+
+// 复选框：1/on/true 为真
+func (f Form) Flag(name string) bool {
+	switch f.Lower(name) {
+	case "1", "on", "true", "yes":
+		return true
+	}
+	return false
+}
+
+// 字段的值不能转为绑定目标的类型
+type FieldError struct {
+	Name  string
+	Value string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s=%q", e.Name, e.Value)
+}
+
+// 按 form 标签把字段绑定到结构体：string 去除首尾空白（标签含 ",lower" 时转小写），
+// int 必须为整数，bool 按复选框解析；表单中没有的字段保持原值，*string 因此可区分未提交与留空。
+// 格式错误时返回 *FieldError
+func Bind(f Form, dst interface{}) error {
+	return bind(f, reflect.ValueOf(dst).Elem())
+}
+
+func bind(f Form, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		// 嵌入的结构体按同样的规则绑定
+		if sf := t.Field(i); sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if err := bind(f, v.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		tag := t.Field(i).Tag.Get("form")
+		if tag == "" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if !f.Has(name) {
+			continue
+		}
+		field := v.Field(i)
+		s := f.Trimmed(name)
+		if opts == "lower" {
+			s = strings.ToLower(s)
+		}
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(s)
+		case reflect.Ptr:
+			if field.Type().Elem().Kind() == reflect.String {
+				field.Set(reflect.ValueOf(&s))
+			}
+		case reflect.Int:
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return &FieldError{Name: name, Value: f.Get(name)}
+			}
+			field.SetInt(int64(n))
+		case reflect.Bool:
+			field.SetBool(f.Flag(name))
+		}
+	}
+	return nil
+}
+
+// 结构体中带 form 标签的字段名，按声明顺序，页面按同样的顺序生成输入框
+func Names(v interface{}) []string {
+	t := reflect.TypeOf(v)
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("form"); tag != "" {
+			name, _, _ := strings.Cut(tag, ",")
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// 结构体中已提交的 *string 字段，按字段名返回，用于只修改提交了的设置项
+func Submitted(v interface{}) map[string]string {
+	rv := reflect.ValueOf(v)
+	t := rv.Type()
+	out := map[string]string{}
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("form")
+		field := rv.Field(i)
+		if tag == "" || field.Kind() != reflect.Ptr || field.IsNil() {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		out[name] = field.Elem().String()
+	}
+	return out
+}
+
+// 浏览器发起的跨站请求：Sec-Fetch-Site 不是同源，或 Origin、Referer 的主机与本站不同。
+// 浏览器会自动附带基本认证与Cookie，其他网站的表单或链接因此也能带着登录状态提交；
+// 命令行客户端与脚本不带这些头，不受影响
+func CrossSite(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site != "same-origin" && site != "none"
+	}
+	ref := r.Header.Get("Origin")
+	if ref == "" {
+		ref = r.Header.Get("Referer")
+	}
+	if ref == "" {
+		return false
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Host == "" {
+		// 包括隐私模式下的 Origin: null
+		return true
+	}
+	return !strings.EqualFold(u.Host, r.Host) && !strings.EqualFold(u.Host, r.Header.Get("X-Forwarded-Host"))
+}
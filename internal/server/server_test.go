@@ -0,0 +1,106 @@
+package server
+
+import (
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCheckForm(t *testing.T) {
+	cases := []struct {
+		values url.Values
+		ok     bool
+	}{
+		{url.Values{"dmz": {"on"}}, true},
+		{url.Values{"note": {"a\tb\r\nc"}}, true},
+		{url.Values{"dmz": {"on", "off"}}, false},
+		{url.Values{"ip": {"1.2.3.4\x00"}}, false},
+		{url.Values{"ip": {"\xff"}}, false},
+		{url.Values{"ip": {strings.Repeat("a", MaxFormValueBytes+1)}}, false},
+	}
+	for _, c := range cases {
+		if err := CheckForm(c.values); (err == nil) != c.ok {
+			t.Errorf("CheckForm(%q) = %v", c.values, err)
+		}
+	}
+}
+
+func TestBind(t *testing.T) {
+	var dst struct {
+		Mode  string  `form:"mode,lower"`
+		Port  int     `form:"port"`
+		On    bool    `form:"on"`
+		Keep  string  `form:"keep"`
+		Empty *string `form:"empty"`
+		Unset *string `form:"unset"`
+		Other string
+	}
+	dst.Keep = "old"
+	f := Form{"mode": {" Strict "}, "port": {"8080"}, "on": {"yes"}, "empty": {" "}, "Other": {"x"}}
+	if err := Bind(f, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Mode != "strict" || dst.Port != 8080 || !dst.On || dst.Keep != "old" || dst.Other != "" {
+		t.Errorf("绑定结果错误: %+v", dst)
+	}
+	if dst.Empty == nil || *dst.Empty != "" || dst.Unset != nil {
+		t.Errorf("*string 应区分留空与未提交: %v %v", dst.Empty, dst.Unset)
+	}
+	var fe *FieldError
+	if err := Bind(Form{"port": {"80a"}}, &dst); !errors.As(err, &fe) || fe.Name != "port" {
+		t.Errorf("非整数应返回字段错误，得到 %v", err)
+	}
+}
+
+func TestBindEmbeddedAndSubmitted(t *testing.T) {
+	type settings struct {
+		Enable *string `form:"enable,lower"`
+		Server *string `form:"server"`
+		Zone   *string `form:"zone"`
+	}
+	var dst struct {
+		Op string `form:"op"`
+		settings
+	}
+	if err := Bind(Form{"op": {"save"}, "enable": {"ON"}, "zone": {""}}, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Op != "save" {
+		t.Errorf("op = %q", dst.Op)
+	}
+	got := Submitted(dst.settings)
+	if len(got) != 2 || got["enable"] != "on" || got["zone"] != "" {
+		t.Errorf("Submitted = %v", got)
+	}
+	if names := Names(settings{}); strings.Join(names, ",") != "enable,server,zone" {
+		t.Errorf("Names = %v", names)
+	}
+}
+
+func TestCrossSite(t *testing.T) {
+	cases := []struct {
+		header, value string
+		cross         bool
+	}{
+		{"", "", false},
+		{"Sec-Fetch-Site", "same-origin", false},
+		{"Sec-Fetch-Site", "none", false},
+		{"Sec-Fetch-Site", "cross-site", true},
+		{"Origin", "http://panel.lan", false},
+		{"Origin", "http://evil.example", true},
+		{"Origin", "null", true},
+		{"Referer", "http://panel.lan/settings", false},
+		{"Referer", "http://evil.example/x", true},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("POST", "http://panel.lan/apply", nil)
+		if c.header != "" {
+			r.Header.Set(c.header, c.value)
+		}
+		if got := CrossSite(r); got != c.cross {
+			t.Errorf("%s: %s => %v, want %v", c.header, c.value, got, c.cross)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"tplinkfirewalloff/internal/server"
+)
+
+// IPTV/VLAN 绑定设置，模块与节均为 iptv
+const iptvModule = "iptv"
+
+// IPTV设置表单，页面按字段顺序显示，留空的字段不修改
+type iptvForm struct {
+	Enable       *string `form:"enable,lower"`        // on/off
+	Mode         *string `form:"mode,lower"`          // bridge=桥接 custom=自定义VLAN，其余为运营商预设
+	InternetVID  *string `form:"internet_vid"`        // 上网VLAN ID，0表示不打标签
+	InternetPrio *string `form:"internet_prio"`       // 上网802.1p优先级 0~7
+	IPTVVID      *string `form:"iptv_vid"`            // IPTV VLAN ID
+	IPTVPrio     *string `form:"iptv_prio"`           // IPTV 802.1p优先级
+	IGMPSnooping *string `form:"igmp_snooping,lower"` // on/off
+	LAN1         *string `form:"lan1,lower"`          // 各LAN口用途 internet/iptv
+	LAN2         *string `form:"lan2,lower"`
+	LAN3         *string `form:"lan3,lower"`
+	LAN4         *string `form:"lan4,lower"`
+}
+
+var iptvFields = server.Names(iptvForm{})
+
+var iptvModes = []string{"bridge", "custom", "russia", "singapore_singtel", "malaysia_unifi", "portugal_meo", "portugal_vodafone"}
+
+// 校验字段取值，与固件的取值范围一致
+func validIPTVField(field, value string) bool {
+	switch field {
+	case "enable", "igmp_snooping":
+		return value == "on" || value == "off"
+	case "mode":
+		for _, m := range iptvModes {
+			if m == value {
+				return true
+			}
+		}
+		return false
+	case "internet_vid", "iptv_vid":
+		n, err := strconv.Atoi(value)
+		return err == nil && n >= 0 && n <= 4094
+	case "internet_prio", "iptv_prio":
+		n, err := strconv.Atoi(value)
+		return err == nil && n >= 0 && n <= 7
+	case "lan1", "lan2", "lan3", "lan4":
+		return value == "internet" || value == "iptv"
+	}
+	return false
+}
+
+func queryIPTV() (map[string]interface{}, error) {
+	st, err := queryRouter(iptvModule, iptvModule)
+	if err != nil {
+		return nil, err
+	}
+	return st[iptvModule], nil
+}
+
+func setIPTV(fields map[string]interface{}) error {
+	return setSection(iptvModule, iptvModule, fields)
+}
+
+// /iptv：查看并修改IPTV/VLAN绑定
+func iptvHandler(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{"CanEdit": canEdit(r), "Fields": iptvFields, "Modes": iptvModes}
+
+	if r.Method == http.MethodPost {
+		var form iptvForm
+		if err := bindForm(formOf(r), &form); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		submitted := server.Submitted(form)
+		fields := map[string]interface{}{}
+		var invalid []string
+		for _, f := range iptvFields {
+			v := submitted[f]
+			if v == "" {
+				continue
+			}
+			if !validIPTVField(f, v) {
+				invalid = append(invalid, f)
+				continue
+			}
+			fields[f] = v
+		}
+		switch {
+		case len(invalid) > 0:
+			data["Error"] = tr("iptv.invalid", strings.Join(invalid, ", "))
+		case len(fields) == 0:
+			data["Error"] = tr("error.bad_parameter")
+		default:
+			if err := setIPTV(fields); err != nil {
+				data["Error"] = userMessage(err) + ": " + err.Error()
+			} else {
+				recordEvent("iptv", tr("iptv.updated"), map[string]interface{}{"fields": fields})
+				data["Result"] = tr("iptv.updated")
+			}
+		}
+	}
+
+	current, err := queryIPTV()
+	if err != nil && data["Error"] == nil {
+		data["Error"] = userMessage(err)
+	}
+	data["Current"] = current
+	renderTemplate(w, http.StatusOK, "iptv.html", data)
+}
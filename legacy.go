@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// 登录后跳转地址中的会话令牌，如 http://192.168.1.1/ABCDEFGHIJKLMNOP/userRpm/Index.htm
+	legacyTokenPattern = regexp.MustCompile(`/([A-Z]{16})/userRpm/Index\.htm`)
+	// DMZ页面中的 var DMZInf = new Array(1, "192.168.1.100", 0,0 );
+	legacyDMZPattern = regexp.MustCompile(`DMZInf\s*=\s*new Array\(\s*(\d+)\s*,\s*"([^"]*)"`)
+)
+
+// 早期固件的认证Cookie：Basic base64(用户名:MD5(密码))
+func legacyAuthCookie(password string) string {
+	user := globalConfig{}.Get().RouterUsername
+	if user == "" {
+		user = "admin"
+	}
+	sum := md5.Sum([]byte(password))
+	registerSecret(hex.EncodeToString(sum[:]))
+	token := base64.StdEncoding.EncodeToString([]byte(user + ":" + hex.EncodeToString(sum[:])))
+	registerSecret(token)
+	return "Basic%20" + token
+}
+
+// 请求早期固件的页面，返回页面内容
+func legacyGet(addr, path string, query url.Values, password string) (string, error) {
+	u := routerURL(addr, path)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(routerCtx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", routerErr(ErrBadParameter, 0, err.Error())
+	}
+	req.Header.Set("Cookie", "Authorization="+legacyAuthCookie(password))
+	// 早期固件校验 Referer，不是来自管理页面的请求会被拒绝
+	req.Header.Set("Referer", routerURL(addr, "/"))
+	resp, err := routerClient.Do(req)
+	if err != nil {
+		recordExchange(u, nil, 0, nil, err)
+		return "", routerErr(ErrUnreachable, 0, err.Error())
+	}
+	defer resp.Body.Close()
+	body, err := readLimited(resp.Body, maxRouterResponseBytes)
+	if err != nil {
+		return "", routerErr(ErrRouter, 0, tr("response.read_failed", err))
+	}
+	recordExchange(u, nil, resp.StatusCode, body, nil)
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return "", routerErr(ErrAuthExpired, 0, resp.Status)
+	case resp.StatusCode == http.StatusNotFound:
+		return "", routerErr(ErrUnsupportedFirmware, 0, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return "", routerErr(ErrRouter, 0, resp.Status)
+	}
+	return string(body), nil
+}
+
+// 早期固件登录，返回会话令牌，作为stok使用
+func legacyLogin(host, password string) (string, error) {
+	addr, err := resolveRouterHost(host)
+	if err != nil {
+		return "", err
+	}
+	registerSecret(password)
+	page, err := legacyGet(addr, "/userRpm/LoginRpm.htm", url.Values{"Save": {"Save"}}, password)
+	if err != nil {
+		return "", err
+	}
+	m := legacyTokenPattern.FindStringSubmatch(page)
+	if m == nil {
+		return "", routerErr(ErrAuthExpired, codeBadCredentials, tr("login.bad_password"))
+	}
+	registerSecret(m[1])
+	return m[1], nil
+}
+
+// 把 /ds 形式的请求转换为早期固件的页面请求，并把结果转换回 /ds 形式的响应，
+// 其余代码因此无需区分固件。早期固件没有IPv6防火墙（IPv6流量不做入站过滤），
+// 只支持 firewall 模块的 dmz
+func legacyDS(host, token string, requestBody map[string]interface{}) ([]byte, error) {
+	addr, err := resolveRouterHost(host)
+	if err != nil {
+		return nil, err
+	}
+	firewall, _ := requestBody["firewall"].(map[string]interface{})
+	method, _ := requestBody["method"].(string)
+	if firewall == nil || len(requestBody) != 2 {
+		return mustJSON(map[string]interface{}{"error_code": codeUnsupported}), nil
+	}
+	dmzPath := "/" + token + "/userRpm/DMZRpm.htm"
+	password := globalConfig{}.Get().RouterPassword
+
+	switch method {
+	case "get":
+		page, err := legacyGet(addr, dmzPath, nil, password)
+		if err != nil {
+			return nil, err
+		}
+		m := legacyDMZPattern.FindStringSubmatch(page)
+		if m == nil {
+			// 令牌失效时返回的是登录页面
+			if strings.Contains(page, "LoginRpm") || strings.Contains(page, "loginBox") {
+				return mustJSON(map[string]interface{}{"error_code": codeUnauthorized}), nil
+			}
+			return nil, routerErr(ErrUnsupportedFirmware, 0, tr("legacy.bad_dmz_page"))
+		}
+		return mustJSON(map[string]interface{}{
+			"error_code": codeOK,
+			"firewall": map[string]interface{}{
+				"dmz": map[string]interface{}{"enable": m[1], "dest_ip": m[2], "dest_ip6": "", "wan_port": "0"},
+			},
+		}), nil
+	case "set":
+		if fw, ok := firewall["ipv6_firewall"].(map[string]interface{}); ok && fw["enable"] != "off" {
+			return mustJSON(map[string]interface{}{"error_code": codeUnsupported}), nil
+		}
+		dmz, ok := firewall["dmz"].(map[string]interface{})
+		if !ok {
+			return mustJSON(map[string]interface{}{"error_code": codeOK}), nil
+		}
+		q := url.Values{
+			"enable": {fmt.Sprint(dmz["enable"])},
+			"ipAddr": {fmt.Sprint(dmz["dest_ip"])},
+			"Save":   {"Save"},
+		}
+		page, err := legacyGet(addr, dmzPath, q, password)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(page, "LoginRpm") {
+			return mustJSON(map[string]interface{}{"error_code": codeUnauthorized}), nil
+		}
+		return mustJSON(map[string]interface{}{"error_code": codeOK}), nil
+	}
+	return mustJSON(map[string]interface{}{"error_code": codeUnsupported}), nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const (
+	maxRouterResponseBytes = 1 << 20  // 路由器响应上限，正常响应只有几百字节
+	maxRequestBodyBytes    = 64 << 10 // 客户端请求体上限
+)
+
+// 读取至多 limit 字节，超出时返回错误而不是继续缓冲
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s", tr("limits.too_large", limit))
+	}
+	return data, nil
+}
+
+// 限制所有客户端请求体大小的中间件
+func limitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxRequestBodyBytes {
+			http.Error(w, tr("limits.body_too_large"), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// 退出时等待进行中请求完成的最长时间；Windows 关闭控制台窗口后约5秒会强制结束进程
+const shutdownTimeout = 4 * time.Second
+
+// 所有监听服务关闭后 Wait 返回
+var servers sync.WaitGroup
+
+// 一个监听地址，可同时配置多个，如本机HTTP免登录、局域网HTTPS需登录
+type ListenerConfig struct {
+	Addr          string `json:"addr"`            // 如 127.0.0.1:8080、192.168.0.10:8443
+	TLSCert       string `json:"tls_cert"`        // 证书文件，留空为HTTP
+	TLSKey        string `json:"tls_key"`         // 私钥文件
+	TLSSelfSigned bool   `json:"tls_self_signed"` // 没有证书时使用启动时生成的自签名证书
+	Auth          string `json:"auth"`            // required=按 users/oidc 登录（默认） none=不需要登录
+}
+
+func (l ListenerConfig) https() bool {
+	return l.TLSCert != "" || l.TLSSelfSigned
+}
+
+// 实际监听的地址：主机为空时分别监听IPv4与IPv6，localhost 时监听两个回环地址
+func (l ListenerConfig) bindAddrs() []string {
+	host, port, _ := net.SplitHostPort(l.Addr)
+	switch host {
+	case "":
+		return []string{net.JoinHostPort("0.0.0.0", port), net.JoinHostPort("::", port)}
+	case "localhost":
+		return []string{net.JoinHostPort("127.0.0.1", port), net.JoinHostPort("::1", port)}
+	}
+	return []string{l.Addr}
+}
+
+// 某个监听地址的浏览器访问地址，监听所有地址时使用对应协议的回环地址
+func (l ListenerConfig) urlFor(addr string) string {
+	host, port, _ := net.SplitHostPort(addr)
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		host = "127.0.0.1"
+		if ip.To4() == nil {
+			host = "::1"
+		}
+	}
+	scheme := "http"
+	if l.https() {
+		scheme = "https"
+	}
+	// JoinHostPort 会给IPv6地址加上方括号
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, port))
+}
+
+func (l ListenerConfig) urls() []string {
+	var urls []string
+	for _, addr := range l.bindAddrs() {
+		urls = append(urls, l.urlFor(addr))
+	}
+	return urls
+}
+
+func (l ListenerConfig) url() string {
+	return l.urls()[0]
+}
+
+// 实际使用的监听配置，未配置 listeners 时沿用 server_port
+func listenerConfigs() []ListenerConfig {
+	cfg := globalConfig{}.Get()
+	if len(cfg.Listeners) > 0 {
+		return cfg.Listeners
+	}
+	return []ListenerConfig{{Addr: ":" + cfg.ServerPort}}
+}
+
+// 启动时校验监听配置
+func validateListeners() error {
+	for _, l := range config.Listeners {
+		if _, _, err := net.SplitHostPort(l.Addr); err != nil {
+			return fmt.Errorf("addr %q: %v", l.Addr, err)
+		}
+		if (l.TLSCert == "") != (l.TLSKey == "") {
+			return fmt.Errorf("%s", tr("listener.cert_key", l.Addr))
+		}
+		if l.Auth != "" && l.Auth != "required" && l.Auth != "none" {
+			return fmt.Errorf("%s", tr("listener.bad_auth", l.Addr, l.Auth))
+		}
+	}
+	return nil
+}
+
+// 不需要登录的监听地址上，请求视为本机管理员；仍拒绝其他网站发起的修改
+func trustListener(next http.Handler) http.Handler {
+	local := &UserAccount{Name: "local", Role: roleAdmin}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if crossSiteChange(r) {
+			http.Error(w, tr("auth.cross_site"), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, local)))
+	})
+}
+
+func listenerHandler(l ListenerConfig) http.Handler {
+	var h http.Handler = requireAuth(http.DefaultServeMux)
+	if l.Auth == "none" {
+		h = trustListener(http.DefaultServeMux)
+	}
+	return logRequests(limitRequestBody(h))
+}
+
+// 生成自签名证书，有效期一年
+func selfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "tplinkfirewalloff"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
+				tmpl.IPAddresses = append(tmpl.IPAddresses, ipnet.IP)
+			}
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// 打开监听套接字。双栈时IPv6失败（如系统禁用了IPv6）只提示，仍使用IPv4
+func listen(l ListenerConfig) ([]net.Listener, error) {
+	addrs := l.bindAddrs()
+	var listeners []net.Listener
+	var firstErr error
+	for _, addr := range addrs {
+		host, _, _ := net.SplitHostPort(addr)
+		network := "tcp"
+		if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+			network = "tcp4"
+		} else if ip != nil {
+			// 单独监听IPv6，避免与IPv4的套接字冲突
+			network = "tcp6"
+		}
+		ln, err := net.Listen(network, addr)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			if len(addrs) > 1 {
+				warn("console.listen_failed", addr, err)
+			}
+			continue
+		}
+		listeners = append(listeners, ln)
+	}
+	if len(listeners) == 0 {
+		return nil, firstErr
+	}
+	return listeners, nil
+}
+
+// 在已打开的套接字上提供服务，quit 关闭时停止接受新连接，并等待进行中的请求完成
+func serveListener(l ListenerConfig, listeners []net.Listener, quit <-chan struct{}) {
+	srv := &http.Server{Handler: listenerHandler(l)}
+	servers.Add(1)
+	go func() {
+		defer servers.Done()
+		<-quit
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			srv.Close()
+		}
+	}()
+
+	certFile, keyFile := l.TLSCert, l.TLSKey
+	if l.TLSCert == "" && l.TLSSelfSigned {
+		cert, err := selfSignedCert()
+		if err != nil {
+			sayError("console.server_error", err)
+			return
+		}
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+	for _, ln := range listeners {
+		go func(ln net.Listener) {
+			var err error
+			if l.https() {
+				err = srv.ServeTLS(ln, certFile, keyFile)
+			} else {
+				err = srv.Serve(ln)
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				sayError("console.server_error", err)
+			}
+		}(ln)
+	}
+}
+
+// 网页界面的全部监听，配置中的监听地址改变后可以重新监听
+type webServer struct {
+	mu      sync.Mutex
+	configs []ListenerConfig // 当前监听所用的配置
+	quit    chan struct{}
+	stopped bool
+}
+
+var web webServer
+
+// 按当前配置监听，返回各访问地址；无法监听的地址只提示，不影响其他地址
+func (s *webServer) start() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.startLocked()
+}
+
+func (s *webServer) startLocked() []string {
+	s.configs = listenerConfigs()
+	s.quit = make(chan struct{})
+	var urls []string
+	for _, l := range s.configs {
+		lns, err := listen(l)
+		if err != nil {
+			_, port, _ := net.SplitHostPort(l.Addr)
+			sayError("console.server_error", err)
+			say("console.port_hint", port)
+			continue
+		}
+		for _, ln := range lns {
+			u := l.urlFor(ln.Addr().String())
+			say("console.server_started", u)
+			urls = append(urls, u)
+		}
+		serveListener(l, lns, s.quit)
+	}
+	return urls
+}
+
+// 监听配置（server_port、listeners）改变时，关闭原有监听并等待进行中的请求完成，再按新配置监听
+func (s *webServer) restart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped || s.quit == nil || reflect.DeepEqual(s.configs, listenerConfigs()) {
+		return
+	}
+	close(s.quit)
+	servers.Wait()
+	s.startLocked()
+}
+
+// 关闭全部监听，之后不再重新监听
+func (s *webServer) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped && s.quit != nil {
+		close(s.quit)
+	}
+	s.stopped = true
+}
+
+// 依次尝试连接各访问地址，返回第一个可用的，都不可用时返回第一个
+func reachableURL(urls []string) string {
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			continue
+		}
+		if conn, err := net.DialTimeout("tcp", parsed.Host, time.Second); err == nil {
+			conn.Close()
+			return u
+		}
+	}
+	return urls[0]
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// 本机Windows防火墙的入站放行规则，DMZ指向本机时随路由器设置一起添加或删除
+type LocalFirewallConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Ports    string `json:"ports"`     // 如 "tcp:25565, udp:19132"，留空放行全部入站
+	RuleName string `json:"rule_name"` // 规则名称，默认 TurnOffTPLINKIpv6Firewall
+}
+
+const defaultLocalRuleName = "TurnOffTPLINKIpv6Firewall"
+
+func (c LocalFirewallConfig) ruleName() string {
+	if c.RuleName != "" {
+		return c.RuleName
+	}
+	return defaultLocalRuleName
+}
+
+// 启动时校验端口列表
+func validateLocalFirewall() error {
+	if !config.LocalFirewall.Enabled {
+		return nil
+	}
+	_, err := parsePorts(config.LocalFirewall.Ports)
+	return err
+}
+
+// 地址是否属于本机某个网卡
+func isLocalAddress(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// 按当前设置同步本机规则：DMZ开启且目标为本机时放行，否则删除规则
+func syncLocalFirewall() {
+	cfg := globalConfig{}.Get()
+	lf := cfg.LocalFirewall
+	if !lf.Enabled {
+		return
+	}
+	exposed := cfg.DmzEnable == "1" && (isLocalAddress(cfg.DmzDestIP) || isLocalAddress(cfg.DmzDestIP6))
+	if !exposed {
+		if err := removeLocalRule(lf.ruleName()); err != nil {
+			warn("console.local_firewall_failed", err)
+			return
+		}
+		sayDebug("debug.localfw_removed", lf.ruleName())
+		return
+	}
+	ports, _ := parsePorts(lf.Ports)
+	if err := addLocalRule(lf.ruleName(), ports); err != nil {
+		warn("console.local_firewall_failed", err)
+		return
+	}
+	say("console.local_firewall_added", lf.ruleName(), describePorts(ports))
+}
+
+func describePorts(ports []portSpec) string {
+	if len(ports) == 0 {
+		return tr("local_firewall.all_ports")
+	}
+	return fmt.Sprint(ports)
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+// 其他系统上由用户自行配置本机防火墙
+func addLocalRule(name string, ports []portSpec) error {
+	return errors.New(tr("local_firewall.unsupported"))
+}
+
+func removeLocalRule(name string) error {
+	return nil
+}
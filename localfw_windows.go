@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// 先删除同名规则再按协议分别添加，重复调用结果相同
+func addLocalRule(name string, ports []portSpec) error {
+	removeLocalRule(name)
+	if len(ports) == 0 {
+		return netsh("add", "rule", "name="+name, "dir=in", "action=allow", "protocol=any")
+	}
+	byProto := map[string][]string{}
+	for _, p := range ports {
+		byProto[p.Proto] = append(byProto[p.Proto], strconv.Itoa(p.Port))
+	}
+	for _, proto := range []string{"tcp", "udp"} {
+		if len(byProto[proto]) == 0 {
+			continue
+		}
+		if err := netsh("add", "rule", "name="+name, "dir=in", "action=allow", "protocol="+proto, "localport="+strings.Join(byProto[proto], ",")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 删除同名的全部规则，规则不存在时不算失败
+func removeLocalRule(name string) error {
+	// show 找不到规则时返回非零，输出随系统语言变化，不解析文本
+	if netsh("show", "rule", "name="+name) != nil {
+		return nil
+	}
+	return netsh("delete", "rule", "name="+name)
+}
+
+// 执行 netsh advfirewall firewall ...，需要管理员权限
+func netsh(args ...string) error {
+	out, err := exec.Command("netsh", append([]string{"advfirewall", "firewall"}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("netsh %s: %v: %s", strings.Join(args[:2], " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
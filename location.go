@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 一个常用网络（如家里、公司），按网关MAC或SSID识别，识别到后改用对应的路由器
+type Location struct {
+	Name       string `json:"name"`
+	GatewayMAC string `json:"gateway_mac"` // 默认网关的MAC地址
+	SSID       string `json:"ssid"`        // 当前连接的无线网络名称
+	RouterIP   string `json:"router_ip"`   // 留空时使用检测到的默认网关
+	Stok       string `json:"stok"`        // 该路由器的stok，留空沿用当前值
+	Profile    string `json:"profile"`     // 切换后执行的预设（profiles 中的名称），留空只切换路由器
+}
+
+// 每隔多久检测一次所在网络
+const locationCheckInterval = 30 * time.Second
+
+// 本机当前所在网络
+type networkInfo struct {
+	Gateway    string
+	GatewayMAC string
+	SSID       string
+}
+
+var (
+	locationMu      sync.Mutex
+	currentLocation string // 当前匹配的网络名称
+)
+
+// 当前匹配的网络名称，未匹配任何网络时为空
+func activeLocation() string {
+	locationMu.Lock()
+	defer locationMu.Unlock()
+	return currentLocation
+}
+
+// 检测默认网关、网关MAC和SSID，取不到的项留空
+func detectNetwork() networkInfo {
+	var n networkInfo
+	if gateways, err := defaultGateways(); err == nil && len(gateways) > 0 {
+		n.Gateway = gateways[0]
+		if mac, err := gatewayMAC(n.Gateway); err == nil {
+			n.GatewayMAC, _ = normalizeMAC(mac)
+		} else {
+			sayDebug("debug.gateway_mac_failed", n.Gateway, err)
+		}
+	}
+	if ssid, err := currentSSID(); err == nil {
+		n.SSID = ssid
+	}
+	return n
+}
+
+// 网络是否符合该位置；配置了的条件都要满足
+func (l Location) matches(n networkInfo) bool {
+	if l.GatewayMAC != "" {
+		want, _ := normalizeMAC(l.GatewayMAC)
+		if want != n.GatewayMAC {
+			return false
+		}
+	}
+	if l.SSID != "" && l.SSID != n.SSID {
+		return false
+	}
+	return true
+}
+
+func matchLocation(n networkInfo) *Location {
+	cfg := globalConfig{}.Get()
+	for i := range cfg.Locations {
+		if cfg.Locations[i].matches(n) {
+			return &config.Locations[i]
+		}
+	}
+	return nil
+}
+
+func validateLocations() error {
+	seen := map[string]bool{}
+	for _, l := range config.Locations {
+		if l.Name == "" || seen[l.Name] {
+			return fmt.Errorf("%s", tr("location.bad_name", l.Name))
+		}
+		seen[l.Name] = true
+		if l.GatewayMAC == "" && l.SSID == "" {
+			return fmt.Errorf("%s", tr("location.incomplete", l.Name))
+		}
+		if _, ok := normalizeMAC(l.GatewayMAC); l.GatewayMAC != "" && !ok {
+			return fmt.Errorf("%s", tr("location.bad_mac", l.Name, l.GatewayMAC))
+		}
+		if l.Profile != "" && findProfile(l.Profile) == nil {
+			return fmt.Errorf("%s: %s", l.Name, tr("deeplink.unknown_profile", l.Profile))
+		}
+	}
+	return nil
+}
+
+// 切换到新网络的路由器，并按需执行预设
+func switchLocation(l Location, n networkInfo) {
+	routerIP := l.RouterIP
+	if routerIP == "" {
+		routerIP = n.Gateway
+	}
+
+	var old string
+	updateConfig(func(c *Config) {
+		old = c.RouterIP
+		c.RouterIP = routerIP
+		if l.Stok != "" {
+			c.Stok = l.Stok
+			registerSecret(l.Stok)
+		}
+	})
+	queryCache.invalidate()
+
+	// 换了一台路由器，之前记住的身份不再适用
+	if old != routerIP {
+		trackedMu.Lock()
+		tracked.Identity = nil
+		tracked.RouterMove = nil
+		trackedMu.Unlock()
+		saveTrackedState()
+	}
+
+	msg := tr("notify.location_changed.detail", l.Name, routerIP)
+	fields := map[string]interface{}{"location": l.Name, "router_ip": routerIP, "gateway_mac": n.GatewayMAC, "ssid": n.SSID}
+	if l.Profile != "" {
+		path, err := applyProfile(*findProfile(l.Profile), actionOpen)
+		fields["profile"], fields["path"], fields["success"] = l.Profile, path, err == nil
+		if err != nil {
+			msg += "; " + tr("console.location_apply_failed", l.Profile, userMessage(err))
+		} else {
+			msg += "; " + tr("deeplink.done_path", l.Profile, actionOpen, pathLabel(path))
+		}
+	}
+	logf("%s\n", msg)
+	notify("location_changed", tr("notify.location_changed"), msg)
+	recordEvent("location_changed", msg, fields)
+}
+
+// 检测一次所在网络，进入另一个已配置的网络时切换
+func checkLocation() {
+	n := detectNetwork()
+	l := matchLocation(n)
+	name := ""
+	if l != nil {
+		name = l.Name
+	}
+
+	locationMu.Lock()
+	changed := name != currentLocation
+	currentLocation = name
+	locationMu.Unlock()
+	if !changed {
+		return
+	}
+	if l == nil {
+		sayDebug("debug.no_location", n.Gateway, n.GatewayMAC, strings.TrimSpace(n.SSID))
+		return
+	}
+	switchLocation(*l, n)
+}
+
+// 后台检测所在网络，stop 关闭时退出
+func runLocationMonitor(stop <-chan struct{}) {
+	ticker := time.NewTicker(locationCheckInterval)
+	defer ticker.Stop()
+	for {
+		checkLocation()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
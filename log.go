@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 日志级别
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l logLevel) String() string {
+	return levelNames[l]
+}
+
+func parseLogLevel(s string) (logLevel, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return logLevel(i), nil
+		}
+	}
+	if strings.EqualFold(s, "warning") {
+		return levelWarn, nil
+	}
+	return levelInfo, fmt.Errorf("%s", tr("log.bad_level", s))
+}
+
+var (
+	logMu     sync.Mutex
+	minLevel  = levelInfo
+	logJSON   bool          // 每行输出一个JSON对象，便于日志系统采集
+	logOutput io.Writer     = os.Stdout
+	logFile   *rotatingFile // 配置了 log_file 时同时写入的文件
+	logSinks  []logSink     // syslog、Windows 事件日志
+
+	// 命令行指定的级别与格式，优先于配置文件
+	flagLogLevel  string
+	flagLogFormat string
+)
+
+// 为子命令注册 -log-level 与 -log-format 参数
+func registerLogFlags(fs *flag.FlagSet) {
+	fs.StringVar(&flagLogLevel, "log-level", "", tr("flag.log_level"))
+	fs.StringVar(&flagLogFormat, "log-format", "", tr("flag.log_format"))
+}
+
+// 按命令行参数和配置设置日志级别与格式；debug=true 等同于 debug 级别
+func configureLogging() error {
+	level, format := config.LogLevel, config.LogFormat
+	if flagLogLevel != "" {
+		level = flagLogLevel
+	}
+	if flagLogFormat != "" {
+		format = flagLogFormat
+	}
+	min := levelInfo
+	if level != "" {
+		l, err := parseLogLevel(level)
+		if err != nil {
+			return err
+		}
+		min = l
+	} else if config.Debug {
+		min = levelDebug
+	}
+	switch format {
+	case "", "console", "text":
+		logJSON = false
+	case "json":
+		logJSON = true
+	default:
+		return fmt.Errorf("%s", tr("log.bad_format", format))
+	}
+	if err := validateLogRotation(); err != nil {
+		return err
+	}
+	var file *rotatingFile
+	if config.LogFile != "" {
+		f, err := openRotatingFile(config.LogFile, config.LogRotation)
+		if err != nil {
+			return err
+		}
+		file = f
+	}
+	sinks, err := openLogSinks()
+	if err != nil {
+		if file != nil {
+			file.Close()
+		}
+		return err
+	}
+
+	logMu.Lock()
+	minLevel = min
+	if logFile != nil {
+		logFile.Close()
+	}
+	logFile = file
+	for _, s := range logSinks {
+		s.Close()
+	}
+	logSinks = sinks
+	logOutput = os.Stdout
+	if file != nil {
+		logOutput = io.MultiWriter(os.Stdout, file)
+	}
+	logMu.Unlock()
+	return nil
+}
+
+// 是否输出该级别的日志
+func logEnabled(l logLevel) bool {
+	logMu.Lock()
+	defer logMu.Unlock()
+	return l >= minLevel
+}
+
+// 输出一条日志，内容先脱敏。控制台格式下 info 原样输出，其他级别带级别前缀
+func logAt(l logLevel, msg string) {
+	if !logEnabled(l) {
+		return
+	}
+	msg = redact(msg)
+	var line string
+	if logJSON {
+		data, _ := json.Marshal(map[string]string{
+			"time":  time.Now().Format(time.RFC3339),
+			"level": l.String(),
+			"msg":   strings.TrimRight(msg, "\n"),
+		})
+		line = string(data) + "\n"
+	} else {
+		line = msg
+		if l != levelInfo {
+			line = "[" + l.String() + "] " + msg
+		}
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+	}
+	logMu.Lock()
+	defer logMu.Unlock()
+	io.WriteString(logOutput, line)
+	// 发送失败时不再记录日志，避免递归
+	for _, s := range logSinks {
+		s.Log(l, strings.TrimRight(msg, "\n"))
+	}
+}
+
+// 普通日志（info）
+func logf(format string, args ...interface{}) {
+	logAt(levelInfo, fmt.Sprintf(format, args...))
+}
+
+// 调试日志，仅在 debug 级别时输出（凭据已脱敏）
+func debugf(format string, args ...interface{}) {
+	if !logEnabled(levelDebug) {
+		return
+	}
+	logAt(levelDebug, fmt.Sprintf(format, args...))
+}
+
+// 按翻译键输出调试日志，未开启 debug 时不做翻译
+func sayDebug(key string, args ...interface{}) {
+	if !logEnabled(levelDebug) {
+		return
+	}
+	logAt(levelDebug, tr(key, args...))
+}
+
+// 按翻译键输出警告
+func warn(key string, args ...interface{}) {
+	logAt(levelWarn, tr(key, args...))
+}
+
+// 按翻译键输出错误
+func sayError(key string, args ...interface{}) {
+	logAt(levelError, tr(key, args...))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"tplinkfirewalloff/pkg/tplink"
+)
+
+// 登录同时只进行一次，避免并发请求各自登录使旧stok失效
+var loginMu sync.Mutex
+
+// 用管理员密码登录路由器，返回新的stok
+func routerLogin(host, password string) (string, error) {
+	return currentBackend().Login(host, password)
+}
+
+// stok固件的JSON登录，path 为登录请求的路径
+func jsonLogin(host, password, path string) (string, error) {
+	addr, err := resolveRouterHost(host)
+	if err != nil {
+		return "", err
+	}
+	registerSecret(password)
+	registerSecret(tplink.EncodePassword(password))
+	c := newTPLinkClient(addr, "")
+	c.LoginPath = path
+	err = c.Login(routerCtx, password)
+	var codeErr *tplink.Error
+	if errors.As(err, &codeErr) && codeErr.Code == codeBadCredentials {
+		return "", routerErr(ErrAuthExpired, codeErr.Code, tr("login.bad_password"))
+	}
+	if err != nil {
+		return "", tplinkErr(err)
+	}
+	registerSecret(c.Stok)
+	return c.Stok, nil
+}
+
+// 用配置的管理员密码重新登录并保存stok；stale 为调用方认为已失效的stok，
+// 其他请求已经换过新的stok时直接沿用
+func refreshStok(stale string) error {
+	cfg := globalConfig{}.Get()
+	loginMu.Lock()
+	defer loginMu.Unlock()
+	if currentStok() != stale {
+		return nil
+	}
+	stok, err := routerLogin(cfg.RouterIP, cfg.RouterPassword)
+	countStokRefresh(err)
+	if err != nil {
+		warn("console.login_failed", err)
+		return err
+	}
+	// 可能在应用设置的过程中调用，调用方已持有 applyMu，这里只取 configMu
+	globalConfig{}.Update(func(c *Config) { c.Stok = stok })
+	debugf("%s\n", tr("console.login_ok", cfg.RouterIP))
+	return nil
+}
+
+// 当前的stok，其他请求可能随时重新登录更换
+func currentStok() string {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config.Stok
+}
+
+// 配置了管理员密码而还没有stok时先登录
+func ensureStok() error {
+	password := globalConfig{}.Get().RouterPassword
+	if currentStok() != "" || password == "" {
+		return nil
+	}
+	return refreshStok("")
+}
+
+// 已填写路由器地址，并有stok或可用于登录的密码
+func routerConfigured() bool {
+	c := globalConfig{}.Get()
+	return c.routerConfigured()
+}
+
+func (c Config) routerConfigured() bool {
+	return c.RouterIP != "" && (c.Stok != "" || c.RouterPassword != "")
+}
+
+// 请求是否因stok失效而失败：HTTP 401/403，或响应中 error_code 为 -40401/-40404
+func authExpired(responseBody []byte, err error) bool {
+	if err != nil {
+		return errors.Is(err, ErrAuthExpired)
+	}
+	var resp struct {
+		ErrorCode int `json:"error_code"`
+	}
+	return json.Unmarshal(responseBody, &resp) == nil && (resp.ErrorCode == codeUnauthorized || resp.ErrorCode == codeSessionExpired)
+}
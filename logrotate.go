@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 日志文件的轮转设置
+type LogRotationConfig struct {
+	MaxSizeMB  int `json:"max_size_mb"`  // 单个文件的最大大小，超过后轮转，0=不轮转
+	MaxBackups int `json:"max_backups"`  // 保留的旧文件个数，0=不限
+	MaxAgeDays int `json:"max_age_days"` // 旧文件保留天数，0=不限
+}
+
+// 按大小轮转的日志文件：写满后改名为 .1、.2 …，数字越大越旧
+type rotatingFile struct {
+	mu   sync.Mutex
+	path string
+	rot  LogRotationConfig
+	f    *os.File
+	size int64
+}
+
+func openRotatingFile(path string, rot LogRotationConfig) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, rot: rot}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	r.prune()
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	if dir := filepath.Dir(r.path); dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, fi.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	if max := int64(r.rot.MaxSizeMB) << 20; max > 0 && r.size > 0 && r.size+int64(len(p)) > max {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// 关闭当前文件并依次改名，再打开新文件。Windows 上打开的文件不能改名，需先关闭
+func (r *rotatingFile) rotate() error {
+	r.f.Close()
+	r.f = nil
+	backups := r.backups()
+	for i := len(backups); i >= 1; i-- {
+		os.Rename(backups[i-1], fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.prune()
+	return nil
+}
+
+// 现有的旧文件，按编号从新到旧
+func (r *rotatingFile) backups() []string {
+	var out []string
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("%s.%d", r.path, i)
+		if _, err := os.Stat(name); err != nil {
+			return out
+		}
+		out = append(out, name)
+	}
+}
+
+// 删除超出个数或过期的旧文件
+func (r *rotatingFile) prune() {
+	cutoff := time.Time{}
+	if r.rot.MaxAgeDays > 0 {
+		cutoff = time.Now().AddDate(0, 0, -r.rot.MaxAgeDays)
+	}
+	for i, name := range r.backups() {
+		fi, err := os.Stat(name)
+		if err != nil {
+			continue
+		}
+		if (r.rot.MaxBackups > 0 && i >= r.rot.MaxBackups) || fi.ModTime().Before(cutoff) {
+			os.Remove(name)
+		}
+	}
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// 校验轮转设置
+func validateLogRotation() error {
+	rot := config.LogRotation
+	if rot.MaxSizeMB < 0 || rot.MaxBackups < 0 || rot.MaxAgeDays < 0 {
+		return fmt.Errorf("%s", tr("log.bad_rotation"))
+	}
+	return nil
+}
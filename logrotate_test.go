@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	r, err := openRotatingFile(path, LogRotationConfig{MaxSizeMB: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// 每次写入600KB，第二次起每次都超过1MB而轮转
+	for _, c := range []byte("abcd") {
+		if _, err := r.Write(bytes.Repeat([]byte{c}, 600<<10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, want := range map[string]byte{path: 'd', path + ".1": 'c', path + ".2": 'b'} {
+		data, err := os.ReadFile(name)
+		if err != nil || len(data) != 600<<10 || data[0] != want {
+			t.Errorf("%s: %d bytes, %v; want 600KB of %q", filepath.Base(name), len(data), err, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("超出 max_backups 的旧文件没有删除: %v", err)
+	}
+}
+
+func TestRotatingFilePrunesOldBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	old := time.Now().AddDate(0, 0, -3)
+	for _, name := range []string{path + ".1", path + ".2"} {
+		if err := os.WriteFile(name, []byte("old\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chtimes(path+".2", old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := openRotatingFile(path, LogRotationConfig{MaxAgeDays: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Errorf("未过期的旧文件被删除: %v", err)
+	}
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Errorf("过期的旧文件没有删除: %v", err)
+	}
+}
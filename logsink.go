@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 作为服务运行时没有控制台，日志可同时发送到 syslog 或 Windows 事件日志
+type logSink interface {
+	Log(l logLevel, msg string) error
+	Close() error
+}
+
+// syslog 设置；network 留空时写入本机 syslog（Windows 上必须指定远程地址）
+type SyslogConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Network  string `json:"network"`  // udp / tcp，留空为本机
+	Address  string `json:"address"`  // 如 192.168.0.10:514
+	Tag      string `json:"tag"`      // 默认 tplinkfirewalloff
+	Facility string `json:"facility"` // daemon / user / local0-local7，默认 daemon
+}
+
+// Windows 事件日志设置
+type EventLogConfig struct {
+	Enabled bool   `json:"enabled"`
+	Source  string `json:"source"` // 事件来源名称，默认 tplinkfirewalloff
+}
+
+const defaultLogTag = "tplinkfirewalloff"
+
+var syslogFacilities = map[string]int{
+	"user": 1, "daemon": 3,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// 日志级别对应的 syslog 严重程度
+var syslogSeverity = map[logLevel]int{levelDebug: 7, levelInfo: 6, levelWarn: 4, levelError: 3}
+
+// RFC 3164 格式的 syslog 客户端，连接断开时下次写入重新连接
+type syslogSink struct {
+	mu       sync.Mutex
+	network  string
+	address  string
+	tag      string
+	facility int
+	hostname string
+	conn     net.Conn
+}
+
+func newSyslogSink(c SyslogConfig) (*syslogSink, error) {
+	s := &syslogSink{network: c.Network, address: c.Address, tag: c.Tag, facility: 3}
+	if s.tag == "" {
+		s.tag = defaultLogTag
+	}
+	if c.Facility != "" {
+		f, ok := syslogFacilities[strings.ToLower(c.Facility)]
+		if !ok {
+			return nil, fmt.Errorf("%s", tr("log.bad_facility", c.Facility))
+		}
+		s.facility = f
+	}
+	s.hostname, _ = os.Hostname()
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *syslogSink) connect() error {
+	if s.network != "" {
+		conn, err := net.DialTimeout(s.network, s.address, 5*time.Second)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+		return nil
+	}
+	// 本机 syslog 的套接字位置因系统而异
+	for _, path := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+		for _, network := range []string{"unixgram", "unix"} {
+			if conn, err := net.Dial(network, path); err == nil {
+				s.conn = conn
+				return nil
+			}
+		}
+	}
+	return errors.New(tr("log.no_local_syslog"))
+}
+
+func (s *syslogSink) Log(l logLevel, msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pri := s.facility*8 + syslogSeverity[l]
+	line := fmt.Sprintf("<%d>%s %s %s[%d]: %s", pri, time.Now().Format(time.Stamp), s.hostname, s.tag, os.Getpid(), msg)
+	if s.network == "tcp" {
+		line += "\n"
+	}
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+	if _, err := s.conn.Write([]byte(line)); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// 按配置打开 syslog 与事件日志
+func openLogSinks() ([]logSink, error) {
+	var sinks []logSink
+	if config.Syslog.Enabled {
+		s, err := newSyslogSink(config.Syslog)
+		if err != nil {
+			return nil, fmt.Errorf("syslog: %v", err)
+		}
+		sinks = append(sinks, s)
+	}
+	if config.EventLog.Enabled {
+		source := config.EventLog.Source
+		if source == "" {
+			source = defaultLogTag
+		}
+		s, err := openEventLog(source)
+		if err != nil {
+			for _, other := range sinks {
+				other.Close()
+			}
+			return nil, fmt.Errorf("event_log: %v", err)
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
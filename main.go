@@ -2,320 +2,882 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"html/template"
-	"io"
 	"net/http"
 	"os"
-	"os/exec"
+	"os/signal"
 	"runtime"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
+
+	"tplinkfirewalloff/internal/browser"
+	"tplinkfirewalloff/internal/configstore"
+	"tplinkfirewalloff/pkg/tplink"
 )
 
 // 配置结构
 type Config struct {
-	RouterIP           string `json:"router_ip"`
-	Stok               string `json:"stok"`
-	IPv6FirewallEnable string `json:"ipv6_firewall_enable"`
-	DmzDestIP          string `json:"dmz_dest_ip"`
-	DmzDestIP6         string `json:"dmz_dest_ip6"`
-	ServerPort         string `json:"server_port"`
-	DmzEnable          string `json:"dmz_enable"` // DMZ启用状态 0=关闭 1=启用
+	RouterIP           string              `json:"router_ip"`
+	Stok               string              `json:"stok"`
+	RouterPassword     string              `json:"router_password"` // 路由器管理员密码，填写后自动登录获取stok
+	IPv6FirewallEnable string              `json:"ipv6_firewall_enable"`
+	DmzDestIP          string              `json:"dmz_dest_ip"`
+	DmzDestIP6         string              `json:"dmz_dest_ip6"`
+	ServerPort         string              `json:"server_port"`
+	DmzEnable          string              `json:"dmz_enable"`           // DMZ启用状态 0=关闭 1=启用
+	WANPort            string              `json:"wan_port"`             // DMZ映射的WAN口编号，双WAN或IPTV/桥接机型按需修改
+	Debug              bool                `json:"debug"`                // 输出调试日志（凭据已脱敏）
+	Cassette           string              `json:"cassette"`             // 录制/回放路由器交互的磁带文件
+	CassetteMode       string              `json:"cassette_mode"`        // record=录制 replay=回放
+	BreakerThreshold   int                 `json:"breaker_threshold"`    // 连续失败多少次后熔断，0=不熔断
+	BreakerCooldown    string              `json:"breaker_cooldown"`     // 熔断冷却时间，如 "30s"
+	RateLimit          float64             `json:"rate_limit"`           // 每秒最多向路由器发送的请求数，0=不限
+	RateBurst          int                 `json:"rate_burst"`           // 允许的突发请求数
+	RateLimitWait      string              `json:"rate_limit_wait"`      // 超出速率时最长排队时间，超过则拒绝
+	StateCacheTTL      string              `json:"state_cache_ttl"`      // 路由器状态查询结果的缓存时间，如 "2s"
+	Language           string              `json:"language"`             // 界面语言 zh/en
+	Hooks              HooksConfig         `json:"hooks"`                // 应用设置前后执行的命令
+	ExposureAudit      bool                `json:"exposure_audit"`       // 开启DMZ后扫描目标主机的常见端口并提示风险
+	Notify             NotifyConfig        `json:"notify"`               // 通知渠道
+	RouterMonitor      MonitorConfig       `json:"router_monitor"`       // 路由器可用性监控
+	TargetMonitor      MonitorConfig       `json:"target_monitor"`       // DMZ目标主机存活监控
+	HistoryFile        string              `json:"history_file"`         // 历史事件库文件，留空不记录
+	HistoryMaxEvents   int                 `json:"history_max_events"`   // 历史事件库保留的事件数，0 为不限
+	StateFile          string              `json:"state_file"`           // 期望状态与路由器确认状态的持久化文件
+	MaintenanceWindows []MaintenanceWindow `json:"maintenance_windows"`  // 维护时段，期间不自动修改路由器
+	Schedules          []Schedule          `json:"schedules"`            // 定时任务
+	Timezone           string              `json:"timezone"`             // 定时任务与维护时段使用的IANA时区，留空为本机时区
+	Users              []UserAccount       `json:"users"`                // 界面用户，留空则不需要登录
+	OIDC               OIDCConfig          `json:"oidc"`                 // OpenID Connect 单点登录
+	Controller         ControllerConfig    `json:"controller"`           // 作为中心管理多个网络中的代理
+	Agent              AgentConfig         `json:"agent"`                // 作为代理连接到中心
+	TrafficAlert       TrafficConfig       `json:"traffic_alert"`        // 流量阈值告警
+	RouterIdentity     RouterIdentity      `json:"router_identity"`      // 期望的路由器型号/MAC，修改前校验
+	Profiles           []Profile           `json:"profiles"`             // 预设的暴露目标，用于 /apply 链接
+	ApplyToken         string              `json:"apply_token"`          // /apply 链接携带此令牌时无需登录
+	Listeners          []ListenerConfig    `json:"listeners"`            // 监听地址，留空为 server_port 上的HTTP
+	StatusFile         string              `json:"status_file"`          // 状态变化时写入的文件（.json 或 .ini），供外部脚本读取
+	LocalFirewall      LocalFirewallConfig `json:"local_firewall"`       // DMZ指向本机时同步Windows防火墙入站规则
+	Reboot             RebootConfig        `json:"reboot"`               // 定时重启路由器，重启后重新应用设置
+	Routers            []RouterProfile     `json:"routers"`              // 管理的多台路由器，可在界面或用 --profile 切换
+	ApplyConcurrency   int                 `json:"apply_concurrency"`    // “应用到全部路由器”时同时操作的路由器数，默认 4
+	MeshRedirect       bool                `json:"mesh_redirect"`        // router_ip 为易展子路由时自动改为操作主路由
+	FirmwareType       string              `json:"firmware_type"`        // 固件类型：留空为stok接口，legacy 为早期Cookie认证固件，smb 为商用ER/TL-R系列，mercury/fast 为水星/迅捷，plugin:<名称> 为后端插件
+	RouterUsername     string              `json:"router_username"`      // legacy 固件的登录用户名，默认 admin
+	Locations          []Location          `json:"locations"`            // 常用网络，检测到所在网络后自动切换路由器
+	PluginDir          string              `json:"plugin_dir"`           // 插件目录，启动时加载其中的可执行文件
+	Watch              WatchConfig         `json:"watch"`                // 守护：路由器设置被改回时重新设置
+	RouterLog          string              `json:"router_log"`           // 记录每次路由器请求与响应（已脱敏）的文件，留空不记录
+	LogLevel           string              `json:"log_level"`            // 日志级别 debug/info/warn/error，默认 info
+	LogFormat          string              `json:"log_format"`           // 日志格式 console/json
+	LogFile            string              `json:"log_file"`             // 同时写入的日志文件，留空只输出到控制台
+	LogRotation        LogRotationConfig   `json:"log_rotation"`         // 日志文件按大小轮转
+	Syslog             SyslogConfig        `json:"syslog"`               // 同时发送到本机或远程 syslog
+	EventLog           EventLogConfig      `json:"event_log"`            // 同时写入Windows事件日志
+	ConnectTimeout     string              `json:"connect_timeout"`      // 连接路由器的超时，默认 5s
+	ReadTimeout        string              `json:"read_timeout"`         // 等待路由器响应的超时，默认 15s
+	Retry              RetryConfig         `json:"retry"`                // 路由器暂时不可用时的重试
+	RouterScheme       string              `json:"router_scheme"`        // 访问路由器的协议 http/https，默认 http
+	InsecureSkipVerify bool                `json:"insecure_skip_verify"` // HTTPS时不校验路由器证书
+	CAFile             string              `json:"ca_file"`              // HTTPS时用于校验路由器自签名证书的CA文件（PEM）
+	Headers            map[string]string   `json:"headers"`              // 附加到每个路由器请求的请求头，如 Referer、User-Agent
+	Proxy              string              `json:"proxy"`                // 访问路由器的代理 http://、socks5://，direct=不用代理，留空按环境变量
 }
 
 var (
 	config       Config
-	childProcess *os.Process // 跟踪子进程
-	mu           sync.Mutex  // 确保进程操作线程安全
-	processGroup int         // Windows进程组ID
+	routerClient = &http.Client{}
+	breaker      *circuitBreaker
+	applyMu      sync.Mutex // 修改依次执行，界面、定时任务、ctl 命令共用同一队列
 )
 
-// 读取配置文件
+// 默认配置，配置文件不存在或未包含这些字段时生效
+func defaultConfig() Config {
+	c := Config{
+		ServerPort:       "8080",
+		DmzEnable:        "1", // 默认启用DMZ
+		WANPort:          "0",
+		BreakerThreshold: 5,
+		BreakerCooldown:  "30s",
+		RateLimit:        2,
+		RateBurst:        3,
+		RateLimitWait:    "5s",
+		StateCacheTTL:    "2s",
+		HistoryFile:      "history.db",
+		HistoryMaxEvents: 10000,
+		StateFile:        "state.json",
+		LogRotation:      LogRotationConfig{MaxSizeMB: 10, MaxBackups: 5},
+		Retry:            RetryConfig{Attempts: 3, BaseDelay: "1s", MaxDelay: "10s", Jitter: 0.2},
+	}
+	c.Notify.MaxPerHour = 20
+	return c
+}
+
+// 读取配置文件，filename 为 "-" 时从标准输入读取；环境变量与命令行参数覆盖文件中的值
 func readConfig(filename string) error {
-	file, err := os.Open(filename)
-	if err != nil {
-		// 配置文件不存在时，设置默认值
-		config.ServerPort = "8080"
-		config.DmzEnable = "1" // 默认启用DMZ
-		return err
+	return loadConfig(configstore.NewFile(findConfig(filename)))
+}
+
+// 从 store 读取配置；先读到副本中，再在 configMu 内整体替换，后台任务不会读到一半的配置
+func loadConfig(store configstore.Store) error {
+	// 从默认配置开始，重新读取时文件中删去的字段也恢复默认
+	next := defaultConfig()
+	var keep configstore.Store
+	err := store.Load(&next)
+	switch {
+	case err == nil:
+		if f, ok := store.(*configstore.File); !ok || f.Path != "-" {
+			keep = store
+		}
+		err = applyOverrides(&next)
+	case os.IsNotExist(err):
+		// 没有配置文件时在默认配置上应用环境变量与命令行参数；
+		// 已给出路由器地址时不再报错，容器中可以不挂载配置文件
+		if oerr := applyOverrides(&next); oerr != nil {
+			err = oerr
+		} else if envConfigured() || flagOverridden("router_ip") {
+			err = nil
+		}
 	}
-	defer file.Close()
+	configMu.Lock()
+	config, configStore = next, keep
+	configMu.Unlock()
+	return err
+}
+
+// 程序当前使用的配置，首页等处理器通过它读写配置
+type ConfigStore interface {
+	Get() Config          // 配置的副本
+	Update(func(*Config)) // 在锁内修改配置
+}
+
+// 读写全局 config，由 configMu 保护
+type globalConfig struct{}
+
+var configMu sync.RWMutex
+
+func (globalConfig) Get() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
+
+func (globalConfig) Update(f func(*Config)) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	f(&config)
+}
+
+// 应用当前配置：依次执行 pre_apply 钩子、发送设置请求、执行 post_apply 钩子。
+// source 为修改来源，自动来源在维护时段内会被拒绝
+func applySettings(source string) error {
+	return applySettingsBy(source, "")
+}
 
-	bytes, err := io.ReadAll(file)
+// 同 applySettings，actor 为发起修改的界面用户或客户端地址，记入历史
+func applySettingsBy(source, actor string) error {
+	_, err := applyChanges(source, actor, allSections)
+	return err
+}
+
+// 应用当前配置中 sections 选中的部分；路由器已是目标状态时不发送 set，changed 为 false
+func applyChanges(source, actor string, sections applySections) (changed bool, err error) {
+	applyMu.Lock()
+	defer applyMu.Unlock()
+	return applyLocked(activeTarget(currentBackend()), source, actor, sections)
+}
+
+// 在修改队列中先用 update 修改 store 中的配置再经 client 应用，并发提交的字段不会互相穿插；
+// confirm 不为 nil 且返回 false 时恢复原配置，不应用
+func applyUpdate(client RouterClient, store ConfigStore, update func(*Config), confirm func() bool, source, actor string, sections applySections) (bool, error) {
+	applyMu.Lock()
+	defer applyMu.Unlock()
+	prev := store.Get()
+	store.Update(update)
+	if confirm != nil && !confirm() {
+		store.Update(func(c *Config) { *c = prev })
+		return false, nil
+	}
+	return applyLocked(activeTarget(client), source, actor, sections)
+}
+
+// 在修改队列中把配置中 sections 选中的部分改为 desired 后应用
+func applyDesired(desired firewallState, source, actor string, sections applySections) (bool, error) {
+	return applyUpdate(currentBackend(), globalConfig{}, func(c *Config) { assignSections(c, desired, sections) }, nil, source, actor, sections)
+}
+
+// 在修改队列中修改配置而不应用，如切换路由器；与应用设置、重新读取配置互斥
+func updateConfig(update func(*Config)) {
+	applyMu.Lock()
+	defer applyMu.Unlock()
+	globalConfig{}.Update(update)
+}
+
+// 一次应用的目标路由器
+type applyTarget struct {
+	client RouterClient
+	router RouterProfile // 地址与期望设置
+	active bool          // 当前选中的路由器：核对记住的身份，更新跟踪状态并写回配置
+}
+
+// 当前选中的路由器，期望设置取自当前配置
+func activeTarget(client RouterClient) applyTarget {
+	p := profileOf(globalConfig{}.Get())
+	p.Name = activeRouter()
+	return applyTarget{client: client, router: p, active: true}
+}
+
+// 同 applyChanges，把 t 的期望设置写入 t 指向的路由器；调用方持有 applyMu
+func applyLocked(t applyTarget, source, actor string, sections applySections) (changed bool, err error) {
+	cfg := globalConfig{}.Get()
+	desired := t.router.desired()
+	if err := guardAutomatic(source); err != nil {
+		say("console.maintenance_skip", err)
+		return false, err
+	}
+	if t.active {
+		checkMeshTarget()
+		err = verifyRouterIdentity()
+	} else {
+		err = verifyProfileIdentity(t.client, t.router)
+	}
 	if err != nil {
-		config.ServerPort = "8080"
-		config.DmzEnable = "1"
+		recordApplyEventFor(t.router.RouterIP, desired, source, actor, 0, "", err)
+		countApply(source, err)
+		return false, err
+	}
+	// 先读取路由器当前设置：已是目标状态时不再写入（避免反复写闪存和多余的通知），
+	// 否则记为撤销目标；读取失败时照常发送
+	if current, getErr := getState(t.client); getErr == nil && current.IPv6FirewallEnable != "" {
+		if sections.Matches(current, desired) {
+			if t.active {
+				recordUnchanged(current)
+				persistConfig(source)
+			}
+			sayDebug("debug.already_desired")
+			return false, nil
+		}
+		if t.active {
+			rememberPrevious(current)
+		}
+	}
+	if err := runHooks("pre_apply", cfg.Hooks.PreApply, t.router, nil); err != nil {
+		return false, routerErr(ErrBadParameter, 0, err.Error())
+	}
+	start := time.Now()
+	response, err := sendRequest(t, sections)
+	elapsed := time.Since(start)
+	if t.active {
+		recordApply(desired, err)
+	}
+	recordApplyEventFor(t.router.RouterIP, desired, source, actor, elapsed, response, err)
+	countApply(source, err)
+	if err == nil && t.active {
+		// 读回路由器状态确认设置已生效
+		start := time.Now()
+		_, verifyErr := refreshConfirmedState(t.client)
+		recordTiming("verify", time.Since(start), verifyErr)
+		syncLocalFirewall()
+		persistConfig(source)
+	}
+	if hookErr := runHooks("post_apply", cfg.Hooks.PostApply, t.router, err); hookErr != nil {
+		warn("console.hook_failed", hookErr)
+	}
+	return true, err
+}
+
+// 经 t.client 发送设置到路由器，返回响应内容
+func sendRequest(t applyTarget, sections applySections) (string, error) {
+	desired := t.router.desired()
+	if err := checkCapabilities(t.client.Capabilities(), desired, sections); err != nil {
+		return "", err
+	}
+	var responseBody []byte
+	err := withRetry("set", func() (err error) {
+		defer queryCache.invalidate()
+		responseBody, err = setSections(t.client, desired, sections, t.router.WANPort)
 		return err
+	})
+	return string(responseBody), err
+}
+
+// 向路由器 /ds 接口发送请求，经过限流和熔断器保护，op 用于耗时统计
+func callRouter(op string, requestBody map[string]interface{}) ([]byte, error) {
+	return callRouterVia(postRouterStok, op, requestBody)
+}
+
+// 同 callRouter，经 post 发送；后端用它以自己的方式发送，不再查找当前后端
+func callRouterVia(post dsPost, op string, requestBody map[string]interface{}) ([]byte, error) {
+	cfg := globalConfig{}.Get()
+	if err := limiterFor(cfg.RouterIP).Wait(); err != nil {
+		return nil, err
+	}
+	if err := breaker.Allow(); err != nil {
+		return nil, err
+	}
+	if err := ensureStok(); err != nil {
+		breaker.Record(err)
+		return nil, err
 	}
+	start := time.Now()
+	stok := currentStok()
+	responseBody, err := post(cfg.RouterIP, stok, requestBody)
+	// stok过期时用保存的密码重新登录，并重试一次原请求
+	if cfg.RouterPassword != "" && authExpired(responseBody, err) {
+		sayDebug("debug.stok_expired")
+		if loginErr := refreshStok(stok); loginErr == nil {
+			responseBody, err = post(cfg.RouterIP, currentStok(), requestBody)
+		}
+	}
+	recordTiming(op, time.Since(start), err)
+	breaker.Record(err)
+	return responseBody, err
+}
 
-	// 解析前设置默认值，防止配置文件中未包含这些字段
-	config.ServerPort = "8080"
-	config.DmzEnable = "1"
-	return json.Unmarshal(bytes, &config)
+// 向指定地址的路由器发送请求，不经过限流和熔断器
+func postRouterTo(host string, requestBody map[string]interface{}) ([]byte, error) {
+	return postRouterStok(host, currentStok(), requestBody)
 }
 
-// 发送请求到路由器
-func sendRequest() (bool, string) {
-	requestBody := map[string]interface{}{
-		"firewall": map[string]interface{}{
-			"dmz": map[string]interface{}{
-				"enable":   config.DmzEnable,
-				"dest_ip":  config.DmzDestIP,
-				"wan_port": "0",
-				"dest_ip6": config.DmzDestIP6,
-			},
-			"ipv6_firewall": map[string]interface{}{
-				"enable": config.IPv6FirewallEnable,
-			},
-		},
-		"method": "set",
+// 用指定的stok向路由器发送请求，用于同时操作多台路由器
+func postRouterStok(host, stok string, requestBody map[string]interface{}) ([]byte, error) {
+	if c, ok := currentBackend().(dsRequester); ok {
+		return c.DS(host, stok, requestBody)
 	}
+	return nil, routerErr(ErrUnsupportedFirmware, codeUnsupported, "firmware_type "+globalConfig{}.Get().FirmwareType)
+}
 
-	body, err := json.Marshal(requestBody)
+// 向 /stok=.../ds 发送JSON请求，返回原始响应
+func postDS(host, stok string, requestBody map[string]interface{}) ([]byte, error) {
+	addr, err := resolveRouterHost(host)
 	if err != nil {
-		return false, fmt.Sprintf("错误: %v", err)
+		return nil, err
 	}
-
-	url := fmt.Sprintf("http://%s/stok=%s/ds", config.RouterIP, config.Stok)
-
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(body))
+	registerSecret(stok)
+	c := newTPLinkClient(addr, stok)
+	sayDebug("debug.router_request", c.BaseURL+"/stok="+stok+"/ds", mustJSON(requestBody))
+	responseBody, err := c.DS(routerCtx, requestBody)
 	if err != nil {
-		return false, fmt.Sprintf("请求错误: %v", err)
+		// 错误信息中包含完整URL，routerErr会脱敏
+		return nil, tplinkErr(err)
 	}
-	defer resp.Body.Close()
+	sayDebug("debug.router_response", responseBody)
+	return responseBody, nil
+}
 
-	responseBody, err := io.ReadAll(resp.Body)
+// 解析路由器JSON响应并检查 error_code
+func decodeRouterResponse(responseBody []byte) (map[string]json.RawMessage, error) {
+	resp, err := tplink.Decode(responseBody)
 	if err != nil {
-		return false, fmt.Sprintf("读取响应错误: %v", err)
+		return nil, tplinkErr(err)
 	}
+	return resp, nil
+}
+
+// 首页提交的设置
+type settingsForm struct {
+	RouterIP           string `form:"router_ip"`
+	Stok               string `form:"stok"`
+	RouterPassword     string `form:"router_password"`
+	IPv6FirewallEnable string `form:"ipv6_firewall_enable,lower"`
+	DmzEnable          string `form:"dmz_enable"`
+	DmzDestIP          string `form:"dmz_dest_ip"`
+	DmzDestIP6         string `form:"dmz_dest_ip6"`
+	WANPort            string `form:"wan_port"`
+	Overwrite          bool   `form:"overwrite"`
+	Preview            bool   `form:"preview"` // 只预览将发送的请求，不修改
+	OnlyFirewall       bool   `form:"only_firewall"`
+	OnlyDMZ            bool   `form:"only_dmz"`
+}
 
-	return resp.StatusCode == 200, string(responseBody)
+// 首页：显示并修改IPv6防火墙与DMZ设置；路由器后端与配置由调用方传入，测试时可替换
+type indexHandler struct {
+	client RouterClient
+	store  ConfigStore
 }
 
-// HTTP请求处理
-func handler(w http.ResponseWriter, r *http.Request) {
+func (h indexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodPost {
-		config.RouterIP = r.FormValue("router_ip")
-		config.Stok = r.FormValue("stok")
-
-		// 处理IPv6防火墙状态
-		ipv6Firewall := strings.ToLower(r.FormValue("ipv6_firewall_enable"))
-		switch ipv6Firewall {
-		case "on":
-			config.IPv6FirewallEnable = "on"
-		case "off":
-			config.IPv6FirewallEnable = "off"
-		default:
-			config.IPv6FirewallEnable = ipv6Firewall
+		var warnings []string
+		var form settingsForm
+		if err := bindForm(formOf(r), &form); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-
-		// 处理DMZ启用状态
-		dmzEnable := r.FormValue("dmz_enable")
-		if dmzEnable == "0" || dmzEnable == "1" {
-			config.DmzEnable = dmzEnable
-		} else {
-			fmt.Fprintf(w, "DMZ启用状态必须为0或1，已保持原有值: %s<br>", config.DmzEnable)
+		sections := sectionsFor(form.OnlyFirewall, form.OnlyDMZ)
+		if form.Preview {
+			cfg := h.store.Get()
+			stok, wanPort := form.Stok, form.WANPort
+			if stok == "" {
+				stok = cfg.Stok
+			}
+			if wanPort == "" {
+				wanPort = cfg.WANPort
+			}
+			renderPreview(w, form.RouterIP, stok, firewallState{
+				IPv6FirewallEnable: form.IPv6FirewallEnable,
+				DmzEnable:          form.DmzEnable,
+				DmzDestIP:          form.DmzDestIP,
+				DmzDestIP6:         form.DmzDestIP6,
+			}, sections, wanPort)
+			return
 		}
 
-		config.DmzDestIP = r.FormValue("dmz_dest_ip")
-		config.DmzDestIP6 = r.FormValue("dmz_dest_ip6")
+		// 修改配置与应用在同一队列中完成，同时进行的其他提交不会插入其间；
+		// 在 configMu 内只记下沿用的原值，提示在锁外翻译
+		var badDMZ, badWAN bool
+		var keptDMZ, keptWAN string
+		update := func(c *Config) {
+			c.RouterIP = form.RouterIP
+			c.Stok = form.Stok
+			// 密码留空时沿用已保存的密码
+			if form.RouterPassword != "" {
+				c.RouterPassword = form.RouterPassword
+			}
+			// 未选中的部分沿用原配置，不写入路由器
+			if sections.Firewall {
+				c.IPv6FirewallEnable = form.IPv6FirewallEnable
+			}
+
+			// 处理DMZ启用状态
+			if sections.DMZ {
+				if form.DmzEnable == "0" || form.DmzEnable == "1" {
+					c.DmzEnable = form.DmzEnable
+				} else {
+					badDMZ, keptDMZ = true, c.DmzEnable
+				}
 
-		success, message := sendRequest()
-		if success {
-			http.Redirect(w, r, "/success", http.StatusSeeOther)
-		} else {
-			fmt.Fprintf(w, "操作失败: %s", message)
+				c.DmzDestIP = form.DmzDestIP
+				c.DmzDestIP6 = form.DmzDestIP6
+				if validWANPort(form.WANPort) {
+					c.WANPort = form.WANPort
+				} else if form.WANPort != "" {
+					badWAN, keptWAN = true, c.WANPort
+				}
+			}
+		}
+		// 与路由器现有配置冲突时先让用户确认是否覆盖，此时不修改配置
+		var conflicts []conflict
+		var confirm func() bool
+		if !form.Overwrite && sections.DMZ {
+			confirm = func() bool {
+				conflicts = detectConflicts(desiredFromConfig(), nil)
+				return len(conflicts) == 0
+			}
+		}
+
+		changed, err := applyUpdate(h.client, h.store, update, confirm, sourceUser, actorOf(r), sections)
+		if badDMZ {
+			warnings = append(warnings, tr("warn.dmz_enable", keptDMZ))
 		}
+		if badWAN {
+			warnings = append(warnings, tr("warn.wan_port", keptWAN))
+		}
+		if len(conflicts) > 0 {
+			renderConflicts(w, r, conflicts)
+			return
+		}
+		if err != nil {
+			renderTemplate(w, httpStatusFor(err), "error.html", map[string]interface{}{
+				"Warnings":         warnings,
+				"Message":          userMessage(err),
+				"Detail":           err.Error(),
+				"IdentityMismatch": errors.Is(err, ErrIdentityMismatch) && h.store.Get().RouterIdentity == (RouterIdentity{}),
+			})
+			return
+		}
+		warnings = append(warnings, exposureWarnings()...)
+		if len(warnings) > 0 || !changed {
+			data := map[string]interface{}{"Warnings": warnings}
+			if !changed {
+				data["Notice"] = tr("apply.unchanged")
+			}
+			renderTemplate(w, http.StatusOK, "success.html", data)
+			return
+		}
+		http.Redirect(w, r, "/success", http.StatusSeeOther)
 		return
 	}
 
-	// 网页表单模板
-	tmpl := `<html>
-		<body>
-			<form method="post">
-				<label>Router IP:</label><br>
-				<input type="text" name="router_ip" placeholder="例如: 192.168.0.1" value="{{.RouterIP}}"><br>
-				
-				<label>Stok:</label><br>
-				<input type="text" name="stok" placeholder="路由器认证令牌" value="{{.Stok}}"><br>
-				
-				<label>IPv6 Firewall Enable (on=开启,off=关闭):</label><br>
-				<input type="text" name="ipv6_firewall_enable" placeholder="on或off" value="{{.IPv6FirewallEnable}}"><br>
-				
-				<label>DMZ 启用状态 (1=启用,0=关闭):</label><br>
-				<input type="text" name="dmz_enable" placeholder="0或1" value="{{.DmzEnable}}"><br>
-				
-				<label>DMZ Destination IP (IPv4):</label><br>
-				<input type="text" name="dmz_dest_ip" placeholder="例如: 192.168.0.102" value="{{.DmzDestIP}}"><br>
-				
-				<label>DMZ Destination IPv6:</label><br>
-				<input type="text" name="dmz_dest_ip6" placeholder="例如: 240e:370:xx" value="{{.DmzDestIP6}}"><br>
-				
-				<input type="submit" value="提交">
-			</form>
-		</body>
-	</html>`
-	t, _ := template.New("form").Parse(tmpl)
-	t.Execute(w, config)
+	// 已填写stok时读取路由器当前状态，失败不影响表单显示
+	cfg := h.store.Get()
+	var current *firewallState
+	var quick []quickToggle
+	var clock *routerClock
+	var mesh meshInfo
+	form, unchanged := desiredFromConfig(), false
+	if routerConfigured() {
+		if st, err := h.client.GetFirewall(); err == nil {
+			current = &st
+			form, unchanged = formState(st), st.Matches(desiredFromConfig())
+			confirmState(st)
+			quick = quickToggles()
+			clock, _ = queryRouterClock()
+			mesh, _ = queryMesh()
+		} else {
+			sayDebug("debug.state_read_failed", err)
+		}
+	}
+	snapshot, syncState := trackedSnapshot()
+
+	state, remaining := breaker.State()
+	up, known, downSince := availability.Status()
+	data := struct {
+		Config
+		Current          *firewallState // 路由器当前设置，读取失败时为 nil
+		BreakerState     string
+		BreakerRemaining time.Duration
+		RouterDown       bool
+		DownSince        time.Time
+		Sync             string
+		Tracked          trackedState
+		Maintenance      *MaintenanceWindow
+		NextSchedule     string
+		NextScheduleAt   time.Time
+		CanEdit          bool
+		User             *UserAccount
+		Controller       bool
+		Quick            []quickToggle
+		Clock            *routerClock
+		Location         string
+		Form             firewallState // 表单预填值，优先取路由器当前设置
+		Unchanged        bool          // 路由器当前设置已与配置一致
+		CurrentRouter    string        // routers 中当前选中的路由器
+		DefaultRouter    bool          // 顶层配置中也填写了路由器
+		Mesh             meshInfo      // 易展组网角色
+	}{cfg, current, state, remaining, known && !up, downSince, syncState, snapshot, activeMaintenance(time.Now()), "", time.Time{}, canEdit(r), currentUser(r), controllerEnabled(), quick, clock, activeLocation(), form, unchanged, activeRouter(), baseRouter.RouterIP != "", mesh}
+	if s, at, ok := nextScheduled(time.Now()); ok {
+		data.NextSchedule, data.NextScheduleAt = s.label(), at
+	}
+	renderTemplate(w, http.StatusOK, "index.html", data)
 }
 
 // 成功页面处理
 func successHandler(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintln(w, "操作成功！可关闭浏览器返回程序，按Enter退出。")
+	renderTemplate(w, http.StatusOK, "success.html", nil)
 }
 
-// 安全执行命令并跟踪进程组
-func safeExecCommand(name string, args ...string) error {
-	mu.Lock()
-	defer mu.Unlock()
-
-	// 先终止任何已存在的子进程和进程组
-	cleanupProcesses()
+// 打开浏览器
+func openBrowser(url string) error {
+	err := browser.Open(url)
+	switch {
+	case errors.Is(err, browser.ErrNoDisplay):
+		return errors.New(tr("console.no_display"))
+	case errors.Is(err, browser.ErrUnsupported):
+		return errors.New(tr("console.unsupported_os", runtime.GOOS))
+	}
+	return err
+}
 
-	// 创建命令并配置进程组
-	cmd := exec.Command(name, args...)
+// 程序退出前的清理工作
+func cleanup() {
+	var killErr *browser.KillError
+	if err := browser.Cleanup(); errors.As(err, &killErr) {
+		warn("console.kill_failed", killErr.PID, killErr.Err)
+	}
+}
 
-	// Windows特有的进程组设置
-	if runtime.GOOS == "windows" {
-		// 创建新的进程组
-		cmd.SysProcAttr = &syscall.SysProcAttr{
-			CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP,
+func main() {
+	// 子命令：模拟路由器
+	if len(os.Args) > 1 && os.Args[1] == "simulator" {
+		if err := runSimulator(os.Args[2:]); err != nil {
+			sayError("console.simulator_error", err)
+			os.Exit(1)
 		}
+		return
+	}
+	// 子命令：生成界面用户的密码摘要
+	if len(os.Args) > 1 && os.Args[1] == "hash-password" {
+		if err := runHashPassword(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitCodeFor(ErrBadParameter))
+		}
+		return
 	}
 
-	// 启动命令
-	if err := cmd.Start(); err != nil {
-		return err
+	// 其余子命令；不带子命令或直接以参数开头时为 serve
+	args := os.Args[1:]
+	cmd := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	var run func(args []string) error
+	switch cmd {
+	case "serve":
+		run = runServe
+	case "apply":
+		run = runApply
+	case "status":
+		run = runStatus
+	case "login":
+		run = runLogin
+	case "discover":
+		run = runDiscover
+	case "watch":
+		run = runWatch
+	case "history":
+		run = runHistory
+	case "rollback":
+		run = runRollback
+	case "systemd-unit":
+		run = runSystemdUnit
+	case "ctl":
+		run = runCtl
+	case "config":
+		run = runConfig
+	default:
+		fmt.Fprintln(os.Stderr, tr("cli.usage"))
+		os.Exit(exitCodeFor(ErrBadParameter))
 	}
+	if err := run(args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCodeFor(err))
+	}
+}
 
-	// 保存进程引用
-	childProcess = cmd.Process
+// 各子命令共用的启动步骤：读取配置，初始化熔断器、缓存与录制回放，加载状态并校验配置
+func setup(configPath string) error {
+	return setupFrom(configstore.NewFile(findConfig(configPath)))
+}
 
-	// Windows下获取进程组ID
-	if runtime.GOOS == "windows" {
-		processGroup = cmd.Process.Pid
+// 同 setup，配置从 store 读取
+func setupFrom(store configstore.Store) error {
+	if err := loadConfig(store); err != nil {
+		warn("console.config_read_failed", err)
+		say("console.config_fallback")
+	}
+	if err := configureLogging(); err != nil {
+		return routerErr(ErrBadParameter, 0, tr("console.config_invalid", "log", err))
 	}
 
-	// 启动goroutine监控进程，确保完成后清理引用
-	go func() {
-		cmd.Wait()
-		mu.Lock()
-		childProcess = nil
-		processGroup = 0
-		mu.Unlock()
-	}()
-
-	return nil
-}
+	cooldown, err := time.ParseDuration(config.BreakerCooldown)
+	if err != nil {
+		warn("console.bad_duration", "breaker_cooldown", err, "30s")
+		cooldown = 30 * time.Second
+	}
+	breaker = newCircuitBreaker(config.BreakerThreshold, cooldown)
 
-// 清理所有相关进程
-func cleanupProcesses() {
-	if childProcess != nil && childProcess.Pid > 0 {
-		// 先尝试优雅关闭
-		childProcess.Signal(os.Interrupt)
+	if queryCache.ttl, err = time.ParseDuration(config.StateCacheTTL); err != nil {
+		warn("console.bad_cache_ttl", err)
+	}
 
-		// 等待1秒给进程退出时间
-		time.Sleep(1 * time.Second)
+	if err := configureRouterClient(); err != nil {
+		return routerErr(ErrBadParameter, 0, tr("console.config_invalid", "router", err))
+	}
 
-		// 如果仍在运行，强制终止
-		if err := childProcess.Signal(os.Kill); err != nil {
-			fmt.Printf("警告: 无法终止进程 %d: %v\n", childProcess.Pid, err)
+	// 录制/回放路由器交互
+	if config.Cassette != "" {
+		cassette, err := openCassette(config.Cassette, config.CassetteMode, routerClient.Transport)
+		if err != nil {
+			return routerErr(ErrBadParameter, 0, tr("console.cassette_open_failed", err))
 		}
-
-		// Windows下特殊处理：终止整个进程组
-		if runtime.GOOS == "windows" && processGroup > 0 {
-			kernel32, err := syscall.LoadLibrary("kernel32.dll")
-			if err == nil {
-				defer syscall.FreeLibrary(kernel32)
-
-				terminateProc, err := syscall.GetProcAddress(kernel32, "TerminateProcess")
-				if err == nil {
-					// 打开进程组
-					handle, err := syscall.OpenProcess(syscall.PROCESS_TERMINATE, false, uint32(processGroup))
-					if err == nil {
-						defer syscall.CloseHandle(handle)
-
-						// 终止进程组
-						syscall.Syscall(terminateProc, 2, uintptr(handle), 0, 0)
-					}
-				}
-			}
+		routerClient.Transport = cassette
+		if cassette.replay {
+			say("console.cassette_replay", config.Cassette)
+		} else {
+			say("console.cassette_record", config.Cassette)
 		}
+	}
 
-		childProcess = nil
-		processGroup = 0
+	loadTrackedState()
+	applyRouterMove()
+
+	for _, v := range configValidators() {
+		if err := v.check(); err != nil {
+			return routerErr(ErrBadParameter, 0, tr("console.config_invalid", v.name, err))
+		}
 	}
+	initRouters()
+	return nil
 }
 
-// 打开浏览器
-func openBrowser(url string) error {
-	switch runtime.GOOS {
-	case "windows":
-		// 使用start命令的/b参数不创建新窗口，减少进程残留
-		return safeExecCommand("cmd", "/c", "start", "/b", url)
-	default:
-		return fmt.Errorf("不支持的操作系统: %s", runtime.GOOS)
+// 配置各部分的校验，部分校验同时补全默认值
+func configValidators() []struct {
+	name  string
+	check func() error
+} {
+	return []struct {
+		name  string
+		check func() error
+	}{
+		{"timezone", func() error { _, err := loadLocation(""); return err }},
+		{"maintenance_windows", validateMaintenanceWindows},
+		{"schedules", validateSchedules},
+		{"reboot", validateReboot},
+		{"plugin_dir", loadPlugins},
+		{"locations", validateLocations},
+		{"users", validateUsers},
+		{"oidc", validateOIDC},
+		{"controller/agent", validateAgents},
+		{"profiles", validateProfiles},
+		{"listeners", validateListeners},
+		{"local_firewall", validateLocalFirewall},
+		{"proxy", validateProxy},
+		{"routers", validateRouters},
+		{"firmware_type", validateFirmwareType},
 	}
 }
 
-// 程序退出前的清理工作
-func cleanup() {
-	mu.Lock()
-	defer mu.Unlock()
-	cleanupProcesses()
-}
+// serve：启动网页界面并在后台运行监控与定时任务（默认子命令）
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configPath := fs.String("config", "config.json", tr("flag.config"))
+	registerLogFlags(fs)
+	registerRouterFlag(fs)
+	// 兼容 -apply/-headless 参数，与 apply 子命令相同
+	apply := fs.Bool("apply", false, tr("flag.apply"))
+	fs.BoolVar(apply, "headless", false, tr("flag.apply"))
+	daemon := fs.Bool("daemon", false, tr("flag.daemon"))
+	noBrowser := fs.Bool("no-browser", false, tr("flag.no_browser"))
+	noStdin := fs.Bool("no-stdin", false, tr("flag.no_stdin"))
+	headless := registerHeadlessFlags(fs)
+	registerConfigFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return routerErr(ErrBadParameter, 0, err.Error())
+	}
 
-func main() {
 	// 注册程序退出时的清理函数
 	defer cleanup()
 
-	if err := readConfig("config.json"); err != nil {
-		fmt.Println("读取配置文件错误:", err)
-		fmt.Println("将允许通过网页输入配置，服务器使用默认端口 8080...")
+	if err := setup(*configPath); err != nil {
+		return err
+	}
+	if *apply {
+		return applyOnce(headless)
+	}
+
+	if err := loadTemplates(); err != nil {
+		return fmt.Errorf("%s", tr("console.templates_failed", err))
 	}
 
-	http.HandleFunc("/", handler)
-	http.HandleFunc("/success", successHandler)
+	get, post := http.MethodGet, http.MethodPost
+	handle("/", indexHandler{client: activeBackend{}, store: globalConfig{}}.ServeHTTP, get, post)
+	handle("/success", successHandler, get)
+	handle("/advisor", advisorHandler, get, post)
+	handle("/guest", guestHandler, get, post)
+	handle("/iptv", iptvHandler, get, post)
+	handle("/quick", quickHandler, get, post)
+	handle("/access", accessHandler, get, post)
+	handle("/routes", routesHandler, get, post)
+	handle("/dhcp", dhcpHandler, get, post)
+	handle("/triggers", triggersHandler, get, post)
+	handle("/time", timeHandler, get, post)
+	handle("/wan-dmz", wanDMZHandler, get, post)
+	handle("/identity/forget", identityForgetHandler, get, post)
+	handle("/api/clipboard", clipboardHandler, get, post)
+	handle("/apply", applyLinkHandler, get, post)
+	handle("/stats", statsHandler, get)
+	handle("/history", historyPageHandler, get)
+	handle("/rollback", rollbackHandler, post)
+	handle("/router", selectRouterHandler, post)
+	handle("/router/apply-all", applyAllHandler, post)
+	handle("/mesh/primary", meshPrimaryHandler, post)
+	handle("/api/stats", statsHandler, get)
+	handle("/api/status", statusHandler, get)
+	handle("/api/apply", apiApplyHandler, post)
+	handle("/api/logs", logsHandler, get)
+	handle("/api/debug/self", debugSelfHandler, get)
+	handle("/api/debug/bundle", diagnosticsHandler, get)
+	v1 := apiV1Handlers{client: activeBackend{}, store: globalConfig{}}
+	handleJSON("/api/v1/status", v1.status, get)
+	handleJSON("/api/v1/apply", v1.apply, post)
+	handleJSON("/api/v1/config", v1.config, get, http.MethodPut)
+	handle("/api/openapi.json", openAPIHandler, get)
+	handle("/api/docs", apiDocsHandler, get)
+	handle("/healthz", healthzHandler, get)
+	handle("/readyz", readyzHandler, get)
+	handle("/metrics", metricsHandler, get)
+	if config.OIDC.enabled() {
+		handle("/auth/login", oidcLoginHandler, get)
+		handle("/auth/callback", oidcCallbackHandler, get)
+		handle("/auth/logout", oidcLogoutHandler, get, post)
+	}
+	if controllerEnabled() {
+		handle("/agents", agentsHandler, get, post)
+		handleJSON("/api/agent/poll", agentPollHandler)
+	}
 
+	// 没有控制台（容器、被重定向）时与后台服务相同：不读取标准输入、不打开浏览器
+	service := runningAsService(*daemon) || !stdinIsTerminal()
 	serverQuit := make(chan struct{})
+	if config.RouterMonitor.Enabled {
+		go runRouterMonitor(serverQuit)
+	}
+	if config.TargetMonitor.Enabled {
+		go runTargetMonitor(serverQuit)
+	}
+	if len(config.Schedules) > 0 {
+		go runScheduler(serverQuit)
+	}
+	if config.Reboot.Enabled {
+		go runRebootScheduler(serverQuit)
+	}
+	if config.Watch.Enabled {
+		go runWatchdog(serverQuit)
+	}
+	if len(config.Locations) > 0 {
+		go runLocationMonitor(serverQuit)
+	}
+	if config.Agent.ControllerURL != "" {
+		go runAgent(serverQuit)
+	}
+	if config.TrafficAlert.Enabled {
+		go runTrafficMonitor(serverQuit)
+	}
+	// 配置文件修改后重新读取，监听地址改变时重新监听
+	go runConfigReloader(serverQuit, web.restart)
 	go func() {
-		serverAddr := fmt.Sprintf(":%s", config.ServerPort)
-		serverURL := fmt.Sprintf("http://localhost:%s", config.ServerPort)
-
-		fmt.Printf("服务器启动，访问 %s\n", serverURL)
-
+		urls := web.start()
+		if len(urls) == 0 {
+			return
+		}
+		sdNotify("READY=1\nSTATUS=" + tr("console.server_started", urls[0]))
+		if service || *noBrowser {
+			return
+		}
+		// 用第一个能连上的地址打开浏览器（IPv4或IPv6）
+		serverURL := reachableURL(urls)
 		if err := openBrowser(serverURL); err != nil {
-			fmt.Printf("自动打开浏览器失败，请手动访问: %s\n错误原因: %v\n", serverURL, err)
+			warn("console.browser_failed", serverURL, err)
 		} else {
-			fmt.Println("已自动打开默认浏览器，若未弹出请手动访问上述地址")
+			say("console.browser_opened")
 		}
+	}()
 
-		// 创建带关闭功能的服务器
-		srv := &http.Server{Addr: serverAddr}
+	// Ctrl+C 和 SIGTERM（systemd停止服务）都正常退出；
+	// Windows 上关闭控制台窗口、注销或关机（CTRL_CLOSE_EVENT 等）也以 SIGTERM 送达
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	if *configPath == "-" || service || *noStdin {
+		// 标准输入已用于读取配置或没有控制台，只等待信号
+		say("console.press_ctrl_c")
+	} else {
+		say("console.press_enter")
 		go func() {
-			<-serverQuit
-			srv.Close()
+			// 标准输入已关闭（如被重定向）时不退出，继续等待信号
+			if bufio.NewScanner(os.Stdin).Scan() {
+				sig <- os.Interrupt
+			}
 		}()
+	}
+	<-sig
 
-		err := srv.ListenAndServe()
-		if err != nil && !strings.Contains(err.Error(), "closed") {
-			fmt.Printf("服务器错误: %v\n", err)
-			fmt.Printf("提示：端口 %s 可能已被占用，请修改 config.json 中的 server_port 字段（如 8081）\n", config.ServerPort)
-		}
-	}()
-
-	fmt.Println("按Enter键关闭程序...")
-	scanner := bufio.NewScanner(os.Stdin)
-	scanner.Scan()
-
-	fmt.Println("程序正在关闭...")
+	sdNotify("STOPPING=1")
+	say("console.shutting_down")
 	close(serverQuit)
-	// 给服务器关闭留出时间
-	time.Sleep(500 * time.Millisecond)
-	os.Exit(0)
+	// 等待进行中的页面与接口请求完成
+	web.stop()
+	servers.Wait()
+	// 超过等待时间仍未返回的路由器请求直接取消
+	cancelRouterRequests()
+	// 等待进行中的修改完成后保存状态，子进程由 cleanup 清理
+	applyMu.Lock()
+	saveTrackedState()
+	applyMu.Unlock()
+	return nil
 }
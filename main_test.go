@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"tplinkfirewalloff/internal/configstore"
+)
+
+// 不访问网络的路由器后端
+type fakeRouter struct {
+	mu    sync.Mutex
+	state firewallState
+	err   error
+	sets  int
+	dmz   []firewallState // 每次写入的DMZ设置
+}
+
+func (f *fakeRouter) Login(host, password string) (string, error) {
+	return "fake-stok", nil
+}
+
+func (f *fakeRouter) GetFirewall() (firewallState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state, f.err
+}
+
+func (f *fakeRouter) SetFirewall(fs firewallState) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sets++
+	f.state.IPv6FirewallEnable = fs.IPv6FirewallEnable
+	return []byte(`{"error_code":0}`), nil
+}
+
+func (f *fakeRouter) SetDMZ(fs firewallState, wanPort string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sets++
+	f.dmz = append(f.dmz, fs)
+	f.state.DmzEnable, f.state.DmzDestIP, f.state.DmzDestIP6 = fs.DmzEnable, fs.DmzDestIP, fs.DmzDestIP6
+	return []byte(`{"error_code":0}`), nil
+}
+
+func (f *fakeRouter) Capabilities() Capabilities {
+	return fullCapabilities
+}
+
+// 其余功能的 /ds 请求照常发往测试服务器
+func (f *fakeRouter) DS(host, stok string, requestBody map[string]interface{}) ([]byte, error) {
+	return postDS(host, stok, requestBody)
+}
+
+// 用内存中的配置初始化，其余路由器请求发往只返回空结果的测试服务器
+func setupTest(t *testing.T) {
+	t.Helper()
+	router := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"error_code":0}`))
+	}))
+	t.Cleanup(router.Close)
+	store := &configstore.Memory{}
+	store.Save(map[string]interface{}{
+		"router_ip":    strings.TrimPrefix(router.URL, "http://"),
+		"stok":         "test-stok",
+		"state_file":   "",
+		"history_file": "",
+		"rate_limit":   0,
+		"retry":        map[string]interface{}{"attempts": 1},
+	})
+	if err := setupFrom(store); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexShowsRouterState(t *testing.T) {
+	setupTest(t)
+	fake := &fakeRouter{state: firewallState{IPv6FirewallEnable: "on", DmzEnable: "1", DmzDestIP: "192.168.0.77"}}
+	h := indexHandler{client: fake, store: globalConfig{}}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "192.168.0.77") {
+		t.Errorf("页面中没有路由器当前的DMZ地址:\n%s", body)
+	}
+}
+
+func TestIndexPreviewDoesNotChangeConfig(t *testing.T) {
+	setupTest(t)
+	fake := &fakeRouter{}
+	h := indexHandler{client: fake, store: globalConfig{}}
+	before := h.store.Get()
+
+	form := url.Values{
+		"router_ip":            {"192.168.1.1"},
+		"ipv6_firewall_enable": {"off"},
+		"dmz_enable":           {"1"},
+		"dmz_dest_ip":          {"192.168.1.50"},
+		"preview":              {"1"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.ParseForm() // 注册路由时由 handle 完成
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "192.168.1.50") {
+		t.Fatalf("预览 status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if after := h.store.Get(); after.RouterIP != before.RouterIP || after.DmzDestIP != before.DmzDestIP {
+		t.Errorf("预览修改了配置: %+v", after)
+	}
+	if fake.sets != 0 {
+		t.Errorf("预览向路由器写入了 %d 次", fake.sets)
+	}
+}
+
+// 提交设置时读写的是注入的后端，而不是按 firmware_type 选择的全局后端
+func TestIndexPostUsesInjectedClient(t *testing.T) {
+	setupTest(t)
+	fake := &fakeRouter{}
+	h := indexHandler{client: fake, store: globalConfig{}}
+	form := url.Values{
+		"router_ip":            {config.RouterIP},
+		"stok":                 {config.Stok},
+		"ipv6_firewall_enable": {"off"},
+		"dmz_enable":           {"1"},
+		"dmz_dest_ip":          {"192.168.0.7"},
+		"overwrite":            {"1"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.ParseForm()
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if fake.state.IPv6FirewallEnable != "off" || fake.state.DmzDestIP != "192.168.0.7" {
+		t.Errorf("注入的后端状态为 %+v（%d 次写入）", fake.state, fake.sets)
+	}
+}
+
+// 使用 fake 作为当前后端
+func useFakeBackend(t *testing.T, fake *fakeRouter) {
+	routerBackends["fake"] = fake
+	config.FirmwareType = "fake"
+	t.Cleanup(func() {
+		delete(routerBackends, "fake")
+		config.FirmwareType = ""
+	})
+}
+
+func TestConcurrentSubmissionsDoNotInterleave(t *testing.T) {
+	setupTest(t)
+	fake := &fakeRouter{}
+	useFakeBackend(t, fake)
+	h := indexHandler{client: fake, store: globalConfig{}}
+	routerIP, stok := config.RouterIP, config.Stok
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			form := url.Values{
+				"router_ip":            {routerIP},
+				"stok":                 {stok},
+				"ipv6_firewall_enable": {"off"},
+				"dmz_enable":           {"1"},
+				"dmz_dest_ip":          {fmt.Sprintf("192.168.0.%d", i)},
+				"dmz_dest_ip6":         {fmt.Sprintf("fd00::%d", i)},
+				"overwrite":            {"1"},
+			}
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.ParseForm()
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		}(i)
+	}
+	wg.Wait()
+
+	// 每次提交都应按自己的值写入一次，IPv4与IPv6地址来自同一次提交
+	written := map[string]bool{}
+	for _, fs := range fake.dmz {
+		v4 := strings.TrimPrefix(fs.DmzDestIP, "192.168.0.")
+		v6 := strings.TrimPrefix(fs.DmzDestIP6, "fd00::")
+		if v4 != v6 {
+			t.Errorf("写入了不同提交的字段: %s / %s", fs.DmzDestIP, fs.DmzDestIP6)
+		}
+		written[fs.DmzDestIP] = true
+	}
+	if len(written) != 20 {
+		t.Errorf("20次提交只写入了 %d 个不同的地址", len(written))
+	}
+}
+
+// 定时任务、ctl 与界面读取配置同时进行时，每次写入的值都来自同一个来源（需配合 -race 运行）
+func TestConcurrentSourcesDoNotInterleave(t *testing.T) {
+	setupTest(t)
+	fake := &fakeRouter{}
+	useFakeBackend(t, fake)
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			runSchedule(Schedule{Name: "close", IPv6FirewallEnable: "on", DmzEnable: "0"})
+		}()
+		go func(i int) {
+			defer wg.Done()
+			desired := firewallState{
+				IPv6FirewallEnable: "off",
+				DmzEnable:          "1",
+				DmzDestIP:          fmt.Sprintf("192.168.0.%d", i),
+				DmzDestIP6:         fmt.Sprintf("fd00::%d", i),
+			}
+			if _, err := applyDesired(desired, sourceCtl, "", allSections); err != nil {
+				t.Error(err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			globalConfig{}.Get()
+		}()
+	}
+	wg.Wait()
+
+	for _, fs := range fake.dmz {
+		v4 := strings.TrimPrefix(fs.DmzDestIP, "192.168.0.")
+		v6 := strings.TrimPrefix(fs.DmzDestIP6, "fd00::")
+		if fs.DmzEnable == "1" && v4 != v6 {
+			t.Errorf("写入了不同来源的字段: %+v", fs)
+		}
+	}
+	if c := (globalConfig{}).Get(); c.DmzEnable == "0" && c.IPv6FirewallEnable != "on" || c.DmzEnable == "1" && c.IPv6FirewallEnable != "off" {
+		t.Errorf("最终配置混合了不同来源: firewall=%q dmz=%q", c.IPv6FirewallEnable, c.DmzEnable)
+	}
+}
+
+func TestSendRequestUsesBackend(t *testing.T) {
+	setupTest(t)
+	fake := &fakeRouter{}
+	useFakeBackend(t, fake)
+	config.IPv6FirewallEnable, config.DmzEnable, config.DmzDestIP = "off", "1", "192.168.0.9"
+
+	if _, err := sendRequest(activeTarget(fake), applySections{DMZ: true}); err != nil {
+		t.Fatal(err)
+	}
+	if fake.sets != 1 || fake.state.DmzDestIP != "192.168.0.9" || fake.state.IPv6FirewallEnable != "" {
+		t.Errorf("只写DMZ后后端状态为 %+v（%d 次写入）", fake.state, fake.sets)
+	}
+	if _, err := sendRequest(activeTarget(fake), allSections); err != nil {
+		t.Fatal(err)
+	}
+	if fake.state.IPv6FirewallEnable != "off" {
+		t.Errorf("写入全部后 ipv6_firewall = %q", fake.state.IPv6FirewallEnable)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 维护时段：期间定时任务和守护只观察、不修改路由器
+type MaintenanceWindow struct {
+	Start string   `json:"start"` // "02:00"
+	End   string   `json:"end"`   // "04:00"，早于 start 表示跨越午夜
+	Days  []string `json:"days"`  // 可选，如 ["sun","sat"] 或 "weekends"，按开始时刻所在日判断
+}
+
+// 修改来源；只有自动来源受维护时段限制
+const (
+	sourceUser      = "user"
+	sourceScheduler = "scheduler"
+	sourceWatchdog  = "watchdog"
+	sourceRemote    = "remote" // 中心控制端管理员下发
+	sourceCtl       = "ctl"    // 命令行 ctl 子命令
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// 解析 "HH:MM" 为当天的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%s", tr("maintenance.bad_time", s))
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// 判断某时刻是否在时段内
+func (w MaintenanceWindow) contains(now time.Time) bool {
+	start, err1 := parseClock(w.Start)
+	end, err2 := parseClock(w.End)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	if loc, err := loadLocation(""); err == nil {
+		now = now.In(loc)
+	}
+	minute := now.Hour()*60 + now.Minute()
+
+	// 跨午夜时，凌晨部分属于前一天开始的时段
+	day := now
+	switch {
+	case start <= end:
+		if minute < start || minute >= end {
+			return false
+		}
+	case minute >= start:
+	case minute < end:
+		day = now.AddDate(0, 0, -1)
+	default:
+		return false
+	}
+	return w.onDay(day.Weekday())
+}
+
+func (w MaintenanceWindow) onDay(d time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	days, err := expandDays(w.Days)
+	return err == nil && days[d]
+}
+
+// 识别 "mon"、"Monday" 等写法
+func parseWeekday(name string) (time.Weekday, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if len(name) > 3 {
+		name = name[:3]
+	}
+	wd, ok := weekdayNames[name]
+	return wd, ok
+}
+
+func (w MaintenanceWindow) String() string {
+	s := w.Start + "-" + w.End
+	if len(w.Days) > 0 {
+		s += " (" + strings.Join(w.Days, ",") + ")"
+	}
+	return s
+}
+
+// 当前生效的维护时段，不在任何时段内时返回 nil
+func activeMaintenance(now time.Time) *MaintenanceWindow {
+	cfg := globalConfig{}.Get()
+	for i := range cfg.MaintenanceWindows {
+		if cfg.MaintenanceWindows[i].contains(now) {
+			return &config.MaintenanceWindows[i]
+		}
+	}
+	return nil
+}
+
+// 界面、中心控制端或 ctl 命令发起的手动修改
+func manualSource(source string) bool {
+	return source == sourceUser || source == sourceRemote || source == sourceCtl
+}
+
+// 自动修改前调用；维护时段内返回 ErrMaintenance
+func guardAutomatic(source string) error {
+	if manualSource(source) {
+		return nil
+	}
+	if w := activeMaintenance(time.Now()); w != nil {
+		return &RouterError{Kind: ErrMaintenance, Detail: fmt.Sprintf("%s, %s", source, w)}
+	}
+	return nil
+}
+
+// 校验配置中的维护时段
+func validateMaintenanceWindows() error {
+	for _, w := range config.MaintenanceWindows {
+		if _, err := parseClock(w.Start); err != nil {
+			return err
+		}
+		if _, err := parseClock(w.End); err != nil {
+			return err
+		}
+		if _, err := expandDays(w.Days); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// 易展/OneMesh 组网中的角色：防火墙与DMZ只在主路由上生效，子路由上修改没有作用
+type meshInfo struct {
+	Role      string // primary 主路由 / satellite 子路由，未组网或固件不支持时为空
+	PrimaryIP string // 子路由上报的主路由地址
+}
+
+const (
+	meshPrimary   = "primary"
+	meshSatellite = "satellite"
+)
+
+func (m meshInfo) Satellite() bool {
+	return m.Role == meshSatellite
+}
+
+// 读取路由器的易展角色（hyfi 模块），固件不支持时返回空角色
+func queryMesh() (meshInfo, error) {
+	st, err := queryRouter("hyfi", "info")
+	if errors.Is(err, ErrUnsupportedFirmware) {
+		return meshInfo{}, nil
+	}
+	if err != nil {
+		return meshInfo{}, err
+	}
+	info := st["info"]
+	str := func(keys ...string) string {
+		for _, k := range keys {
+			if v, ok := info[k].(string); ok && v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	var m meshInfo
+	// 不同固件的取值不同：master/slave、main/re、AP/RE
+	switch strings.ToLower(str("role", "mode")) {
+	case "master", "main", "ap", "primary":
+		m.Role = meshPrimary
+	case "slave", "re", "satellite", "agent":
+		m.Role = meshSatellite
+		m.PrimaryIP = str("master_ip", "main_ip", "gateway")
+	}
+	return m, nil
+}
+
+// 修改前检查目标是否为子路由：配置了 mesh_redirect 时改为操作主路由，否则提示。
+// 调用方持有 applyMu
+func checkMeshTarget() {
+	cfg := globalConfig{}.Get()
+	m, err := queryMesh()
+	if err != nil || !m.Satellite() {
+		return
+	}
+	if cfg.MeshRedirect && m.PrimaryIP != "" {
+		useMeshPrimary(m)
+		return
+	}
+	warn("mesh.satellite", cfg.RouterIP, m.PrimaryIP)
+}
+
+// 改为操作主路由；调用方持有 applyMu
+func useMeshPrimary(m meshInfo) {
+	cfg := globalConfig{}.Get()
+	logf("%s\n", tr("mesh.redirected", cfg.RouterIP, m.PrimaryIP))
+	recordEvent("mesh_redirect", tr("mesh.redirected", cfg.RouterIP, m.PrimaryIP), map[string]interface{}{
+		"from": cfg.RouterIP,
+		"to":   m.PrimaryIP,
+	})
+	globalConfig{}.Update(func(c *Config) { c.RouterIP = m.PrimaryIP })
+	queryCache.invalidate()
+	// 换了一台路由器，之前记住的身份不再适用
+	trackedMu.Lock()
+	tracked.Identity = nil
+	tracked.Previous = nil
+	trackedMu.Unlock()
+	saveTrackedState()
+}
+
+// POST /mesh/primary：界面上确认改为操作主路由
+func meshPrimaryHandler(w http.ResponseWriter, r *http.Request) {
+	m, err := queryMesh()
+	if err == nil && (!m.Satellite() || m.PrimaryIP == "") {
+		err = routerErr(ErrBadParameter, 0, tr("mesh.not_satellite"))
+	}
+	if err != nil {
+		renderTemplate(w, httpStatusFor(err), "error.html", map[string]interface{}{"Message": userMessage(err), "Detail": err.Error()})
+		return
+	}
+	applyMu.Lock()
+	useMeshPrimary(m)
+	applyMu.Unlock()
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Prometheus 计数器，进程内累计
+var (
+	metricsMu     sync.Mutex
+	applyCounts   = map[[2]string]int{} // [来源, success/failure] -> 次数
+	stokRefreshes = map[string]int{}    // success/failure -> 次数
+)
+
+func resultLabel(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// 记录一次修改尝试
+func countApply(source string, err error) {
+	metricsMu.Lock()
+	applyCounts[[2]string{source, resultLabel(err)}]++
+	metricsMu.Unlock()
+}
+
+// 记录一次重新登录获取stok
+func countStokRefresh(err error) {
+	metricsMu.Lock()
+	stokRefreshes[resultLabel(err)]++
+	metricsMu.Unlock()
+}
+
+// 开关类设置值转换为 1/0
+func enabledValue(s string) int {
+	switch s {
+	case "on", "1", "true":
+		return 1
+	}
+	return 0
+}
+
+func boolValue(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// 输出一个指标的 HELP 与 TYPE 行
+func writeMetricHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// /metrics：Prometheus 文本格式的运行指标
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := globalConfig{}.Get()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	metricsMu.Lock()
+	applies := make([][2]string, 0, len(applyCounts))
+	for k := range applyCounts {
+		applies = append(applies, k)
+	}
+	sort.Slice(applies, func(i, j int) bool {
+		if applies[i][0] != applies[j][0] {
+			return applies[i][0] < applies[j][0]
+		}
+		return applies[i][1] < applies[j][1]
+	})
+	writeMetricHeader(w, "tplink_apply_total", "counter", "Settings apply attempts by source and result.")
+	for _, k := range applies {
+		fmt.Fprintf(w, "tplink_apply_total{source=%q,result=%q} %d\n", k[0], k[1], applyCounts[k])
+	}
+	writeMetricHeader(w, "tplink_stok_refresh_total", "counter", "Router logins performed to obtain a new stok.")
+	for _, result := range []string{"success", "failure"} {
+		fmt.Fprintf(w, "tplink_stok_refresh_total{result=%q} %d\n", result, stokRefreshes[result])
+	}
+	metricsMu.Unlock()
+
+	timings := timingSnapshot()
+	ops := make([]string, 0, len(timings))
+	for op := range timings {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	writeMetricHeader(w, "tplink_router_request_duration_seconds", "summary", "Router request latency by operation.")
+	for _, op := range ops {
+		t := timings[op]
+		fmt.Fprintf(w, "tplink_router_request_duration_seconds_sum{op=%q} %g\n", op, t.Total.Seconds())
+		fmt.Fprintf(w, "tplink_router_request_duration_seconds_count{op=%q} %d\n", op, t.Count)
+	}
+	writeMetricHeader(w, "tplink_router_request_errors_total", "counter", "Failed router requests by operation.")
+	for _, op := range ops {
+		fmt.Fprintf(w, "tplink_router_request_errors_total{op=%q} %d\n", op, timings[op].Errors)
+	}
+
+	s, sync := trackedSnapshot()
+	if s.Confirmed != nil {
+		writeMetricHeader(w, "tplink_ipv6_firewall_enabled", "gauge", "IPv6 firewall state last read from the router (1 = on).")
+		fmt.Fprintf(w, "tplink_ipv6_firewall_enabled %d\n", enabledValue(s.Confirmed.IPv6FirewallEnable))
+		writeMetricHeader(w, "tplink_dmz_enabled", "gauge", "DMZ state last read from the router (1 = on).")
+		fmt.Fprintf(w, "tplink_dmz_enabled %d\n", enabledValue(s.Confirmed.DmzEnable))
+	}
+	if s.Desired != nil {
+		writeMetricHeader(w, "tplink_ipv6_firewall_desired", "gauge", "IPv6 firewall state last applied (1 = on).")
+		fmt.Fprintf(w, "tplink_ipv6_firewall_desired %d\n", enabledValue(s.Desired.IPv6FirewallEnable))
+	}
+	writeMetricHeader(w, "tplink_in_sync", "gauge", "Whether the router matches the desired state (1 = in sync, 0 = drifted, -1 = unknown).")
+	switch sync {
+	case syncInSync:
+		fmt.Fprintln(w, "tplink_in_sync 1")
+	case syncDrifted:
+		fmt.Fprintln(w, "tplink_in_sync 0")
+	default:
+		fmt.Fprintln(w, "tplink_in_sync -1")
+	}
+	if !s.DesiredAt.IsZero() {
+		writeMetricHeader(w, "tplink_last_successful_apply_timestamp_seconds", "gauge", "Unix time of the last successful apply.")
+		fmt.Fprintf(w, "tplink_last_successful_apply_timestamp_seconds %d\n", s.DesiredAt.Unix())
+	}
+
+	state, _ := breaker.State()
+	writeMetricHeader(w, "tplink_circuit_breaker_open", "gauge", "Whether router requests are paused after repeated failures.")
+	fmt.Fprintf(w, "tplink_circuit_breaker_open %d\n", boolValue(state != "closed"))
+	if cfg.RouterMonitor.Enabled {
+		up, _, _ := availability.Status()
+		writeMetricHeader(w, "tplink_router_up", "gauge", "Router availability from the router monitor.")
+		fmt.Fprintf(w, "tplink_router_up %d\n", boolValue(up))
+	}
+	writeMetricHeader(w, "tplink_start_time_seconds", "gauge", "Unix time the process started.")
+	fmt.Fprintf(w, "tplink_start_time_seconds %d\n", startedAt.Unix())
+	writeMetricHeader(w, "tplink_uptime_seconds", "gauge", "Seconds since the process started.")
+	fmt.Fprintf(w, "tplink_uptime_seconds %d\n", int(time.Since(startedAt).Seconds()))
+}
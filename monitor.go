@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 路由器可用性监控配置
+type MonitorConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Interval string `json:"interval"` // 探测间隔，默认 30s
+	Timeout  string `json:"timeout"`  // 单次探测超时，默认 3s
+	Failures int    `json:"failures"` // 连续失败多少次判定为离线，默认 3
+}
+
+// 在线状态跟踪，连续失败达到阈值判定离线，状态变化时回调
+type availabilityTracker struct {
+	mu        sync.Mutex
+	up        bool
+	known     bool // 是否已完成首次探测
+	failures  int
+	downSince time.Time
+	lastCheck time.Time
+	onDown    func(failures int)
+	onUp      func(downtime time.Duration)
+}
+
+// 路由器在线状态，离线/恢复时发出通知并写入历史
+var availability = &availabilityTracker{
+	onDown: func(failures int) {
+		routerIP := globalConfig{}.Get().RouterIP
+		msg := tr("notify.router_down.detail", routerIP, failures)
+		notify("router_down", tr("notify.router_down"), msg)
+		recordEvent("router_down", msg, map[string]interface{}{"router_ip": routerIP})
+		// 可能是LAN地址变了（如恢复出厂后），尝试找到同一台设备；定时重启期间的离线除外
+		if !rebootInProgress() {
+			go relocateRouter()
+		}
+	},
+	onUp: func(downtime time.Duration) {
+		routerIP := globalConfig{}.Get().RouterIP
+		msg := tr("notify.router_up.detail", routerIP, downtime)
+		notify("router_up", tr("notify.router_up"), msg)
+		recordEvent("router_up", msg, map[string]interface{}{
+			"router_ip":        routerIP,
+			"downtime_seconds": int(downtime.Seconds()),
+		})
+	},
+}
+
+// 当前状态：是否在线、是否已知、离线开始时间
+func (a *availabilityTracker) Status() (up, known bool, downSince time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.up, a.known, a.downSince
+}
+
+// 记录一次探测结果
+func (a *availabilityTracker) observe(ok bool, threshold int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.lastCheck = time.Now()
+	if ok {
+		a.failures = 0
+		if a.known && !a.up {
+			a.onUp(time.Since(a.downSince).Round(time.Second))
+		}
+		a.up, a.known = true, true
+		return
+	}
+
+	a.failures++
+	if a.failures < threshold || (a.known && !a.up) {
+		return
+	}
+	// 首次探测就失败时以第一次失败为离线开始时间
+	a.downSince = time.Now()
+	a.up, a.known = false, true
+	a.onDown(a.failures)
+}
+
+// 探测路由器管理端口是否可连接
+func probeRouter(routerIP string, timeout time.Duration) bool {
+	host, err := resolveRouterHost(routerIP)
+	if err != nil {
+		return false
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), "80")
+	}
+	conn, err := net.DialTimeout("tcp", host, timeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// 探测一次并记录结果，每次按当前配置
+func monitorOnce(timeout time.Duration) {
+	c := globalConfig{}.Get()
+	if c.RouterIP == "" {
+		return
+	}
+	threshold := c.RouterMonitor.Failures
+	if threshold < 1 {
+		threshold = 3
+	}
+	availability.observe(probeRouter(c.RouterIP, timeout), threshold)
+}
+
+// 后台持续探测路由器，stop 关闭时退出
+func runRouterMonitor(stop <-chan struct{}) {
+	c := globalConfig{}.Get()
+	interval := parseDurationOr(c.RouterMonitor.Interval, 30*time.Second)
+	timeout := parseDurationOr(c.RouterMonitor.Timeout, 3*time.Second)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		monitorOnce(timeout)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 解析时长配置，为空或格式错误时使用默认值
+func parseDurationOr(s string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
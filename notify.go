@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// 通知配置
+type NotifyConfig struct {
+	WebhookURL       string `json:"webhook_url"`        // 以JSON POST事件
+	TelegramBotToken string `json:"telegram_bot_token"` // Telegram机器人令牌
+	TelegramChatID   string `json:"telegram_chat_id"`
+
+	DedupWindow  string            `json:"dedup_window"`  // 同类事件去重窗口，默认 10m，"0s" 关闭去重
+	EventWindows map[string]string `json:"event_windows"` // 按事件类型覆盖去重窗口，如 {"router_down":"30m"}
+	MaxPerHour   int               `json:"max_per_hour"`  // 每小时最多发送的通知数，0=不限
+}
+
+// 一条通知
+type notification struct {
+	Event   string    `json:"event"` // router_down / router_up ...
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+
+// 发送通知：经过去重和限流后输出到终端，并投递到已配置的渠道。投递在后台进行，不阻塞调用方
+func notify(event, title, message string) {
+	n := notification{Event: event, Title: title, Message: redact(message), Time: time.Now()}
+	if !throttle.allow(n, deliver) {
+		sayDebug("debug.notify_suppressed", n.Event, n.Title)
+		return
+	}
+	deliver(n)
+}
+
+func deliver(n notification) {
+	cfg := globalConfig{}.Get()
+	say("console.notify", n.Title, n.Message)
+
+	go func() {
+		if cfg.Notify.WebhookURL != "" {
+			if err := sendWebhook(cfg.Notify.WebhookURL, n); err != nil {
+				warn("console.notify_failed", "webhook", err)
+			}
+		}
+		if cfg.Notify.TelegramBotToken != "" && cfg.Notify.TelegramChatID != "" {
+			if err := sendTelegram(cfg.Notify.TelegramBotToken, cfg.Notify.TelegramChatID, n); err != nil {
+				warn("console.notify_failed", "telegram", err)
+			}
+		}
+		notifyPlugins(n)
+	}()
+}
+
+func sendWebhook(webhookURL string, n notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	resp, err := notifyClient.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+	return nil
+}
+
+func sendTelegram(token, chatID string, n notification) error {
+	registerSecret(token)
+	resp, err := notifyClient.PostForm("https://api.telegram.org/bot"+token+"/sendMessage", url.Values{
+		"chat_id": {chatID},
+		"text":    {n.Title + "\n" + n.Message},
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifyThrottle(t *testing.T) {
+	setupTest(t)
+	updateConfig(func(c *Config) {
+		c.Notify.DedupWindow = "0s"
+		c.Notify.EventWindows = map[string]string{"router_down": "50ms"}
+		c.Notify.MaxPerHour = 3
+	})
+	th := &notifyThrottle{entries: map[string]*throttleEntry{}}
+	summaries := make(chan notification, 1)
+	deliver := func(n notification) { summaries <- n }
+
+	// 去重窗口内的相同事件只发送第一条，窗口结束后汇总被抑制的条数
+	for i := 0; i < 5; i++ {
+		n := notification{Event: "router_down", Title: "down", Message: "offline"}
+		if got := th.allow(n, deliver); got != (i == 0) {
+			t.Fatalf("第%d条 allow = %v", i+1, got)
+		}
+	}
+	select {
+	case s := <-summaries:
+		if !strings.Contains(s.Message, tr("notify.suppressed", 4)) {
+			t.Errorf("汇总 = %q", s.Message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("窗口结束后没有发送汇总")
+	}
+
+	// 已发送 router_down 与汇总两条，每小时上限3条
+	if !th.allow(notification{Event: "router_up"}, deliver) {
+		t.Error("未达上限的通知被抑制")
+	}
+	if th.allow(notification{Event: "wan_down"}, deliver) {
+		t.Error("超出每小时上限的通知没有被抑制")
+	}
+}
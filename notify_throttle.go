@@ -0,0 +1,107 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// 通知节流：相同事件在去重窗口内只发送一次，窗口结束时汇总被抑制的次数；
+// 另外限制每小时发送的总数，防止路由器反复上下线时刷屏
+type notifyThrottle struct {
+	mu      sync.Mutex
+	entries map[string]*throttleEntry // 事件类型 -> 状态
+	sent    []time.Time               // 最近一小时已发送的时间
+}
+
+type throttleEntry struct {
+	lastSent   time.Time
+	suppressed int
+	last       notification // 最近一次被抑制的通知，用于汇总
+	flushing   bool
+}
+
+var throttle = &notifyThrottle{entries: map[string]*throttleEntry{}}
+
+// 事件类型对应的去重窗口
+func dedupWindow(event string) time.Duration {
+	cfg := globalConfig{}.Get()
+	if w, ok := cfg.Notify.EventWindows[event]; ok {
+		return parseDurationOr(w, 0)
+	}
+	return parseDurationOr(cfg.Notify.DedupWindow, 10*time.Minute)
+}
+
+// 判断通知是否应该发送；被抑制时安排窗口结束后的汇总
+func (t *notifyThrottle) allow(n notification, deliver func(notification)) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	window := dedupWindow(n.Event)
+	e, ok := t.entries[n.Event]
+	if !ok {
+		e = &throttleEntry{}
+		t.entries[n.Event] = e
+	}
+
+	if (window > 0 && now.Sub(e.lastSent) < window) || !t.withinRate(now) {
+		e.suppressed++
+		e.last = n
+		if !e.flushing {
+			e.flushing = true
+			wait := window - now.Sub(e.lastSent)
+			if wait <= 0 {
+				wait = time.Minute
+			}
+			time.AfterFunc(wait, func() { t.flush(n.Event, deliver) })
+		}
+		return false
+	}
+
+	e.lastSent = now
+	t.sent = append(t.sent, now)
+	return true
+}
+
+// 每小时发送总数是否仍在上限内
+func (t *notifyThrottle) withinRate(now time.Time) bool {
+	limit := globalConfig{}.Get().Notify.MaxPerHour
+	if limit <= 0 {
+		return true
+	}
+	kept := t.sent[:0]
+	for _, s := range t.sent {
+		if now.Sub(s) < time.Hour {
+			kept = append(kept, s)
+		}
+	}
+	t.sent = kept
+	return len(t.sent) < limit
+}
+
+// 窗口结束后发送汇总通知
+func (t *notifyThrottle) flush(event string, deliver func(notification)) {
+	t.mu.Lock()
+	e := t.entries[event]
+	e.flushing = false
+	if e.suppressed == 0 {
+		t.mu.Unlock()
+		return
+	}
+	if !t.withinRate(time.Now()) {
+		// 仍超出总量限制，稍后再试
+		e.flushing = true
+		t.mu.Unlock()
+		time.AfterFunc(time.Minute, func() { t.flush(event, deliver) })
+		return
+	}
+	summary := e.last
+	summary.Message += "\n" + tr("notify.suppressed", e.suppressed)
+	summary.Time = time.Now()
+	e.suppressed = 0
+	e.lastSent = summary.Time
+	t.sent = append(t.sent, summary.Time)
+	t.mu.Unlock()
+
+	deliver(summary)
+}
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OpenID Connect 登录配置，issuer为空时不启用
+type OIDCConfig struct {
+	Issuer        string   `json:"issuer"` // 如 https://auth.example.com
+	ClientID      string   `json:"client_id"`
+	ClientSecret  string   `json:"client_secret"`
+	RedirectURL   string   `json:"redirect_url"`   // 留空为 http://localhost:<端口>/auth/callback
+	Scopes        []string `json:"scopes"`         // 默认 openid profile email groups
+	UsernameClaim string   `json:"username_claim"` // 默认 preferred_username
+	GroupsClaim   string   `json:"groups_claim"`   // 默认 groups
+	AdminGroups   []string `json:"admin_groups"`   // 属于这些组的用户为管理员
+	AdminUsers    []string `json:"admin_users"`    // 这些用户名为管理员
+	DefaultRole   string   `json:"default_role"`   // 其他用户的角色，留空表示拒绝登录
+	SessionTTL    string   `json:"session_ttl"`    // 登录有效期，默认 12h
+}
+
+func (c OIDCConfig) enabled() bool { return c.Issuer != "" }
+
+// 提供方的 .well-known/openid-configuration 中用到的字段
+type oidcProvider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	IntrospectionEndpoint string `json:"introspection_endpoint"`
+}
+
+type oidcSession struct {
+	user    *UserAccount
+	expires time.Time
+}
+
+type oidcPending struct {
+	nonce   string
+	next    string
+	expires time.Time
+}
+
+const oidcSessionCookie = "tplink_session"
+const oidcStateCookie = "tplink_oidc_state"
+
+var (
+	oidcClient = &http.Client{Timeout: 10 * time.Second}
+	oidcMu     sync.Mutex
+	oidcMeta   *oidcProvider
+	sessions   = map[string]oidcSession{}
+	pendings   = map[string]oidcPending{}
+	bearers    = map[string]oidcSession{} // access token 校验结果缓存
+)
+
+func randomToken() string {
+	buf := make([]byte, 24)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// 获取并缓存提供方元数据
+func oidcDiscover() (*oidcProvider, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	if oidcMeta != nil {
+		return oidcMeta, nil
+	}
+	issuer := strings.TrimSuffix(globalConfig{}.Get().OIDC.Issuer, "/")
+	resp, err := oidcClient.Get(issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := readLimited(resp.Body, maxRouterResponseBytes)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery: %s", resp.Status)
+	}
+	var meta oidcProvider
+	if err := json.Unmarshal(body, &meta); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(meta.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("%s", tr("oidc.issuer_mismatch", meta.Issuer))
+	}
+	oidcMeta = &meta
+	return oidcMeta, nil
+}
+
+func oidcRedirectURL() string {
+	cfg := globalConfig{}.Get()
+	if cfg.OIDC.RedirectURL != "" {
+		return cfg.OIDC.RedirectURL
+	}
+	return fmt.Sprintf("http://localhost:%s/auth/callback", cfg.ServerPort)
+}
+
+// 启动时补全默认值并校验配置
+func validateOIDC() error {
+	c := &config.OIDC
+	if !c.enabled() {
+		return nil
+	}
+	if c.ClientID == "" {
+		return fmt.Errorf("%s", tr("oidc.client_id_required"))
+	}
+	if c.DefaultRole != "" && c.DefaultRole != roleAdmin && c.DefaultRole != roleViewer {
+		return fmt.Errorf("%s", tr("auth.bad_role", "default_role", c.DefaultRole))
+	}
+	if len(c.Scopes) == 0 {
+		c.Scopes = []string{"openid", "profile", "email", "groups"}
+	}
+	if c.UsernameClaim == "" {
+		c.UsernameClaim = "preferred_username"
+	}
+	if c.GroupsClaim == "" {
+		c.GroupsClaim = "groups"
+	}
+	registerSecret(c.ClientSecret)
+	return nil
+}
+
+// 根据声明决定角色，返回空串表示无权访问
+func oidcUser(claims map[string]interface{}) *UserAccount {
+	c := globalConfig{}.Get().OIDC
+	name, _ := claims[c.UsernameClaim].(string)
+	if name == "" {
+		name, _ = claims["sub"].(string)
+	}
+	role := c.DefaultRole
+	for _, admin := range c.AdminUsers {
+		if admin == name {
+			role = roleAdmin
+		}
+	}
+	groups, _ := claims[c.GroupsClaim].([]interface{})
+	for _, g := range groups {
+		for _, admin := range c.AdminGroups {
+			if g == admin {
+				role = roleAdmin
+			}
+		}
+	}
+	if name == "" || role == "" {
+		return nil
+	}
+	return &UserAccount{Name: name, Role: role, SSO: true}
+}
+
+// /auth/login：跳转到身份提供方
+func oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := globalConfig{}.Get()
+	meta, err := oidcDiscover()
+	if err != nil {
+		http.Error(w, tr("oidc.unavailable", redact(err.Error())), http.StatusBadGateway)
+		return
+	}
+	state, nonce := randomToken(), randomToken()
+	next := r.URL.Query().Get("next")
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") {
+		next = "/"
+	}
+
+	oidcMu.Lock()
+	now := time.Now()
+	for k, p := range pendings {
+		if now.After(p.expires) {
+			delete(pendings, k)
+		}
+	}
+	pendings[state] = oidcPending{nonce: nonce, next: next, expires: now.Add(10 * time.Minute)}
+	oidcMu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Value: state, Path: "/auth/", HttpOnly: true, Secure: r.TLS != nil, SameSite: http.SameSiteLaxMode, MaxAge: 600})
+	q := url.Values{
+		"response_type": {"code"},
+		"client_id":     {cfg.OIDC.ClientID},
+		"redirect_uri":  {oidcRedirectURL()},
+		"scope":         {strings.Join(cfg.OIDC.Scopes, " ")},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	http.Redirect(w, r, meta.AuthorizationEndpoint+sep+q.Encode(), http.StatusFound)
+}
+
+// /auth/callback：用授权码换取ID令牌并建立会话
+func oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || cookie.Value != state || state == "" {
+		http.Error(w, tr("oidc.bad_state"), http.StatusBadRequest)
+		return
+	}
+	oidcMu.Lock()
+	pending, ok := pendings[state]
+	delete(pendings, state)
+	oidcMu.Unlock()
+	if !ok || time.Now().After(pending.expires) {
+		http.Error(w, tr("oidc.bad_state"), http.StatusBadRequest)
+		return
+	}
+	if e := r.URL.Query().Get("error"); e != "" {
+		http.Error(w, tr("oidc.denied", e), http.StatusForbidden)
+		return
+	}
+
+	claims, err := oidcExchange(r.URL.Query().Get("code"), pending.nonce)
+	if err != nil {
+		warn("console.oidc_failed", err)
+		http.Error(w, tr("oidc.failed"), http.StatusBadGateway)
+		return
+	}
+	user := oidcUser(claims)
+	if user == nil {
+		http.Error(w, tr("auth.forbidden"), http.StatusForbidden)
+		return
+	}
+
+	ttl := parseDurationOr(globalConfig{}.Get().OIDC.SessionTTL, 12*time.Hour)
+	id := randomToken()
+	oidcMu.Lock()
+	now := time.Now()
+	for k, s := range sessions {
+		if now.After(s.expires) {
+			delete(sessions, k)
+		}
+	}
+	sessions[id] = oidcSession{user: user, expires: now.Add(ttl)}
+	oidcMu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/auth/", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{Name: oidcSessionCookie, Value: id, Path: "/", HttpOnly: true, Secure: r.TLS != nil, SameSite: http.SameSiteLaxMode, MaxAge: int(ttl.Seconds())})
+	http.Redirect(w, r, pending.next, http.StatusSeeOther)
+}
+
+// 在令牌端点兑换授权码。ID令牌通过TLS直接从令牌端点取得，
+// 按OIDC规范可不校验签名，但仍检查 iss/aud/exp/nonce
+func oidcExchange(code, nonce string) (map[string]interface{}, error) {
+	cfg := globalConfig{}.Get()
+	meta, err := oidcDiscover()
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {oidcRedirectURL()},
+	}
+	req, err := http.NewRequest(http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(cfg.OIDC.ClientID), url.QueryEscape(cfg.OIDC.ClientSecret))
+	resp, err := oidcClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := readLimited(resp.Body, maxRouterResponseBytes)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token: %s %s", resp.Status, body)
+	}
+	var tok struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tok); err != nil {
+		return nil, err
+	}
+
+	claims, err := jwtClaims(tok.IDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if iss, _ := claims["iss"].(string); iss != meta.Issuer {
+		return nil, fmt.Errorf("%s", tr("oidc.id_token_issuer", iss))
+	}
+	if !audienceContains(claims["aud"], cfg.OIDC.ClientID) {
+		return nil, fmt.Errorf("%s", tr("oidc.id_token_audience"))
+	}
+	if exp, _ := claims["exp"].(float64); time.Now().After(time.Unix(int64(exp), 0)) {
+		return nil, fmt.Errorf("%s", tr("oidc.id_token_expired"))
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, fmt.Errorf("%s", tr("oidc.id_token_nonce"))
+	}
+	return claims, nil
+}
+
+// 读取JWT中的声明，不校验签名
+func jwtClaims(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%s", tr("oidc.bad_jwt"))
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, a := range v {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// /auth/logout：结束本地会话
+func oidcLogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(oidcSessionCookie); err == nil {
+		oidcMu.Lock()
+		delete(sessions, c.Value)
+		oidcMu.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcSessionCookie, Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// 从会话Cookie或 Authorization: Bearer 取得OIDC用户
+func oidcRequestUser(r *http.Request) *UserAccount {
+	if c, err := r.Cookie(oidcSessionCookie); err == nil {
+		oidcMu.Lock()
+		s, ok := sessions[c.Value]
+		oidcMu.Unlock()
+		if ok && time.Now().Before(s.expires) {
+			return s.user
+		}
+	}
+	if token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); token != r.Header.Get("Authorization") && token != "" {
+		return oidcBearerUser(token)
+	}
+	return nil
+}
+
+// API客户端使用access token时，先确认令牌由本提供方签发给本客户端，再经userinfo端点校验，
+// 结果缓存1分钟。校验通过前不登记为需脱敏的内容，避免任意请求使脱敏表无限增长
+func oidcBearerUser(token string) *UserAccount {
+	oidcMu.Lock()
+	s, ok := bearers[token]
+	oidcMu.Unlock()
+	if ok && time.Now().Before(s.expires) {
+		return s.user
+	}
+
+	meta, err := oidcDiscover()
+	if err != nil || meta.UserinfoEndpoint == "" {
+		return nil
+	}
+	if err := checkAccessToken(meta, token); err != nil {
+		sayDebug("debug.oidc_token_invalid", err)
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodGet, meta.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := oidcClient.Do(req)
+	if err != nil {
+		sayDebug("debug.oidc_userinfo_failed", err)
+		return nil
+	}
+	defer resp.Body.Close()
+	body, err := readLimited(resp.Body, maxRouterResponseBytes)
+	var claims map[string]interface{}
+	if err != nil || resp.StatusCode != http.StatusOK || json.Unmarshal(body, &claims) != nil {
+		return nil
+	}
+	user := oidcUser(claims)
+	registerSecret(token)
+
+	oidcMu.Lock()
+	now := time.Now()
+	for k, b := range bearers {
+		if now.After(b.expires) {
+			delete(bearers, k)
+		}
+	}
+	bearers[token] = oidcSession{user: user, expires: now.Add(time.Minute)}
+	oidcMu.Unlock()
+	return user
+}
+
+// 检查 access token 的签发方、受众与有效期。JWT格式直接读取其中的声明（真伪由随后的userinfo请求确认），
+// 其他格式经提供方的 introspection 端点查询；都不能确认时拒绝
+func checkAccessToken(meta *oidcProvider, token string) error {
+	claims, err := jwtClaims(token)
+	introspected := err != nil
+	if introspected {
+		if meta.IntrospectionEndpoint == "" {
+			return fmt.Errorf("%s", tr("oidc.token_unverifiable"))
+		}
+		if claims, err = introspectToken(meta, token); err != nil {
+			return err
+		}
+		if active, _ := claims["active"].(bool); !active {
+			return fmt.Errorf("%s", tr("oidc.token_inactive"))
+		}
+	}
+	// introspection 的结果可能不含 iss，此时以端点本身为准
+	if iss, ok := claims["iss"].(string); (ok || !introspected) && strings.TrimSuffix(iss, "/") != strings.TrimSuffix(meta.Issuer, "/") {
+		return fmt.Errorf("%s", tr("oidc.issuer_mismatch", iss))
+	}
+	id := globalConfig{}.Get().OIDC.ClientID
+	azp, _ := claims["azp"].(string)
+	clientID, _ := claims["client_id"].(string)
+	if !audienceContains(claims["aud"], id) && azp != id && clientID != id {
+		return fmt.Errorf("%s", tr("oidc.token_audience"))
+	}
+	if exp, ok := claims["exp"].(float64); !ok || time.Now().After(time.Unix(int64(exp), 0)) {
+		return fmt.Errorf("%s", tr("oidc.token_inactive"))
+	}
+	return nil
+}
+
+// 按 RFC 7662 查询不透明的 access token
+func introspectToken(meta *oidcProvider, token string) (map[string]interface{}, error) {
+	cfg := globalConfig{}.Get()
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequest(http.MethodPost, meta.IntrospectionEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(cfg.OIDC.ClientID), url.QueryEscape(cfg.OIDC.ClientSecret))
+	resp, err := oidcClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := readLimited(resp.Body, maxRouterResponseBytes)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspection: %s", resp.Status)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(body, &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// 只实现发现与userinfo端点的身份提供方，接受任何令牌
+func fakeIdentityProvider(t *testing.T) string {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(oidcProvider{Issuer: srv.URL, UserinfoEndpoint: srv.URL + "/userinfo"})
+		case "/userinfo":
+			fmt.Fprint(w, `{"sub":"1","preferred_username":"alice"}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	prev := config.OIDC
+	config.OIDC = OIDCConfig{Issuer: srv.URL, ClientID: "tplink", DefaultRole: roleViewer}
+	validateOIDC()
+	oidcMeta = nil
+	t.Cleanup(func() { config.OIDC, oidcMeta = prev, nil })
+	return srv.URL
+}
+
+func testJWT(claims map[string]interface{}) string {
+	payload, _ := json.Marshal(claims)
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+func TestBearerTokenChecksAudience(t *testing.T) {
+	issuer := fakeIdentityProvider(t)
+	exp := float64(time.Now().Add(time.Hour).Unix())
+	secretCount := func() int {
+		secretsMu.RLock()
+		defer secretsMu.RUnlock()
+		return len(secrets)
+	}
+
+	before := secretCount()
+	for _, token := range []string{
+		"opaque-junk-token",
+		testJWT(map[string]interface{}{"iss": issuer, "aud": "other-app", "exp": exp}),
+		testJWT(map[string]interface{}{"iss": "https://evil.example", "aud": "tplink", "exp": exp}),
+		testJWT(map[string]interface{}{"iss": issuer, "aud": "tplink", "exp": float64(time.Now().Add(-time.Hour).Unix())}),
+	} {
+		if u := oidcBearerUser(token); u != nil {
+			t.Errorf("接受了无效的令牌 %s", token)
+		}
+	}
+	if n := secretCount(); n != before {
+		t.Errorf("未通过校验的令牌被登记为敏感值（%d -> %d）", before, n)
+	}
+
+	good := testJWT(map[string]interface{}{"iss": issuer, "aud": []interface{}{"api", "tplink"}, "exp": exp})
+	if u := oidcBearerUser(good); u == nil || u.Name != "alice" {
+		t.Fatalf("有效令牌的用户 = %+v", u)
+	}
+	if redact(good) != redactedMark {
+		t.Error("通过校验的令牌没有脱敏")
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// 由Go类型生成JSON Schema，请求与响应的结构改动后文档自动同步
+func schemaFor(t reflect.Type) map[string]interface{} {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaFor(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" || !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = schemaFor(f.Type)
+		}
+		return map[string]interface{}{"type": "object", "properties": props}
+	}
+	return map[string]interface{}{}
+}
+
+// JSON请求体或响应体
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// /api/v1 的响应，data 为指定结构
+func v1Responses(dataSchema string) map[string]interface{} {
+	ok := schemaRef("Response")
+	if dataSchema != "" {
+		ok = map[string]interface{}{"allOf": []interface{}{
+			schemaRef("Response"),
+			map[string]interface{}{"properties": map[string]interface{}{"data": schemaRef(dataSchema)}},
+		}}
+	}
+	return map[string]interface{}{
+		"200":     map[string]interface{}{"description": "OK", "content": jsonContent(ok)},
+		"default": map[string]interface{}{"description": tr("openapi.error"), "content": jsonContent(schemaRef("Response"))},
+	}
+}
+
+func operation(summary string, responses map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"summary": summary, "responses": responses}
+}
+
+func withBody(op map[string]interface{}, schema string) map[string]interface{} {
+	op["requestBody"] = map[string]interface{}{"required": true, "content": jsonContent(schemaRef(schema))}
+	return op
+}
+
+// OpenAPI 3 文档
+func openAPIDocument() map[string]interface{} {
+	plain := func(desc, contentType string) map[string]interface{} {
+		return map[string]interface{}{"200": map[string]interface{}{
+			"description": desc,
+			"content":     map[string]interface{}{contentType: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}},
+		}}
+	}
+	health := map[string]interface{}{
+		"200": map[string]interface{}{"description": "OK", "content": jsonContent(map[string]interface{}{"type": "object"})},
+		"503": map[string]interface{}{"description": tr("openapi.not_ready"), "content": jsonContent(map[string]interface{}{"type": "object"})},
+	}
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       tr("title"),
+			"version":     "1",
+			"description": tr("openapi.description"),
+		},
+		"paths": map[string]interface{}{
+			"/api/v1/status": map[string]interface{}{
+				"get": operation(tr("openapi.status"), v1Responses("Status")),
+			},
+			"/api/v1/apply": map[string]interface{}{
+				"post": withBody(operation(tr("openapi.apply"), v1Responses("")), "ApplyRequest"),
+			},
+			"/api/v1/config": map[string]interface{}{
+				"get": operation(tr("openapi.config_get"), v1Responses("Config")),
+				"put": withBody(operation(tr("openapi.config_put"), v1Responses("Config")), "ConfigUpdate"),
+			},
+			"/healthz": map[string]interface{}{
+				"get": operation(tr("openapi.healthz"), health),
+			},
+			"/readyz": map[string]interface{}{
+				"get": operation(tr("openapi.readyz"), health),
+			},
+			"/metrics": map[string]interface{}{
+				"get": operation(tr("openapi.metrics"), plain("Prometheus", "text/plain")),
+			},
+		},
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"Response":      schemaFor(reflect.TypeOf(apiV1Response{})),
+				"Status":        schemaFor(reflect.TypeOf(apiV1Status{})),
+				"ApplyRequest":  schemaFor(reflect.TypeOf(apiV1ApplyRequest{})),
+				"Config":        schemaFor(reflect.TypeOf(apiV1Config{})),
+				"ConfigUpdate":  schemaFor(reflect.TypeOf(apiV1ConfigUpdate{})),
+				"FirewallState": schemaFor(reflect.TypeOf(firewallState{})),
+			},
+		},
+	}
+	if authEnabled() {
+		doc["components"].(map[string]interface{})["securitySchemes"] = map[string]interface{}{
+			"basicAuth": map[string]interface{}{"type": "http", "scheme": "basic"},
+		}
+		doc["security"] = []interface{}{map[string]interface{}{"basicAuth": []interface{}{}}}
+	}
+	return doc
+}
+
+// /api/openapi.json：接口描述
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(openAPIDocument())
+}
+
+// /api/docs：根据接口描述列出各接口并可直接试用
+func apiDocsHandler(w http.ResponseWriter, r *http.Request) {
+	renderTemplate(w, http.StatusOK, "apidocs.html", nil)
+}
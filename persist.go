@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"tplinkfirewalloff/internal/configstore"
+)
+
+// 读取配置的位置，手动修改成功后写回；从标准输入读取或没有配置文件时为 nil
+var configStore configstore.Store
+
+// 写回配置文件的字段；密码、stok 等凭据不写回
+func persistedValues(c Config) []struct{ key, value string } {
+	return []struct{ key, value string }{
+		{"router_ip", c.RouterIP},
+		{"ipv6_firewall_enable", c.IPv6FirewallEnable},
+		{"dmz_enable", c.DmzEnable},
+		{"dmz_dest_ip", c.DmzDestIP},
+		{"dmz_dest_ip6", c.DmzDestIP6},
+		{"wan_port", c.WANPort},
+	}
+}
+
+// 手动修改成功后把路由器地址与期望设置写回配置文件，下次启动沿用上次生效的设置。
+// 只改上面的字段，其余字段、未知字段和字段顺序保持不变；
+// 由环境变量给出的字段不写回，操作 routers 中的其他路由器时也不写回
+func persistConfig(source string) {
+	if configStore == nil || !manualSource(source) || activeRouter() != "" {
+		return
+	}
+	var doc jsonObject
+	if err := configStore.Load(&doc); err != nil {
+		warn("console.config_save_failed", err)
+		return
+	}
+	changed := false
+	defaults := persistedValues(defaultConfig())
+	for i, f := range persistedValues(config) {
+		if envOverridden(f.key) || flagOverridden(f.key) {
+			continue
+		}
+		var old string
+		raw, ok := doc.get(f.key)
+		if ok && json.Unmarshal(raw, &old) != nil {
+			// YAML、TOML中未加引号的数字，如 dmz_enable: 1
+			old = string(raw)
+		}
+		// 文件中没有的字段仍为默认值时不必写入
+		if ok && old == f.value || !ok && f.value == defaults[i].value {
+			continue
+		}
+		value, _ := json.Marshal(f.value)
+		doc.set(f.key, value)
+		changed = true
+	}
+	if !changed {
+		return
+	}
+	if err := configStore.Save(doc); err != nil {
+		warn("console.config_save_failed", err)
+		return
+	}
+	sayDebug("debug.config_saved")
+}
+
+// 保持字段顺序的JSON对象，写回后用户手写的配置文件只有改动的值不同
+type jsonObject struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+func (o *jsonObject) get(key string) (json.RawMessage, bool) {
+	v, ok := o.values[key]
+	return v, ok
+}
+
+func (o *jsonObject) set(key string, value json.RawMessage) {
+	if o.values == nil {
+		o.values = map[string]json.RawMessage{}
+	}
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *jsonObject) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return errors.New(tr("persist.not_object"))
+	}
+	*o = jsonObject{}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+		o.set(t.(string), value)
+	}
+	return nil
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(o.values[key])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"tplinkfirewalloff/internal/configstore"
+)
+
+func TestApplyPersistsSettings(t *testing.T) {
+	setupTest(t)
+	useFakeBackend(t, &fakeRouter{})
+	store := configStore.(*configstore.Memory)
+	config.Stok = "new-stok"
+	config.IPv6FirewallEnable, config.DmzEnable, config.DmzDestIP = "off", "1", "192.168.0.9"
+
+	if _, err := applyChanges(sourceScheduler, "", allSections); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(store.Data), "192.168.0.9") {
+		t.Errorf("自动修改写回了配置文件: %s", store.Data)
+	}
+
+	config.DmzDestIP = "192.168.0.10"
+	if _, err := applyChanges(sourceCtl, "", allSections); err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]interface{}
+	if err := json.Unmarshal(store.Data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved["dmz_dest_ip"] != "192.168.0.10" || saved["ipv6_firewall_enable"] != "off" {
+		t.Errorf("没有写回设置: %s", store.Data)
+	}
+	if saved["stok"] != "test-stok" || saved["rate_limit"] != 0.0 {
+		t.Errorf("改动了其他字段: %s", store.Data)
+	}
+	if !strings.HasPrefix(string(store.Data), `{"history_file"`) {
+		t.Errorf("字段顺序改变: %s", store.Data)
+	}
+}
+
+func TestPersistSkipsEnvOverrides(t *testing.T) {
+	setupTest(t)
+	useFakeBackend(t, &fakeRouter{})
+	store := configStore.(*configstore.Memory)
+	t.Setenv("TPLINK_DMZ_DEST_IP", "192.168.0.20")
+	t.Setenv("SERVER_PORT", "9555")
+	config.DmzDestIP = "192.168.0.20"
+	config.ServerPort = "9555"
+	config.IPv6FirewallEnable = "off"
+
+	if _, err := applyChanges(sourceCtl, "", allSections); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(store.Data), "192.168.0.20") || strings.Contains(string(store.Data), "9555") {
+		t.Errorf("写回了环境变量给出的值: %s", store.Data)
+	}
+	if !strings.Contains(string(store.Data), `"ipv6_firewall_enable":"off"`) {
+		t.Errorf("没有写回设置: %s", store.Data)
+	}
+}
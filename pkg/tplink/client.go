@@ -0,0 +1,230 @@
+// Package tplink 是TP-LINK家用路由器网页管理接口（登录得到stok，再向
+// /stok=<stok>/ds 发送JSON请求）的客户端，可供其他程序直接引用：
+//
+//	c := tplink.NewClient("http://192.168.1.1")
+//	if err := c.Login(ctx, "管理员密码"); err != nil {
+//		return err
+//	}
+//	fw, err := c.GetFirewall(ctx)
+//
+// 路由器以HTTP 200返回非0的 error_code 时，错误为 *Error；HTTP状态码异常时为 *StatusError。
+package tplink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// 路由器响应的大小上限，正常响应只有几百字节
+const MaxResponseBytes = 1 << 20
+
+// 浏览器风格的 User-Agent，部分固件拒绝非浏览器发出的请求
+const BrowserUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+
+// 路由器客户端，零值以外的字段在创建后可直接修改；同一 Client 不应并发登录
+type Client struct {
+	BaseURL    string            // 路由器地址，如 http://192.168.1.1
+	LoginPath  string            // 登录请求的路径，默认为 /，部分衍生固件不同
+	Stok       string            // 会话令牌，Login 成功后填入，也可直接使用已知的stok
+	HTTPClient *http.Client      // 为 nil 时使用 http.DefaultClient
+	Header     map[string]string // 每个请求附带的请求头
+
+	// 每次请求完成后调用，可用于记录原始请求与响应；err 为请求失败的原因
+	Trace func(url string, request []byte, status int, response []byte, err error)
+
+	// 替代HTTP发送 /ds 请求，返回原始响应；为 nil 时按 BaseURL 与 Stok 发送。
+	// 可用于经过限流、自动重新登录，或转换为其他固件的请求格式
+	Send func(ctx context.Context, request interface{}) ([]byte, error)
+}
+
+// 创建客户端，默认附带管理页面的 Referer 与浏览器的 User-Agent
+func NewClient(baseURL string) *Client {
+	baseURL = strings.TrimRight(baseURL, "/")
+	return &Client{
+		BaseURL: baseURL,
+		Header: map[string]string{
+			"Referer":    baseURL + "/",
+			"User-Agent": BrowserUserAgent,
+		},
+	}
+}
+
+// 路由器的JSON响应，按顶层键（模块名、error_code 等）保留原始内容
+type Response map[string]json.RawMessage
+
+// 响应中的 error_code，没有时为0
+func (r Response) Code() int {
+	var code int
+	json.Unmarshal(r["error_code"], &code)
+	return code
+}
+
+// 解析响应中的一个模块，如 r.Module("firewall", &v)
+func (r Response) Module(name string, v interface{}) error {
+	raw, ok := r[name]
+	if !ok {
+		return fmt.Errorf("%w: missing %s", ErrBadResponse, name)
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// 解析原始响应并检查 error_code
+func Decode(raw []byte) (Response, error) {
+	var resp Response
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBadResponse, raw)
+	}
+	if code := resp.Code(); code != CodeOK {
+		return nil, &Error{Code: code}
+	}
+	return resp, nil
+}
+
+// 登录请求的密码编码（登录页脚本中的 securityEncode），固定密钥与字典取自路由器登录页
+const (
+	encodeKey  = "RDpbLfCPsJZ7fiv"
+	encodeDict = "yLwVl0zKqws7LgKPRQ84Mdt708T1qQ3Ha7xv3H7NyU84p21BriUWBU43odz3iP4rBL3cD02KZciXTysVXiV8ngg6vL48rPJyAUw0HurW20xqxv9aYb4M9wK1Ae0wlro510qXeU07kV57fQMc8L6aLgMLwygtc0F10a0Dg70TOoouyFhdysuRMO51yY5ZlOZZLEal1h0t9YQW0Ko7oBwmCAHoic4HYbUyVeU3sfQ1xtXcPcf1aT303wAQhv66qzW"
+)
+
+// 按位异或密码与密钥，较短的一方以 0xBB 补齐，再映射到字典字符
+func EncodePassword(password string) string {
+	n := len(password)
+	if len(encodeKey) > n {
+		n = len(encodeKey)
+	}
+	out := make([]byte, n)
+	for i := 0; i < n; i++ {
+		cl, cr := byte(0xBB), byte(0xBB)
+		if i < len(password) {
+			cl = password[i]
+		}
+		if i < len(encodeKey) {
+			cr = encodeKey[i]
+		}
+		out[i] = encodeDict[int(cl^cr)%len(encodeDict)]
+	}
+	return string(out)
+}
+
+// 用管理员密码登录，成功后 c.Stok 为新的会话令牌
+func (c *Client) Login(ctx context.Context, password string) error {
+	path := c.LoginPath
+	if path == "" {
+		path = "/"
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"method": "do",
+		"login":  map[string]interface{}{"password": EncodePassword(password)},
+	})
+	raw, err := c.Post(ctx, path, body)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Stok      string `json:"stok"`
+		ErrorCode int    `json:"error_code"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return fmt.Errorf("%w: cannot parse login response", ErrBadResponse)
+	}
+	if result.ErrorCode != CodeOK {
+		return &Error{Code: result.ErrorCode}
+	}
+	if result.Stok == "" {
+		return fmt.Errorf("%w: no stok in login response", ErrBadResponse)
+	}
+	c.Stok = result.Stok
+	return nil
+}
+
+// 向 /stok=<stok>/ds 发送请求，返回原始响应，不检查 error_code；
+// request 为可编码为JSON的值，如 map[string]interface{}
+func (c *Client) DS(ctx context.Context, request interface{}) ([]byte, error) {
+	if c.Send != nil {
+		return c.Send(ctx, request)
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	return c.Post(ctx, "/stok="+c.Stok+"/ds", body)
+}
+
+// 发送请求并解析响应，error_code 非0时返回 *Error
+func (c *Client) Call(ctx context.Context, request interface{}) (Response, error) {
+	raw, err := c.DS(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(raw)
+}
+
+// 向路由器的 path 发送JSON请求体，返回响应内容；
+// 连接失败时为 http.Client 返回的 *url.Error，HTTP状态码不是200时为 *StatusError
+func (c *Client) Post(ctx context.Context, path string, body []byte) ([]byte, error) {
+	url := c.BaseURL + path
+	raw, status, err := c.post(ctx, url, body)
+	if c.Trace != nil {
+		c.Trace(url, body, status, raw, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, &StatusError{StatusCode: status, Body: raw}
+	}
+	return raw, nil
+}
+
+func (c *Client) post(ctx context.Context, url string, body []byte) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	for k, v := range c.Header {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !jsonContentType(ct) {
+		return nil, resp.StatusCode, &ContentTypeError{ContentType: ct}
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, MaxResponseBytes+1))
+	if err != nil {
+		return nil, resp.StatusCode, &ReadError{Err: err}
+	}
+	if len(raw) > MaxResponseBytes {
+		return nil, resp.StatusCode, &ReadError{Err: fmt.Errorf("response exceeds %d bytes", MaxResponseBytes)}
+	}
+	return raw, resp.StatusCode, nil
+}
+
+// 路由器响应应为JSON；部分固件标为text/plain或text/html，也一并接受
+func jsonContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(mediaType, "json") || strings.HasPrefix(mediaType, "text/")
+}
+
+// 响应无法解析或缺少必需的字段
+var ErrBadResponse = errors.New("tplink: cannot parse router response")
@@ -0,0 +1,176 @@
+package tplink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 模拟路由器：密码为 admin，stok 为 abc
+func newRouter(t *testing.T) *httptest.Server {
+	t.Helper()
+	state := map[string]interface{}{
+		"ipv6_firewall": map[string]interface{}{"enable": "on"},
+		"dmz":           map[string]interface{}{"enable": "0", "dest_ip": "", "dest_ip6": "", "wan_port": "0"},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]json.RawMessage
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &req)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		switch r.URL.Path {
+		case "/":
+			var login struct {
+				Password string `json:"password"`
+			}
+			json.Unmarshal(req["login"], &login)
+			if login.Password != EncodePassword("admin") {
+				w.Write([]byte(`{"error_code":-40321}`))
+				return
+			}
+			w.Write([]byte(`{"stok":"abc","error_code":0}`))
+		case "/stok=abc/ds":
+			var method string
+			json.Unmarshal(req["method"], &method)
+			if method == "set" {
+				var set struct {
+					Firewall map[string]map[string]interface{} `json:"firewall"`
+				}
+				json.Unmarshal(body, &set)
+				for section, fields := range set.Firewall {
+					state[section] = fields
+				}
+				w.Write([]byte(`{"error_code":0}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"firewall": state, "error_code": 0})
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestEncodePassword(t *testing.T) {
+	// 固定的编码结果，防止改动后与路由器不再一致
+	if got := EncodePassword("admin"); got != "WaQ7xbhc9TefbwK" {
+		t.Errorf("EncodePassword(admin) = %q", got)
+	}
+	if got := EncodePassword(""); len(got) != len(encodeKey) {
+		t.Errorf("空密码编码长度 %d", len(got))
+	}
+}
+
+func TestLoginAndFirewall(t *testing.T) {
+	srv := newRouter(t)
+	ctx := context.Background()
+	c := NewClient(srv.URL + "/")
+	var traced int
+	c.Trace = func(string, []byte, int, []byte, error) { traced++ }
+
+	if err := c.Login(ctx, "admin"); err != nil || c.Stok != "abc" {
+		t.Fatalf("Login = %v, stok %q", err, c.Stok)
+	}
+	fw, err := c.GetFirewall(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fw.IPv6Firewall == nil || fw.IPv6Firewall.Enable != "on" || fw.DMZ == nil || fw.DMZ.Enable != "0" {
+		t.Fatalf("GetFirewall = %+v", fw)
+	}
+	err = c.SetFirewall(ctx, Firewall{DMZ: &DMZ{Enable: "1", DestIP: "192.168.1.8", WANPort: "0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw, _ = c.GetFirewall(ctx)
+	if fw.DMZ.DestIP != "192.168.1.8" || fw.IPv6Firewall.Enable != "on" {
+		t.Errorf("只写DMZ后 = %+v %+v", fw.DMZ, fw.IPv6Firewall)
+	}
+	if traced != 4 {
+		t.Errorf("Trace 调用 %d 次，应为4次", traced)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var sent []interface{}
+	c := &Client{Send: func(ctx context.Context, request interface{}) ([]byte, error) {
+		sent = append(sent, request)
+		return []byte(`{"error_code":0,"firewall":{"ipv6_firewall":{"enable":"off"}}}`), nil
+	}}
+	fw, err := c.GetFirewall(context.Background())
+	if err != nil || fw.IPv6Firewall == nil || fw.IPv6Firewall.Enable != "off" {
+		t.Fatalf("GetFirewall = %+v, %v", fw, err)
+	}
+	if err := c.SetFirewall(context.Background(), Firewall{IPv6Firewall: &IPv6Firewall{Enable: "on"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 {
+		t.Fatalf("Send 调用 %d 次，应为2次", len(sent))
+	}
+	set, _ := json.Marshal(sent[1])
+	if string(set) != `{"firewall":{"ipv6_firewall":{"enable":"on"}},"method":"set"}` {
+		t.Errorf("set 请求 = %s", set)
+	}
+}
+
+func TestLoginBadPassword(t *testing.T) {
+	c := NewClient(newRouter(t).URL)
+	err := c.Login(context.Background(), "wrong")
+	var e *Error
+	if !errors.As(err, &e) || e.Code != CodeBadCredentials {
+		t.Fatalf("Login = %v", err)
+	}
+	if c.Stok != "" {
+		t.Errorf("登录失败后 stok = %q", c.Stok)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	c := NewClient(newRouter(t).URL)
+	c.Stok = "expired"
+	_, err := c.Call(context.Background(), map[string]interface{}{"method": "get"})
+	var e *StatusError
+	if !errors.As(err, &e) || e.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Call = %v", err)
+	}
+}
+
+func TestContentTypeError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte{0x89})
+	}))
+	defer srv.Close()
+	_, err := NewClient(srv.URL).DS(context.Background(), map[string]interface{}{})
+	var e *ContentTypeError
+	if !errors.As(err, &e) {
+		t.Fatalf("DS = %v", err)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	resp, err := Decode([]byte(`{"error_code":0,"firewall":{"dmz":{"enable":"1"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fw Firewall
+	if err := resp.Module("firewall", &fw); err != nil || fw.DMZ.Enable != "1" {
+		t.Errorf("Module = %+v, %v", fw, err)
+	}
+	if err := resp.Module("network", &fw); !errors.Is(err, ErrBadResponse) {
+		t.Errorf("缺少模块时 = %v", err)
+	}
+
+	var e *Error
+	if _, err := Decode([]byte(`{"error_code":-40401}`)); !errors.As(err, &e) || !e.AuthExpired() {
+		t.Errorf("Decode(-40401) = %v", err)
+	}
+	if _, err := Decode([]byte(`<html>`)); !errors.Is(err, ErrBadResponse) {
+		t.Errorf("Decode(html) = %v", err)
+	}
+}
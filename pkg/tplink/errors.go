@@ -0,0 +1,66 @@
+package tplink
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// 路由器常见error_code
+const (
+	CodeOK             = 0
+	CodeSystem         = -40101 // 系统错误，多为路由器忙（保存配置、升级或重启中）
+	CodeTableFull      = -40105 // 条目数已达上限
+	CodeBadFormat      = -40106 // 请求格式错误
+	CodeInvalidParam   = -40209 // 参数错误
+	CodeUnsupported    = -40210 // 不支持的模块、字段或方法
+	CodeBadCredentials = -40321 // 登录密码错误
+	CodeLoginLocked    = -40325 // 密码错误次数过多，登录被暂时锁定
+	CodeUnauthorized   = -40401 // stok无效或已过期
+	CodeSessionExpired = -40404 // 会话超时，需要重新登录
+)
+
+// 路由器以HTTP 200返回的非0 error_code
+type Error struct {
+	Code int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("tplink: error_code=%d", e.Code)
+}
+
+// 是否需要重新登录
+func (e *Error) AuthExpired() bool {
+	return e.Code == CodeUnauthorized || e.Code == CodeSessionExpired
+}
+
+// HTTP状态码不是200；401/403 多为stok失效，404 多为固件没有该接口
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("tplink: HTTP %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// 响应不是JSON，常见于地址指向了其他设备或登录页面
+type ContentTypeError struct {
+	ContentType string
+}
+
+func (e *ContentTypeError) Error() string {
+	return "tplink: unexpected content type: " + e.ContentType
+}
+
+// 读取响应失败或响应过大
+type ReadError struct {
+	Err error
+}
+
+func (e *ReadError) Error() string {
+	return "tplink: reading response: " + e.Err.Error()
+}
+
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,50 @@
+package tplink
+
+import "context"
+
+// firewall 模块中的IPv6防火墙
+type IPv6Firewall struct {
+	Enable string `json:"enable"` // on / off
+}
+
+// firewall 模块中的DMZ
+type DMZ struct {
+	Enable  string `json:"enable"`             // 1 开启，0 关闭
+	DestIP  string `json:"dest_ip"`            // IPv4 DMZ主机
+	DestIP6 string `json:"dest_ip6"`           // IPv6 DMZ主机
+	WANPort string `json:"wan_port,omitempty"` // 作用的WAN口，0 为全部
+}
+
+// IPv6防火墙与DMZ设置；写入时为 nil 的部分不发送，路由器上保持不变
+type Firewall struct {
+	IPv6Firewall *IPv6Firewall `json:"ipv6_firewall,omitempty"`
+	DMZ          *DMZ          `json:"dmz,omitempty"`
+}
+
+// 读取IPv6防火墙与DMZ设置
+func (c *Client) GetFirewall(ctx context.Context) (Firewall, error) {
+	var fw Firewall
+	resp, err := c.Call(ctx, map[string]interface{}{
+		"method":   "get",
+		"firewall": map[string]interface{}{"name": []string{"dmz", "ipv6_firewall"}},
+	})
+	if err != nil {
+		return fw, err
+	}
+	err = resp.Module("firewall", &fw)
+	return fw, err
+}
+
+// 写入IPv6防火墙与DMZ设置
+func (c *Client) SetFirewall(ctx context.Context, fw Firewall) error {
+	_, err := c.Call(ctx, SetFirewallRequest(fw))
+	return err
+}
+
+// SetFirewall 发送的请求体，可用于预览或自行发送
+func SetFirewallRequest(fw Firewall) map[string]interface{} {
+	return map[string]interface{}{
+		"method":   "set",
+		"firewall": fw,
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"tplinkfirewalloff/pkg/tplink"
+)
+
+// 插件是 plugin_dir 中的独立可执行文件，不需要修改本程序即可支持特殊设备和集成。
+// 协议：以 "<插件> describe" 启动时输出 {"name":"...","kinds":["backend","notifier","hook"]}；
+// 调用时以 "<插件> <kind>" 启动，请求JSON写入标准输入，结果从标准输出读取，退出码非0表示失败。
+// 插件在自己的目录中运行，只能拿到少数系统环境变量，拿不到本程序的设置、密码和stok；
+// 超过 pluginTimeout 即被结束，输出超过 maxPluginOutput 视为失败。
+//
+// 没有采用WASM或内嵌解释器：本程序只依赖标准库，二者都要引入较大的第三方运行时。
+// 独立进程同样与本程序隔离（内存、环境变量、工作目录与运行时间），插件也可以用任何语言编写
+const (
+	pluginBackend  = "backend"  // 代替HTTP接口与路由器通信，需将 firmware_type 设为 "plugin:<名称>"
+	pluginNotifier = "notifier" // 额外的通知渠道
+	pluginHook     = "hook"     // 与 hooks 相同的应用前后钩子
+)
+
+var pluginTimeout = 30 * time.Second
+
+// 插件标准输出的大小上限，与路由器响应相同
+const maxPluginOutput = tplink.MaxResponseBytes
+
+// 超过上限时写入失败的输出缓冲
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.max {
+		return 0, fmt.Errorf("output exceeds %d bytes", b.max)
+	}
+	return b.Buffer.Write(p)
+}
+
+type plugin struct {
+	Name  string   `json:"name"`
+	Kinds []string `json:"kinds"`
+	Path  string   `json:"path"`
+}
+
+func (p plugin) provides(kind string) bool {
+	for _, k := range p.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// 启动时加载的插件
+var plugins []plugin
+
+// 提供某种功能的插件
+func pluginsOf(kind string) []plugin {
+	var out []plugin
+	for _, p := range plugins {
+		if p.provides(kind) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// 判断目录中的文件是否可执行
+func isExecutable(path string, info os.FileInfo) bool {
+	if info.IsDir() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".exe", ".bat", ".cmd":
+			return true
+		}
+		return false
+	}
+	return info.Mode()&0111 != 0
+}
+
+// 加载 plugin_dir 中的全部插件，单个插件加载失败只输出提示
+func loadPlugins() error {
+	if config.PluginDir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(config.PluginDir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		path := filepath.Join(config.PluginDir, e.Name())
+		info, err := e.Info()
+		if err != nil || !isExecutable(path, info) {
+			continue
+		}
+		out, err := runPlugin(path, "describe", nil)
+		if err != nil {
+			warn("console.plugin_failed", e.Name(), err)
+			continue
+		}
+		var p plugin
+		if err := json.Unmarshal(out, &p); err != nil || len(p.Kinds) == 0 {
+			warn("console.plugin_failed", e.Name(), tr("plugin.bad_describe"))
+			continue
+		}
+		if p.Name == "" {
+			p.Name = e.Name()
+		}
+		p.Path = path
+		plugins = append(plugins, p)
+		say("console.plugin_loaded", p.Name, strings.Join(p.Kinds, ", "))
+		if p.provides(pluginBackend) {
+			registerPluginBackend(p)
+		}
+	}
+	return nil
+}
+
+// 后端插件按 firmware_type "plugin:<名称>" 登记，只有配置选中它时才会使用
+const pluginFirmwarePrefix = "plugin:"
+
+func registerPluginBackend(p plugin) {
+	name := pluginFirmwarePrefix + p.Name
+	if _, taken := routerBackends[name]; taken {
+		warn("console.plugin_backend_taken", p.Name, name)
+		return
+	}
+	routerBackends[name] = dsClient{
+		// 插件自行登录路由器，本程序不会把密码或stok交给插件
+		login: func(host, password string) (string, error) { return "", nil },
+		post: func(host, _ string, requestBody map[string]interface{}) ([]byte, error) {
+			return postPluginBackend(p, host, requestBody)
+		},
+		caps: fullCapabilities,
+	}
+	say("console.plugin_backend", p.Name, name)
+}
+
+// 传给插件的环境变量：只保留查找程序、临时目录和语言所需的系统变量，
+// TPLINK_* 设置等不会传给插件
+var pluginEnvKeys = []string{"PATH", "HOME", "TMPDIR", "TEMP", "TMP", "LANG", "TZ", "SYSTEMROOT", "WINDIR", "COMSPEC", "PATHEXT"}
+
+func pluginEnv() []string {
+	var env []string
+	for _, key := range pluginEnvKeys {
+		if v, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+v)
+		}
+	}
+	return env
+}
+
+// 执行插件并返回标准输出
+func runPlugin(path, kind string, input interface{}) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pluginTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, kind)
+	cmd.Dir = filepath.Dir(path)
+	cmd.Env = pluginEnv()
+	if input != nil {
+		stdin, err := json.Marshal(input)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	stdout, stderr := &limitedBuffer{max: maxPluginOutput}, &limitedBuffer{max: maxPluginOutput}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	// 超时结束插件后，其子进程可能仍占用输出管道，最多再等一秒
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+	sayDebug("debug.plugin_timing", filepath.Base(path), kind, time.Since(start).Round(time.Millisecond), stderr.Bytes())
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("%s", tr("exec.timeout", pluginTimeout))
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// 传给后端插件的请求，不含stok
+type backendRequest struct {
+	RouterIP string                 `json:"router_ip"`
+	Request  map[string]interface{} `json:"request"` // 与 /ds 接口相同的请求体
+}
+
+// 选中后端插件时由插件代为请求路由器，插件输出与 /ds 接口相同格式的响应
+func postPluginBackend(p plugin, host string, requestBody map[string]interface{}) ([]byte, error) {
+	out, err := runPlugin(p.Path, pluginBackend, backendRequest{RouterIP: host, Request: requestBody})
+	recordExchange("plugin:"+p.Name, mustJSON(requestBody), 0, out, err)
+	if err != nil {
+		return nil, routerErr(ErrUnreachable, 0, p.Name+": "+err.Error())
+	}
+	sayDebug("debug.plugin_response", p.Name, out)
+	return out, nil
+}
+
+func mustJSON(v interface{}) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+// 通过通知插件投递
+func notifyPlugins(n notification) {
+	for _, p := range pluginsOf(pluginNotifier) {
+		if _, err := runPlugin(p.Path, pluginNotifier, n); err != nil {
+			warn("console.notify_failed", p.Name, err)
+		}
+	}
+}
+
+// 依次执行钩子插件，任一失败即返回
+func runPluginHooks(payload hookPayload) error {
+	for _, p := range pluginsOf(pluginHook) {
+		if _, err := runPlugin(p.Path, pluginHook, payload); err != nil {
+			return fmt.Errorf("%s", tr("plugin.failed", payload.Phase, p.Name, err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"tplinkfirewalloff/pkg/tplink"
+)
+
+// WAN口编号为非负整数，单WAN机型为 "0"
+func validWANPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n >= 0
+}
+
+// 要写入的防火墙与DMZ设置，只包含 sections 中选中的部分；wanPort 为DMZ映射的WAN口
+func firewallFor(fs firewallState, sections applySections, wanPort string) tplink.Firewall {
+	if wanPort == "" {
+		wanPort = "0"
+	}
+	var fw tplink.Firewall
+	if sections.DMZ {
+		fw.DMZ = &tplink.DMZ{Enable: fs.DmzEnable, DestIP: fs.DmzDestIP, DestIP6: fs.DmzDestIP6, WANPort: wanPort}
+	}
+	if sections.Firewall {
+		fw.IPv6Firewall = &tplink.IPv6Firewall{Enable: fs.IPv6FirewallEnable}
+	}
+	return fw
+}
+
+// 将要发送的请求地址与请求体；地址中的stok已脱敏
+func previewRequest(routerIP, stok string, fs firewallState, sections applySections, wanPort string) (string, string) {
+	body, _ := json.MarshalIndent(tplink.SetFirewallRequest(firewallFor(fs, sections, wanPort)), "", "  ")
+	if stok == "" {
+		stok = "<stok>"
+	}
+	url := redact(routerURL(routerIP, "/stok="+stok+"/ds"))
+	return url, string(body)
+}
+
+// 界面上的“预览”：显示请求内容，不发送
+func renderPreview(w http.ResponseWriter, routerIP, stok string, fs firewallState, sections applySections, wanPort string) {
+	url, body := previewRequest(routerIP, stok, fs, sections, wanPort)
+	renderTemplate(w, http.StatusOK, "preview.html", map[string]interface{}{"URL": url, "Body": body})
+}
+
+// -dry-run：在命令行输出将要发送的请求
+func printPreview(routerIP, stok string, fs firewallState, sections applySections) {
+	url, body := previewRequest(routerIP, stok, fs, sections, globalConfig{}.Get().WANPort)
+	fmt.Println("POST " + url)
+	fmt.Println(body)
+}
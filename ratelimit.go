@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// 令牌桶限流器，保护路由器性能有限的管理CPU
+type tokenBucket struct {
+	mu      sync.Mutex
+	rate    float64 // 每秒补充的令牌数
+	burst   float64 // 桶容量
+	tokens  float64
+	last    time.Time
+	maxWait time.Duration // 排队等待的最长时间，超过则直接拒绝
+	waiting int           // 正在排队的请求数
+}
+
+func newTokenBucket(rate float64, burst int, maxWait time.Duration) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:    rate,
+		burst:   float64(burst),
+		tokens:  float64(burst),
+		last:    time.Now(),
+		maxWait: maxWait,
+	}
+}
+
+// 取得一个令牌，必要时排队等待；预计等待超过 maxWait 时返回 ErrRateLimited
+func (b *tokenBucket) Wait() error {
+	if b == nil || b.rate <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		b.mu.Unlock()
+		return nil
+	}
+
+	// 令牌透支，按欠缺数量计算需要等待的时间
+	wait := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	if wait > b.maxWait {
+		b.tokens++
+		b.mu.Unlock()
+		return &RouterError{Kind: ErrRateLimited, Detail: tr("ratelimit.wait", wait.Round(time.Millisecond), b.maxWait)}
+	}
+
+	b.waiting++
+	b.mu.Unlock()
+
+	sayDebug("debug.rate_limited", wait.Round(time.Millisecond))
+	time.Sleep(wait)
+
+	b.mu.Lock()
+	b.waiting--
+	b.mu.Unlock()
+	return nil
+}
+
+// 当前排队等待的请求数
+func (b *tokenBucket) Waiting() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.waiting
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = map[string]*tokenBucket{}
+)
+
+// 每台路由器一个令牌桶，UI、定时任务等所有来源共享
+func limiterFor(routerIP string) *tokenBucket {
+	cfg := globalConfig{}.Get()
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	if l, ok := limiters[routerIP]; ok {
+		return l
+	}
+	maxWait, err := time.ParseDuration(cfg.RateLimitWait)
+	if err != nil {
+		maxWait = 5 * time.Second
+	}
+	l := newTokenBucket(cfg.RateLimit, cfg.RateBurst, maxWait)
+	limiters[routerIP] = l
+	return l
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	// 每秒20个，容量2：前两个立即通过，第三个排队约50ms
+	b := newTokenBucket(20, 2, 80*time.Millisecond)
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := b.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d > 20*time.Millisecond {
+		t.Errorf("突发请求等待了 %v", d)
+	}
+	if err := b.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Errorf("超出容量的请求只等待了 %v", d)
+	}
+
+	// 预计等待超过 maxWait 时直接拒绝，且不占用令牌
+	b = newTokenBucket(20, 1, 10*time.Millisecond)
+	if err := b.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Wait(); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Wait = %v, want ErrRateLimited", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if err := b.Wait(); err != nil {
+		t.Errorf("令牌补充后 Wait = %v", err)
+	}
+
+	if err := newTokenBucket(0, 1, 0).Wait(); err != nil {
+		t.Errorf("rate 为 0 时不限流: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 定时重启路由器。部分机型长时间运行后不稳定，而重启会恢复防火墙设置，
+// 因此重启前发出通知，重启完成后重新应用当前设置
+type RebootConfig struct {
+	Enabled      bool     `json:"enabled"`
+	At           string   `json:"at"`            // "04:00"
+	Days         []string `json:"days"`          // 如 ["mon"]，留空为每天
+	Timezone     string   `json:"timezone"`      // 留空使用全局 timezone
+	NotifyBefore string   `json:"notify_before"` // 提前多久通知，默认 10m，"0s" 不通知
+	WaitTimeout  string   `json:"wait_timeout"`  // 等待路由器重新上线的最长时间，默认 10m
+}
+
+// 重启进行中时为1，离线监控据此不把重启当作地址变化
+var rebooting int32
+
+func rebootInProgress() bool {
+	return atomic.LoadInt32(&rebooting) == 1
+}
+
+// 借用定时任务的时刻计算
+func (c RebootConfig) schedule() Schedule {
+	return Schedule{Name: "reboot", At: c.At, Days: c.Days, Timezone: c.Timezone}
+}
+
+func validateReboot() error {
+	if !config.Reboot.Enabled {
+		return nil
+	}
+	_, err := config.Reboot.schedule().next(time.Now())
+	return err
+}
+
+// 向路由器发送重启命令
+func rebootRouter() error {
+	responseBody, err := callRouter("reboot", map[string]interface{}{
+		"method": "do",
+		"system": map[string]interface{}{"reboot": nil},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = decodeRouterResponse(responseBody)
+	return err
+}
+
+// 等待路由器先离线再重新上线；没有观察到离线时以超时前最后一次探测为准
+func waitForReboot(routerIP string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	wentDown := false
+	for time.Now().Before(deadline) {
+		time.Sleep(5 * time.Second)
+		up := probeRouter(routerIP, 3*time.Second)
+		if !up {
+			wentDown = true
+			continue
+		}
+		if wentDown {
+			// 管理端口先于防火墙服务就绪，再等一会
+			time.Sleep(15 * time.Second)
+			return true
+		}
+	}
+	return probeRouter(routerIP, 3*time.Second)
+}
+
+// 执行一次定时重启并在完成后重新应用设置
+func runReboot() {
+	if err := guardAutomatic(sourceScheduler); err != nil {
+		msg := tr("console.reboot_skipped", err)
+		logf("%s\n", msg)
+		recordEvent("reboot", msg, map[string]interface{}{"success": false})
+		return
+	}
+
+	atomic.StoreInt32(&rebooting, 1)
+	defer atomic.StoreInt32(&rebooting, 0)
+
+	applyMu.Lock()
+	err := rebootRouter()
+	applyMu.Unlock()
+	if err != nil {
+		msg := tr("console.reboot_failed", err)
+		logf("%s\n", msg)
+		notify("reboot_failed", tr("notify.reboot_failed"), msg)
+		recordEvent("reboot", msg, map[string]interface{}{"success": false})
+		return
+	}
+	c := globalConfig{}.Get()
+	say("console.reboot_sent", c.RouterIP)
+	queryCache.invalidate()
+
+	if !waitForReboot(c.RouterIP, parseDurationOr(c.Reboot.WaitTimeout, 10*time.Minute)) {
+		msg := tr("notify.reboot_timeout.detail", c.RouterIP)
+		notify("reboot_failed", tr("notify.reboot_failed"), msg)
+		recordEvent("reboot", msg, map[string]interface{}{"success": false})
+		return
+	}
+
+	err = applySettings(sourceScheduler)
+	msg := tr("notify.reboot_done.detail", c.RouterIP)
+	if err != nil {
+		msg = tr("notify.reboot_reapply.detail", c.RouterIP, userMessage(err))
+		notify("reboot_failed", tr("notify.reboot_failed"), msg)
+	} else {
+		notify("reboot_done", tr("notify.reboot_done"), msg)
+	}
+	recordEvent("reboot", msg, map[string]interface{}{"success": err == nil})
+}
+
+// 后台按计划重启路由器，stop 关闭时退出
+func runRebootScheduler(stop <-chan struct{}) {
+	c := globalConfig{}.Get()
+	s := c.Reboot.schedule()
+	notifyBefore := 10 * time.Minute
+	if d, err := time.ParseDuration(c.Reboot.NotifyBefore); err == nil {
+		notifyBefore = d
+	}
+
+	for {
+		at, err := s.next(time.Now())
+		if err != nil {
+			return
+		}
+		sayDebug("debug.next_reboot", at.Format(time.RFC3339))
+
+		// 先等到通知时刻，已经过了则直接通知
+		if notifyBefore > 0 {
+			if !sleepUntil(at.Add(-notifyBefore), stop) {
+				return
+			}
+			notify("reboot_soon", tr("notify.reboot_soon"),
+				tr("notify.reboot_soon.detail", globalConfig{}.Get().RouterIP, at.Format("15:04")))
+		}
+		if !sleepUntil(at, stop) {
+			return
+		}
+		runReboot()
+	}
+}
+
+// 等待到指定时刻，stop 关闭时返回 false
+func sleepUntil(t time.Time, stop <-chan struct{}) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 脱敏后的占位符
+const redactedMark = "***"
+
+// 常见凭据格式：URL中的stok、JSON/表单中的密码与令牌字段、Authorization头
+var redactPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?i)(stok=)[^/&\s"'<>]+`), "${1}" + redactedMark},
+	{regexp.MustCompile(`(?i)("[\w-]*(?:stok|password|passwd|pwd|token|api_key|secret)"\s*:\s*")[^"]*(")`), "${1}" + redactedMark + "${2}"},
+	{regexp.MustCompile(`(?i)((?:^|[?&\s])[\w-]*(?:password|passwd|pwd|token|api_key|secret)=)[^&\s"'<>]+`), "${1}" + redactedMark},
+	{regexp.MustCompile(`(?i)(authorization:\s*(?:basic|bearer)?\s*)\S+`), "${1}" + redactedMark},
+}
+
+var (
+	secretsMu sync.RWMutex
+	secrets   = map[string]struct{}{}
+)
+
+// 登记运行时已知的敏感值（如当前stok），日志中出现时一律替换
+func registerSecret(s string) {
+	// 过短的值替换后反而会破坏正常文本
+	if len(s) < 4 {
+		return
+	}
+	secretsMu.Lock()
+	secrets[s] = struct{}{}
+	secretsMu.Unlock()
+}
+
+// 对任意字符串脱敏，用于日志、错误信息和调试输出
+func redact(s string) string {
+	for _, p := range redactPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redactedMark)
+	}
+	return s
+}
+
+// 脱敏后的错误信息
+func redactErr(err error) string {
+	if err == nil {
+		return ""
+	}
+	return redact(err.Error())
+}
+
+// 记录访问日志的中间件，URL和表单内容均经过脱敏
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		debugf("%s %s %s (%v)\n", r.RemoteAddr, r.Method, r.URL.String(), time.Since(start))
+		if r.Method == http.MethodPost && r.Form != nil {
+			sayDebug("debug.form", r.Form.Encode())
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	cases := map[string]string{
+		"http://192.168.1.1/stok=abc123/ds":                 "http://192.168.1.1/stok=***/ds",
+		`{"method":"do","login":{"password":"hunter2"}}`:    `{"method":"do","login":{"password":"***"}}`,
+		`{"api_key": "k-1", "name":"home"}`:                 `{"api_key": "***", "name":"home"}`,
+		"router_ip=192.168.1.1&router_password=hunter2&x=1": "router_ip=192.168.1.1&router_password=***&x=1",
+		"Authorization: Bearer eyJhbGciOi":                  "Authorization: Bearer ***",
+		"dmz_enable=1":                                      "dmz_enable=1",
+	}
+	for in, want := range cases {
+		if got := redact(in); got != want {
+			t.Errorf("redact(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	registerSecret("s3cr3t-stok")
+	registerSecret("ab")
+	if got := redactErr(errors.New("login failed for s3cr3t-stok")); got != "login failed for ***" {
+		t.Errorf("redactErr = %q", got)
+	}
+	if got := redact("about"); got != "about" {
+		t.Errorf("过短的值不应被替换: %q", got)
+	}
+}
+
+// debug 级别的访问日志中不出现stok和密码
+func TestLogRequestsRedacts(t *testing.T) {
+	var buf bytes.Buffer
+	logMu.Lock()
+	oldOutput, oldLevel := logOutput, minLevel
+	logOutput, minLevel = &buf, levelDebug
+	logMu.Unlock()
+	t.Cleanup(func() {
+		logMu.Lock()
+		logOutput, minLevel = oldOutput, oldLevel
+		logMu.Unlock()
+	})
+
+	h := logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+	}))
+	form := url.Values{"router_password": {"hunter2"}, "stok": {"abc123"}, "dmz_enable": {"1"}}
+	req := httptest.NewRequest(http.MethodPost, "/apply?stok=abc123", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	out := buf.String()
+	if !strings.Contains(out, "dmz_enable=1") {
+		t.Fatalf("没有记录表单: %q", out)
+	}
+	if strings.Contains(out, "hunter2") || strings.Contains(out, "abc123") {
+		t.Errorf("日志泄露了凭据: %q", out)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"time"
+
+	"tplinkfirewalloff/internal/configstore"
+)
+
+// 检查配置文件是否修改的间隔
+const configPollInterval = 2 * time.Second
+
+// 配置文件的修改时间与大小，任一变化即视为已修改
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// 文件不存在时 ok 为 false
+func stampOf(path string) (stamp fileStamp, ok bool) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, false
+	}
+	return fileStamp{modTime: fi.ModTime(), size: fi.Size()}, true
+}
+
+// 后台轮询配置文件，修改后重新读取，有变化时调用 reloaded；stop 关闭时退出。
+// 配置从标准输入读取或没有配置文件时不检查
+func runConfigReloader(stop <-chan struct{}, reloaded func()) {
+	f, ok := configStore.(*configstore.File)
+	if !ok {
+		return
+	}
+	last, _ := stampOf(f.Path)
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		// 编辑器保存时文件可能短暂不存在，等下次检查
+		stamp, ok := stampOf(f.Path)
+		if !ok || stamp.size == last.size && stamp.modTime.Equal(last.modTime) {
+			continue
+		}
+		last = stamp
+		changed, err := reloadConfig()
+		if err != nil {
+			warn("console.config_reload_failed", err)
+			continue
+		}
+		if changed {
+			say("console.config_reloaded", f.Path)
+			if reloaded != nil {
+				reloaded()
+			}
+		}
+	}
+}
+
+// 重新读取配置文件并校验，无效时保持原配置；返回配置是否有变化。
+// 选中的路由器以及运行中登录获得的stok保留。
+// 监控、日志、路由器连接等启动时初始化的设置仍需重启后生效
+func reloadConfig() (changed bool, err error) {
+	// 持有 applyMu，其他整体替换配置的操作（修改设置、重新读取）不会同时进行；
+	// 不持有 configMu，读取与校验中输出的消息需要读取语言设置
+	applyMu.Lock()
+	defer applyMu.Unlock()
+	prev, prevBase, store := globalConfig{}.Get(), baseRouter, configStore
+	name := activeRouter()
+	before, _ := resolveRouter(name)
+	err = loadConfig(store)
+	if err == nil {
+		err = validateReloaded()
+	}
+	if err != nil {
+		configMu.Lock()
+		config, configStore = prev, store
+		configMu.Unlock()
+		return false, err
+	}
+
+	base := profileOf(globalConfig{}.Get())
+	baseRouter = base
+	p, err := resolveRouter(name)
+	if err != nil {
+		// 选中的路由器已从 routers 中删除，回到顶层配置
+		name, p = "", base
+	}
+	var next Config
+	globalConfig{}.Update(func(c *Config) {
+		p.applyTo(c)
+		if p.RouterIP == before.RouterIP && p.Stok == before.Stok {
+			c.Stok = prev.Stok
+		}
+		configStore = store
+		next = *c
+	})
+	changed = !reflect.DeepEqual(next, prev) || base != prevBase
+
+	registerSecret(p.Stok)
+	registerSecret(p.RouterPassword)
+	routerMu.Lock()
+	currentRouter = name
+	routerMu.Unlock()
+	if !changed {
+		return false, nil
+	}
+	if queryCache.ttl, err = time.ParseDuration(next.StateCacheTTL); err != nil {
+		warn("console.bad_cache_ttl", err)
+	}
+	queryCache.invalidate()
+	if p.RouterIP != prev.RouterIP {
+		forgetRouter()
+	}
+	return true, nil
+}
+
+// 同启动时的校验，插件只在启动时加载
+func validateReloaded() error {
+	for _, v := range configValidators() {
+		if v.name == "plugin_dir" {
+			continue
+		}
+		if err := v.check(); err != nil {
+			return routerErr(ErrBadParameter, 0, tr("console.config_invalid", v.name, err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tplinkfirewalloff/internal/configstore"
+)
+
+func TestReloadConfig(t *testing.T) {
+	setupTest(t)
+	store := configStore.(*configstore.Memory)
+	var doc map[string]interface{}
+	json.Unmarshal(store.Data, &doc)
+	config.Stok = "login-stok" // 运行中重新登录获得
+
+	if changed, err := reloadConfig(); err != nil || changed {
+		t.Fatalf("文件未修改时 changed=%v err=%v", changed, err)
+	}
+	if config.Stok != "login-stok" {
+		t.Errorf("重新读取后 stok = %q", config.Stok)
+	}
+
+	doc["dmz_dest_ip"] = "192.168.0.30"
+	store.Save(doc)
+	if changed, err := reloadConfig(); err != nil || !changed {
+		t.Fatalf("修改后 changed=%v err=%v", changed, err)
+	}
+	if config.DmzDestIP != "192.168.0.30" || config.Stok != "login-stok" {
+		t.Errorf("重新读取后 dmz_dest_ip=%q stok=%q", config.DmzDestIP, config.Stok)
+	}
+
+	store.Data = []byte(`{"dmz_dest_ip": "192.168.0.31",`)
+	if _, err := reloadConfig(); err == nil {
+		t.Fatal("无效的配置没有报错")
+	}
+	if config.DmzDestIP != "192.168.0.30" || configStore != store {
+		t.Errorf("无效的配置替换了原配置: dmz_dest_ip=%q", config.DmzDestIP)
+	}
+}
+
+// 守护与监控在后台读取配置，同时界面或重新读取配置文件在修改；需在 -race 下运行才能发现问题
+func TestBackgroundReadersDuringUpdate(t *testing.T) {
+	setupTest(t)
+	// 路由器总是报告被改回的设置，守护每次都会重新应用
+	var sets int32
+	router := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), `"set"`) {
+			atomic.AddInt32(&sets, 1)
+		}
+		w.Write([]byte(`{"error_code":0,"firewall":{"ipv6_firewall":{"enable":"on"},"dmz":{"enable":"0","dest_ip":"","dest_ip6":""}}}`))
+	}))
+	defer router.Close()
+	config.RouterIP = strings.TrimPrefix(router.URL, "http://")
+	config.IPv6FirewallEnable = "off"
+	config.StateCacheTTL = "0s"
+	queryCache.ttl = 0
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			// 与 reloadConfig 一样整体替换配置
+			globalConfig{}.Update(func(c *Config) {
+				next := *c
+				next.DmzDestIP = fmt.Sprintf("192.168.0.%d", 10+i%100)
+				*c = next
+			})
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		watchOnce()
+		monitorOnce(time.Second)
+	}
+	close(stop)
+	wg.Wait()
+	if atomic.LoadInt32(&sets) == 0 {
+		t.Error("守护没有重新应用设置")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"time"
+)
+
+// 路由器LAN地址的变更记录，重启后据此沿用新地址
+type routerMove struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+// TP-LINK 常见的默认LAN地址，恢复出厂后多为其中之一
+var commonRouterIPs = []string{"192.168.0.1", "192.168.1.1", "192.168.2.1"}
+
+// 启动时沿用之前检测到的新地址
+func applyRouterMove() {
+	trackedMu.Lock()
+	move := tracked.RouterMove
+	trackedMu.Unlock()
+	if move != nil && config.RouterIP == move.From {
+		config.RouterIP = move.To
+		say("console.router_move_loaded", move.From, move.To)
+	}
+}
+
+// 路由器离线时在默认网关和常见地址中查找同一台设备，找到后改用新地址
+func relocateRouter() {
+	expected, ok := knownIdentity()
+	if !ok {
+		sayDebug("debug.relocate_no_identity")
+		return
+	}
+	old := globalConfig{}.Get().RouterIP
+	for _, ip := range routerCandidates(old) {
+		if !probeHost(ip, 2*time.Second) {
+			continue
+		}
+		actual, err := queryIdentityAt(ip)
+		if errors.Is(err, ErrAuthExpired) {
+			// 设备在线但stok失效，无法确认身份，提示用户重新登录
+			say("console.router_move_auth", ip)
+			continue
+		}
+		if err != nil || !expected.matches(actual) {
+			sayDebug("debug.relocate_mismatch", ip, actual, err)
+			continue
+		}
+
+		updateConfig(func(c *Config) { c.RouterIP = ip })
+		trackedMu.Lock()
+		tracked.RouterMove = &routerMove{From: old, To: ip, At: time.Now()}
+		if tracked.Identity != nil {
+			tracked.Identity.RouterIP = ip
+		}
+		trackedMu.Unlock()
+		saveTrackedState()
+
+		msg := tr("notify.router_moved.detail", old, ip, actual)
+		notify("router_moved", tr("notify.router_moved"), msg)
+		recordEvent("router_moved", msg, map[string]interface{}{"from": old, "to": ip})
+		logf("%s\n", msg)
+		return
+	}
+}
+
+// 用于比较的身份：配置的型号/MAC优先，其次是首次使用时记住的身份
+func knownIdentity() (RouterIdentity, bool) {
+	id := globalConfig{}.Get().RouterIdentity
+	if id.Disabled {
+		return id, false
+	}
+	if id.Model != "" || id.MAC != "" {
+		return id, true
+	}
+	trackedMu.Lock()
+	defer trackedMu.Unlock()
+	if tracked.Identity == nil || tracked.Identity.MAC == "" {
+		return id, false
+	}
+	return *tracked.Identity, true
+}
+
+// 候选地址：本机默认网关在前，去掉当前地址
+func routerCandidates(current string) []string {
+	gateways, err := defaultGateways()
+	if err != nil {
+		sayDebug("debug.gateway_failed", err)
+	}
+	seen := map[string]bool{current: true}
+	var out []string
+	for _, ip := range append(gateways, commonRouterIPs...) {
+		if !seen[ip] {
+			seen[ip] = true
+			out = append(out, ip)
+		}
+	}
+	return out
+}
+
+func probeHost(ip string, timeout time.Duration) bool {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, "80"), timeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// 直接读取指定地址上设备的身份，不经过熔断器（此时原地址正处于离线熔断中）
+func queryIdentityAt(ip string) (RouterIdentity, error) {
+	responseBody, err := postRouterTo(ip, map[string]interface{}{
+		"method":      "get",
+		"device_info": map[string]interface{}{"name": []string{"info"}},
+	})
+	if err != nil {
+		return RouterIdentity{}, err
+	}
+	resp, err := decodeRouterResponse(responseBody)
+	if err != nil {
+		return RouterIdentity{}, err
+	}
+	var st sectionState
+	if err := json.Unmarshal(resp["device_info"], &st); err != nil {
+		return RouterIdentity{}, routerErr(ErrUnsupportedFirmware, 0, tr("response.missing", "device_info"))
+	}
+	return identityFromInfo(ip, st["info"]), nil
+}
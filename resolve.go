@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// 解析 router_ip 中的主机名（如 tplinkwifi.net），解析失败时使用上次成功的结果
+var (
+	resolvedMu    sync.Mutex
+	resolvedHosts = map[string]string{} // 主机名 -> 上次解析到的IP
+)
+
+const resolveTimeout = 3 * time.Second
+
+// 把 router_ip 转换为可直接放进URL的地址：IP原样返回（IPv6加方括号），
+// 主机名按请求时解析，优先IPv4；可带端口，如 router.lan:8080
+func resolveRouterHost(host string) (string, error) {
+	name, port, err := net.SplitHostPort(host)
+	if err != nil {
+		name, port = host, ""
+	}
+	ip, err := resolveName(name)
+	if err != nil {
+		return "", err
+	}
+	if port != "" {
+		return net.JoinHostPort(ip, port), nil
+	}
+	if net.ParseIP(ip).To4() == nil {
+		return "[" + ip + "]", nil
+	}
+	return ip, nil
+}
+
+func resolveName(name string) (string, error) {
+	if ip := net.ParseIP(name); ip != nil {
+		return ip.String(), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, name)
+	if err == nil && len(addrs) > 0 {
+		ip := pickAddress(addrs)
+		resolvedMu.Lock()
+		changed := resolvedHosts[name] != ip
+		resolvedHosts[name] = ip
+		resolvedMu.Unlock()
+		if changed {
+			sayDebug("debug.resolved", name, ip)
+			trackedMu.Lock()
+			if tracked.ResolvedHosts == nil {
+				tracked.ResolvedHosts = map[string]string{}
+			}
+			tracked.ResolvedHosts[name] = ip
+			trackedMu.Unlock()
+			saveTrackedState()
+		}
+		return ip, nil
+	}
+
+	// 解析失败时使用上次的结果，路由器自带的DNS重启期间也能继续工作
+	resolvedMu.Lock()
+	last, ok := resolvedHosts[name]
+	resolvedMu.Unlock()
+	if !ok {
+		trackedMu.Lock()
+		last, ok = tracked.ResolvedHosts[name]
+		trackedMu.Unlock()
+	}
+	if ok {
+		warn("console.resolve_fallback", name, last)
+		return last, nil
+	}
+	if err == nil {
+		err = &net.DNSError{Err: "no addresses", Name: name}
+	}
+	return "", routerErr(ErrUnreachable, 0, err.Error())
+}
+
+// 优先IPv4；只有AAAA记录时使用第一个IPv6地址
+func pickAddress(addrs []net.IPAddr) string {
+	for _, a := range addrs {
+		if a.IP.To4() != nil {
+			return a.IP.String()
+		}
+	}
+	return addrs[0].IP.String()
+}
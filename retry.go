@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// 路由器暂时不可用（刚重启、WAN重连）时的重试策略
+type RetryConfig struct {
+	Attempts  int     `json:"attempts"`   // 最多尝试次数（含第一次），1=不重试，默认 3
+	BaseDelay string  `json:"base_delay"` // 第一次重试前的等待，之后每次翻倍，默认 1s
+	MaxDelay  string  `json:"max_delay"`  // 单次等待的上限，默认 10s
+	Jitter    float64 `json:"jitter"`     // 等待时间随机浮动的比例 0~1，默认 0.2
+}
+
+// 只重试可能自行恢复的错误：连不上路由器，或路由器忙
+func transientError(err error) bool {
+	var re *RouterError
+	if errors.As(err, &re) && re.Code == codeSystem {
+		return true
+	}
+	return errors.Is(err, ErrUnreachable)
+}
+
+// 第 n 次重试前的等待时间（n 从0开始）
+func retryDelay(n int) time.Duration {
+	cfg := globalConfig{}.Get()
+	base := parseDurationOr(cfg.Retry.BaseDelay, time.Second)
+	max := parseDurationOr(cfg.Retry.MaxDelay, 10*time.Second)
+	d := base << uint(n)
+	if d > max || d <= 0 {
+		d = max
+	}
+	if j := cfg.Retry.Jitter; j > 0 && j <= 1 {
+		d = time.Duration(float64(d) * (1 + j*(2*rand.Float64()-1)))
+	}
+	return d
+}
+
+// 按重试策略执行 fn，遇到暂时性错误时指数退避后重试；程序退出时不再等待
+func withRetry(op string, fn func() error) error {
+	attempts := globalConfig{}.Get().Retry.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for n := 0; n < attempts; n++ {
+		if n > 0 {
+			d := retryDelay(n - 1)
+			sayDebug("debug.retry", op, d.Round(time.Millisecond), n, err)
+			select {
+			case <-routerCtx.Done():
+				return err
+			case <-time.After(d):
+			}
+		}
+		if err = fn(); err == nil || !transientError(err) {
+			return err
+		}
+	}
+	return err
+}
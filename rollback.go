@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"time"
+)
+
+// 记录修改前路由器的设置，作为撤销目标
+func rememberPrevious(current firewallState) {
+	trackedMu.Lock()
+	tracked.Previous = &current
+	tracked.PreviousAt = time.Now()
+	trackedMu.Unlock()
+}
+
+// 重新应用上次修改前的设置
+func rollbackSettings(source, actor string) (firewallState, error) {
+	trackedMu.Lock()
+	prev := tracked.Previous
+	trackedMu.Unlock()
+	if prev == nil {
+		return firewallState{}, routerErr(ErrBadParameter, 0, tr("rollback.none"))
+	}
+	_, err := applyDesired(*prev, source, actor, allSections)
+	return *prev, err
+}
+
+// POST /rollback：界面上的“撤销”按钮
+func rollbackHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := globalConfig{}.Get()
+	if _, err := rollbackSettings(sourceUser, actorOf(r)); err != nil {
+		renderTemplate(w, httpStatusFor(err), "error.html", map[string]interface{}{
+			"Message":          userMessage(err),
+			"Detail":           err.Error(),
+			"IdentityMismatch": errors.Is(err, ErrIdentityMismatch) && cfg.RouterIdentity.Model == "" && cfg.RouterIdentity.MAC == "",
+		})
+		return
+	}
+	http.Redirect(w, r, "/success", http.StatusSeeOther)
+}
+
+// rollback：在命令行撤销上次修改
+func runRollback(args []string) error {
+	fs := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	configPath := fs.String("config", "config.json", tr("flag.config"))
+	registerLogFlags(fs)
+	registerRouterFlag(fs)
+	registerConfigFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	defer cleanup()
+	if err := setup(*configPath); err != nil {
+		return err
+	}
+	prev, err := rollbackSettings(sourceCtl, "")
+	if err != nil {
+		return err
+	}
+	say("rollback.done", prev.IPv6FirewallEnable, prev.DmzEnable, prev.DmzDestIP, prev.DmzDestIP6)
+	return nil
+}
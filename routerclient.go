@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"tplinkfirewalloff/pkg/tplink"
+)
+
+// 路由器请求的默认超时
+const (
+	defaultConnectTimeout = 5 * time.Second
+	defaultReadTimeout    = 15 * time.Second
+)
+
+// 所有路由器请求共用的上下文，程序退出时取消以中断仍在等待的请求
+var routerCtx, cancelRouterRequests = context.WithCancel(context.Background())
+
+// 按配置设置路由器客户端：connect_timeout 为建立连接，read_timeout 为等待响应；
+// 以HTTPS管理的路由器可指定自签名证书的 ca_file，或用 insecure_skip_verify 跳过校验
+func configureRouterClient() error {
+	switch config.RouterScheme {
+	case "", "http", "https":
+	default:
+		return fmt.Errorf("router_scheme %q", config.RouterScheme)
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%s", tr("tls.no_pem", config.CAFile))
+		}
+	}
+
+	connect := parseDurationOr(config.ConnectTimeout, defaultConnectTimeout)
+	read := parseDurationOr(config.ReadTimeout, defaultReadTimeout)
+	if u, err := url.Parse(config.Proxy); err == nil && u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			registerSecret(password)
+		}
+	}
+	routerClient.Transport = &http.Transport{
+		Proxy:                 routerProxy,
+		DialContext:           (&net.Dialer{Timeout: connect, KeepAlive: 30 * time.Second}).DialContext,
+		ResponseHeaderTimeout: read,
+		IdleConnTimeout:       90 * time.Second,
+		TLSClientConfig:       tlsConfig,
+	}
+	// 整个请求（含读取响应体）的上限，避免路由器只发一半响应时一直等待
+	routerClient.Timeout = connect + read
+	return nil
+}
+
+// 路由器接口地址，router_scheme 默认为 http
+func routerURL(addr, path string) string {
+	scheme := globalConfig{}.Get().RouterScheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, addr, path)
+}
+
+// 访问路由器使用的代理：配置了 proxy 时使用它（"direct" 表示不用代理），
+// 否则按 HTTP_PROXY/NO_PROXY 环境变量，再退回 ALL_PROXY
+func routerProxy(req *http.Request) (*url.URL, error) {
+	cfg := globalConfig{}.Get()
+	switch cfg.Proxy {
+	case "":
+	case "direct":
+		return nil, nil
+	default:
+		return url.Parse(cfg.Proxy)
+	}
+	if u, err := http.ProxyFromEnvironment(req); u != nil || err != nil {
+		return u, err
+	}
+	for _, name := range []string{"ALL_PROXY", "all_proxy"} {
+		if v := os.Getenv(name); v != "" {
+			return url.Parse(v)
+		}
+	}
+	return nil, nil
+}
+
+// proxy 须为 http://、https:// 或 socks5:// 地址
+func validateProxy() error {
+	if config.Proxy == "" || config.Proxy == "direct" {
+		return nil
+	}
+	u, err := url.Parse(config.Proxy)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		if u.Host != "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("proxy %q", redact(config.Proxy))
+}
+
+// 按型号前缀附加的默认请求头，值中的 {base} 替换为路由器地址（如 http://192.168.1.1）
+var modelHeaders = []struct {
+	prefix  string
+	headers map[string]string
+}{
+	// 易展/XDR 系列新固件还校验 Origin
+	{"TL-XDR", map[string]string{"Origin": "{base}"}},
+	{"TL-XTR", map[string]string{"Origin": "{base}"}},
+	{"TL-7DR", map[string]string{"Origin": "{base}"}},
+}
+
+// 已知的路由器型号：优先用配置中的，其次是记住的身份
+func routerModel() string {
+	cfg := globalConfig{}.Get()
+	if cfg.RouterIdentity.Model != "" {
+		return cfg.RouterIdentity.Model
+	}
+	trackedMu.Lock()
+	defer trackedMu.Unlock()
+	if tracked.Identity != nil {
+		return tracked.Identity.Model
+	}
+	return ""
+}
+
+// 每个路由器请求附带的请求头：默认的 Referer 与 User-Agent，加上型号默认值，
+// 最后是配置中的 headers；headers 中值为空表示去掉该请求头
+func routerHeaders(base string) map[string]string {
+	headers := map[string]string{
+		"Referer":    base + "/",
+		"User-Agent": tplink.BrowserUserAgent,
+	}
+	model := strings.ToUpper(routerModel())
+	for _, m := range modelHeaders {
+		if strings.HasPrefix(model, m.prefix) {
+			for k, v := range m.headers {
+				headers[k] = v
+			}
+		}
+	}
+	configured := globalConfig{}.Get().Headers
+	for k, v := range configured {
+		headers[k] = v
+	}
+	for k, v := range headers {
+		if v == "" {
+			delete(headers, k)
+		} else {
+			headers[k] = strings.ReplaceAll(v, "{base}", base)
+		}
+	}
+	return headers
+}
+
+// 指向 addr 的协议客户端，使用本程序的HTTP客户端与请求头，收发内容记入诊断记录
+func newTPLinkClient(addr, stok string) *tplink.Client {
+	base := routerURL(addr, "")
+	c := tplink.NewClient(base)
+	c.Stok = stok
+	c.HTTPClient = routerClient
+	c.Header = routerHeaders(base)
+	c.Trace = func(url string, request []byte, status int, response []byte, err error) {
+		// 登录响应中的stok须在记录前登记为机密
+		var login struct {
+			Stok string `json:"stok"`
+		}
+		if json.Unmarshal(response, &login) == nil {
+			registerSecret(login.Stok)
+		}
+		recordExchange(url, request, status, response, err)
+	}
+	return c
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// routers 中的一台路由器：地址、凭据与期望的防火墙/DMZ设置，留空的项沿用顶层配置
+type RouterProfile struct {
+	Name               string `json:"name"`
+	RouterIP           string `json:"router_ip"`
+	Stok               string `json:"stok"`
+	RouterPassword     string `json:"router_password"`
+	IPv6FirewallEnable string `json:"ipv6_firewall_enable"`
+	DmzEnable          string `json:"dmz_enable"`
+	DmzDestIP          string `json:"dmz_dest_ip"`
+	DmzDestIP6         string `json:"dmz_dest_ip6"`
+	WANPort            string `json:"wan_port"`
+	FirmwareType       string `json:"firmware_type"`
+
+	// 期望的型号/MAC，应用到全部路由器时校验；不继承顶层的 router_identity
+	RouterIdentity RouterIdentity `json:"router_identity"`
+}
+
+var (
+	routerMu      sync.Mutex
+	currentRouter string        // 当前选中的路由器名称，为空时使用顶层配置
+	baseRouter    RouterProfile // 顶层配置中的路由器设置，切换时以它为底
+	flagRouter    string        // 命令行 --profile 指定的路由器
+)
+
+// 为子命令注册 --profile 参数；-router 为旧名称，保留兼容
+func registerRouterFlag(fs *flag.FlagSet) {
+	fs.StringVar(&flagRouter, "profile", "", tr("flag.router"))
+	fs.StringVar(&flagRouter, "router", "", tr("flag.router"))
+}
+
+// 当前选中的路由器名称
+func activeRouter() string {
+	routerMu.Lock()
+	defer routerMu.Unlock()
+	return currentRouter
+}
+
+func findRouter(name string) *RouterProfile {
+	cfg := globalConfig{}.Get()
+	for i := range cfg.Routers {
+		if cfg.Routers[i].Name == name {
+			return &cfg.Routers[i]
+		}
+	}
+	return nil
+}
+
+func validateRouters() error {
+	seen := map[string]bool{}
+	for _, r := range config.Routers {
+		if r.Name == "" || seen[r.Name] {
+			return fmt.Errorf("%s", tr("routers.bad_name", r.Name))
+		}
+		seen[r.Name] = true
+		if r.RouterIP == "" {
+			return fmt.Errorf("%s", tr("routers.no_ip", r.Name))
+		}
+		if r.WANPort != "" && !validWANPort(r.WANPort) {
+			return fmt.Errorf("%s: wan_port %q", r.Name, r.WANPort)
+		}
+		if _, ok := routerBackends[r.FirmwareType]; !ok {
+			return fmt.Errorf("%s: firmware_type %q", r.Name, r.FirmwareType)
+		}
+	}
+	if flagRouter != "" && findRouter(flagRouter) == nil {
+		return fmt.Errorf("%s", tr("router.unknown", flagRouter))
+	}
+	return nil
+}
+
+// 启动时记下顶层配置，并选中 --profile 指定的路由器；顶层没有填写 router_ip 时选中第一台
+func initRouters() {
+	baseRouter = profileOf(config)
+	name := flagRouter
+	if name == "" && config.RouterIP == "" && len(config.Routers) > 0 {
+		name = config.Routers[0].Name
+	}
+	if name != "" {
+		selectRouter(name)
+	}
+}
+
+// 配置中顶层的路由器设置
+func profileOf(c Config) RouterProfile {
+	return RouterProfile{
+		RouterIP:           c.RouterIP,
+		Stok:               c.Stok,
+		RouterPassword:     c.RouterPassword,
+		IPv6FirewallEnable: c.IPv6FirewallEnable,
+		DmzEnable:          c.DmzEnable,
+		DmzDestIP:          c.DmzDestIP,
+		DmzDestIP6:         c.DmzDestIP6,
+		WANPort:            c.WANPort,
+		FirmwareType:       c.FirmwareType,
+		RouterIdentity:     c.RouterIdentity,
+	}
+}
+
+// 用路由器的设置替换配置中对应的字段
+func (p RouterProfile) applyTo(c *Config) {
+	c.RouterIP = p.RouterIP
+	c.Stok = p.Stok
+	c.RouterPassword = p.RouterPassword
+	c.IPv6FirewallEnable = p.IPv6FirewallEnable
+	c.DmzEnable = p.DmzEnable
+	c.DmzDestIP = p.DmzDestIP
+	c.DmzDestIP6 = p.DmzDestIP6
+	c.WANPort = p.WANPort
+	c.FirmwareType = p.FirmwareType
+	c.RouterIdentity = p.RouterIdentity
+}
+
+// 指定路由器的完整设置：routers 中的项叠加在顶层配置上，name 为空时就是顶层配置
+func resolveRouter(name string) (RouterProfile, error) {
+	p := baseRouter
+	if name == "" {
+		return p, nil
+	}
+	r := findRouter(name)
+	if r == nil {
+		return p, routerErr(ErrBadParameter, 0, tr("router.unknown", name))
+	}
+	overlay := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	p.Name = r.Name
+	p.RouterIP = r.RouterIP
+	p.Stok = r.Stok
+	overlay(&p.RouterPassword, r.RouterPassword)
+	overlay(&p.IPv6FirewallEnable, r.IPv6FirewallEnable)
+	overlay(&p.DmzEnable, r.DmzEnable)
+	overlay(&p.DmzDestIP, r.DmzDestIP)
+	overlay(&p.DmzDestIP6, r.DmzDestIP6)
+	overlay(&p.WANPort, r.WANPort)
+	overlay(&p.FirmwareType, r.FirmwareType)
+	p.RouterIdentity = r.RouterIdentity
+	return p, nil
+}
+
+func (p RouterProfile) desired() firewallState {
+	return firewallState{
+		IPv6FirewallEnable: p.IPv6FirewallEnable,
+		DmzEnable:          p.DmzEnable,
+		DmzDestIP:          p.DmzDestIP,
+		DmzDestIP6:         p.DmzDestIP6,
+	}
+}
+
+// 切换到指定的路由器，name 为空时回到顶层配置
+func selectRouter(name string) error {
+	p, err := resolveRouter(name)
+	if err != nil {
+		return err
+	}
+	registerSecret(p.Stok)
+	registerSecret(p.RouterPassword)
+
+	var old string
+	updateConfig(func(c *Config) {
+		old = c.RouterIP
+		p.applyTo(c)
+	})
+	routerMu.Lock()
+	currentRouter = name
+	routerMu.Unlock()
+	queryCache.invalidate()
+
+	if old != p.RouterIP {
+		forgetRouter()
+	}
+	sayDebug("debug.router_switched", name, p.RouterIP)
+	return nil
+}
+
+// 换了一台路由器，之前记住的身份与撤销目标不再适用
+func forgetRouter() {
+	trackedMu.Lock()
+	tracked.Identity = nil
+	tracked.RouterMove = nil
+	tracked.Previous = nil
+	tracked.Confirmed = nil
+	trackedMu.Unlock()
+	saveTrackedState()
+}
+
+// POST /router：界面上切换当前管理的路由器
+func selectRouterHandler(w http.ResponseWriter, r *http.Request) {
+	var form struct {
+		Router string `form:"router"`
+	}
+	if err := bindForm(formOf(r), &form); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := selectRouter(form.Router); err != nil {
+		http.Error(w, userMessage(err), httpStatusFor(err))
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// “应用到全部路由器”中一台路由器的结果
+type routerResult struct {
+	Name     string
+	RouterIP string
+	Desired  firewallState
+	Elapsed  time.Duration
+	Err      error
+}
+
+// 面向用户的结果说明
+func (r routerResult) Message() string {
+	return userMessage(r.Err)
+}
+
+// 参与“应用到全部路由器”的路由器：顶层配置（填写了 router_ip 时）和 routers 中的每一台
+func allRouterNames() []string {
+	cfg := globalConfig{}.Get()
+	var names []string
+	if baseRouter.RouterIP != "" {
+		names = append(names, "")
+	}
+	for _, r := range cfg.Routers {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+// 向一台路由器发送它的期望设置，与单台设置一样经过身份校验与钩子。
+// 当前选中的路由器使用当前配置；其他路由器按各自的 firmware_type 创建后端，
+// 不改变当前选中的路由器，stok失效时用密码重新登录一次。调用方持有 applyMu
+func applyToRouter(name, source, actor string) routerResult {
+	res := routerResult{Name: name}
+	if res.Name == "" {
+		res.Name = tr("router.default")
+	}
+	var t applyTarget
+	if name == activeRouter() {
+		t = activeTarget(currentBackend())
+	} else {
+		p, err := resolveRouter(name)
+		if err != nil {
+			res.Err = err
+			return res
+		}
+		client, err := backendFor(p)
+		if err != nil {
+			res.Err = err
+			return res
+		}
+		t = applyTarget{client: client, router: p}
+	}
+	res.RouterIP, res.Desired = t.router.RouterIP, t.router.desired()
+	start := time.Now()
+	if res.Err = validateFirewallState(res.Desired); res.Err != nil {
+		recordApplyEventFor(res.RouterIP, res.Desired, source, actor, 0, "", res.Err)
+		countApply(source, res.Err)
+		return res
+	}
+	_, res.Err = applyLocked(t, source, actor, allSections)
+	res.Elapsed = time.Since(start)
+	return res
+}
+
+// 同时向所有路由器发送各自的期望设置，最多 apply_concurrency 台并行，结果按配置顺序返回
+func applyAllRouters(source, actor string) []routerResult {
+	applyMu.Lock()
+	defer applyMu.Unlock()
+	names := allRouterNames()
+	results := make([]routerResult, len(names))
+	workers := globalConfig{}.Get().ApplyConcurrency
+	if workers <= 0 {
+		workers = 4
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(names); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = applyToRouter(names[i], source, actor)
+			}
+		}()
+	}
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	queryCache.invalidate()
+	return results
+}
+
+// 汇总结果；有失败时返回的错误包含失败的路由器
+func summarizeResults(results []routerResult) (ok int, err error) {
+	var failed []error
+	for _, r := range results {
+		if r.Err == nil {
+			ok++
+		} else {
+			failed = append(failed, fmt.Errorf("%s (%s): %w", r.Name, r.RouterIP, r.Err))
+		}
+	}
+	if len(failed) > 0 {
+		return ok, fmt.Errorf("%s: %w", tr("router.apply_all_partial", len(failed), len(results)), errors.Join(failed...))
+	}
+	return ok, nil
+}
+
+// POST /router/apply-all：把各自的期望设置应用到所有路由器
+func applyAllHandler(w http.ResponseWriter, r *http.Request) {
+	results := applyAllRouters(sourceUser, actorOf(r))
+	ok, err := summarizeResults(results)
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusMultiStatus
+	}
+	renderTemplate(w, status, "routers.html", map[string]interface{}{
+		"Results": results,
+		"OK":      ok,
+		"Failed":  len(results) - ok,
+	})
+}
+
+// apply -all-routers：在命令行应用到所有路由器，每台一行结果
+func runApplyAll(source string) error {
+	results := applyAllRouters(source, "")
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Println(tr("router.result_failed", r.Name, r.RouterIP, userMessage(r.Err)))
+		} else {
+			fmt.Println(tr("router.result_ok", r.Name, r.RouterIP, r.Desired.IPv6FirewallEnable, r.Desired.DmzEnable, r.Elapsed.Round(time.Millisecond)))
+		}
+	}
+	_, err := summarizeResults(results)
+	return err
+}
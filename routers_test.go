@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"sync"
+	"testing"
+)
+
+func TestApplyAllRoutersUsesProfileBackends(t *testing.T) {
+	setupTest(t)
+	var mu sync.Mutex
+	sets := map[string]string{} // 路由器地址 -> 写入时使用的stok
+	routerBackends["test"] = dsClient{
+		post: func(host, stok string, requestBody map[string]interface{}) ([]byte, error) {
+			switch {
+			case requestBody["device_info"] != nil:
+				return []byte(`{"error_code":0,"device_info":{"info":{"device_model":"TL-XDR6088","mac":"00-11-22-33-44-55"}}}`), nil
+			case requestBody["method"] == "set":
+				mu.Lock()
+				sets[host] = stok
+				mu.Unlock()
+			}
+			return []byte(`{"error_code":0}`), nil
+		},
+		caps: fullCapabilities,
+	}
+	prevBase := baseRouter
+	t.Cleanup(func() {
+		delete(routerBackends, "test")
+		config.Routers, baseRouter = nil, prevBase
+	})
+	config.IPv6FirewallEnable, config.DmzEnable = "off", "0"
+	baseRouter = profileOf(config)
+	config.Routers = []RouterProfile{
+		{Name: "study", RouterIP: "192.168.2.1", Stok: "study-stok", FirmwareType: "test"},
+		{Name: "garage", RouterIP: "192.168.3.1", Stok: "garage-stok", FirmwareType: "test", RouterIdentity: RouterIdentity{Model: "TL-XDR3010"}},
+	}
+
+	results := applyAllRouters(sourceUser, "")
+	if len(results) != 3 {
+		t.Fatalf("结果 %d 条，应为3条", len(results))
+	}
+	if results[0].Err != nil || results[1].Err != nil {
+		t.Errorf("顶层与 study 应成功: %v / %v", results[0].Err, results[1].Err)
+	}
+	if sets["192.168.2.1"] != "study-stok" {
+		t.Errorf("study 应经自己的后端与stok写入，实际 %v", sets)
+	}
+	// 与单台设置一样校验身份，型号不符时不写入
+	if !errors.Is(results[2].Err, ErrIdentityMismatch) {
+		t.Errorf("garage 应因身份不符失败: %v", results[2].Err)
+	}
+	if _, ok := sets["192.168.3.1"]; ok {
+		t.Error("身份不符的路由器不应写入")
+	}
+	if activeRouter() != "" || config.RouterIP == "192.168.2.1" {
+		t.Error("应用到全部路由器不应改变当前选中的路由器")
+	}
+}
+
+func TestProfileFlagSelectsRouter(t *testing.T) {
+	t.Cleanup(func() { flagRouter = "" })
+	for _, args := range [][]string{
+		{"--profile", "home", "--preset", "game"},
+		{"-router", "home", "-preset", "game"},
+	} {
+		flagRouter = ""
+		fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+		registerRouterFlag(fs)
+		f := registerHeadlessFlags(fs)
+		if err := fs.Parse(args); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		if flagRouter != "home" || *f.profile != "game" {
+			t.Errorf("%v: router = %q, preset = %q", args, flagRouter, *f.profile)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"tplinkfirewalloff/internal/server"
+)
+
+// 路由器时间与NTP设置所在的模块与节
+const (
+	timeModule  = "system"
+	timeSection = "time"
+)
+
+// 超过该偏差时在首页提示时钟不准
+const maxClockSkew = 2 * time.Minute
+
+// 时间设置表单，只修改提交了的字段
+type timeForm struct {
+	NTPEnable  *string `form:"ntp_enable,lower"`
+	NTPServer1 *string `form:"ntp_server1"`
+	NTPServer2 *string `form:"ntp_server2"`
+	Timezone   *string `form:"timezone"`
+}
+
+var timeFields = server.Names(timeForm{})
+
+var tzOffsetPattern = regexp.MustCompile(`^[+-](0\d|1[0-4]):[0-5]\d$`)
+
+// 路由器时钟状态
+type routerClock struct {
+	Settings  map[string]interface{}
+	Time      time.Time     // 路由器当前时间
+	Skew      time.Duration // 路由器时间减本机时间
+	TZOffset  int           // 路由器时区偏移（秒）
+	TZDiffers bool          // 路由器时区与定时任务使用的时区当前偏移不同
+}
+
+// 解析 +08:00 形式的时区偏移
+func parseTZOffset(s string) (int, bool) {
+	if !tzOffsetPattern.MatchString(s) {
+		return 0, false
+	}
+	h, _ := strconv.Atoi(s[1:3])
+	m, _ := strconv.Atoi(s[4:6])
+	offset := h*3600 + m*60
+	if s[0] == '-' {
+		offset = -offset
+	}
+	return offset, true
+}
+
+// 读取路由器时间并与本机比较
+func queryRouterClock() (*routerClock, error) {
+	st, err := queryRouter(timeModule, timeSection)
+	if err != nil {
+		return nil, err
+	}
+	fields := st[timeSection]
+	c := &routerClock{Settings: fields}
+	// 路由器返回的 timestamp 为Unix秒
+	ts, err := strconv.ParseInt(fmt.Sprint(fields["timestamp"]), 10, 64)
+	if err != nil {
+		return nil, routerErr(ErrUnsupportedFirmware, 0, tr("response.missing", "timestamp"))
+	}
+	c.Time = time.Unix(ts, 0)
+	c.Skew = c.Time.Sub(time.Now()).Round(time.Second)
+
+	if offset, ok := parseTZOffset(fmt.Sprint(fields["timezone"])); ok {
+		c.TZOffset = offset
+		if loc, err := loadLocation(""); err == nil {
+			_, local := time.Now().In(loc).Zone()
+			c.TZDiffers = local != offset
+		}
+	}
+	return c, nil
+}
+
+// 偏差是否需要提示
+func (c *routerClock) Skewed() bool {
+	return c.Skew > maxClockSkew || c.Skew < -maxClockSkew
+}
+
+// /time：路由器时间、时区与NTP设置
+func timeHandler(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{"CanEdit": canEdit(r), "Fields": timeFields, "LocalTime": time.Now()}
+
+	if r.Method == http.MethodPost {
+		var form timeForm
+		if err := bindForm(formOf(r), &form); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		submitted := server.Submitted(form)
+		fields := map[string]interface{}{}
+		var invalid []string
+		for _, f := range timeFields {
+			v, ok := submitted[f]
+			if !ok {
+				continue
+			}
+			switch f {
+			case "ntp_enable":
+				ok = v == "on" || v == "off"
+			case "timezone":
+				_, ok = parseTZOffset(v)
+			default:
+				ok = v == "" || !strings.ContainsAny(v, " /:")
+			}
+			if !ok {
+				invalid = append(invalid, f)
+				continue
+			}
+			fields[f] = v
+		}
+		switch {
+		case len(invalid) > 0 || len(fields) == 0:
+			data["Error"] = tr("iptv.invalid", strings.Join(invalid, ", "))
+		default:
+			if err := setSection(timeModule, timeSection, fields); err != nil {
+				data["Error"] = userMessage(err) + ": " + err.Error()
+			} else {
+				recordEvent("router_time", tr("time.updated"), map[string]interface{}{"fields": fields})
+				data["Result"] = tr("time.updated")
+			}
+		}
+	}
+
+	clock, err := queryRouterClock()
+	if err != nil && data["Error"] == nil {
+		data["Error"] = userMessage(err)
+	}
+	data["Clock"] = clock
+	renderTemplate(w, http.StatusOK, "time.html", data)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net"
+	"net/http"
+)
+
+// 静态路由所在的模块与表
+const (
+	routeModule = "network"
+	routeTable  = "static_route"
+)
+
+// /routes 提交的表单
+type routeForm struct {
+	Op        string `form:"op"`
+	Name      string `form:"name"` // 删除的路由
+	Target    string `form:"target"`
+	Netmask   string `form:"netmask"`
+	Gateway   string `form:"gateway"`
+	Interface string `form:"interface"`
+}
+
+// 由表单构造一条静态路由，目标可写成 10.8.0.0/24 或分别填写掩码
+func staticRouteFromForm(form routeForm) (map[string]interface{}, string) {
+	target, mask := form.Target, form.Netmask
+	if _, ipnet, err := net.ParseCIDR(target); err == nil {
+		target, mask = ipnet.IP.String(), net.IP(ipnet.Mask).String()
+	}
+	ip := net.ParseIP(target).To4()
+	m := net.ParseIP(mask).To4()
+	if ip == nil || m == nil {
+		return nil, tr("routes.bad_target", target, mask)
+	}
+	if ones, bits := net.IPMask(m).Size(); ones == 0 && bits == 0 {
+		return nil, tr("routes.bad_target", target, mask)
+	}
+	if !ip.Equal(ip.Mask(net.IPMask(m))) {
+		return nil, tr("routes.not_network", target, mask)
+	}
+	gw := net.ParseIP(form.Gateway).To4()
+	if gw == nil {
+		return nil, tr("routes.bad_gateway", form.Gateway)
+	}
+	iface := form.Interface
+	if iface != "lan" && iface != "wan" {
+		return nil, tr("error.bad_parameter")
+	}
+	return map[string]interface{}{
+		"target":    ip.String(),
+		"netmask":   m.String(),
+		"gateway":   gw.String(),
+		"interface": iface,
+	}, ""
+}
+
+// /routes：静态路由的查看、添加与删除
+func routesHandler(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{"CanEdit": canEdit(r)}
+
+	if r.Method == http.MethodPost {
+		var form routeForm
+		if err := bindForm(formOf(r), &form); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var err error
+		var msg string
+		switch form.Op {
+		case "add":
+			route, problem := staticRouteFromForm(form)
+			if problem != "" {
+				data["Error"] = problem
+				break
+			}
+			err = addTableEntry(routeModule, routeTable, route)
+			msg = tr("routes.added", route["target"], route["netmask"], route["gateway"])
+		case "delete":
+			err = deleteTableEntry(routeModule, routeTable, form.Name)
+			msg = tr("routes.deleted", form.Name)
+		default:
+			data["Error"] = tr("error.bad_parameter")
+		}
+		if err != nil {
+			data["Error"] = userMessage(err) + ": " + err.Error()
+		} else if msg != "" {
+			logf("%s\n", msg)
+			recordEvent("static_route", msg, map[string]interface{}{"op": form.Op})
+			data["Result"] = msg
+		}
+	}
+
+	routes, err := queryTable(routeModule, routeTable)
+	if err != nil && data["Error"] == nil {
+		data["Error"] = userMessage(err)
+	}
+	data["Routes"] = routes
+	renderTemplate(w, http.StatusOK, "routes.html", data)
+}
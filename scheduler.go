@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // Windows上没有系统时区库，内嵌一份保证 LoadLocation 可用
+)
+
+// 定时任务：在指定时刻把路由器设为指定状态
+type Schedule struct {
+	Name               string   `json:"name"`
+	At                 string   `json:"at"`                   // "08:00"
+	Days               []string `json:"days"`                 // ["mon","fri"]，或 "weekdays"/"workdays"/"weekends"，留空为每天
+	Cron               string   `json:"cron"`                 // cron表达式，如 "0 * * * *" 每小时；填写后忽略 at 和 days
+	Timezone           string   `json:"timezone"`             // IANA时区，如 "Asia/Shanghai"，留空使用全局 timezone
+	IPv6FirewallEnable string   `json:"ipv6_firewall_enable"` // 留空表示不修改
+	DmzEnable          string   `json:"dmz_enable"`           // 留空表示不修改
+}
+
+// 展开星期配置，支持单日名称和 weekdays/workdays/weekends/daily 简写
+func expandDays(days []string) (map[time.Weekday]bool, error) {
+	set := map[time.Weekday]bool{}
+	for _, d := range days {
+		switch strings.ToLower(strings.TrimSpace(d)) {
+		case "daily", "everyday", "*":
+			for wd := time.Sunday; wd <= time.Saturday; wd++ {
+				set[wd] = true
+			}
+		case "weekdays", "workdays":
+			for wd := time.Monday; wd <= time.Friday; wd++ {
+				set[wd] = true
+			}
+		case "weekends":
+			set[time.Saturday], set[time.Sunday] = true, true
+		default:
+			wd, ok := parseWeekday(d)
+			if !ok {
+				return nil, fmt.Errorf("%s", tr("schedule.bad_day", d))
+			}
+			set[wd] = true
+		}
+	}
+	return set, nil
+}
+
+// 解析时区，留空时依次使用全局 timezone 和本机时区
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		name = globalConfig{}.Get().Timezone
+	}
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// loc 中的本地时刻。夏令时跳过的时刻（如春季的 02:30）按跳过的时长顺延，
+// 回拨后重复出现的时刻取第一次
+func localTime(year int, month time.Month, day, hour, min int, loc *time.Location) time.Time {
+	t := time.Date(year, month, day, hour, min, 0, 0, loc)
+	if t.Hour() != hour || t.Minute() != min {
+		_, offBefore := t.Zone()
+		_, offAfter := t.Add(3 * time.Hour).Zone()
+		return t.Add(time.Duration(offAfter-offBefore) * time.Second)
+	}
+	if earlier := t.Add(-time.Hour); earlier.Hour() == hour && earlier.Minute() == min {
+		return earlier
+	}
+	return t
+}
+
+// 计算 after 之后的下一次执行时间，按目标时区逐日构造本地时刻，每天只执行一次
+func (s Schedule) next(after time.Time) (time.Time, error) {
+	loc, err := loadLocation(s.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if s.Cron != "" {
+		c, err := parseCron(s.Cron)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return c.next(after, loc)
+	}
+	minute, err := parseClock(s.At)
+	if err != nil {
+		return time.Time{}, err
+	}
+	days, err := expandDays(s.Days)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	local := after.In(loc)
+	for i := 0; i <= 7; i++ {
+		day := local.AddDate(0, 0, i)
+		candidate := localTime(day.Year(), day.Month(), day.Day(), minute/60, minute%60, loc)
+		if !candidate.After(after) {
+			continue
+		}
+		if len(days) > 0 && !days[candidate.Weekday()] {
+			continue
+		}
+		return candidate, nil
+	}
+	return time.Time{}, fmt.Errorf("%s", tr("schedule.never", s.Name))
+}
+
+func (s Schedule) label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	if s.Cron != "" {
+		return s.Cron
+	}
+	return s.At
+}
+
+// 校验全部定时任务
+func validateSchedules() error {
+	for _, s := range config.Schedules {
+		if _, err := s.next(time.Now()); err != nil {
+			return fmt.Errorf("%s: %v", s.label(), err)
+		}
+		if s.IPv6FirewallEnable != "" && s.IPv6FirewallEnable != "on" && s.IPv6FirewallEnable != "off" {
+			return fmt.Errorf("%s", tr("schedule.bad_firewall", s.label()))
+		}
+		if s.DmzEnable != "" && s.DmzEnable != "0" && s.DmzEnable != "1" {
+			return fmt.Errorf("%s", tr("schedule.bad_dmz", s.label()))
+		}
+	}
+	return nil
+}
+
+// 最近一次将要执行的定时任务
+func nextScheduled(now time.Time) (Schedule, time.Time, bool) {
+	cfg := globalConfig{}.Get()
+	var (
+		best     Schedule
+		bestTime time.Time
+		found    bool
+	)
+	for _, s := range cfg.Schedules {
+		t, err := s.next(now)
+		if err != nil {
+			continue
+		}
+		if !found || t.Before(bestTime) {
+			best, bestTime, found = s, t, true
+		}
+	}
+	return best, bestTime, found
+}
+
+// 执行一个定时任务
+func runSchedule(s Schedule) {
+	_, err := applyUpdate(currentBackend(), globalConfig{}, func(c *Config) {
+		if s.IPv6FirewallEnable != "" {
+			c.IPv6FirewallEnable = s.IPv6FirewallEnable
+		}
+		if s.DmzEnable != "" {
+			c.DmzEnable = s.DmzEnable
+		}
+	}, nil, sourceScheduler, "", allSections)
+	msg := tr("console.schedule_done", s.label())
+	if err != nil {
+		msg = tr("console.schedule_failed", s.label(), err)
+	}
+	logf("%s\n", msg)
+	recordEvent("schedule", msg, map[string]interface{}{"schedule": s.label(), "success": err == nil})
+}
+
+// 后台执行定时任务，stop 关闭时退出
+func runScheduler(stop <-chan struct{}) {
+	for {
+		now := time.Now()
+		s, at, ok := nextScheduled(now)
+		if !ok {
+			return
+		}
+		sayDebug("debug.next_schedule", s.label(), at.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		// 同一时刻可能有多个任务
+		for _, other := range (globalConfig{}).Get().Schedules {
+			if t, err := other.next(now); err == nil && t.Equal(at) {
+				runSchedule(other)
+			}
+		}
+	}
+}
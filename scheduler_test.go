@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	sh, _ := time.LoadLocation("Asia/Shanghai")
+	ny, _ := time.LoadLocation("America/New_York")
+	cases := []struct {
+		s     Schedule
+		after time.Time
+		want  time.Time
+	}{
+		// 2026-01-05 是周一
+		{Schedule{At: "08:00", Days: []string{"weekends"}, Timezone: "Asia/Shanghai"},
+			time.Date(2026, 1, 5, 0, 0, 0, 0, sh), time.Date(2026, 1, 10, 8, 0, 0, 0, sh)},
+		{Schedule{At: "08:00", Days: []string{"workdays"}, Timezone: "Asia/Shanghai"},
+			time.Date(2026, 1, 9, 9, 0, 0, 0, sh), time.Date(2026, 1, 12, 8, 0, 0, 0, sh)},
+		// 星期按计划的时区判断：纽约周一23点在UTC已是周二
+		{Schedule{At: "23:00", Days: []string{"mon"}, Timezone: "America/New_York"},
+			time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC), time.Date(2026, 1, 5, 23, 0, 0, 0, ny)},
+		// 春季跳过的 02:30 顺延一小时，回拨重复的 01:30 只执行一次
+		{Schedule{At: "02:30", Timezone: "America/New_York"},
+			time.Date(2026, 3, 8, 0, 0, 0, 0, ny), time.Date(2026, 3, 8, 3, 30, 0, 0, ny)},
+		{Schedule{At: "01:30", Timezone: "America/New_York"},
+			time.Date(2026, 11, 1, 1, 30, 0, 0, ny), time.Date(2026, 11, 2, 1, 30, 0, 0, ny)},
+		{Schedule{Cron: "0 8 * * sat,sun", Timezone: "Asia/Shanghai"},
+			time.Date(2026, 1, 5, 0, 0, 0, 0, sh), time.Date(2026, 1, 10, 8, 0, 0, 0, sh)},
+	}
+	for _, c := range cases {
+		got, err := c.s.next(c.after)
+		if err != nil || !got.Equal(c.want) {
+			t.Errorf("%+v.next(%v) = %v, %v; want %v", c.s, c.after, got, err, c.want)
+		}
+	}
+
+	for _, bad := range []Schedule{
+		{At: "08:00", Days: []string{"someday"}, Timezone: "UTC"},
+		{At: "08:00", Timezone: "Mars/Olympus"},
+		{At: "25:00", Timezone: "UTC"},
+	} {
+		if _, err := bad.next(time.Now()); err == nil {
+			t.Errorf("%+v 应报错", bad)
+		}
+	}
+}
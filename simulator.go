@@ -0,0 +1,472 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"tplinkfirewalloff/pkg/tplink"
+)
+
+// 模拟路由器，实现stok登录与 /ds 的 get/set 语义
+type Simulator struct {
+	Password    string        // 管理员密码
+	StokTTL     time.Duration // stok有效期，0表示永不过期
+	ClockOffset time.Duration // 模拟路由器时钟偏差
+
+	mu      sync.Mutex
+	stoks   map[string]time.Time
+	state   map[string]map[string]map[string]interface{}   // 模块 -> 节 -> 字段
+	tables  map[string]map[string][]map[string]interface{} // 模块 -> 表 -> 条目
+	nextID  int
+	Applied int // 成功的set次数
+	Reboots int // 收到的重启命令次数
+}
+
+// 创建模拟路由器，初始状态与出厂设置一致：IPv6防火墙开启、DMZ关闭
+func NewSimulator(password string) *Simulator {
+	return &Simulator{
+		Password: password,
+		stoks:    map[string]time.Time{},
+		state: map[string]map[string]map[string]interface{}{
+			"firewall": {
+				"dmz": {
+					"enable":   "0",
+					"dest_ip":  "",
+					"wan_port": "0",
+					"dest_ip6": "",
+				},
+				"ipv6_firewall": {
+					"enable": "on",
+				},
+			},
+			"access_control": {},
+			"network": {
+				"wan_ipv6": {"ip6addr": "240e:370:1234:5678::1", "prefix": "240e:370:1234:5600::/56"},
+			},
+			// 易展组网角色，未组网的路由器同样报告为主路由
+			"hyfi": {
+				"info": {"role": "master", "master_ip": ""},
+			},
+			"device_info": {
+				"info": {"device_model": "TL-SIM1000", "hw_version": "1.0", "sw_version": "1.0.0", "mac": "00-0A-EB-00-00-01"},
+			},
+			"traffic": {
+				"wan": {"tx_bytes": "0", "rx_bytes": "0"},
+			},
+			"system": {
+				"time": {"ntp_enable": "on", "ntp_server1": "cn.pool.ntp.org", "ntp_server2": "", "timezone": "+08:00"},
+			},
+			"dhcpd": {
+				"udhcpd": {
+					"enable": "on", "pool_start": "192.168.0.100", "pool_end": "192.168.0.199",
+					"lease_time": "120", "gateway": "", "pri_dns": "", "snd_dns": "",
+				},
+			},
+			"wireless": {
+				"wlan_host_2g": {"enable": "on", "ssid": "TP-LINK_2.4G"},
+				"wlan_host_5g": {"enable": "on", "ssid": "TP-LINK_5G"},
+			},
+			"iptv": {
+				"iptv": {
+					"enable": "off", "mode": "bridge", "igmp_snooping": "on",
+					"internet_vid": "0", "internet_prio": "0", "iptv_vid": "0", "iptv_prio": "0",
+					"lan1": "internet", "lan2": "internet", "lan3": "internet", "lan4": "internet",
+				},
+			},
+			"guest_network": {
+				"guest_2g": {"enable": "off", "ssid": "TP-LINK_Guest", "isolate": "on", "access_lan": "off"},
+				"guest_5g": {"enable": "off", "ssid": "TP-LINK_Guest_5G", "isolate": "on", "access_lan": "off"},
+			},
+		},
+		tables: map[string]map[string][]map[string]interface{}{
+			"firewall": {
+				"ipv6_rule":    {}, // IPv6防火墙放行规则
+				"redirect":     {}, // IPv4端口转发（虚拟服务器）
+				"port_trigger": {}, // 端口触发
+				// 第二个WAN口的DMZ，模拟双WAN机型
+				"wan_dmz": {{"name": "wan_dmz_wan2", "wan_port": "1", "enable": "0", "dest_ip": "", "dest_ip6": ""}},
+			},
+			"access_control": {
+				"rule": {}, // 按MAC的访问控制规则
+			},
+			"network": {
+				"static_route": {}, // 静态路由
+			},
+			"dhcpd": {
+				"dhcp_static": {}, // DHCP地址保留
+			},
+			"traffic": {
+				"host_stats": {}, // 各主机累计流量
+			},
+		},
+	}
+}
+
+// 模拟主机产生流量，同时计入WAN口计数器
+func (s *Simulator) AddTraffic(ip string, bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wan := s.state["traffic"]["wan"]
+	wan["rx_bytes"] = fmt.Sprint(counterValue(wan["rx_bytes"]) + bytes)
+	for _, e := range s.tables["traffic"]["host_stats"] {
+		if e["ip"] == ip {
+			e["rx_bytes"] = fmt.Sprint(counterValue(e["rx_bytes"]) + bytes)
+			return
+		}
+	}
+	s.tables["traffic"]["host_stats"] = append(s.tables["traffic"]["host_stats"], map[string]interface{}{
+		"name": "host_" + ip, "ip": ip, "tx_bytes": "0", "rx_bytes": fmt.Sprint(bytes),
+	})
+}
+
+// 读取某张表的全部条目
+func (s *Simulator) Table(module, table string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []map[string]interface{}
+	for _, entry := range s.tables[module][table] {
+		out = append(out, copyFields(entry))
+	}
+	return out
+}
+
+// 启动基于httptest的模拟路由器，供自动化测试使用，调用方负责Close
+func StartTestSimulator(password string) (*Simulator, *httptest.Server) {
+	sim := NewSimulator(password)
+	return sim, httptest.NewServer(sim)
+}
+
+// 直接签发一个有效stok，便于测试跳过登录
+func (s *Simulator) IssueStok() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	stok := hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	s.stoks[stok] = time.Now()
+	s.mu.Unlock()
+	return stok
+}
+
+// 使所有stok失效，模拟路由器重启或会话超时
+func (s *Simulator) ExpireStoks() {
+	s.mu.Lock()
+	s.stoks = map[string]time.Time{}
+	s.mu.Unlock()
+}
+
+// 读取当前某个字段的值
+func (s *Simulator) Get(module, section, field string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state[module][section][field]
+}
+
+func (s *Simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req map[string]interface{}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil || json.Unmarshal(body, &req) != nil {
+		writeSimResponse(w, map[string]interface{}{"error_code": codeInvalidParam})
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/" || strings.HasPrefix(r.URL.Path, "/login"):
+		writeSimResponse(w, s.login(req))
+	case strings.HasPrefix(r.URL.Path, "/stok=") && strings.HasSuffix(r.URL.Path, "/ds"):
+		stok := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/stok="), "/ds")
+		if !s.validStok(stok) {
+			writeSimResponse(w, map[string]interface{}{"error_code": codeUnauthorized})
+			return
+		}
+		writeSimResponse(w, s.ds(req))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// 处理 {"method":"do","login":{"password":"..."}}，密码为网页编码后的形式
+func (s *Simulator) login(req map[string]interface{}) map[string]interface{} {
+	login, ok := req["login"].(map[string]interface{})
+	if !ok || req["method"] != "do" {
+		return map[string]interface{}{"error_code": codeUnsupported}
+	}
+	if password, _ := login["password"].(string); password != tplink.EncodePassword(s.Password) {
+		return map[string]interface{}{"error_code": codeBadCredentials}
+	}
+	return map[string]interface{}{"stok": s.IssueStok(), "error_code": codeOK}
+}
+
+func (s *Simulator) validStok(stok string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	issued, ok := s.stoks[stok]
+	if !ok {
+		return false
+	}
+	if s.StokTTL > 0 && time.Since(issued) > s.StokTTL {
+		delete(s.stoks, stok)
+		return false
+	}
+	return true
+}
+
+// 处理 /ds 的 get/set 请求
+func (s *Simulator) ds(req map[string]interface{}) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	method, _ := req["method"].(string)
+	switch method {
+	case "get":
+		resp := map[string]interface{}{"error_code": codeOK}
+		for module, v := range req {
+			if module == "method" {
+				continue
+			}
+			sections, ok := s.state[module]
+			if !ok {
+				return map[string]interface{}{"error_code": codeUnsupported}
+			}
+			// 表查询格式: {"firewall":{"table":"redirect"}}
+			if arg, ok := v.(map[string]interface{}); ok {
+				if table, ok := arg["table"].(string); ok {
+					entries, ok := s.tables[module][table]
+					if !ok {
+						return map[string]interface{}{"error_code": codeUnsupported}
+					}
+					list := []interface{}{}
+					for _, entry := range entries {
+						list = append(list, copyFields(entry))
+					}
+					resp[module] = map[string]interface{}{table: list}
+					continue
+				}
+			}
+			// 节查询格式: {"firewall":{"name":["dmz","ipv6_firewall"]}}
+			names := []interface{}{}
+			if arg, ok := v.(map[string]interface{}); ok {
+				names, _ = arg["name"].([]interface{})
+			}
+			out := map[string]interface{}{}
+			for _, n := range names {
+				name, _ := n.(string)
+				section, ok := sections[name]
+				if !ok {
+					return map[string]interface{}{"error_code": codeUnsupported}
+				}
+				fields := copyFields(section)
+				if module == "system" && name == "time" {
+					fields["timestamp"] = fmt.Sprint(time.Now().Add(s.ClockOffset).Unix())
+				}
+				out[name] = fields
+			}
+			resp[module] = out
+		}
+		return resp
+
+	case "set":
+		// 表条目修改格式: {"method":"set","firewall":{"table":"redirect","filter":[{"name":"redirect_1"}],"para":{...}}}
+		for module, v := range req {
+			if arg, ok := v.(map[string]interface{}); ok && arg["table"] != nil {
+				return s.setTableEntry(module, arg)
+			}
+		}
+		// 先整体校验，避免部分写入
+		for module, v := range req {
+			if module == "method" {
+				continue
+			}
+			sections, ok := s.state[module]
+			arg, isMap := v.(map[string]interface{})
+			if !ok || !isMap {
+				return map[string]interface{}{"error_code": codeUnsupported}
+			}
+			for name, fields := range arg {
+				section, ok := sections[name]
+				values, isMap := fields.(map[string]interface{})
+				if !ok || !isMap {
+					return map[string]interface{}{"error_code": codeUnsupported}
+				}
+				for field, value := range values {
+					if _, known := section[field]; !known || !validSimValue(name, field, value) {
+						return map[string]interface{}{"error_code": codeInvalidParam}
+					}
+				}
+			}
+		}
+		for module, v := range req {
+			if module == "method" {
+				continue
+			}
+			for name, fields := range v.(map[string]interface{}) {
+				for field, value := range fields.(map[string]interface{}) {
+					s.state[module][name][field] = value
+				}
+			}
+		}
+		s.Applied++
+		return map[string]interface{}{"error_code": codeOK}
+
+	case "do":
+		// 重启: {"method":"do","system":{"reboot":null}}，防火墙恢复为出厂状态
+		if system, ok := req["system"].(map[string]interface{}); ok {
+			if _, ok := system["reboot"]; ok {
+				s.Reboots++
+				s.state["firewall"]["ipv6_firewall"]["enable"] = "on"
+				s.state["firewall"]["dmz"]["enable"] = "0"
+				return map[string]interface{}{"error_code": codeOK}
+			}
+		}
+		return map[string]interface{}{"error_code": codeUnsupported}
+
+	case "add", "delete":
+		// 格式: {"method":"add","firewall":{"table":"redirect","para":{...}}}
+		//      {"method":"delete","firewall":{"table":"redirect","filter":[{"name":"redirect_1"}]}}
+		for module, v := range req {
+			if module == "method" {
+				continue
+			}
+			arg, _ := v.(map[string]interface{})
+			table, _ := arg["table"].(string)
+			entries, ok := s.tables[module][table]
+			if !ok {
+				return map[string]interface{}{"error_code": codeUnsupported}
+			}
+			if method == "add" {
+				para, ok := arg["para"].(map[string]interface{})
+				if !ok {
+					return map[string]interface{}{"error_code": codeInvalidParam}
+				}
+				entry := copyFields(para)
+				if _, ok := entry["name"]; !ok {
+					s.nextID++
+					entry["name"] = fmt.Sprintf("%s_%d", table, s.nextID)
+				}
+				s.tables[module][table] = append(entries, entry)
+				continue
+			}
+			filters, _ := arg["filter"].([]interface{})
+			kept := entries[:0]
+			for _, entry := range entries {
+				matched := false
+				for _, f := range filters {
+					if fm, ok := f.(map[string]interface{}); ok && fm["name"] == entry["name"] {
+						matched = true
+					}
+				}
+				if !matched {
+					kept = append(kept, entry)
+				}
+			}
+			s.tables[module][table] = kept
+		}
+		s.Applied++
+		return map[string]interface{}{"error_code": codeOK}
+	}
+
+	return map[string]interface{}{"error_code": codeUnsupported}
+}
+
+// 按名称修改表中条目，调用方已持有锁
+func (s *Simulator) setTableEntry(module string, arg map[string]interface{}) map[string]interface{} {
+	table, _ := arg["table"].(string)
+	entries, ok := s.tables[module][table]
+	para, isMap := arg["para"].(map[string]interface{})
+	filters, _ := arg["filter"].([]interface{})
+	if !ok {
+		return map[string]interface{}{"error_code": codeUnsupported}
+	}
+	if !isMap || len(filters) == 0 {
+		return map[string]interface{}{"error_code": codeInvalidParam}
+	}
+	found := false
+	for _, entry := range entries {
+		for _, f := range filters {
+			if fm, ok := f.(map[string]interface{}); ok && fm["name"] == entry["name"] {
+				for k, v := range para {
+					entry[k] = v
+				}
+				found = true
+			}
+		}
+	}
+	if !found {
+		return map[string]interface{}{"error_code": codeInvalidParam}
+	}
+	s.Applied++
+	return map[string]interface{}{"error_code": codeOK}
+}
+
+// 校验与真实固件一致的取值范围
+func validSimValue(section, field string, value interface{}) bool {
+	str, ok := value.(string)
+	if !ok {
+		return false
+	}
+	switch section + "." + field {
+	case "ipv6_firewall.enable":
+		return str == "on" || str == "off"
+	case "dmz.enable":
+		return str == "0" || str == "1"
+	}
+	if section == "iptv" {
+		return validIPTVField(field, str)
+	}
+	if (strings.HasPrefix(section, "guest_") || strings.HasPrefix(section, "wlan_host_")) && field != "ssid" {
+		return str == "on" || str == "off"
+	}
+	return true
+}
+
+func copyFields(fields map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		out[k] = v
+	}
+	return out
+}
+
+func writeSimResponse(w http.ResponseWriter, resp map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// simulator 子命令：独立运行模拟路由器，便于在不接触真实设备的情况下试用
+func runSimulator(args []string) error {
+	fs := flag.NewFlagSet("simulator", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8090", tr("flag.sim_addr"))
+	password := fs.String("password", "admin", tr("flag.sim_password"))
+	ttl := fs.Duration("stok-ttl", 0, tr("flag.sim_stok_ttl"))
+	primary := fs.String("mesh-primary", "", tr("flag.sim_mesh_primary"))
+	fs.Parse(args)
+
+	sim := NewSimulator(*password)
+	sim.StokTTL = *ttl
+	if *primary != "" {
+		sim.state["hyfi"]["info"] = map[string]interface{}{"role": "slave", "master_ip": *primary}
+	}
+	stok := sim.IssueStok()
+
+	say("console.simulator_started", *addr)
+	// 模拟器的stok需要原样显示给用户，不经过脱敏
+	fmt.Println(tr("console.simulator_usage", *addr, stok))
+	return http.ListenAndServe(*addr, sim)
+}
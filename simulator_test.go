@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"tplinkfirewalloff/internal/configstore"
+)
+
+// 对模拟路由器完整走一遍：用密码登录、设置、stok失效后重新登录再设置
+func TestApplyAgainstSimulator(t *testing.T) {
+	sim, router := StartTestSimulator("admin")
+	t.Cleanup(router.Close)
+	store := &configstore.Memory{}
+	store.Save(map[string]interface{}{
+		"router_ip":       strings.TrimPrefix(router.URL, "http://"),
+		"router_password": "admin",
+		"state_file":      "",
+		"history_file":    "",
+		"rate_limit":      0,
+		"retry":           map[string]interface{}{"attempts": 1},
+	})
+	if err := setupFrom(store); err != nil {
+		t.Fatal(err)
+	}
+
+	desired := firewallState{IPv6FirewallEnable: "off", DmzEnable: "1", DmzDestIP: "192.168.1.100"}
+	if _, err := applyDesired(desired, sourceCtl, "", allSections); err != nil {
+		t.Fatal(err)
+	}
+	if got := sim.Get("firewall", "ipv6_firewall", "enable"); got != "off" {
+		t.Errorf("ipv6_firewall.enable = %v", got)
+	}
+	if got := sim.Get("firewall", "dmz", "dest_ip"); got != "192.168.1.100" {
+		t.Errorf("dmz.dest_ip = %v", got)
+	}
+
+	sim.ExpireStoks()
+	desired.IPv6FirewallEnable = "on"
+	if _, err := applyDesired(desired, sourceCtl, "", allSections); err != nil {
+		t.Fatalf("stok失效后没有重新登录: %v", err)
+	}
+	if got := sim.Get("firewall", "ipv6_firewall", "enable"); got != "on" || sim.Applied != 2 {
+		t.Errorf("ipv6_firewall.enable = %v, applied = %d", got, sim.Applied)
+	}
+	if current, err := refreshConfirmedState(currentBackend()); err != nil || !current.Matches(desired) {
+		t.Errorf("读回的状态 = %+v, %v", current, err)
+	}
+}
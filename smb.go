@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+// 商用 ER/TL-R 系列（如 TL-R470GP-AC、TL-ER3220G）的 /ds 接口按表读写：
+//
+//	读：{"method":"get","firewall":{"table":"dmz"}}，响应中每张表是条目数组
+//	写：{"method":"set","firewall":{"table":"dmz","para":{...}}}，一次只能写一张表
+//
+// 把家用固件形式的请求逐节转换后发送，再合并为家用固件形式的响应
+func smbDS(host, stok string, requestBody map[string]interface{}) ([]byte, error) {
+	method, _ := requestBody["method"].(string)
+	var module string
+	var sections interface{}
+	for k, v := range requestBody {
+		if k != "method" {
+			if module != "" {
+				// 一次请求多个模块，ER系列不支持
+				return mustJSON(map[string]interface{}{"error_code": codeUnsupported}), nil
+			}
+			module, sections = k, v
+		}
+	}
+	if module == "" || (method != "get" && method != "set") {
+		return postDS(host, stok, requestBody)
+	}
+
+	merged := map[string]interface{}{}
+	call := func(table string, para interface{}) ([]map[string]interface{}, error) {
+		req := map[string]interface{}{"table": table}
+		if para != nil {
+			req["para"] = para
+		}
+		raw, err := postDS(host, stok, map[string]interface{}{"method": method, module: req})
+		if err != nil {
+			return nil, err
+		}
+		resp, err := decodeRouterResponse(raw)
+		if err != nil {
+			return nil, err
+		}
+		var tables map[string][]map[string]interface{}
+		json.Unmarshal(resp[module], &tables)
+		return tables[table], nil
+	}
+
+	switch method {
+	case "get":
+		// {"module":{"name":["a","b"]}}
+		var spec struct {
+			Name []string `json:"name"`
+		}
+		json.Unmarshal(mustJSON(sections), &spec)
+		for _, table := range spec.Name {
+			entries, err := call(table, nil)
+			if err != nil {
+				return smbError(err)
+			}
+			if len(entries) > 0 {
+				merged[table] = entries[0]
+			} else {
+				merged[table] = map[string]interface{}{}
+			}
+		}
+	case "set":
+		// {"module":{"a":{...},"b":{...}}}
+		spec, _ := sections.(map[string]interface{})
+		for table, para := range spec {
+			if _, err := call(table, para); err != nil {
+				return smbError(err)
+			}
+		}
+	}
+	resp := map[string]interface{}{"error_code": codeOK}
+	if method == "get" {
+		resp[module] = merged
+	}
+	return mustJSON(resp), nil
+}
+
+// 把子请求中路由器返回的 error_code 原样放回响应，连接错误等直接返回
+func smbError(err error) ([]byte, error) {
+	if re, ok := err.(*RouterError); ok && re.Code != 0 {
+		return mustJSON(map[string]interface{}{"error_code": re.Code}), nil
+	}
+	return nil, err
+}
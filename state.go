@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"tplinkfirewalloff/internal/router"
+	"tplinkfirewalloff/pkg/tplink"
+)
+
+// 防火墙设置与写入部分的类型定义在 router 包中
+type (
+	firewallState = router.State
+	applySections = router.Sections
+)
+
+var (
+	allSections = router.AllSections
+	sectionsFor = router.SectionsFor
+)
+
+// 把 fs 中 sections 选中的部分写入配置
+func assignSections(c *Config, fs firewallState, sections applySections) {
+	if sections.Firewall {
+		c.IPv6FirewallEnable = fs.IPv6FirewallEnable
+	}
+	if sections.DMZ {
+		c.DmzEnable, c.DmzDestIP, c.DmzDestIP6 = fs.DmzEnable, fs.DmzDestIP, fs.DmzDestIP6
+	}
+}
+
+// 同步状态
+const (
+	syncInSync  = "in_sync"
+	syncDrifted = "drifted"
+	syncUnknown = "unknown"
+)
+
+// 持久化的期望状态与最近一次确认的路由器状态
+type trackedState struct {
+	Desired        *firewallState    `json:"desired,omitempty"`
+	DesiredAt      time.Time         `json:"desired_at,omitempty"`
+	Confirmed      *firewallState    `json:"confirmed,omitempty"`
+	ConfirmedAt    time.Time         `json:"confirmed_at,omitempty"`
+	LastApplyAt    time.Time         `json:"last_apply_at,omitempty"`
+	LastApplyError string            `json:"last_apply_error,omitempty"`
+	Traffic        trafficUsage      `json:"traffic"`
+	Identity       *RouterIdentity   `json:"identity,omitempty"`       // 记住的路由器身份
+	WANIPv6        string            `json:"wan_ipv6,omitempty"`       // 路由器WAN口IPv6地址
+	RouterMove     *routerMove       `json:"router_move,omitempty"`    // 检测到的路由器地址变更
+	ResolvedHosts  map[string]string `json:"resolved_hosts,omitempty"` // router_ip 为主机名时上次解析到的IP
+	Previous       *firewallState    `json:"previous,omitempty"`       // 上次修改前路由器的设置，用于撤销
+	PreviousAt     time.Time         `json:"previous_at,omitempty"`
+}
+
+var (
+	trackedMu sync.Mutex
+	tracked   trackedState
+)
+
+// 当前配置中的期望状态
+func desiredFromConfig() firewallState {
+	return globalConfig{}.Get().desired()
+}
+
+// 配置中的期望状态
+func (c Config) desired() firewallState {
+	return firewallState{
+		IPv6FirewallEnable: c.IPv6FirewallEnable,
+		DmzEnable:          c.DmzEnable,
+		DmzDestIP:          c.DmzDestIP,
+		DmzDestIP6:         c.DmzDestIP6,
+	}
+}
+
+// 从路由器 firewall 模块的查询结果中取出设置值
+func stateFromRouter(st sectionState) firewallState {
+	str := func(section, field string) string {
+		v, _ := st[section][field].(string)
+		return v
+	}
+	return firewallState{
+		IPv6FirewallEnable: str("ipv6_firewall", "enable"),
+		DmzEnable:          str("dmz", "enable"),
+		DmzDestIP:          str("dmz", "dest_ip"),
+		DmzDestIP6:         str("dmz", "dest_ip6"),
+	}
+}
+
+// pkg/tplink 读到的设置，路由器未返回的部分为空
+func stateFromFirewall(fw tplink.Firewall) firewallState {
+	var fs firewallState
+	if fw.IPv6Firewall != nil {
+		fs.IPv6FirewallEnable = fw.IPv6Firewall.Enable
+	}
+	if fw.DMZ != nil {
+		fs.DmzEnable, fs.DmzDestIP, fs.DmzDestIP6 = fw.DMZ.Enable, fw.DMZ.DestIP, fw.DMZ.DestIP6
+	}
+	return fs
+}
+
+// 经 client 读取路由器当前的IPv6防火墙与DMZ设置
+func getState(client RouterClient) (firewallState, error) {
+	var fs firewallState
+	err := withRetry("get", func() (err error) {
+		fs, err = client.GetFirewall()
+		return err
+	})
+	return fs, err
+}
+
+// 表单预填的值：以路由器当前设置为准，路由器未返回的字段用配置中的值
+func formState(current firewallState) firewallState {
+	fs := desiredFromConfig()
+	if current.IPv6FirewallEnable != "" {
+		fs.IPv6FirewallEnable = current.IPv6FirewallEnable
+	}
+	if current.DmzEnable != "" {
+		fs.DmzEnable = current.DmzEnable
+	}
+	if current.DmzDestIP != "" {
+		fs.DmzDestIP = current.DmzDestIP
+	}
+	if current.DmzDestIP6 != "" {
+		fs.DmzDestIP6 = current.DmzDestIP6
+	}
+	return fs
+}
+
+// 读取路由器当前状态并记为已确认状态
+func refreshConfirmedState(client RouterClient) (firewallState, error) {
+	fs, err := getState(client)
+	if err != nil {
+		return firewallState{}, err
+	}
+	confirmState(fs)
+	return fs, nil
+}
+
+// 把已读到的路由器状态记为已确认状态
+func confirmState(fs firewallState) {
+	wan := queryWANIPv6()
+	trackedMu.Lock()
+	tracked.Confirmed = &fs
+	tracked.ConfirmedAt = time.Now()
+	tracked.WANIPv6 = wan
+	trackedMu.Unlock()
+	saveTrackedState()
+}
+
+// 记录一次设置的结果；成功时更新期望状态
+func recordApply(desired firewallState, applyErr error) {
+	trackedMu.Lock()
+	tracked.LastApplyAt = time.Now()
+	tracked.LastApplyError = ""
+	if applyErr != nil {
+		tracked.LastApplyError = redact(applyErr.Error())
+	} else {
+		tracked.Desired = &desired
+		tracked.DesiredAt = tracked.LastApplyAt
+	}
+	trackedMu.Unlock()
+	saveTrackedState()
+}
+
+// 路由器已是目标状态、未发送设置时，同样更新期望状态与确认状态
+func recordUnchanged(current firewallState) {
+	trackedMu.Lock()
+	desired := desiredFromConfig()
+	tracked.Desired = &desired
+	tracked.DesiredAt = time.Now()
+	tracked.Confirmed = &current
+	tracked.ConfirmedAt = tracked.DesiredAt
+	trackedMu.Unlock()
+	saveTrackedState()
+}
+
+// 快照与同步状态
+func trackedSnapshot() (trackedState, string) {
+	trackedMu.Lock()
+	defer trackedMu.Unlock()
+
+	s := tracked
+	switch {
+	case s.Desired == nil || s.Confirmed == nil:
+		return s, syncUnknown
+	case s.Desired.Matches(*s.Confirmed):
+		return s, syncInSync
+	}
+	return s, syncDrifted
+}
+
+func loadTrackedState() {
+	cfg := globalConfig{}.Get()
+	if cfg.StateFile == "" {
+		return
+	}
+	data, err := os.ReadFile(cfg.StateFile)
+	if err != nil {
+		return
+	}
+	trackedMu.Lock()
+	defer trackedMu.Unlock()
+	if err := json.Unmarshal(data, &tracked); err != nil {
+		warn("console.state_load_failed", err)
+	}
+}
+
+// 先写临时文件再改名，避免中途退出留下损坏的文件
+func saveTrackedState() {
+	cfg := globalConfig{}.Get()
+	exportStatus()
+	if cfg.StateFile == "" {
+		return
+	}
+	trackedMu.Lock()
+	data, err := json.MarshalIndent(tracked, "", "  ")
+	trackedMu.Unlock()
+	if err != nil {
+		return
+	}
+	tmp := cfg.StateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		warn("console.state_save_failed", err)
+		return
+	}
+	if err := os.Rename(tmp, cfg.StateFile); err != nil {
+		warn("console.state_save_failed", err)
+	}
+}
+
+// /api/status：期望状态、路由器确认状态与同步情况
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	var refreshErr string
+	if routerConfigured() {
+		if _, err := refreshConfirmedState(currentBackend()); err != nil {
+			refreshErr = redact(err.Error())
+		}
+	}
+	s, sync := trackedSnapshot()
+
+	resp := map[string]interface{}{
+		"sync":         sync,
+		"state":        s,
+		"capabilities": currentBackend().Capabilities(),
+	}
+	if w := activeMaintenance(time.Now()); w != nil {
+		resp["maintenance_window"] = w.String()
+	}
+	if s, at, ok := nextScheduled(time.Now()); ok {
+		resp["next_schedule"] = map[string]interface{}{"name": s.label(), "at": at.Format(time.RFC3339)}
+	}
+	if refreshErr != "" {
+		resp["refresh_error"] = refreshErr
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(resp)
+}
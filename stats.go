@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 从历史记录汇总出的统计
+type historyStats struct {
+	Since             time.Time `json:"since"`
+	Days              int       `json:"days"`
+	Applies           int       `json:"applies"`
+	ApplySuccesses    int       `json:"apply_successes"`
+	ApplySuccessRate  float64   `json:"apply_success_rate"` // 0~1，没有记录时为0
+	MeanApplyMs       float64   `json:"mean_apply_ms"`
+	WatchdogActions   int       `json:"watchdog_interventions"`
+	WatchdogPerWeek   float64   `json:"watchdog_interventions_per_week"`
+	PrefixRotations   int       `json:"prefix_rotations"`
+	PrefixRotPerWeek  float64   `json:"prefix_rotations_per_week"`
+	RouterOutages     int       `json:"router_outages"`
+	RouterDowntimeSec int       `json:"router_downtime_seconds"`
+}
+
+// 统计 days 天内的历史事件
+func computeStats(events []historyEvent, days int, now time.Time) historyStats {
+	since := now.AddDate(0, 0, -days)
+	st := historyStats{Since: since, Days: days}
+
+	var totalMs float64
+	for _, e := range events {
+		if e.Time.Before(since) {
+			continue
+		}
+		switch e.Kind {
+		case "apply":
+			st.Applies++
+			if ok, _ := e.Fields["success"].(bool); ok {
+				st.ApplySuccesses++
+			}
+			if ms, ok := e.Fields["duration_ms"].(float64); ok {
+				totalMs += ms
+			}
+		case "watchdog":
+			st.WatchdogActions++
+		case "target_ipv6_gone", "prefix_changed":
+			st.PrefixRotations++
+		case "router_down":
+			st.RouterOutages++
+		case "router_up":
+			if sec, ok := e.Fields["downtime_seconds"].(float64); ok {
+				st.RouterDowntimeSec += int(sec)
+			}
+		}
+	}
+
+	if st.Applies > 0 {
+		st.ApplySuccessRate = float64(st.ApplySuccesses) / float64(st.Applies)
+		st.MeanApplyMs = totalMs / float64(st.Applies)
+	}
+	weeks := float64(days) / 7
+	st.WatchdogPerWeek = float64(st.WatchdogActions) / weeks
+	st.PrefixRotPerWeek = float64(st.PrefixRotations) / weeks
+	return st
+}
+
+// 统计周期，默认30天
+func statsDays(r *http.Request) int {
+	if d, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && d > 0 && d <= 3650 {
+		return d
+	}
+	return 30
+}
+
+// /stats 页面与 /api/stats 接口
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	events, err := readHistory()
+	if err != nil {
+		http.Error(w, redact(err.Error()), http.StatusInternalServerError)
+		return
+	}
+	st := computeStats(events, statsDays(r), time.Now())
+
+	if r.URL.Path == "/api/stats" {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(st)
+		return
+	}
+	renderTemplate(w, http.StatusOK, "stats.html", map[string]interface{}{
+		"Stats":   st,
+		"Timings": timingSnapshot(),
+	})
+}
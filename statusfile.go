@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 导出给外部脚本读取的状态
+type exportedStatus struct {
+	RouterIP           string `json:"router_ip"`
+	Sync               string `json:"sync"`
+	IPv6FirewallEnable string `json:"ipv6_firewall_enable"`
+	DmzEnable          string `json:"dmz_enable"`
+	DmzDestIP          string `json:"dmz_dest_ip"`
+	DmzDestIP6         string `json:"dmz_dest_ip6"`
+	WANIPv6            string `json:"wan_ipv6"`
+	LastApplyAt        string `json:"last_apply_at"`
+	LastApplyError     string `json:"last_apply_error"`
+}
+
+var (
+	statusFileMu   sync.Mutex
+	lastStatusFile []byte
+)
+
+// 读取路由器WAN口的IPv6地址，固件不支持时返回空
+func queryWANIPv6() string {
+	st, err := queryRouter(routeModule, "wan_ipv6")
+	if err != nil {
+		return ""
+	}
+	addr, _ := st["wan_ipv6"]["ip6addr"].(string)
+	return addr
+}
+
+func currentExportedStatus() exportedStatus {
+	s, sync := trackedSnapshot()
+	e := exportedStatus{RouterIP: globalConfig{}.Get().RouterIP, Sync: sync, WANIPv6: s.WANIPv6, LastApplyError: s.LastApplyError}
+	// 优先使用路由器确认的状态
+	fs := s.Confirmed
+	if fs == nil {
+		fs = s.Desired
+	}
+	if fs != nil {
+		e.IPv6FirewallEnable, e.DmzEnable, e.DmzDestIP, e.DmzDestIP6 = fs.IPv6FirewallEnable, fs.DmzEnable, fs.DmzDestIP, fs.DmzDestIP6
+	}
+	if !s.LastApplyAt.IsZero() {
+		e.LastApplyAt = s.LastApplyAt.Format(time.RFC3339)
+	}
+	return e
+}
+
+// ini格式：每行 key=value，键按字母排序
+func (e exportedStatus) ini() []byte {
+	var fields map[string]string
+	data, _ := json.Marshal(e)
+	json.Unmarshal(data, &fields)
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString("[status]\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s=%s\n", k, fields[k])
+	}
+	return []byte(b.String())
+}
+
+// 状态有变化时写入 status_file，扩展名为 .ini 时使用ini格式
+func exportStatus() {
+	cfg := globalConfig{}.Get()
+	if cfg.StatusFile == "" {
+		return
+	}
+	e := currentExportedStatus()
+	var data []byte
+	if strings.EqualFold(filepath.Ext(cfg.StatusFile), ".ini") {
+		data = e.ini()
+	} else {
+		data, _ = json.MarshalIndent(e, "", "  ")
+	}
+
+	statusFileMu.Lock()
+	defer statusFileMu.Unlock()
+	if string(data) == string(lastStatusFile) {
+		return
+	}
+	tmp := cfg.StatusFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		warn("console.status_file_failed", err)
+		return
+	}
+	if err := os.Rename(tmp, cfg.StatusFile); err != nil {
+		warn("console.status_file_failed", err)
+		return
+	}
+	lastStatusFile = data
+}
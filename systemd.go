@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// 向systemd报告状态（sd_notify），未在 Type=notify 的服务中运行时什么也不做
+func sdNotify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	// 以@开头表示抽象命名空间
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		sayDebug("debug.sd_notify_failed", err)
+		return
+	}
+	defer conn.Close()
+	conn.Write([]byte(state))
+}
+
+// 是否作为后台服务运行：由systemd启动，或指定了 -daemon
+func runningAsService(daemon bool) bool {
+	return daemon || os.Getenv("NOTIFY_SOCKET") != "" || os.Getenv("INVOCATION_ID") != ""
+}
+
+const systemdUnitTemplate = `[Unit]
+Description=TP-LINK IPv6 firewall and DMZ manager
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=notify
+ExecStart=%s serve -daemon -config %s
+WorkingDirectory=%s
+Restart=on-failure
+RestartSec=10
+User=%s
+NoNewPrivileges=true
+ProtectSystem=full
+ProtectHome=read-only
+ReadWritePaths=%s
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// systemd-unit：输出可放到 /etc/systemd/system/ 的服务文件
+func runSystemdUnit(args []string) error {
+	fs := flag.NewFlagSet("systemd-unit", flag.ContinueOnError)
+	configPath := fs.String("config", "config.json", tr("flag.config"))
+	user := fs.String("user", "nobody", tr("flag.systemd_user"))
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	cfg, err := filepath.Abs(findConfig(*configPath))
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(cfg)
+	fmt.Printf(systemdUnitTemplate, exe, cfg, dir, *user, dir)
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+// 路由器表中的一个条目（端口转发、IPv6规则等），name 字段为条目标识
+type tableEntry map[string]interface{}
+
+// 读取路由器某模块下的一张表，如 queryTable("firewall", "redirect")
+func queryTable(module, table string) ([]tableEntry, error) {
+	value, err := queryCache.get(globalConfig{}.Get().RouterIP+"|"+module+"|table:"+table, func() (interface{}, error) {
+		responseBody, err := callRouter("get", map[string]interface{}{
+			"method": "get",
+			module:   map[string]interface{}{"table": table},
+		})
+		if err != nil {
+			return nil, err
+		}
+		resp, err := decodeRouterResponse(responseBody)
+		if err != nil {
+			return nil, err
+		}
+		var tables map[string][]tableEntry
+		if err := json.Unmarshal(resp[module], &tables); err != nil {
+			return nil, routerErr(ErrUnsupportedFirmware, 0, tr("response.missing_table", table))
+		}
+		return tables[table], nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]tableEntry), nil
+}
+
+// 向表中添加条目
+func addTableEntry(module, table string, para map[string]interface{}) error {
+	return modifyTable("add", map[string]interface{}{
+		"method": "add",
+		module:   map[string]interface{}{"table": table, "para": para},
+	})
+}
+
+// 按名称删除表中的条目
+func deleteTableEntry(module, table, name string) error {
+	return modifyTable("delete", map[string]interface{}{
+		"method": "delete",
+		module: map[string]interface{}{
+			"table":  table,
+			"filter": []interface{}{map[string]interface{}{"name": name}},
+		},
+	})
+}
+
+// 按名称修改表中条目的部分字段
+func updateTableEntry(module, table, name string, para map[string]interface{}) error {
+	return modifyTable("set", map[string]interface{}{
+		"method": "set",
+		module: map[string]interface{}{
+			"table":  table,
+			"filter": []interface{}{map[string]interface{}{"name": name}},
+			"para":   para,
+		},
+	})
+}
+
+// 修改某模块下一个节的部分字段，如 setSection("guest_network", "guest_2g", ...)
+func setSection(module, section string, fields map[string]interface{}) error {
+	return modifyTable("set", map[string]interface{}{
+		"method": "set",
+		module:   map[string]interface{}{section: fields},
+	})
+}
+
+func modifyTable(op string, requestBody map[string]interface{}) error {
+	if err := verifyRouterIdentity(); err != nil {
+		return err
+	}
+	responseBody, err := callRouter(op, requestBody)
+	if err != nil {
+		return err
+	}
+	if _, err := decodeRouterResponse(responseBody); err != nil {
+		return err
+	}
+	queryCache.invalidate()
+	return nil
+}
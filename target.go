@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 探测主机存活时尝试的端口；连接被拒绝同样说明主机在线
+var livenessPorts = []string{"80", "443", "22", "445", "3389"}
+
+// 判断主机是否在线：任一端口能连上或明确拒绝连接即视为在线
+func hostAlive(host string, timeout time.Duration) bool {
+	results := make(chan bool, len(livenessPorts))
+	for _, port := range livenessPorts {
+		go func(port string) {
+			conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), timeout)
+			if err == nil {
+				conn.Close()
+			}
+			results <- err == nil || connRefused(err)
+		}(port)
+	}
+	alive := false
+	for range livenessPorts {
+		if <-results {
+			alive = true
+		}
+	}
+	return alive
+}
+
+// 本机是否持有该地址；本工具运行在DMZ目标主机上时可直接确认地址归属
+func localAddress(ip string) bool {
+	target := net.ParseIP(ip)
+	if target == nil {
+		return false
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.Equal(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// 连接被拒绝；Windows下为 WSAECONNREFUSED(10061)
+func connRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.Errno(10061))
+}
+
+var (
+	targetsMu sync.Mutex
+	targets   = map[string]*availabilityTracker{}
+)
+
+// 每个DMZ目标地址一个跟踪器
+func targetTracker(addr string) *availabilityTracker {
+	targetsMu.Lock()
+	defer targetsMu.Unlock()
+
+	if t, ok := targets[addr]; ok {
+		return t
+	}
+	t := &availabilityTracker{
+		onDown: func(failures int) {
+			msg := tr("notify.target_down.detail", addr, failures)
+			notify("target_down", tr("notify.target_down"), msg)
+			recordEvent("target_down", msg, map[string]interface{}{"address": addr})
+		},
+		onUp: func(downtime time.Duration) {
+			msg := tr("notify.target_up.detail", addr, downtime)
+			notify("target_up", tr("notify.target_up"), msg)
+			recordEvent("target_up", msg, map[string]interface{}{
+				"address":          addr,
+				"downtime_seconds": int(downtime.Seconds()),
+			})
+		},
+	}
+	targets[addr] = t
+	return t
+}
+
+// 检查一次全部DMZ目标地址
+func checkTargets(timeout time.Duration, threshold int) {
+	cfg := globalConfig{}.Get()
+	alive := map[string]bool{}
+	for _, addr := range []string{cfg.DmzDestIP, cfg.DmzDestIP6} {
+		if addr == "" {
+			continue
+		}
+		// 本机就是目标时直接确认地址仍在网卡上
+		alive[addr] = localAddress(addr) || hostAlive(addr, timeout)
+		targetTracker(addr).observe(alive[addr], threshold)
+	}
+
+	// IPv4在线而IPv6无响应，通常是运营商前缀轮换后旧IPv6地址已不存在
+	if cfg.DmzDestIP != "" && cfg.DmzDestIP6 != "" && alive[cfg.DmzDestIP] && !alive[cfg.DmzDestIP6] {
+		if up, known, _ := targetTracker(cfg.DmzDestIP6).Status(); known && !up {
+			warnIPv6Gone()
+		}
+	} else {
+		ipv6GoneWarned = false
+	}
+}
+
+var ipv6GoneWarned bool
+
+// 同一次离线只提醒一次
+func warnIPv6Gone() {
+	cfg := globalConfig{}.Get()
+	if ipv6GoneWarned {
+		return
+	}
+	ipv6GoneWarned = true
+	msg := tr("notify.target_ipv6_gone.detail", cfg.DmzDestIP, cfg.DmzDestIP6)
+	notify("target_ipv6_gone", tr("notify.target_ipv6_gone"), msg)
+	recordEvent("target_ipv6_gone", msg, map[string]interface{}{"dest_ip": cfg.DmzDestIP, "dest_ip6": cfg.DmzDestIP6})
+}
+
+// 后台持续检查DMZ目标，stop 关闭时退出
+func runTargetMonitor(stop <-chan struct{}) {
+	cfg := globalConfig{}.Get()
+	interval := parseDurationOr(cfg.TargetMonitor.Interval, time.Minute)
+	timeout := parseDurationOr(cfg.TargetMonitor.Timeout, 3*time.Second)
+	threshold := cfg.TargetMonitor.Failures
+	if threshold < 1 {
+		threshold = 3
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		dmz := globalConfig{}.Get().DmzEnable
+		if dmz == "1" {
+			checkTargets(timeout, threshold)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates *template.Template
+
+// 模板中可用的辅助函数
+var templateFuncs = template.FuncMap{
+	"t": tr,
+	"duration": func(d time.Duration) string {
+		return d.Round(time.Second).String()
+	},
+	"percent": func(f float64) string {
+		return fmt.Sprintf("%.1f%%", f*100)
+	},
+	"mb": func(b int64) string {
+		return fmt.Sprintf("%.1f MB", float64(b)/(1<<20))
+	},
+	"seconds": func(s int) string {
+		return (time.Duration(s) * time.Second).String()
+	},
+	"datetime": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format("2006-01-02 15:04:05 MST")
+	},
+}
+
+// 启动时一次性解析全部模板，出错直接返回，避免运行中才发现模板错误
+func loadTemplates() error {
+	t, err := template.New("").Funcs(templateFuncs).ParseFS(templateFS, "templates/*.html")
+	if err != nil {
+		return err
+	}
+	templates = t
+	return nil
+}
+
+// 渲染模板，先写入缓冲区，执行失败时返回500而不是输出半个页面
+func renderTemplate(w http.ResponseWriter, status int, name string, data interface{}) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		warn("console.template_failed", name, err)
+		http.Error(w, tr("template.failed"), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
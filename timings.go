@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// 单类路由器操作（login/get/set/verify）的耗时统计
+type opTiming struct {
+	Count    int           `json:"count"`
+	Errors   int           `json:"errors"`
+	Total    time.Duration `json:"-"`
+	Max      time.Duration `json:"-"`
+	Last     time.Duration `json:"-"`
+	LastAt   time.Time     `json:"last_at"`
+	AvgMs    float64       `json:"avg_ms"`
+	MaxMs    float64       `json:"max_ms"`
+	LastMs   float64       `json:"last_ms"`
+	LastFail bool          `json:"last_failed"`
+}
+
+var (
+	timingsMu sync.Mutex
+	timings   = map[string]*opTiming{}
+)
+
+// 记录一次路由器操作的耗时
+func recordTiming(op string, d time.Duration, err error) {
+	timingsMu.Lock()
+	defer timingsMu.Unlock()
+
+	t, ok := timings[op]
+	if !ok {
+		t = &opTiming{}
+		timings[op] = t
+	}
+	t.Count++
+	t.Total += d
+	t.Last = d
+	t.LastAt = time.Now()
+	t.LastFail = err != nil
+	if err != nil {
+		t.Errors++
+	}
+	if d > t.Max {
+		t.Max = d
+	}
+	sayDebug("debug.router_timing", op, d.Round(time.Millisecond))
+}
+
+// 统计快照，毫秒字段已计算好便于JSON输出
+func timingSnapshot() map[string]opTiming {
+	timingsMu.Lock()
+	defer timingsMu.Unlock()
+
+	out := make(map[string]opTiming, len(timings))
+	for op, t := range timings {
+		s := *t
+		s.AvgMs = ms(t.Total / time.Duration(t.Count))
+		s.MaxMs = ms(t.Max)
+		s.LastMs = ms(t.Last)
+		out[op] = s
+	}
+	return out
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// 流量阈值告警配置
+type TrafficConfig struct {
+	Enabled   bool   `json:"enabled"`
+	Interval  string `json:"interval"`   // 读取流量计数器的间隔，默认 5m
+	Host      string `json:"host"`       // 统计的主机IP，留空为DMZ目标，wan表示整个WAN口
+	DailyMB   int64  `json:"daily_mb"`   // 每日阈值（MB），0为不限
+	MonthlyMB int64  `json:"monthly_mb"` // 每月阈值（MB），0为不限
+	ResetDay  int    `json:"reset_day"`  // 每月从几号开始重新计算，默认1
+}
+
+// 流量累计，持久化在状态文件中，重启后继续累计
+type trafficUsage struct {
+	Host         string    `json:"host,omitempty"`
+	Day          string    `json:"day,omitempty"`
+	DayBytes     int64     `json:"day_bytes"`
+	Month        string    `json:"month,omitempty"`
+	MonthBytes   int64     `json:"month_bytes"`
+	LastCounter  int64     `json:"last_counter"`
+	LastAt       time.Time `json:"last_at,omitempty"`
+	AlertedDay   string    `json:"alerted_day,omitempty"`
+	AlertedMonth string    `json:"alerted_month,omitempty"`
+}
+
+const trafficModule = "traffic"
+
+func trafficHost() string {
+	cfg := globalConfig{}.Get()
+	if cfg.TrafficAlert.Host != "" {
+		return cfg.TrafficAlert.Host
+	}
+	return cfg.DmzDestIP
+}
+
+// 计费周期的起始日期，如重置日为15号时，10月3日属于9月15日开始的周期
+func billingMonth(now time.Time, resetDay int) string {
+	if resetDay < 1 || resetDay > 28 {
+		resetDay = 1
+	}
+	start := time.Date(now.Year(), now.Month(), resetDay, 0, 0, 0, 0, now.Location())
+	if now.Before(start) {
+		start = start.AddDate(0, -1, 0)
+	}
+	return start.Format("2006-01-02")
+}
+
+func counterValue(v interface{}) int64 {
+	n, _ := strconv.ParseInt(fmt.Sprint(v), 10, 64)
+	return n
+}
+
+// 读取主机（或WAN口）的累计收发字节数
+func readTrafficCounter(host string) (int64, error) {
+	if host == "wan" {
+		st, err := queryRouter(trafficModule, "wan")
+		if err != nil {
+			return 0, err
+		}
+		return counterValue(st["wan"]["tx_bytes"]) + counterValue(st["wan"]["rx_bytes"]), nil
+	}
+	entries, err := queryTable(trafficModule, "host_stats")
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		if e["ip"] == host {
+			return counterValue(e["tx_bytes"]) + counterValue(e["rx_bytes"]), nil
+		}
+	}
+	return 0, routerErr(ErrBadParameter, 0, tr("traffic.no_host", host))
+}
+
+// 把新的计数器读数计入当日与当月累计，返回需要发送的告警
+func (u *trafficUsage) add(host string, counter int64, now time.Time, cfg TrafficConfig) []string {
+	day, month := now.Format("2006-01-02"), billingMonth(now, cfg.ResetDay)
+	if u.Host != host {
+		// 统计对象变化，重新开始
+		*u = trafficUsage{Host: host, LastCounter: counter}
+	}
+	delta := counter - u.LastCounter
+	if delta < 0 || u.LastAt.IsZero() {
+		// 路由器重启后计数器归零；首次读数只作为基准
+		delta = 0
+		if !u.LastAt.IsZero() {
+			delta = counter
+		}
+	}
+	u.LastCounter, u.LastAt = counter, now
+
+	if u.Day != day {
+		u.Day, u.DayBytes = day, 0
+	}
+	if u.Month != month {
+		u.Month, u.MonthBytes = month, 0
+	}
+	u.DayBytes += delta
+	u.MonthBytes += delta
+
+	var alerts []string
+	if cfg.DailyMB > 0 && u.DayBytes > cfg.DailyMB<<20 && u.AlertedDay != day {
+		u.AlertedDay = day
+		alerts = append(alerts, tr("notify.traffic_daily.detail", host, u.DayBytes>>20, cfg.DailyMB))
+	}
+	if cfg.MonthlyMB > 0 && u.MonthBytes > cfg.MonthlyMB<<20 && u.AlertedMonth != month {
+		u.AlertedMonth = month
+		alerts = append(alerts, tr("notify.traffic_monthly.detail", host, u.MonthBytes>>20, cfg.MonthlyMB))
+	}
+	return alerts
+}
+
+// 读取一次流量并检查阈值
+func checkTraffic() {
+	host := trafficHost()
+	if host == "" {
+		return
+	}
+	counter, err := readTrafficCounter(host)
+	if err != nil {
+		sayDebug("debug.traffic_failed", err)
+		return
+	}
+	loc, err := loadLocation("")
+	if err != nil {
+		loc = time.Local
+	}
+
+	trackedMu.Lock()
+	alerts := tracked.Traffic.add(host, counter, time.Now().In(loc), globalConfig{}.Get().TrafficAlert)
+	trackedMu.Unlock()
+	saveTrackedState()
+
+	for _, msg := range alerts {
+		logf("%s\n", msg)
+		notify("traffic_threshold", tr("notify.traffic"), msg)
+		recordEvent("traffic_threshold", msg, map[string]interface{}{"host": host})
+	}
+}
+
+// 后台定期检查流量，stop 关闭时退出
+func runTrafficMonitor(stop <-chan struct{}) {
+	interval := parseDurationOr(globalConfig{}.Get().TrafficAlert.Interval, 5*time.Minute)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		checkTraffic()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 端口触发规则所在的表，部分固件没有此功能
+const triggerTable = "port_trigger"
+
+// 校验端口列表，如 "6112" "6112-6119" "2300-2400,47624"
+func validPortList(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		start, err := strconv.Atoi(bounds[0])
+		if err != nil || start < 1 || start > 65535 {
+			return false
+		}
+		if len(bounds) == 2 {
+			end, err := strconv.Atoi(bounds[1])
+			if err != nil || end < start || end > 65535 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func validProtocol(s string) bool {
+	return s == "tcp" || s == "udp" || s == "all"
+}
+
+// /triggers 提交的表单
+type triggerForm struct {
+	Op              string `form:"op"`
+	Name            string `form:"name"` // 启停、删除的规则
+	App             string `form:"app"`
+	TriggerPort     string `form:"trigger_port"`
+	TriggerProtocol string `form:"trigger_protocol,lower"`
+	OpenPort        string `form:"open_port"`
+	OpenProtocol    string `form:"open_protocol,lower"`
+}
+
+// 由表单构造一条端口触发规则
+func triggerFromForm(form triggerForm) (map[string]interface{}, string) {
+	noSpace := func(s string) string { return strings.ReplaceAll(s, " ", "") }
+	rule := map[string]interface{}{
+		"enable":           "on",
+		"app":              form.App,
+		"trigger_port":     noSpace(form.TriggerPort),
+		"trigger_protocol": noSpace(form.TriggerProtocol),
+		"open_port":        noSpace(form.OpenPort),
+		"open_protocol":    noSpace(form.OpenProtocol),
+	}
+	switch {
+	case !validPortList(rule["trigger_port"].(string)) || strings.ContainsAny(rule["trigger_port"].(string), ",-"):
+		return nil, tr("trigger.bad_port", form.TriggerPort)
+	case !validPortList(rule["open_port"].(string)):
+		return nil, tr("trigger.bad_port", form.OpenPort)
+	case !validProtocol(rule["trigger_protocol"].(string)) || !validProtocol(rule["open_protocol"].(string)):
+		return nil, tr("error.bad_parameter")
+	}
+	return rule, ""
+}
+
+// /triggers：端口触发规则的查看、添加、启停与删除
+func triggersHandler(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{"CanEdit": canEdit(r)}
+
+	if r.Method == http.MethodPost {
+		var form triggerForm
+		if err := bindForm(formOf(r), &form); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var err error
+		var msg string
+		name := form.Name
+		switch form.Op {
+		case "add":
+			rule, problem := triggerFromForm(form)
+			if problem != "" {
+				data["Error"] = problem
+				break
+			}
+			err = addTableEntry("firewall", triggerTable, rule)
+			msg = tr("trigger.added", rule["trigger_port"], rule["open_port"])
+		case "delete":
+			err = deleteTableEntry("firewall", triggerTable, name)
+			msg = tr("trigger.deleted", name)
+		case "enable", "disable":
+			value := "on"
+			if form.Op == "disable" {
+				value = "off"
+			}
+			err = updateTableEntry("firewall", triggerTable, name, map[string]interface{}{"enable": value})
+			msg = tr("trigger.toggled", name, value)
+		default:
+			data["Error"] = tr("error.bad_parameter")
+		}
+		if err != nil {
+			data["Error"] = userMessage(err) + ": " + err.Error()
+		} else if msg != "" {
+			logf("%s\n", msg)
+			recordEvent("port_trigger", msg, map[string]interface{}{"op": form.Op, "name": name})
+			data["Result"] = msg
+		}
+	}
+
+	rules, err := queryTable("firewall", triggerTable)
+	switch {
+	case errors.Is(err, ErrUnsupportedFirmware):
+		data["Unsupported"] = true
+	case err != nil && data["Error"] == nil:
+		data["Error"] = userMessage(err)
+	}
+	data["Rules"] = rules
+	renderTemplate(w, http.StatusOK, "triggers.html", data)
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	upnpIPv6FirewallService = "urn:schemas-upnp-org:service:WANIPv6FirewallControl:1"
+	ssdpAddrIPv4            = "239.255.255.250:1900"
+)
+
+// 通过SSDP查找提供指定服务的UPnP设备，返回其描述文件地址
+func upnpDiscover(serviceType string, timeout time.Duration) (string, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	dst, err := net.ResolveUDPAddr("udp4", ssdpAddrIPv4)
+	if err != nil {
+		return "", err
+	}
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddrIPv4 + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: " + serviceType + "\r\n\r\n"
+	if _, err := conn.WriteTo([]byte(search), dst); err != nil {
+		return "", err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return "", fmt.Errorf("%s", tr("upnp.not_found", serviceType))
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if location := resp.Header.Get("Location"); location != "" {
+			return location, nil
+		}
+	}
+}
+
+// 设备描述文件中我们关心的部分
+type upnpDevice struct {
+	URLBase string `xml:"URLBase"`
+	Device  struct {
+		Services []upnpService `xml:"serviceList>service"`
+		Devices  []struct {
+			Services []upnpService `xml:"serviceList>service"`
+			Devices  []struct {
+				Services []upnpService `xml:"serviceList>service"`
+			} `xml:"deviceList>device"`
+		} `xml:"deviceList>device"`
+	} `xml:"device"`
+}
+
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+// 从描述文件中找到指定服务的控制地址
+func upnpControlURL(location, serviceType string) (string, error) {
+	resp, err := http.Get(location)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := readLimited(resp.Body, maxRouterResponseBytes)
+	if err != nil {
+		return "", err
+	}
+	var desc upnpDevice
+	if err := xml.Unmarshal(data, &desc); err != nil {
+		return "", fmt.Errorf("%s", tr("upnp.bad_description", err))
+	}
+
+	services := desc.Device.Services
+	for _, d := range desc.Device.Devices {
+		services = append(services, d.Services...)
+		for _, dd := range d.Devices {
+			services = append(services, dd.Services...)
+		}
+	}
+	for _, s := range services {
+		if s.ServiceType != serviceType {
+			continue
+		}
+		base := location
+		if desc.URLBase != "" {
+			base = desc.URLBase
+		}
+		baseURL, err := url.Parse(base)
+		if err != nil {
+			return "", err
+		}
+		ref, err := url.Parse(s.ControlURL)
+		if err != nil {
+			return "", err
+		}
+		return baseURL.ResolveReference(ref).String(), nil
+	}
+	return "", fmt.Errorf("%s", tr("upnp.no_service", serviceType))
+}
+
+// 调用UPnP SOAP动作，返回响应中的输出参数
+func soapCall(controlURL, serviceType, action string, args [][2]string) (map[string]string, error) {
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&body, `<u:%s xmlns:u="%s">`, action, serviceType)
+	for _, arg := range args {
+		fmt.Fprintf(&body, "<%s>", arg[0])
+		xml.EscapeText(&body, []byte(arg[1]))
+		fmt.Fprintf(&body, "</%s>", arg[0])
+	}
+	fmt.Fprintf(&body, `</u:%s></s:Body></s:Envelope>`, action)
+
+	req, err := http.NewRequest(http.MethodPost, controlURL, strings.NewReader(body.String()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", fmt.Sprintf(`"%s#%s"`, serviceType, action))
+
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := readLimited(resp.Body, maxRouterResponseBytes)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", tr("upnp.failed", action, resp.Status, upnpFault(data)))
+	}
+	return soapOutputs(data), nil
+}
+
+// 取出SOAP响应Body中第一个元素的全部子元素
+func soapOutputs(data []byte) map[string]string {
+	out := map[string]string{}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	var current string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF || err != nil {
+			return out
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			// Envelope(1) > Body(2) > ActionResponse(3) > 参数(4)
+			if depth == 4 {
+				current = t.Name.Local
+			}
+		case xml.CharData:
+			if depth == 4 && current != "" {
+				out[current] += string(t)
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+func upnpFault(data []byte) string {
+	var fault struct {
+		Code        string `xml:"Body>Fault>detail>UPnPError>errorCode"`
+		Description string `xml:"Body>Fault>detail>UPnPError>errorDescription"`
+	}
+	if xml.Unmarshal(data, &fault) == nil && fault.Code != "" {
+		return fault.Code + " " + fault.Description
+	}
+	return ""
+}
+
+// 通过IGDv2的IPv6防火墙控制服务为指定主机端口开一个针孔，返回针孔ID。
+// 多数路由器只允许内部主机为自己开针孔，因此通常需在DMZ目标主机上运行本工具
+func addUPnPPinhole(host string, port int, protocol string, lease time.Duration) (string, error) {
+	location, err := upnpDiscover(upnpIPv6FirewallService, 3*time.Second)
+	if err != nil {
+		return "", err
+	}
+	controlURL, err := upnpControlURL(location, upnpIPv6FirewallService)
+	if err != nil {
+		return "", err
+	}
+
+	proto := "6"
+	if strings.EqualFold(protocol, "udp") {
+		proto = "17"
+	}
+	out, err := soapCall(controlURL, upnpIPv6FirewallService, "AddPinhole", [][2]string{
+		{"RemoteHost", ""},
+		{"RemotePort", "0"},
+		{"InternalClient", host},
+		{"InternalPort", fmt.Sprint(port)},
+		{"Protocol", proto},
+		{"LeaseTime", fmt.Sprint(int(lease.Seconds()))},
+	})
+	if err != nil {
+		return "", err
+	}
+	return out["UniqueID"], nil
+}
+
+const upnpWANIPService = "urn:schemas-upnp-org:service:WANIPConnection:1"
+
+// 路由器上已有的一条UPnP IPv4端口映射
+type upnpMapping struct {
+	ExternalPort   int
+	Protocol       string
+	InternalClient string
+	InternalPort   int
+	Description    string
+}
+
+// 发现路由器的 WANIPConnection 控制地址
+func wanIPControlURL() (string, error) {
+	location, err := upnpDiscover(upnpWANIPService, 3*time.Second)
+	if err != nil {
+		return "", err
+	}
+	return upnpControlURL(location, upnpWANIPService)
+}
+
+// 添加一条IPv4端口映射，外部端口与内部端口相同；lease 为0表示永久
+func addUPnPMapping(host string, port portSpec, lease time.Duration, description string) error {
+	controlURL, err := wanIPControlURL()
+	if err != nil {
+		return err
+	}
+	_, err = soapCall(controlURL, upnpWANIPService, "AddPortMapping", [][2]string{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", fmt.Sprint(port.Port)},
+		{"NewProtocol", strings.ToUpper(port.Proto)},
+		{"NewInternalPort", fmt.Sprint(port.Port)},
+		{"NewInternalClient", host},
+		{"NewEnabled", "1"},
+		{"NewPortMappingDescription", description},
+		{"NewLeaseDuration", fmt.Sprint(int(lease.Seconds()))},
+	})
+	return err
+}
+
+// 删除一条IPv4端口映射
+func deleteUPnPMapping(port portSpec) error {
+	controlURL, err := wanIPControlURL()
+	if err != nil {
+		return err
+	}
+	_, err = soapCall(controlURL, upnpWANIPService, "DeletePortMapping", [][2]string{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", fmt.Sprint(port.Port)},
+		{"NewProtocol", strings.ToUpper(port.Proto)},
+	})
+	return err
+}
+
+// 列出路由器上的全部UPnP端口映射
+func listUPnPMappings() ([]upnpMapping, error) {
+	controlURL, err := wanIPControlURL()
+	if err != nil {
+		return nil, err
+	}
+
+	var mappings []upnpMapping
+	// 按序号逐条读取，越界时路由器返回 713 SpecifiedArrayIndexInvalid
+	for i := 0; i < 1024; i++ {
+		out, err := soapCall(controlURL, upnpWANIPService, "GetGenericPortMappingEntry", [][2]string{
+			{"NewPortMappingIndex", fmt.Sprint(i)},
+		})
+		if err != nil {
+			break
+		}
+		var m upnpMapping
+		fmt.Sscan(out["NewExternalPort"], &m.ExternalPort)
+		fmt.Sscan(out["NewInternalPort"], &m.InternalPort)
+		m.Protocol = strings.ToLower(out["NewProtocol"])
+		m.InternalClient = out["NewInternalClient"]
+		m.Description = out["NewPortMappingDescription"]
+		mappings = append(mappings, m)
+	}
+	return mappings, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 多WAN路由器上其他WAN口的DMZ条目所在的表；主DMZ仍是 firewall.dmz 节
+const (
+	wanDMZTable = "wan_dmz"
+	maxWANPort  = 3 // 常见多WAN机型最多4个WAN口
+)
+
+// 一个WAN口的DMZ状态
+type wanDMZ struct {
+	Name    string // 表条目名，主DMZ为空
+	WANPort int
+	Enable  string
+	DestIP  string
+	DestIP6 string
+}
+
+func (d wanDMZ) Label() string {
+	return fmt.Sprintf("WAN%d", d.WANPort+1)
+}
+
+// 该WAN口当前是否把主机暴露到公网
+func (d wanDMZ) Exposed() bool {
+	return d.Enable == "1" && (d.DestIP != "" || d.DestIP6 != "")
+}
+
+func (d wanDMZ) Primary() bool {
+	return d.Name == ""
+}
+
+// 读取全部WAN口的DMZ，按WAN口排序；单WAN固件只返回主DMZ，supported 为 false
+func queryWANDMZ() (entries []wanDMZ, supported bool, err error) {
+	st, err := queryRouter("firewall", "dmz")
+	if err != nil {
+		return nil, false, err
+	}
+	primary := stateFromRouter(st)
+	port, _ := strconv.Atoi(entryString(st["dmz"], "wan_port"))
+	entries = append(entries, wanDMZ{WANPort: port, Enable: primary.DmzEnable, DestIP: primary.DmzDestIP, DestIP6: primary.DmzDestIP6})
+
+	table, err := queryTable("firewall", wanDMZTable)
+	if errors.Is(err, ErrUnsupportedFirmware) {
+		return entries, false, nil
+	}
+	if err != nil {
+		return entries, false, err
+	}
+	for _, e := range table {
+		port, _ := strconv.Atoi(entryString(e, "wan_port"))
+		entries = append(entries, wanDMZ{
+			Name:    entryString(e, "name"),
+			WANPort: port,
+			Enable:  entryString(e, "enable"),
+			DestIP:  entryString(e, "dest_ip"),
+			DestIP6: entryString(e, "dest_ip6"),
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].WANPort < entries[j].WANPort })
+	return entries, true, nil
+}
+
+// /wan-dmz 提交的表单
+type wanDMZForm struct {
+	WANPort string `form:"wan_port"`
+	Enable  string `form:"enable"`
+	DestIP  string `form:"dest_ip"`
+	DestIP6 string `form:"dest_ip6"`
+}
+
+// 由表单构造某个WAN口的DMZ设置
+func wanDMZFromForm(form wanDMZForm) (wanDMZ, string) {
+	d := wanDMZ{Enable: form.Enable, DestIP: form.DestIP, DestIP6: form.DestIP6}
+	port, err := strconv.Atoi(form.WANPort)
+	if err != nil || port < 0 || port > maxWANPort {
+		return d, tr("wandmz.bad_wan", form.WANPort)
+	}
+	d.WANPort = port
+	if d.Enable != "0" && d.Enable != "1" {
+		return d, tr("error.bad_parameter")
+	}
+	if d.DestIP != "" && net.ParseIP(d.DestIP).To4() == nil {
+		return d, tr("wandmz.bad_ip", d.DestIP)
+	}
+	if d.DestIP6 != "" && net.ParseIP(d.DestIP6) == nil {
+		return d, tr("wandmz.bad_ip", d.DestIP6)
+	}
+	return d, ""
+}
+
+// 写入某个WAN口的DMZ：主DMZ按常规流程应用并更新期望状态，其他WAN口修改或新增表条目
+func setWANDMZ(d wanDMZ, current []wanDMZ, actor string) error {
+	for _, c := range current {
+		if c.WANPort != d.WANPort {
+			continue
+		}
+		if c.Primary() {
+			update := func(c *Config) { c.DmzEnable, c.DmzDestIP, c.DmzDestIP6 = d.Enable, d.DestIP, d.DestIP6 }
+			_, err := applyUpdate(currentBackend(), globalConfig{}, update, nil, sourceUser, actor, allSections)
+			return err
+		}
+		return updateTableEntry("firewall", wanDMZTable, c.Name, map[string]interface{}{
+			"enable": d.Enable, "dest_ip": d.DestIP, "dest_ip6": d.DestIP6,
+		})
+	}
+	return addTableEntry("firewall", wanDMZTable, map[string]interface{}{
+		"wan_port": strconv.Itoa(d.WANPort), "enable": d.Enable, "dest_ip": d.DestIP, "dest_ip6": d.DestIP6,
+	})
+}
+
+// /wan-dmz：各WAN口的DMZ总览与设置
+func wanDMZHandler(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{"CanEdit": canEdit(r)}
+
+	entries, supported, err := queryWANDMZ()
+	if r.Method == http.MethodPost && err == nil {
+		var form wanDMZForm
+		if err := bindForm(formOf(r), &form); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		d, problem := wanDMZFromForm(form)
+		switch {
+		case problem != "":
+			data["Error"] = problem
+		case !supported && d.WANPort != entries[0].WANPort:
+			data["Error"] = tr("wandmz.unsupported")
+		default:
+			if setErr := setWANDMZ(d, entries, actorOf(r)); setErr != nil {
+				data["Error"] = userMessage(setErr) + ": " + setErr.Error()
+				break
+			}
+			state := tr("wandmz.disabled")
+			if d.Enable == "1" {
+				state = tr("wandmz.enabled")
+			}
+			msg := tr("wandmz.done", d.Label(), state, strings.TrimSpace(d.DestIP+" "+d.DestIP6))
+			logf("%s\n", msg)
+			recordEvent("wan_dmz", msg, map[string]interface{}{
+				"wan_port": d.WANPort, "enable": d.Enable, "dest_ip": d.DestIP, "dest_ip6": d.DestIP6,
+			})
+			data["Result"] = msg
+			entries, supported, err = queryWANDMZ()
+		}
+	}
+	if err != nil && data["Error"] == nil {
+		data["Error"] = userMessage(err)
+	}
+	data["Entries"] = entries
+	data["Supported"] = supported
+	// 还没有DMZ条目的WAN口，供新增
+	var choices []wanDMZ
+	for port := 0; port <= maxWANPort; port++ {
+		used := false
+		for _, e := range entries {
+			used = used || e.WANPort == port
+		}
+		if !used {
+			choices = append(choices, wanDMZ{WANPort: port})
+		}
+	}
+	data["Choices"] = choices
+	renderTemplate(w, http.StatusOK, "wandmz.html", data)
+}
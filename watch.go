@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// 守护：定时读取路由器设置，被路由器改回（如重启、固件自动恢复）时重新设置
+type WatchConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Interval string `json:"interval"` // 检查间隔，默认 60s
+}
+
+// 检查一次，与配置不一致时重新设置
+func watchOnce() {
+	c := globalConfig{}.Get()
+	if !c.routerConfigured() || rebootInProgress() {
+		return
+	}
+	current, err := refreshConfirmedState(currentBackend())
+	if err != nil {
+		sayDebug("debug.watch_failed", err)
+		return
+	}
+	desired := desiredFromConfig()
+	if current.Matches(desired) {
+		return
+	}
+
+	err = applySettings(sourceWatchdog)
+	msg := tr("notify.drift.detail", c.RouterIP, current.IPv6FirewallEnable, current.DmzEnable, desired.IPv6FirewallEnable, desired.DmzEnable)
+	if err != nil {
+		msg += "; " + tr("console.watch_failed", userMessage(err))
+	} else {
+		msg += "; " + tr("watch.reapplied")
+	}
+	logf("%s\n", msg)
+	notify("drift", tr("notify.drift"), msg)
+	recordEvent("watchdog", msg, map[string]interface{}{
+		"router_ip": c.RouterIP,
+		"current":   current,
+		"desired":   desired,
+		"success":   err == nil,
+	})
+}
+
+// watch 子命令 -interval 指定的检查间隔，优先于配置
+var flagWatchInterval string
+
+// 检查间隔，默认 60s
+func watchInterval() time.Duration {
+	if flagWatchInterval != "" {
+		return parseDurationOr(flagWatchInterval, 60*time.Second)
+	}
+	return parseDurationOr(globalConfig{}.Get().Watch.Interval, 60*time.Second)
+}
+
+// 后台定时检查，stop 关闭时退出；每次按当前配置的间隔等待，修改配置文件后即生效
+func runWatchdog(stop <-chan struct{}) {
+	for {
+		watchOnce()
+		select {
+		case <-stop:
+			return
+		case <-time.After(watchInterval()):
+		}
+	}
+}
+
+// watch：不启动网页界面，只在前台运行守护，Ctrl+C 退出
+func runWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	configPath := fs.String("config", "config.json", tr("flag.config"))
+	registerLogFlags(fs)
+	registerRouterFlag(fs)
+	fs.StringVar(&flagWatchInterval, "interval", "", tr("flag.watch_interval"))
+	registerConfigFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	defer cleanup()
+	if err := setup(*configPath); err != nil {
+		return err
+	}
+	if !routerConfigured() {
+		return routerErr(ErrBadParameter, 0, tr("headless.no_router"))
+	}
+
+	say("console.watch_started", config.RouterIP, watchInterval())
+	stop := make(chan struct{})
+	go runWatchdog(stop)
+	go runConfigReloader(stop, nil)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	<-sig
+	close(stop)
+	say("console.shutting_down")
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// 无线主网络所在模块，节名为各频段
+const wirelessModule = "wireless"
+
+// 首页上的快捷开关
+type quickToggle struct {
+	ID      string // 表单提交的动作名
+	Label   string
+	On      bool
+	module  string
+	section string
+}
+
+// 快捷开关与路由器节的对应关系
+var quickActions = []quickToggle{
+	{ID: "wifi_2g", module: wirelessModule, section: "wlan_host_2g"},
+	{ID: "wifi_5g", module: wirelessModule, section: "wlan_host_5g"},
+	{ID: "guest_2g", module: guestModule, section: "guest_2g"},
+	{ID: "guest_5g", module: guestModule, section: "guest_5g"},
+}
+
+// 读取各快捷开关的当前状态，路由器不支持的项不显示。
+// 同一模块的节合并为一次查询，单频路由器不支持5G节时再逐个查询
+func quickToggles() []quickToggle {
+	sections := map[string][]string{}
+	var modules []string
+	for _, q := range quickActions {
+		if _, ok := sections[q.module]; !ok {
+			modules = append(modules, q.module)
+		}
+		sections[q.module] = append(sections[q.module], q.section)
+	}
+	states := map[string]sectionState{}
+	for _, module := range modules {
+		st, err := queryRouter(module, sections[module]...)
+		if errors.Is(err, ErrUnsupportedFirmware) {
+			st = sectionState{}
+			for _, section := range sections[module] {
+				if one, err := queryRouter(module, section); err == nil {
+					st[section] = one[section]
+				}
+			}
+		} else if err != nil {
+			sayDebug("debug.wifi_read_failed", module, err)
+			continue
+		}
+		states[module] = st
+	}
+
+	var out []quickToggle
+	for _, q := range quickActions {
+		fields, ok := states[q.module][q.section]
+		if !ok {
+			continue
+		}
+		q.On = fields["enable"] == "on"
+		q.Label = tr("quick." + q.ID)
+		out = append(out, q)
+	}
+	return out
+}
+
+// 打开或关闭某个频段的无线/访客网络
+func setRadio(id string, on bool) error {
+	value := "off"
+	if on {
+		value = "on"
+	}
+	for _, q := range quickActions {
+		if q.ID == id {
+			return setSection(q.module, q.section, map[string]interface{}{"enable": value})
+		}
+	}
+	return routerErr(ErrBadParameter, 0, tr("wifi.unknown_switch", id))
+}
+
+// POST /quick：执行首页的快捷开关
+func quickHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	var form struct {
+		Action string `form:"action"`
+		Value  string `form:"value,lower"` // on/off
+	}
+	if err := bindForm(formOf(r), &form); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id, on := form.Action, form.Value == "on"
+	if err := setRadio(id, on); err != nil {
+		renderTemplate(w, httpStatusFor(err), "error.html", map[string]interface{}{
+			"Message": userMessage(err),
+			"Detail":  err.Error(),
+		})
+		return
+	}
+	msg := tr("quick.done", tr("quick."+id), form.Value)
+	logf("%s\n", msg)
+	recordEvent("quick_toggle", msg, map[string]interface{}{"action": id, "on": on})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}